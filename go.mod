@@ -8,7 +8,9 @@ go 1.23
 
 require (
 	github.com/cavaliergopher/cpio v1.0.1
+	github.com/klauspost/compress v1.17.11
 	github.com/stretchr/testify v1.10.0
+	github.com/ulikunitz/xz v0.5.15
 	go.uber.org/goleak v1.3.0
 	golang.org/x/sync v0.10.0
 	golang.org/x/sys v0.29.0
@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// consoleNameRegexp matches valid console names. They are passed via the
+// kernel command line, so the character set is limited.
+var consoleNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// ConsoleMap maps console names to host paths, given as "name:host". The
+// guest finds the device of a console by its name.
+type ConsoleMap map[string]string
+
+func (m *ConsoleMap) String() string {
+	pairs := make([]string, 0, len(*m))
+
+	for _, name := range slices.Sorted(maps.Keys(*m)) {
+		pairs = append(pairs, name+":"+(*m)[name])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (m *ConsoleMap) Set(s string) error {
+	name, hostPath, found := strings.Cut(s, ":")
+	if !found || !consoleNameRegexp.MatchString(name) {
+		return fmt.Errorf("%w: %s", ErrInvalidConsole, s)
+	}
+
+	if _, exists := (*m)[name]; exists {
+		return fmt.Errorf("%w: duplicate name %s", ErrInvalidConsole, name)
+	}
+
+	hostPath, err := AbsoluteFilePath(hostPath)
+	if err != nil {
+		return err
+	}
+
+	if *m == nil {
+		*m = make(ConsoleMap)
+	}
+
+	(*m)[name] = hostPath
+
+	return nil
+}
@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/virtrun"
+	"golang.org/x/sys/unix"
+)
+
+// CPUPreset is a named combination of guest CPU topology and host CPU
+// pinning, intended for reproducible benchmarks across machines.
+type CPUPreset string
+
+const (
+	// CPUPresetIsolated puts all guest CPUs into a single socket and pins
+	// them to the last host CPUs, away from the first one that usually
+	// handles most of the host's housekeeping.
+	CPUPresetIsolated CPUPreset = "isolated"
+
+	// CPUPresetSpread distributes the guest CPUs and memory across two
+	// sockets and NUMA nodes and pins them to host CPUs spread evenly across
+	// all available ones.
+	CPUPresetSpread CPUPreset = "spread"
+)
+
+func (p *CPUPreset) String() string {
+	return string(*p)
+}
+
+func (p *CPUPreset) Set(s string) error {
+	switch CPUPreset(s) {
+	case CPUPresetIsolated, CPUPresetSpread:
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidCPUPreset, s)
+	}
+
+	*p = CPUPreset(s)
+
+	return nil
+}
+
+// apply expands the preset into the detailed fields of the given config. The
+// number of guest CPUs is taken from [virtrun.Qemu.SMP]. The hostCPUs are the
+// CPUs available on the host to pin to.
+func (p CPUPreset) apply(cfg *virtrun.Qemu, hostCPUs []int) error {
+	smp := int(cfg.SMP) //nolint:gosec
+
+	switch p {
+	case CPUPresetIsolated:
+		// Keep at least one host CPU for everything else.
+		if len(hostCPUs) <= smp {
+			return fmt.Errorf("%w: %s requires more than %d host cpus, "+
+				"found %d", ErrCPUPresetUnsatisfiable, p, smp, len(hostCPUs))
+		}
+
+		cfg.Sockets = 1
+		cfg.NUMANodes = 0
+		cfg.HostCPUs = hostCPUs[len(hostCPUs)-smp:]
+	case CPUPresetSpread:
+		if smp%2 != 0 {
+			return fmt.Errorf("%w: %s requires an even smp, got %d",
+				ErrCPUPresetUnsatisfiable, p, smp)
+		}
+
+		if len(hostCPUs) < smp {
+			return fmt.Errorf("%w: %s requires %d host cpus, found %d",
+				ErrCPUPresetUnsatisfiable, p, smp, len(hostCPUs))
+		}
+
+		cfg.Sockets = 2
+		cfg.NUMANodes = 2
+		cfg.HostCPUs = make([]int, smp)
+
+		for idx := range cfg.HostCPUs {
+			cfg.HostCPUs[idx] = hostCPUs[idx*len(hostCPUs)/smp]
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidCPUPreset, p)
+	}
+
+	return nil
+}
+
+// availableHostCPUs returns the host CPUs the current process may run on.
+func availableHostCPUs() ([]int, error) {
+	var set unix.CPUSet
+
+	err := unix.SchedGetaffinity(0, &set)
+	if err != nil {
+		return nil, fmt.Errorf("get cpu affinity: %w", err)
+	}
+
+	cpus := make([]int, 0, set.Count())
+
+	for cpu := range qemu.MaxHostCPUs {
+		if set.IsSet(cpu) {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return cpus, nil
+}
@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCPUPreset_Set(t *testing.T) {
+	var preset CPUPreset
+
+	require.NoError(t, preset.Set("spread"))
+	assert.Equal(t, CPUPresetSpread, preset)
+
+	require.ErrorIs(t, preset.Set("fast"), ErrInvalidCPUPreset)
+	assert.Equal(t, CPUPresetSpread, preset, "unchanged")
+}
+
+func TestCPUPreset_Apply(t *testing.T) {
+	hostCPUs := []int{0, 1, 2, 3, 4, 5, 6, 7}
+
+	tests := []struct {
+		name        string
+		preset      CPUPreset
+		smp         uint64
+		hostCPUs    []int
+		expected    virtrun.Qemu
+		expectedErr error
+	}{
+		{
+			name:     "isolated",
+			preset:   CPUPresetIsolated,
+			smp:      2,
+			hostCPUs: hostCPUs,
+			expected: virtrun.Qemu{
+				SMP:      2,
+				Sockets:  1,
+				HostCPUs: []int{6, 7},
+			},
+		},
+		{
+			name:        "isolated without spare host cpu",
+			preset:      CPUPresetIsolated,
+			smp:         8,
+			hostCPUs:    hostCPUs,
+			expectedErr: ErrCPUPresetUnsatisfiable,
+		},
+		{
+			name:     "spread",
+			preset:   CPUPresetSpread,
+			smp:      4,
+			hostCPUs: hostCPUs,
+			expected: virtrun.Qemu{
+				SMP:       4,
+				Sockets:   2,
+				NUMANodes: 2,
+				HostCPUs:  []int{0, 2, 4, 6},
+			},
+		},
+		{
+			name:     "spread all host cpus",
+			preset:   CPUPresetSpread,
+			smp:      4,
+			hostCPUs: []int{1, 3, 5, 7},
+			expected: virtrun.Qemu{
+				SMP:       4,
+				Sockets:   2,
+				NUMANodes: 2,
+				HostCPUs:  []int{1, 3, 5, 7},
+			},
+		},
+		{
+			name:        "spread odd smp",
+			preset:      CPUPresetSpread,
+			smp:         3,
+			hostCPUs:    hostCPUs,
+			expectedErr: ErrCPUPresetUnsatisfiable,
+		},
+		{
+			name:        "spread too few host cpus",
+			preset:      CPUPresetSpread,
+			smp:         4,
+			hostCPUs:    []int{0, 1},
+			expectedErr: ErrCPUPresetUnsatisfiable,
+		},
+		{
+			name:        "unknown",
+			preset:      "fast",
+			smp:         1,
+			hostCPUs:    hostCPUs,
+			expectedErr: ErrInvalidCPUPreset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := virtrun.Qemu{SMP: tt.smp}
+
+			err := tt.preset.apply(&cfg, tt.hostCPUs)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			if tt.expectedErr == nil {
+				assert.Equal(t, tt.expected, cfg)
+			}
+		})
+	}
+}
+
+func TestAvailableHostCPUs(t *testing.T) {
+	cpus, err := availableHostCPUs()
+	require.NoError(t, err)
+	assert.NotEmpty(t, cpus)
+}
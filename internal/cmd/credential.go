@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"github.com/aibor/virtrun/sysinit"
+)
+
+// credentialValue parses a credential given as "UID:GID".
+type credentialValue struct {
+	Value **sysinit.Credential
+}
+
+func (c *credentialValue) String() string {
+	if c.Value == nil || *c.Value == nil {
+		return ""
+	}
+
+	return (*c.Value).String()
+}
+
+func (c *credentialValue) Set(s string) error {
+	credential, err := sysinit.ParseCredential(s)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	*c.Value = &credential
+
+	return nil
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/aibor/virtrun/sysinit"
+)
+
+// diskList is a list of disk images attached to the guest. Values are given
+// as "PATH" or "create:SIZE" with optional ",format=FORMAT" and
+// ",readonly=on" options.
+type diskList []qemu.Disk
+
+func (d *diskList) String() string {
+	disks := make([]string, 0, len(*d))
+
+	for _, disk := range *d {
+		value := disk.Path
+		if disk.Size > 0 {
+			value = "create:" + strconv.FormatUint(disk.Size, 10)
+		}
+
+		if disk.Format != "" {
+			value += ",format=" + disk.Format
+		}
+
+		if disk.ReadOnly {
+			value += ",readonly=on"
+		}
+
+		disks = append(disks, value)
+	}
+
+	return strings.Join(disks, " ")
+}
+
+func (d *diskList) Set(value string) error {
+	disk, err := qemu.ParseDisk(value)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if disk.Size == 0 {
+		disk.Path, err = AbsoluteFilePath(disk.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	*d = append(*d, disk)
+
+	return nil
+}
+
+// diskMountValue is the mount point of the first disk in the guest, given
+// as "PATH:FSTYPE".
+type diskMountValue struct {
+	Value **virtrun.DiskMount
+}
+
+func (d *diskMountValue) String() string {
+	if d.Value == nil || *d.Value == nil {
+		return ""
+	}
+
+	return (*d.Value).Path + ":" + string((*d.Value).FSType)
+}
+
+func (d *diskMountValue) Set(value string) error {
+	mountPath, fsType, found := strings.Cut(value, ":")
+	if !found || !path.IsAbs(mountPath) || fsType == "" {
+		return fmt.Errorf("%w: %s", ErrInvalidDiskMount, value)
+	}
+
+	*d.Value = &virtrun.DiskMount{
+		Path:   mountPath,
+		FSType: sysinit.FSType(fsType),
+	}
+
+	return nil
+}
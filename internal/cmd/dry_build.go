@@ -0,0 +1,34 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aibor/virtrun/internal/virtrun"
+)
+
+// runDryBuild resolves the libraries and builds the initramfs file tree for
+// the given [virtrun.Spec] without writing the archive. The entries the
+// archive would contain are printed to stdout, one per line.
+func runDryBuild(
+	ctx context.Context,
+	spec *virtrun.Spec,
+	stdin io.Reader,
+	stdout io.Writer,
+) error {
+	entries, err := virtrun.DryBuild(ctx, spec, stdin)
+	if err != nil {
+		return fmt.Errorf("dry build: %w", err)
+	}
+
+	for _, entry := range entries {
+		fmt.Fprintln(stdout, entry)
+	}
+
+	return nil
+}
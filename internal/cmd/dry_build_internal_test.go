@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDryBuild(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	spec := &virtrun.Spec{
+		Initramfs: virtrun.Initramfs{
+			Binary:         "../sys/testdata/bin/main",
+			StandaloneInit: true,
+		},
+	}
+
+	var stdout bytes.Buffer
+
+	err := runDryBuild(context.Background(), spec, nil, &stdout)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Contains(t, lines, "l init -> main")
+	assert.Contains(t, lines, "d lib")
+
+	files := map[string]string{
+		"main":            "../sys/testdata/bin/main",
+		"lib/libfunc1.so": "../sys/testdata/lib/libfunc1.so",
+	}
+
+	for path, source := range files {
+		info, err := os.Stat(source)
+		require.NoError(t, err)
+
+		assert.Contains(t, lines,
+			fmt.Sprintf("- %s (%d bytes)", path, info.Size()))
+	}
+}
@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/aibor/virtrun/internal/virtrun"
+)
+
+// shellSafe matches arguments that need no quoting in a shell.
+var shellSafe = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+// runDryRun resolves the QEMU command and builds the initramfs file tree for
+// the given [virtrun.Spec] without writing the archive or running QEMU. The
+// command is printed to stdout as shell command line, followed by an empty
+// line and the entries of the archive, one per line.
+func runDryRun(
+	ctx context.Context,
+	spec *virtrun.Spec,
+	stdin io.Reader,
+	stdout io.Writer,
+) error {
+	result, err := virtrun.DryRun(ctx, spec, stdin)
+	if err != nil {
+		return fmt.Errorf("dry run: %w", err)
+	}
+
+	fmt.Fprintln(stdout, shellJoin(result.Command))
+	fmt.Fprintln(stdout)
+
+	for _, entry := range result.Initramfs {
+		fmt.Fprintln(stdout, entry)
+	}
+
+	return nil
+}
+
+// shellJoin joins the given arguments to a command line that can be pasted
+// into a POSIX shell.
+func shellJoin(args []string) string {
+	quoted := make([]string, 0, len(args))
+
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote quotes the given argument with single quotes, if necessary.
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDryRun(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	spec := &virtrun.Spec{
+		Initramfs: virtrun.Initramfs{
+			Binary:         "../sys/testdata/bin/main",
+			StandaloneInit: true,
+		},
+		Qemu: virtrun.Qemu{
+			Kernel: "/boot/vmlinuz",
+			Memory: 256,
+			SMP:    1,
+			NoKVM:  true,
+		},
+	}
+
+	var stdout bytes.Buffer
+
+	err := runDryRun(context.Background(), spec, nil, &stdout)
+	require.NoError(t, err)
+
+	written, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, written, "no archive should be written")
+
+	command, manifest, found := strings.Cut(stdout.String(), "\n\n")
+	require.True(t, found, "command and manifest should be separated")
+
+	assert.True(t, strings.HasPrefix(command, "qemu-system-x86_64 "),
+		"command should start with executable")
+	assert.Contains(t, command, "-initrd "+virtrun.DryRunInitramfsPath)
+	assert.Contains(t, command, "-kernel /boot/vmlinuz")
+	assert.Contains(t, command, "-append 'console=",
+		"arguments with spaces should be quoted")
+
+	info, err := os.Stat(spec.Initramfs.Binary)
+	require.NoError(t, err)
+
+	expected := []string{
+		fmt.Sprintf("- main (%d bytes)", info.Size()),
+		"l init -> main",
+	}
+
+	lines := strings.Split(strings.TrimSpace(manifest), "\n")
+	assert.Subset(t, lines, expected)
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		arg      string
+		expected string
+	}{
+		{
+			arg:      "-kernel",
+			expected: "-kernel",
+		},
+		{
+			arg:      "file=/tmp/disk.img,if=none",
+			expected: "file=/tmp/disk.img,if=none",
+		},
+		{
+			arg:      "console=ttyS0 quiet",
+			expected: "'console=ttyS0 quiet'",
+		},
+		{
+			arg:      `it's`,
+			expected: `'it'\''s'`,
+		},
+		{
+			arg:      "",
+			expected: "''",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			assert.Equal(t, tt.expected, shellQuote(tt.arg))
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// envVarKeyRE matches valid environment variable names. Names with dots are
+// not valid, as the kernel would consider them module parameters.
+var envVarKeyRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvVarList is a list of environment variables given as "KEY=VALUE".
+type EnvVarList []string
+
+func (l *EnvVarList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *EnvVarList) Set(s string) error {
+	key, value, found := strings.Cut(s, "=")
+	if !found || !envVarKeyRE.MatchString(key) {
+		return fmt.Errorf("%s: %w", s, ErrInvalidEnvVar)
+	}
+
+	// The value is passed via the kernel command line, which supports
+	// neither quotes nor line breaks in values.
+	if strings.ContainsAny(value, "\"\n") {
+		return fmt.Errorf("%s: %w", s, ErrInvalidEnvVar)
+	}
+
+	*l = append(*l, s)
+
+	return nil
+}
@@ -23,6 +23,74 @@ var (
 	// ErrNotRegularFile is returned if a file should be read but is not a
 	// regular file.
 	ErrNotRegularFile = errors.New("not a regular file")
+
+	// ErrInvalidEnvVar is returned if an environment variable is not given
+	// as "KEY=VALUE" or contains unsupported characters.
+	ErrInvalidEnvVar = errors.New("invalid environment variable")
+
+	// ErrInvalidSysctl is returned if a sysctl is not given as "KEY=VALUE"
+	// with a dotted key or contains unsupported characters.
+	ErrInvalidSysctl = errors.New("invalid sysctl")
+
+	// ErrInvalidMonitor is returned if a QEMU monitor backend is neither
+	// "pty" nor "socket:PATH".
+	ErrInvalidMonitor = errors.New("invalid monitor backend")
+
+	// ErrInvalidShare is returned if a share is not given as "HOST:TAG"
+	// with an optional ":ro" suffix.
+	ErrInvalidShare = errors.New("invalid share")
+
+	// ErrInvalidDiskMount is returned if a disk mount point is not given as
+	// "PATH:FSTYPE" with an absolute path.
+	ErrInvalidDiskMount = errors.New("invalid disk mount")
+
+	// ErrInvalidCPUPreset is returned if an unknown CPU preset is given.
+	ErrInvalidCPUPreset = errors.New("invalid cpu preset")
+
+	// ErrCPUPresetUnsatisfiable is returned if a CPU preset does not fit the
+	// number of guest CPUs or the host topology.
+	ErrCPUPresetUnsatisfiable = errors.New("cpu preset not satisfiable")
+
+	// ErrFileSizeExceeded is returned if a file added to the initramfs is
+	// bigger than the max file size.
+	ErrFileSizeExceeded = errors.New("file size budget exceeded")
+
+	// ErrInitramfsSizeExceeded is returned if the files added to the
+	// initramfs are bigger than the max initramfs size in total.
+	ErrInitramfsSizeExceeded = errors.New("initramfs size budget exceeded")
+
+	// ErrNotDirectory is returned if a directory should be read but is not a
+	// directory.
+	ErrNotDirectory = errors.New("not a directory")
+
+	// ErrInvalidTargetPath is returned if a target path in the guest is not
+	// a valid relative path.
+	ErrInvalidTargetPath = errors.New("invalid target path")
+
+	// ErrNotGoTestBinary is returned if Go test flags are given but the main
+	// binary is not a Go test binary.
+	ErrNotGoTestBinary = errors.New("not a go test binary")
+
+	// ErrInvalidOutputFormat is returned if an unknown output format is
+	// given.
+	ErrInvalidOutputFormat = errors.New("invalid output format")
+
+	// ErrInvalidMatrixTarget is returned if a matrix target is not given as
+	// "KERNEL:BINARY".
+	ErrInvalidMatrixTarget = errors.New("invalid matrix target")
+
+	// ErrDuplicateMatrixArch is returned if the binaries of multiple matrix
+	// targets are built for the same architecture.
+	ErrDuplicateMatrixArch = errors.New("duplicate matrix architecture")
+
+	// ErrInvalidOutputFile is returned if an output file is not given as
+	// "GUEST:HOST" with an absolute guest path without unsupported
+	// characters.
+	ErrInvalidOutputFile = errors.New("invalid output file")
+
+	// ErrInvalidConsole is returned if a named console is not given as
+	// "NAME:HOST" with a name of letters, digits, ".", "_" or "-".
+	ErrInvalidConsole = errors.New("invalid console")
 )
 
 // ParseArgsError wraps errors that occur during argument parsing.
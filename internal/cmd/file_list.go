@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aibor/virtrun/internal/virtrun"
+)
+
+// fileListValue reads additional files from a manifest file with one file
+// per line, given as "source" or "source:target". Lines starting with "#" and
+// blank lines are ignored. Relative sources are relative to the directory of
+// the manifest file. The target is a relative path in the guest's data dir.
+//
+// Files without target are appended to Files, so they are handled like files
+// given with "-addFile". Files with target are appended to Mappings.
+type fileListValue struct {
+	Files    *[]string
+	Mappings *[]virtrun.FileMapping
+	paths    []string
+}
+
+func (f *fileListValue) String() string {
+	return strings.Join(f.paths, ",")
+}
+
+func (f *fileListValue) Set(s string) error {
+	path, err := AbsoluteFilePath(s)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer file.Close()
+
+	mappings, err := parseFileList(file, filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("%s: %w", s, err)
+	}
+
+	for _, mapping := range mappings {
+		if mapping.Target == "" {
+			*f.Files = append(*f.Files, mapping.Source)
+		} else {
+			*f.Mappings = append(*f.Mappings, mapping)
+		}
+	}
+
+	f.paths = append(f.paths, s)
+
+	return nil
+}
+
+// parseFileList parses the entries of a file list as read by
+// [fileListValue]. Relative sources are resolved against baseDir.
+func parseFileList(r io.Reader, baseDir string) ([]virtrun.FileMapping, error) {
+	var mappings []virtrun.FileMapping
+
+	scanner := bufio.NewScanner(r)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		source, target, _ := strings.Cut(line, ":")
+		if source == "" {
+			return nil, fmt.Errorf("line %d: %w", lineNum, ErrEmptyFilePath)
+		}
+
+		if target != "" && !fs.ValidPath(target) {
+			return nil, fmt.Errorf("line %d: %w: %s",
+				lineNum, ErrInvalidTargetPath, target)
+		}
+
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(baseDir, source)
+		}
+
+		mappings = append(mappings, virtrun.FileMapping{
+			Source: filepath.Clean(source),
+			Target: target,
+		})
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	return mappings, nil
+}
@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileListValue(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "files.txt")
+	content := strings.Join([]string{
+		"# fixtures",
+		"input.json",
+		"",
+		"   ",
+		"  # indented comment",
+		"/srv/data/expected.json:fixtures/out.json",
+		"sub/../other.txt:other",
+	}, "\n")
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	var (
+		files    []string
+		mappings []virtrun.FileMapping
+	)
+
+	value := &fileListValue{Files: &files, Mappings: &mappings}
+
+	require.NoError(t, value.Set(path))
+	assert.Equal(t, path, value.String())
+	assert.Equal(t, []string{filepath.Join(dir, "input.json")}, files)
+	assert.Equal(t, []virtrun.FileMapping{
+		{Source: "/srv/data/expected.json", Target: "fixtures/out.json"},
+		{Source: filepath.Join(dir, "other.txt"), Target: "other"},
+	}, mappings)
+
+	err := value.Set(filepath.Join(t.TempDir(), "missing.txt"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileListValue_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expectedErr error
+	}{
+		{
+			name:        "empty source",
+			content:     "a.txt\n:target",
+			expectedErr: ErrEmptyFilePath,
+		},
+		{
+			name:        "absolute target",
+			content:     "a.txt:/etc/a.txt",
+			expectedErr: ErrInvalidTargetPath,
+		},
+		{
+			name:        "escaping target",
+			content:     "a.txt:../a.txt",
+			expectedErr: ErrInvalidTargetPath,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "files.txt")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			var (
+				files    []string
+				mappings []virtrun.FileMapping
+			)
+
+			value := &fileListValue{Files: &files, Mappings: &mappings}
+
+			err := value.Set(path)
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Empty(t, files, "nothing appended")
+			assert.Empty(t, mappings, "nothing appended")
+		})
+	}
+}
+
+func TestValidate_FileListMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	kernel := filepath.Join(dir, "vmlinuz")
+	binary := filepath.Join(dir, "bin.test")
+	existing := filepath.Join(dir, "existing.txt")
+
+	for _, path := range []string{kernel, binary, existing} {
+		require.NoError(t, os.WriteFile(path, nil, 0o600))
+	}
+
+	manifest := filepath.Join(dir, "files.txt")
+	content := "existing.txt\nmissing.txt:fixtures/missing.txt\n"
+
+	require.NoError(t, os.WriteFile(manifest, []byte(content), 0o600))
+
+	flags := newFlags("test", io.Discard)
+
+	err := flags.ParseArgs([]string{
+		"-kernel", kernel,
+		"-addFileList", manifest,
+		binary,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{existing}, flags.spec.Initramfs.Files)
+
+	err = Validate(context.Background(), flags.spec)
+	require.ErrorIs(t, err, os.ErrNotExist)
+	assert.ErrorContains(t, err, "missing.txt")
+}
@@ -6,9 +6,12 @@ package cmd
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/aibor/virtrun/internal/virtrun"
 )
 
 type FilePath string
@@ -44,6 +47,76 @@ func (f *FilePathList) Set(s string) error {
 	return nil
 }
 
+// FileMappingList is a list of host files mapped to target paths in the
+// guest. Values are given as "source:target". The target is optional and
+// must be a relative path.
+type FileMappingList []virtrun.FileMapping
+
+func (f *FileMappingList) String() string {
+	mappings := make([]string, 0, len(*f))
+
+	for _, mapping := range *f {
+		mappings = append(mappings, mapping.Source+":"+mapping.Target)
+	}
+
+	return strings.Join(mappings, ",")
+}
+
+func (f *FileMappingList) Set(s string) error {
+	source, target, _ := strings.Cut(s, ":")
+
+	path, err := AbsoluteFilePath(source)
+	if err != nil {
+		return err
+	}
+
+	if target != "" && !fs.ValidPath(target) {
+		return fmt.Errorf("%w: %s", ErrInvalidTargetPath, target)
+	}
+
+	*f = append(*f, virtrun.FileMapping{
+		Source: path,
+		Target: target,
+	})
+
+	return nil
+}
+
+// TreeMappingList is a list of host directories mapped to absolute target
+// paths in the guest. Values are given as "source=target".
+type TreeMappingList []virtrun.FileMapping
+
+func (f *TreeMappingList) String() string {
+	mappings := make([]string, 0, len(*f))
+
+	for _, mapping := range *f {
+		mappings = append(mappings, mapping.Source+"="+mapping.Target)
+	}
+
+	return strings.Join(mappings, ",")
+}
+
+func (f *TreeMappingList) Set(s string) error {
+	source, target, _ := strings.Cut(s, "=")
+
+	path, err := AbsoluteFilePath(source)
+	if err != nil {
+		return err
+	}
+
+	relTarget, isAbs := strings.CutPrefix(target, "/")
+	if !isAbs || !fs.ValidPath(relTarget) {
+		return fmt.Errorf("%w: %s", ErrInvalidTargetPath, target)
+	}
+
+	*f = append(*f, virtrun.FileMapping{
+		Source: path,
+		Target: target,
+	})
+
+	return nil
+}
+
 func AbsoluteFilePath(path string) (string, error) {
 	if path == "" {
 		return "", ErrEmptyFilePath
@@ -69,3 +142,17 @@ func ValidateFilePath(name string) error {
 
 	return nil
 }
+
+// ValidateDirPath checks that the given path exists and is a directory.
+func ValidateDirPath(name string) error {
+	stat, err := os.Stat(name)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if !stat.IsDir() {
+		return ErrNotDirectory
+	}
+
+	return nil
+}
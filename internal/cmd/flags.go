@@ -8,9 +8,13 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"path/filepath"
 	"runtime/debug"
 
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/report"
 	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/aibor/virtrun/sysinit"
 )
 
 const (
@@ -23,25 +27,45 @@ const (
 	smpDefault = 1
 	smpMin     = 1
 	smpMax     = 16
+
+	matrixParallelDefault = 2
+	matrixParallelMin     = 1
 )
 
 type flags struct {
 	name string
 
-	spec        *virtrun.Spec
-	flagSet     *flag.FlagSet
-	versionFlag bool
-	debugFlag   bool
+	spec         *virtrun.Spec
+	flagSet      *flag.FlagSet
+	versionFlag  bool
+	debugFlag    bool
+	smokeFlag    bool
+	dryBuildFlag bool
+	dryRunFlag   bool
+	metadataFile string
+	junitFile    string
+	tapFile      string
+	corePattern  string
+	coreUsesPid  bool
+	shell        FilePath
+	cpuPreset    CPUPreset
+	outputFormat OutputFormat
+
+	matrixTargets  matrixTargetList
+	matrixParallel uint64
 }
 
 func newFlags(name string, output io.Writer) *flags {
 	flags := &flags{
-		name: name,
+		name:           name,
+		outputFormat:   OutputFormatText,
+		matrixParallel: matrixParallelDefault,
 		spec: &virtrun.Spec{
 			Qemu: virtrun.Qemu{
 				CPU:    cpuDefault,
 				Memory: memDefault,
 				SMP:    1,
+				AddRNG: true,
 			},
 		},
 	}
@@ -90,12 +114,176 @@ func (f *flags) initFlagset(output io.Writer) {
 		"disable hardware support (default depends on binary arch)",
 	)
 
+	fs.Var(
+		&f.spec.Qemu.Accel,
+		"accel",
+		"QEMU accelerator with optional options, like tcg,thread=multi. "+
+			"One of kvm, tcg, hvf, whpx, nvmm or xen. Overrides the KVM "+
+			"detection and -nokvm.",
+	)
+
+	fs.BoolVar(
+		&f.spec.Qemu.MemLock,
+		"memLock",
+		f.spec.Qemu.MemLock,
+		"lock guest memory in host memory. Requires sufficient memlock limit",
+	)
+
+	fs.Var(
+		(*diskList)(&f.spec.Qemu.Disks),
+		"disk",
+		"disk image to attach as virtio block device, given as path or as "+
+			"create:SIZE for a temporary sparse scratch image, like "+
+			"create:64M. Options ,format=raw|qcow2 and ,readonly=on may "+
+			"follow. The guest sees /dev/vda, /dev/vdb, ... in order. Flag "+
+			"may be used more than once.",
+	)
+
+	fs.Var(
+		&diskMountValue{Value: &f.spec.Qemu.DiskMount},
+		"diskMount",
+		"mount the first disk in the guest, given as path:fstype, like "+
+			"/mnt/disk:ext4. The guest kernel must support the file system",
+	)
+
+	fs.StringVar(
+		&f.spec.DiskExtractDir,
+		"diskExtract",
+		f.spec.DiskExtractDir,
+		"extract the files of the first disk into this directory once the "+
+			"run is done. The disk must be a writable raw image file with "+
+			"an ext4 file system",
+	)
+
+	fs.BoolVar(
+		&f.spec.Qemu.Snapshot,
+		"snapshot",
+		f.spec.Qemu.Snapshot,
+		"write to temporary overlays instead of attached disk images, so "+
+			"they are not modified",
+	)
+
 	fs.Var(
 		&f.spec.Qemu.TransportType,
 		"transport",
 		"io transport type: isa, pci, mmio (default depends on binary arch)",
 	)
 
+	fs.Var(
+		&f.spec.Qemu.RTC,
+		"rtc",
+		"guest real time clock given as comma separated list of base=, "+
+			"clock=host|rt|vm and driftfix=none|slew",
+	)
+
+	fs.Var(
+		&f.spec.Qemu.Display,
+		"display",
+		"QEMU display: none or vnc=<display>, like vnc=:1 (default none)",
+	)
+
+	fs.BoolVar(
+		&f.spec.Qemu.AddRNG,
+		"rng",
+		f.spec.Qemu.AddRNG,
+		"add a virtio-rng device feeding the guest with host entropy, so "+
+			"crypto in the guest does not hang on getrandom",
+	)
+
+	fs.Var(
+		&optionalUintValue{&f.spec.Qemu.Seed},
+		"seed",
+		"seed conveyed to the guest as "+sysinit.SeedEnvVar+" and used for "+
+			"a deterministic virtio-rng entropy source. The guest kernel "+
+			"mixes it with other entropy, so only the test's own use of "+
+			"the seed is reproducible",
+	)
+
+	fs.Var(
+		(*smbiosList)(&f.spec.Qemu.SMBIOS),
+		"smbios",
+		"SMBIOS table given like the QEMU argument, like "+
+			"type=1,manufacturer=Acme,product=Rocket. Types 0 to 3 are "+
+			"supported. Flag may be used more than once.",
+	)
+
+	fs.Var(
+		(*StringList)(&f.spec.Qemu.ExtraInitArgs),
+		"arg",
+		"argument appended verbatim to the guest program's arguments. It is "+
+			"not subject to go test flag rewrite. Flag may be used more than "+
+			"once.",
+	)
+
+	fs.Var(
+		(*StringList)(&f.spec.Qemu.ExtraKernelArgs),
+		"kernelArg",
+		"parameter appended to the guest kernel command line, like "+
+			"earlyprintk=serial. It is added after the generated parameters, "+
+			"so it takes precedence. Flag may be used more than once.",
+	)
+
+	fs.Var(
+		&guestConfigValue{Value: &f.spec.Initramfs.GuestConfig},
+		"guestConfig",
+		"JSON file with mounts, symlinks, env, sysctls and setup step "+
+			"timeout for the guest init. It is added to the initramfs as "+
+			sysinit.GuestConfigPath+".",
+	)
+
+	fs.Var(
+		(*shareList)(&f.spec.Qemu.Shares),
+		"share",
+		"host directory to share with the guest via virtio 9p, given as "+
+			"host:tag with optional :ro suffix. It is mounted at /mnt/tag. "+
+			"Flag may be used more than once.",
+	)
+
+	fs.Var(
+		&networkValue{Value: &f.spec.Qemu.Network},
+		"network",
+		"attach a user mode network to the guest. It is configured as "+
+			"eth0 with address 10.0.2.15/24. The host is reachable via the "+
+			"gateway 10.0.2.2.",
+	)
+
+	fs.Var(
+		&hostForwardList{Value: &f.spec.Qemu.Network},
+		"hostfwd",
+		"forward a host port to the guest, given like the QEMU hostfwd "+
+			"option: [tcp|udp]:[hostaddr]:hostport-[guestaddr]:guestport. "+
+			"Implies -network. Flag may be used more than once.",
+	)
+
+	fs.Var(
+		&monitorValue{Value: &f.spec.Qemu.Monitor},
+		"monitor",
+		"attach the QEMU human monitor for manual control: pty or "+
+			"socket:PATH",
+	)
+
+	fs.Var(
+		&f.spec.Qemu.GDB,
+		"gdb",
+		"TCP address the QEMU gdb stub listens on for debugging the guest "+
+			"kernel, like :1234",
+	)
+
+	fs.BoolVar(
+		&f.spec.Qemu.GDBWait,
+		"gdbWait",
+		f.spec.Qemu.GDBWait,
+		"freeze the guest CPUs at start until continued by gdb. Disables "+
+			"all timeouts. Requires -gdb",
+	)
+
+	fs.Var(
+		(*FilePath)(&f.spec.Qemu.QMPSocket),
+		"qmp",
+		"unix socket path a QMP server listens on for external control "+
+			"during the run. Must not exist. Removed once done",
+	)
+
 	fs.BoolVar(
 		&f.spec.Qemu.Verbose,
 		"verbose",
@@ -103,6 +291,44 @@ func (f *flags) initFlagset(output io.Writer) {
 		"enable verbose guest system output",
 	)
 
+	fs.Var(
+		(*EnvVarList)(&f.spec.Qemu.Env),
+		"env",
+		"environment variable for the guest given as KEY=VALUE. Overrides "+
+			"defaults for PATH, HOME and TERM. Flag may be used more than "+
+			"once.",
+	)
+
+	fs.Var(
+		(*PatternList)(&f.spec.Qemu.EnvDeny),
+		"envDeny",
+		"remove guest environment variables with names matching this shell "+
+			"pattern, like AWS_*. Applied after -env. Flag may be used more "+
+			"than once.",
+	)
+
+	fs.Var(
+		(*SysctlMap)(&f.spec.Initramfs.Sysctls),
+		"sysctl",
+		"kernel parameter for the guest given as KEY=VALUE with dotted "+
+			"key. It is set by the guest's init. Flag may be used more than "+
+			"once.",
+	)
+
+	fs.StringVar(
+		&f.corePattern,
+		"corePattern",
+		f.corePattern,
+		"set guest's kernel.core_pattern sysctl for crash testing",
+	)
+
+	fs.BoolVar(
+		&f.coreUsesPid,
+		"coreUsesPid",
+		f.coreUsesPid,
+		"set guest's kernel.core_uses_pid sysctl for crash testing",
+	)
+
 	fs.Var(
 		&limitedUintValue{
 			Value: &f.spec.Qemu.Memory,
@@ -123,6 +349,109 @@ func (f *flags) initFlagset(output io.Writer) {
 		"number of CPUs for the QEMU VM",
 	)
 
+	fs.DurationVar(
+		&f.spec.Qemu.ResourceLimits.CPUTime,
+		"maxCPUTime",
+		f.spec.Qemu.ResourceLimits.CPUTime,
+		"CPU time limit of the QEMU process across all threads, in whole "+
+			"seconds. The process is killed once exceeded (0 disables)",
+	)
+
+	fs.Uint64Var(
+		&f.spec.Qemu.ResourceLimits.AddressSpace,
+		"maxAddressSpace",
+		f.spec.Qemu.ResourceLimits.AddressSpace,
+		"virtual memory limit of the QEMU process (in MB, 0 disables). Must "+
+			"exceed -memory well, as QEMU maps the guest memory",
+	)
+
+	fs.Var(
+		&f.cpuPreset,
+		"cpuPreset",
+		"apply a named CPU topology and host pinning preset for the -smp "+
+			"CPUs for reproducible benchmarks: \"isolated\" (single socket "+
+			"pinned to the last host CPUs) or \"spread\" (two sockets and "+
+			"NUMA nodes pinned across all host CPUs)",
+	)
+
+	fs.DurationVar(
+		&f.spec.Qemu.IdleTimeout,
+		"idleTimeout",
+		f.spec.Qemu.IdleTimeout,
+		"fail if the guest prints no output line for this duration (0 "+
+			"disables)",
+	)
+
+	fs.DurationVar(
+		&f.spec.Qemu.Timeout,
+		"timeout",
+		f.spec.Qemu.Timeout,
+		"fail if the guest does not print its exit code within this "+
+			"duration after start, even if it keeps printing output (0 "+
+			"disables). Ignored with -shell",
+	)
+
+	fs.DurationVar(
+		&f.spec.Qemu.MaxBootTime,
+		"maxBootTime",
+		f.spec.Qemu.MaxBootTime,
+		"fail if the guest init does not finish its setup within this "+
+			"duration after start (0 disables)",
+	)
+
+	fs.Var(
+		&regexpValue{Value: &f.spec.Qemu.OutputFilter.Include},
+		"outputInclude",
+		"print only guest output lines matching this regular expression. "+
+			"Exit code and error detection still see all lines",
+	)
+
+	fs.Var(
+		&regexpValue{Value: &f.spec.Qemu.OutputFilter.Exclude},
+		"outputExclude",
+		"drop guest output lines matching this regular expression, like "+
+			"kernel rate limit messages. Applied after -outputInclude",
+	)
+
+	fs.BoolVar(
+		&f.spec.Qemu.DumpGuestCore,
+		"dumpGuestCore",
+		f.spec.Qemu.DumpGuestCore,
+		"include guest memory in core dumps of the QEMU process",
+	)
+
+	fs.StringVar(
+		&f.spec.Qemu.CrashDump,
+		"crashDump",
+		f.spec.Qemu.CrashDump,
+		"on guest kernel panic, dump the guest memory as ELF core file to "+
+			"this path for analysis with crash or gdb",
+	)
+
+	fs.StringVar(
+		&f.spec.Qemu.OnReady,
+		"onReady",
+		f.spec.Qemu.OnReady,
+		"run this shell command on the host once the guest is ready, that "+
+			"is when -waitForLine matches or the guest init is done "+
+			"otherwise. The run fails if the command fails",
+	)
+
+	fs.Var(
+		&regexpValue{Value: &f.spec.Qemu.WaitForLine},
+		"waitForLine",
+		"shut down the guest and succeed once an output line matches this "+
+			"regular expression. Intended for services that do not exit",
+	)
+
+	fs.DurationVar(
+		&f.spec.Qemu.WaitForLineTimeout,
+		"waitForLineTimeout",
+		f.spec.Qemu.WaitForLineTimeout,
+		"fail if no line matches -waitForLine within this duration (0 "+
+			"disables)",
+	)
+
 	fs.BoolVar(
 		&f.spec.Initramfs.StandaloneInit,
 		"standalone",
@@ -131,6 +460,61 @@ func (f *flags) initFlagset(output io.Writer) {
 			" support built in.",
 	)
 
+	fs.Var(
+		(*FilePath)(&f.spec.Initramfs.InitBinary),
+		"initBinary",
+		"custom init program to use instead of the built-in one. It must run "+
+			"the binary at /main. Not allowed with -standalone",
+	)
+
+	fs.BoolVar(
+		&f.spec.Qemu.InitVerbose,
+		"initVerbose",
+		f.spec.Qemu.InitVerbose,
+		"print each setup step of the guest init program. Intended for "+
+			"debugging the init phase.",
+	)
+
+	fs.BoolVar(
+		&f.spec.Qemu.InitSkipMounts,
+		"initSkipMounts",
+		f.spec.Qemu.InitSkipMounts,
+		"do not mount any file systems in the guest init program.",
+	)
+
+	fs.BoolVar(
+		&f.spec.Qemu.InitReportResources,
+		"initReportResources",
+		f.spec.Qemu.InitReportResources,
+		"report processes and file descriptors left in the guest once the "+
+			"main binary is done. Left processes are logged as leaks.",
+	)
+
+	fs.BoolVar(
+		&f.spec.Qemu.InitPoweroff,
+		"initPoweroff",
+		f.spec.Qemu.InitPoweroff,
+		"power off the guest instead of restarting it once done. Requires"+
+			" ACPI support in the guest kernel on x86.",
+	)
+
+	fs.Var(
+		&credentialValue{Value: &f.spec.Qemu.InitUser},
+		"initUser",
+		"run the main binary as the given user instead of root, given as "+
+			"UID:GID. The guest setup still runs as root. Supplementary "+
+			"groups are cleared.",
+	)
+
+	fs.Var(
+		&panicBehaviorValue{Value: &f.spec.Qemu.InitPanicBehavior},
+		"initPanicBehavior",
+		"handling of kernel oopses in the guest, like caused by a faulty "+
+			"kernel module. \"report\" fails the run with a dedicated exit "+
+			"code if an oops occurred while the main binary ran. \"panic\" "+
+			"panics the guest kernel right away on an oops.",
+	)
+
 	fs.BoolVar(
 		&f.spec.Qemu.NoGoTestFlagRewrite,
 		"noGoTestFlagRewrite",
@@ -138,6 +522,14 @@ func (f *flags) initFlagset(output io.Writer) {
 		"disable automatic go test flag rewrite for file based output.",
 	)
 
+	fs.BoolVar(
+		&f.spec.Qemu.NoTestBinaryCheck,
+		"noTestBinaryCheck",
+		f.spec.Qemu.NoTestBinaryCheck,
+		"do not check that the main binary is a go test binary if go test "+
+			"flags are given.",
+	)
+
 	fs.BoolVar(
 		&f.spec.Initramfs.Keep,
 		"keepInitramfs",
@@ -146,18 +538,284 @@ func (f *flags) initFlagset(output io.Writer) {
 			"The path to the file is printed on stderr",
 	)
 
+	fs.StringVar(
+		&f.spec.Initramfs.CacheDir,
+		"cacheDir",
+		f.spec.Initramfs.CacheDir,
+		"cache initramfs archives in this directory and reuse them for "+
+			"runs with the same input files, identified by path, size, "+
+			"modification time, mode and content. Cached archives are "+
+			"never removed",
+	)
+
+	fs.Var(
+		(*FilePathList)(&f.spec.Initramfs.Binaries),
+		"addBinary",
+		"binary to run after the main binary with the same arguments. The "+
+			"run fails if any of them fails. Flag may be used more than once.",
+	)
+
 	fs.Var(
 		(*FilePathList)(&f.spec.Initramfs.Files),
 		"addFile",
 		"file to add to guest's /data dir. Flag may be used more than once.",
 	)
 
+	fs.Var(
+		&fileListValue{
+			Files:    &f.spec.Initramfs.Files,
+			Mappings: &f.spec.Initramfs.NamedFiles,
+		},
+		"addFileList",
+		"file listing files to add to guest's /data dir, one per line given "+
+			"as host or host:name. Relative paths are relative to the file's "+
+			"dir. Lines starting with # are ignored. Flag may be used more "+
+			"than once.",
+	)
+
+	fs.Var(
+		(*FileMappingList)(&f.spec.Initramfs.Dirs),
+		"addDir",
+		"directory to add recursively to guest's /data dir, given as "+
+			"host:name. Flag may be used more than once.",
+	)
+
+	fs.Var(
+		(*FilePathList)(&f.spec.Initramfs.AllowedSourceDirs),
+		"allowSourceDir",
+		"restrict the sources of -addFile, -addFileList, -addDir, -addTree "+
+			"and -addOverlay to this directory, also via symbolic links. Flag "+
+			"may be used more than once.",
+	)
+
+	fs.Var(
+		(*TreeMappingList)(&f.spec.Initramfs.Trees),
+		"addTree",
+		"directory to add recursively at an absolute path in the guest, "+
+			"given as host=guest. Trees may overlap, later ones win. Flag "+
+			"may be used more than once.",
+	)
+
+	fs.Var(
+		(*FilePathList)(&f.spec.Initramfs.Overlays),
+		"addOverlay",
+		"directory to apply on top of the initramfs at the root, replacing "+
+			"any file, like the main binary. Overlays are applied in the "+
+			"given order, later ones win. Flag may be used more than once.",
+	)
+
 	fs.Var(
 		(*FilePathList)(&f.spec.Initramfs.Modules),
 		"addModule",
 		"kernel module to add to guest. Flag may be used more than once.",
 	)
 
+	fs.Var(
+		(*FileMappingList)(&f.spec.Initramfs.Firmware),
+		"addFirmware",
+		"firmware file to add to guest's /lib/firmware dir, given as "+
+			"host:name. Flag may be used more than once.",
+	)
+
+	fs.StringVar(
+		&f.spec.Initramfs.LibGraph,
+		"libGraph",
+		f.spec.Initramfs.LibGraph,
+		"write the dependency graph of the binaries and their shared "+
+			"libraries in graphviz DOT format to this file",
+	)
+
+	fs.BoolVar(
+		&f.spec.Initramfs.RequireStatic,
+		"requireStatic",
+		f.spec.Initramfs.RequireStatic,
+		"fail if the binary or any added file is a dynamically linked ELF "+
+			"file, so no host libraries are added",
+	)
+
+	fs.Uint64Var(
+		&f.spec.Initramfs.MaxFileSize,
+		"maxFileSize",
+		f.spec.Initramfs.MaxFileSize,
+		"fail if any file added to the initramfs is bigger than this (in MB, "+
+			"0 disables)",
+	)
+
+	fs.Uint64Var(
+		&f.spec.Initramfs.MaxSize,
+		"maxInitramfsSize",
+		f.spec.Initramfs.MaxSize,
+		"fail if all files added to the initramfs including shared "+
+			"libraries are bigger than this in total (in MB, 0 disables)",
+	)
+
+	fs.BoolVar(
+		&f.spec.Initramfs.VerifyModules,
+		"verifyModules",
+		f.spec.Initramfs.VerifyModules,
+		"verify kernel modules are built for the kernel before running.",
+	)
+
+	fs.StringVar(
+		&f.spec.Initramfs.KernelVersion,
+		"kernelVersion",
+		f.spec.Initramfs.KernelVersion,
+		"kernel release to verify modules against (default read from kernel)",
+	)
+
+	fs.StringVar(
+		&f.spec.Initramfs.Timezone,
+		"timezone",
+		f.spec.Initramfs.Timezone,
+		"guest timezone, like Europe/Berlin, or \"local\" for the host's. "+
+			"Adds the zoneinfo file and sets TZ",
+	)
+
+	fs.Var(
+		(*FilePath)(&f.spec.Initramfs.Stdin),
+		"stdin",
+		"file to feed to the guest binaries' standard input instead of the "+
+			"console. They read EOF at its end",
+	)
+
+	fs.StringVar(
+		&f.spec.Initramfs.WorkDir,
+		"workDir",
+		f.spec.Initramfs.WorkDir,
+		"absolute guest path of the directory the guest binaries run in, "+
+			"like /data. It must exist in the initramfs",
+	)
+
+	fs.Var(
+		&f.spec.Initramfs.Compression,
+		"compress",
+		"initramfs archive compression: none, gzip, zstd (default none). "+
+			"The kernel must support decompressing it",
+	)
+
+	fs.Var(
+		(*FilePathList)(&f.spec.Initramfs.PrependArchives),
+		"prependArchive",
+		"uncompressed CPIO archive to prepend to the initramfs, like early "+
+			"microcode. Flag may be used more than once.",
+	)
+
+	fs.StringVar(
+		&f.metadataFile,
+		"metadataFile",
+		f.metadataFile,
+		"write JSON metadata about the run to this file, even on failure",
+	)
+
+	fs.StringVar(
+		&f.junitFile,
+		"junitFile",
+		f.junitFile,
+		"write JUnit XML report parsed from verbose go test output to this "+
+			"file",
+	)
+
+	fs.StringVar(
+		&f.tapFile,
+		"tapFile",
+		f.tapFile,
+		"write TAP report parsed from verbose go test output to this file",
+	)
+
+	fs.StringVar(
+		&f.spec.CastFile,
+		"castFile",
+		f.spec.CastFile,
+		"record guest console output with timing into this asciinema v2 "+
+			"cast file",
+	)
+
+	fs.Var(
+		(*OutputFileMap)(&f.spec.Qemu.OutputFiles),
+		"outputFile",
+		"text file to copy from the guest to the host once the main binary "+
+			"is done, given as guest:host. Not supported with -standalone. "+
+			"Flag may be used more than once.",
+	)
+
+	fs.Var(
+		(*ConsoleMap)(&f.spec.Qemu.NamedConsoles),
+		"console",
+		"additional console given as name:host. The guest finds its device "+
+			"with sysinit.ConsolePath or in "+sysinit.ConsolesEnvVar+". "+
+			"Output is written to the host file. Flag may be used more "+
+			"than once.",
+	)
+
+	fs.Var(
+		(*FilePath)(&f.spec.Qemu.CoverProfile),
+		"cover",
+		"write the coverage profile of the main binary to this file. The "+
+			"binary must be a Go test binary built with coverage enabled. "+
+			"Not supported with -standalone.",
+	)
+
+	fs.Var(
+		&f.shell,
+		"shell",
+		"run this shell interactively instead of the binary for debugging. "+
+			"The binary is added to the guest's /data dir then. The shell "+
+			"should be statically linked, like busybox",
+	)
+
+	fs.BoolVar(
+		&f.smokeFlag,
+		"smoke",
+		f.smokeFlag,
+		"run a built-in trivial program instead of a binary to verify "+
+			"kernel, QEMU, transport and console work together",
+	)
+
+	fs.Var(
+		&f.matrixTargets,
+		"matrix",
+		"run the invocation with this kernel and binary given as "+
+			"KERNEL:BINARY instead of -kernel and the positional binary. "+
+			"The architecture is read from the binary. All positional "+
+			"arguments are passed to each run. Flag may be used more than "+
+			"once, once per architecture",
+	)
+
+	fs.Var(
+		&limitedUintValue{
+			Value: &f.matrixParallel,
+			min:   matrixParallelMin,
+		},
+		"matrixParallel",
+		"maximum number of -matrix runs at once",
+	)
+
+	fs.BoolVar(
+		&f.dryBuildFlag,
+		"dryBuild",
+		f.dryBuildFlag,
+		"only resolve libraries and build the initramfs file tree, print "+
+			"its entries and exit without writing the archive or running "+
+			"QEMU",
+	)
+
+	fs.BoolVar(
+		&f.dryRunFlag,
+		"dryRun",
+		f.dryRunFlag,
+		"like -dryBuild, but print the QEMU command first. The initramfs "+
+			"archive is referenced as "+virtrun.DryRunInitramfsPath,
+	)
+
+	fs.Var(
+		&f.outputFormat,
+		"output",
+		"format the outcome of the run is reported in: text or json. With "+
+			"json, the run's metadata as written by -metadataFile is "+
+			"written to stdout as a single JSON object once the run is "+
+			"done. The guest's output is written to stderr then",
+	)
+
 	fs.BoolVar(
 		&f.debugFlag,
 		"debug",
@@ -189,6 +847,22 @@ func (f *flags) Debug() bool {
 	return f.debugFlag
 }
 
+func (f *flags) Smoke() bool {
+	return f.smokeFlag
+}
+
+func (f *flags) DryBuild() bool {
+	return f.dryBuildFlag
+}
+
+func (f *flags) DryRun() bool {
+	return f.dryRunFlag
+}
+
+func (f *flags) Matrix() bool {
+	return len(f.matrixTargets) > 0
+}
+
 func (f *flags) printVersionInformation() error {
 	buildInfo, ok := debug.ReadBuildInfo()
 	if !ok {
@@ -214,20 +888,92 @@ func (f *flags) ParseArgs(args []string) error {
 		return &ParseArgsError{msg: "version requested", err: err}
 	}
 
-	if f.spec.Qemu.Kernel == "" {
+	if f.spec.Qemu.Kernel == "" && !f.Matrix() {
 		return f.fail("no kernel given (use -kernel)", nil)
 	}
 
+	if f.cpuPreset != "" {
+		err := f.applyCPUPreset()
+		if err != nil {
+			return f.fail("cpu preset", err)
+		}
+	}
+
+	if f.spec.Qemu.DiskMount != nil && len(f.spec.Qemu.Disks) == 0 {
+		return f.fail("-diskMount requires -disk", nil)
+	}
+
+	if f.spec.DiskExtractDir != "" && len(f.spec.Qemu.Disks) == 0 {
+		return f.fail("-diskExtract requires -disk", nil)
+	}
+
+	// Applies to all modes.
+	f.addCoreSysctls()
+
 	positionalArgs := f.flagSet.Args()
 
+	if f.Matrix() {
+		return f.setupMatrix(positionalArgs)
+	}
+
+	// In smoke mode, the built-in smoke binary is used, so no other binary
+	// must be given.
+	if f.smokeFlag {
+		if len(positionalArgs) > 0 {
+			return f.fail("no binary allowed with -smoke", nil)
+		}
+
+		if f.dryBuildFlag {
+			return f.fail("-dryBuild not allowed with -smoke", nil)
+		}
+
+		if f.dryRunFlag {
+			return f.fail("-dryRun not allowed with -smoke", nil)
+		}
+
+		if f.outputFormat == OutputFormatJSON {
+			return f.fail("-output json not allowed with -smoke", nil)
+		}
+
+		return nil
+	}
+
+	if f.shell != "" {
+		return f.setupShell(positionalArgs)
+	}
+
+	if f.spec.Qemu.GDBWait && f.spec.Qemu.GDB == "" {
+		return f.fail("-gdbWait requires -gdb", nil)
+	}
+
+	if f.spec.Initramfs.StandaloneInit && len(f.spec.Qemu.OutputFiles) > 0 {
+		return f.fail("-outputFile not allowed with -standalone", nil)
+	}
+
+	if f.spec.Initramfs.StandaloneInit && f.spec.Qemu.CoverProfile != "" {
+		return f.fail("-cover not allowed with -standalone", nil)
+	}
+
+	if f.outputFormat == OutputFormatJSON && (f.dryBuildFlag || f.dryRunFlag) {
+		return f.fail("-output json not allowed with -dryBuild or -dryRun",
+			nil)
+	}
+
 	// First positional argument is supposed to be a binary file.
 	if len(positionalArgs) < 1 {
 		return f.fail("no binary given", nil)
 	}
 
-	binary, err := AbsoluteFilePath(positionalArgs[0])
-	if err != nil {
-		return f.fail("binary path", err)
+	binary := positionalArgs[0]
+
+	// The binary is read from stdin by virtrun, so it is not a path.
+	if binary != virtrun.StdinBinary {
+		path, err := AbsoluteFilePath(binary)
+		if err != nil {
+			return f.fail("binary path", err)
+		}
+
+		binary = path
 	}
 
 	f.spec.Initramfs.Binary = binary
@@ -236,5 +982,147 @@ func (f *flags) ParseArgs(args []string) error {
 	// the guest system's init program.
 	f.spec.Qemu.InitArgs = positionalArgs[1:]
 
+	if f.spec.Qemu.CoverProfile != "" &&
+		hasCoverProfileFlag(f.spec.Qemu.InitArgs) {
+		return f.fail("-cover not allowed with -test.coverprofile", nil)
+	}
+
+	f.addResultProcessors()
+
 	return nil
 }
+
+// setupMatrix sets up the spec as base for the -matrix runs. As the kernel
+// and the binary are given per run, all positional arguments are passed to
+// the guest system's init program. Modes, output files and host resources
+// like ports and sockets that work only for a single run are rejected, as
+// all parallel runs would use the same.
+func (f *flags) setupMatrix(initArgs []string) error {
+	exclusive := []struct {
+		name string
+		set  bool
+	}{
+		{"-kernel", f.spec.Qemu.Kernel != ""},
+		{"-smoke", f.smokeFlag},
+		{"-shell", f.shell != ""},
+		{"-dryBuild", f.dryBuildFlag},
+		{"-dryRun", f.dryRunFlag},
+		{"-castFile", f.spec.CastFile != ""},
+		{"-metadataFile", f.metadataFile != ""},
+		{"-junitFile", f.junitFile != ""},
+		{"-tapFile", f.tapFile != ""},
+		{"-outputFile", len(f.spec.Qemu.OutputFiles) > 0},
+		{"-cover", f.spec.Qemu.CoverProfile != ""},
+		{"-console", len(f.spec.Qemu.NamedConsoles) > 0},
+		{"-qmp", f.spec.Qemu.QMPSocket != ""},
+		{"-gdb", f.spec.Qemu.GDB != ""},
+		{"-hostfwd", f.spec.Qemu.Network != nil &&
+			len(f.spec.Qemu.Network.HostForwards) > 0},
+		{"-monitor socket", f.spec.Qemu.Monitor != nil &&
+			f.spec.Qemu.Monitor.Type == qemu.ConsoleBackendSocket},
+		{"-crashDump", f.spec.Qemu.CrashDump != ""},
+		{"-libGraph", f.spec.Initramfs.LibGraph != ""},
+		{"writable -disk image", f.hasSharedDisk()},
+		{"-output json", f.outputFormat == OutputFormatJSON},
+	}
+
+	for _, other := range exclusive {
+		if other.set {
+			return f.fail(other.name+" not allowed with -matrix", nil)
+		}
+	}
+
+	f.spec.Qemu.InitArgs = initArgs
+
+	return nil
+}
+
+// hasSharedDisk returns true if any disk is a writable image file, that all
+// parallel runs would write to. Scratch disks are unique per run.
+func (f *flags) hasSharedDisk() bool {
+	for _, disk := range f.spec.Qemu.Disks {
+		if disk.Size == 0 && !disk.ReadOnly && !f.spec.Qemu.Snapshot {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setupShell sets up the spec for running the shell interactively instead of
+// the binary. All given files, including the binary, are added to the guest,
+// so they can be used from the shell in the same environment as a normal run.
+func (f *flags) setupShell(files []string) error {
+	for _, file := range files {
+		path, err := AbsoluteFilePath(file)
+		if err != nil {
+			return f.fail("shell file path", err)
+		}
+
+		f.spec.Initramfs.Files = append(f.spec.Initramfs.Files, path)
+	}
+
+	f.spec.Initramfs.Binary = string(f.shell)
+	f.spec.Initramfs.StandaloneInit = false
+
+	// The shell reads from the default console, which is connected to
+	// stdin. Busybox needs the applet name, as it is called as "/main".
+	f.spec.Qemu.InitArgs = []string{"-i"}
+	if filepath.Base(string(f.shell)) == "busybox" {
+		f.spec.Qemu.InitArgs = []string{"sh", "-i"}
+	}
+
+	// The user may be idle while typing and takes as long as needed.
+	f.spec.Qemu.IdleTimeout = 0
+	f.spec.Qemu.Timeout = 0
+
+	return nil
+}
+
+// addCoreSysctls adds the sysctls for the core dump flags. They replace
+// the ones given with -sysctl.
+func (f *flags) addCoreSysctls() {
+	if f.corePattern == "" && !f.coreUsesPid {
+		return
+	}
+
+	if f.spec.Initramfs.Sysctls == nil {
+		f.spec.Initramfs.Sysctls = make(map[string]string, 2)
+	}
+
+	if f.corePattern != "" {
+		f.spec.Initramfs.Sysctls["kernel.core_pattern"] = f.corePattern
+	}
+
+	if f.coreUsesPid {
+		f.spec.Initramfs.Sysctls["kernel.core_uses_pid"] = "1"
+	}
+}
+
+// applyCPUPreset expands the CPU preset into the detailed QEMU config,
+// resolved against the CPUs available on the host.
+func (f *flags) applyCPUPreset() error {
+	hostCPUs, err := availableHostCPUs()
+	if err != nil {
+		return err
+	}
+
+	return f.cpuPreset.apply(&f.spec.Qemu, hostCPUs)
+}
+
+func (f *flags) addResultProcessors() {
+	if f.junitFile != "" {
+		f.spec.ResultProcessors = append(f.spec.ResultProcessors,
+			&report.JUnitWriter{
+				Path:      f.junitFile,
+				SuiteName: filepath.Base(f.spec.Initramfs.Binary),
+			},
+		)
+	}
+
+	if f.tapFile != "" {
+		f.spec.ResultProcessors = append(f.spec.ResultProcessors,
+			&report.TAPWriter{Path: f.tapFile},
+		)
+	}
+}
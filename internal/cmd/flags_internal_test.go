@@ -6,10 +6,14 @@ package cmd
 
 import (
 	"io"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/report"
 	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/aibor/virtrun/sysinit"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -18,12 +22,19 @@ func TestFlags_ParseArgs(t *testing.T) {
 	absBinPath, err := AbsoluteFilePath("bin.test")
 	require.NoError(t, err)
 
+	absOtherPath, err := AbsoluteFilePath("other.file")
+	require.NoError(t, err)
+
+	seed := uint64(42)
+
 	tests := []struct {
-		name              string
-		args              []string
-		expectedSpec      *virtrun.Spec
-		expectedDebugFlag bool
-		expecterErr       error
+		name                 string
+		args                 []string
+		expectedSpec         *virtrun.Spec
+		expectedDebugFlag    bool
+		expectedDryBuildFlag bool
+		expectedDryRunFlag   bool
+		expecterErr          error
 	}{
 		{
 			name: "help",
@@ -53,6 +64,151 @@ func TestFlags_ParseArgs(t *testing.T) {
 			},
 			expecterErr: &ParseArgsError{},
 		},
+		{
+			name: "smoke with dry build",
+			args: []string{
+				"-kernel=/boot/this",
+				"-smoke",
+				"-dryBuild",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "smoke with json output",
+			args: []string{
+				"-kernel=/boot/this",
+				"-smoke",
+				"-output", "json",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "invalid accelerator",
+			args: []string{
+				"-kernel=/boot/this",
+				"-accel", "qemu",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "invalid disk",
+			args: []string{
+				"-kernel=/boot/this",
+				"-disk", "create:64M,format=qcow2",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "invalid disk mount",
+			args: []string{
+				"-kernel=/boot/this",
+				"-disk", "create:64M",
+				"-diskMount", "mnt/disk:ext4",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "disk mount without disk",
+			args: []string{
+				"-kernel=/boot/this",
+				"-diskMount", "/mnt/disk:ext4",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "disk extract without disk",
+			args: []string{
+				"-kernel=/boot/this",
+				"-diskExtract", "/tmp/out",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "invalid gdb address",
+			args: []string{
+				"-kernel=/boot/this",
+				"-gdb", "1234",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "gdb wait without gdb",
+			args: []string{
+				"-kernel=/boot/this",
+				"-gdbWait",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "invalid output format",
+			args: []string{
+				"-kernel=/boot/this",
+				"-output", "yaml",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "smoke with dry run",
+			args: []string{
+				"-kernel=/boot/this",
+				"-smoke",
+				"-dryRun",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "smoke with binary",
+			args: []string{
+				"-kernel=/boot/this",
+				"-smoke",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "monitor invalid",
+			args: []string{
+				"-kernel=/boot/this",
+				"-monitor=stdio",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "env deny pattern invalid",
+			args: []string{
+				"-kernel=/boot/this",
+				"-envDeny=AWS_[",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "rtc invalid",
+			args: []string{
+				"-kernel=/boot/this",
+				"-rtc=clock=wall",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "sysctl invalid",
+			args: []string{
+				"-kernel=/boot/this",
+				"-sysctl=core_pattern=/tmp",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
 		{
 			name: "additional file is empty",
 			args: []string{
@@ -62,6 +218,191 @@ func TestFlags_ParseArgs(t *testing.T) {
 			},
 			expecterErr: &ParseArgsError{},
 		},
+		{
+			name: "firmware target invalid",
+			args: []string{
+				"-kernel=/boot/this",
+				"-addFirmware=/fw/blob.bin:../escape.bin",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "seed invalid",
+			args: []string{
+				"-kernel=/boot/this",
+				"-seed=-1",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "panic behavior invalid",
+			args: []string{
+				"-kernel=/boot/this",
+				"-initPanicBehavior=ignore",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "output file relative guest path",
+			args: []string{
+				"-kernel=/boot/this",
+				"-outputFile=cover.out:/host/cover.out",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "output file with comma",
+			args: []string{
+				"-kernel=/boot/this",
+				"-outputFile=/tmp/a,b:/host/cover.out",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "output file duplicate",
+			args: []string{
+				"-kernel=/boot/this",
+				"-outputFile=/tmp/a:/host/a",
+				"-outputFile=/tmp/a:/host/b",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "console invalid name",
+			args: []string{
+				"-kernel=/boot/this",
+				"-console=a,b:/host/a",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "console duplicate",
+			args: []string{
+				"-kernel=/boot/this",
+				"-console=trace:/host/a",
+				"-console=trace:/host/b",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "output file with standalone",
+			args: []string{
+				"-kernel=/boot/this",
+				"-standalone",
+				"-outputFile=/tmp/a:/host/a",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "cover with cover profile",
+			args: []string{
+				"-kernel=/boot/this",
+				"-cover=/host/cover.out",
+				"bin.test",
+				"-test.coverprofile=cover.out",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "cover with standalone",
+			args: []string{
+				"-kernel=/boot/this",
+				"-standalone",
+				"-cover=/host/cover.out",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "smbios invalid",
+			args: []string{
+				"-kernel=/boot/this",
+				"-smbios=type=1,vendor=Acme",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "share without tag",
+			args: []string{
+				"-kernel=/boot/this",
+				"-share=/srv/fixtures",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "hostfwd invalid",
+			args: []string{
+				"-kernel=/boot/this",
+				"-hostfwd=tcp::8080",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "env var invalid",
+			args: []string{
+				"-kernel=/boot/this",
+				"-env=module.param=1",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "tree target relative",
+			args: []string{
+				"-kernel=/boot/this",
+				"-addTree=/srv/conf=etc/app",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "tree target missing",
+			args: []string{
+				"-kernel=/boot/this",
+				"-addTree=/srv/conf",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "env var empty key",
+			args: []string{
+				"-kernel=/boot/this",
+				"-env==value",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "env var without value separator",
+			args: []string{
+				"-kernel=/boot/this",
+				"-env=GREETING",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
+		{
+			name: "wait for line invalid",
+			args: []string{
+				"-kernel=/boot/this",
+				"-waitForLine=(unclosed",
+				"bin.test",
+			},
+			expecterErr: &ParseArgsError{},
+		},
 		{
 			name: "debug",
 			args: []string{
@@ -77,6 +418,7 @@ func TestFlags_ParseArgs(t *testing.T) {
 					Kernel:   "/boot/this",
 					CPU:      "max",
 					Memory:   256,
+					AddRNG:   true,
 					SMP:      1,
 					InitArgs: []string{},
 				},
@@ -100,6 +442,7 @@ func TestFlags_ParseArgs(t *testing.T) {
 					Kernel: "/boot/this",
 					CPU:    "max",
 					Memory: 256,
+					AddRNG: true,
 					SMP:    1,
 					InitArgs: []string{
 						"-test.paniconexit0",
@@ -116,15 +459,87 @@ func TestFlags_ParseArgs(t *testing.T) {
 				"-cpu", "host",
 				"-machine=pc",
 				"-transport", "mmio",
+				"-rtc", "base=2024-01-01,clock=vm",
+				"-display", "vnc=:1",
 				"-memory=269",
 				"-verbose",
 				"-smp", "7",
 				"-nokvm=true",
+				"-accel", "tcg,thread=multi",
+				"-rng=false",
+				"-memLock",
+				"-idleTimeout", "2m",
 				"-standalone",
 				"-noGoTestFlagRewrite",
+				"-noTestBinaryCheck",
 				"-keepInitramfs",
+				"-cacheDir", "/tmp/cache",
+				"-addBinary", "/other.test",
 				"-addFile", "/file2",
 				"-addFile", "/dir/file3",
+				"-prependArchive", "/ucode.cpio",
+				"-addFirmware", "/fw/blob.bin:vendor/dev.bin",
+				"-addFirmware", "/fw/other.bin",
+				"-addDir", "/fixtures:testdata/fixtures",
+				"-addDir", "/golden",
+				"-addTree", "/srv/conf=/etc/app",
+				"-addTree", "/srv/override=/etc/app",
+				"-addOverlay", "/srv/base",
+				"-addOverlay", "/srv/layer",
+				"-share", "/srv/fixtures:fixtures",
+				"-share", "/srv/golden:golden:ro",
+				"-hostfwd", "tcp::8080-:80",
+				"-hostfwd", "udp:127.0.0.1:5353-:53",
+				"-smbios", "type=1,manufacturer=Acme,product=Rocket",
+				"-seed", "42",
+				"-disk", "/srv/disk.qcow2,format=qcow2,readonly=on",
+				"-disk", "create:64M",
+				"-diskMount", "/mnt/disk:ext4",
+				"-snapshot",
+				"-verifyModules",
+				"-libGraph", "/tmp/libs.dot",
+				"-requireStatic",
+				"-maxFileSize", "10",
+				"-maxInitramfsSize", "64",
+				"-kernelVersion", "6.8.0",
+				"-timezone", "local",
+				"-allowSourceDir", "/srv",
+				"-stdin", "/tmp/input.txt",
+				"-workDir", "/data",
+				"-compress", "zstd",
+				"-env", "HOME=/tmp",
+				"-env", "GREETING=hello world",
+				"-envDeny", "AWS_*",
+				"-arg", "-test.coverprofile=cover.out",
+				"-arg", "plain,value",
+				"-kernelArg", "earlyprintk=serial",
+				"-kernelArg", "nokaslr",
+				"-junitFile", "/tmp/junit.xml",
+				"-tapFile", "/tmp/report.tap",
+				"-castFile", "/tmp/run.cast",
+				"-waitForLine", "ready$",
+				"-waitForLineTimeout", "30s",
+				"-monitor", "socket:/run/mon.sock",
+				"-qmp", "/run/qmp.sock",
+				"-gdb", ":1234",
+				"-gdbWait",
+				"-sysctl", "vm.overcommit_memory=1",
+				"-corePattern", "/tmp/core.%e",
+				"-coreUsesPid",
+				"-initVerbose",
+				"-initSkipMounts",
+				"-initReportResources",
+				"-initPoweroff",
+				"-initUser", "1000:100",
+				"-initPanicBehavior", "report",
+				"-timeout", "5m",
+				"-maxBootTime", "3s",
+				"-maxCPUTime", "10m",
+				"-maxAddressSpace", "4096",
+				"-onReady", "curl localhost:8080",
+				"-outputExclude", "callbacks suppressed",
+				"-dumpGuestCore",
+				"-crashDump", "/tmp/vmcore",
 				"bin.test",
 				"-test.paniconexit0",
 				"-test.v=true",
@@ -133,12 +548,63 @@ func TestFlags_ParseArgs(t *testing.T) {
 			expectedSpec: &virtrun.Spec{
 				Initramfs: virtrun.Initramfs{
 					Binary: absBinPath,
+					Binaries: []string{
+						"/other.test",
+					},
+					Sysctls: map[string]string{
+						"vm.overcommit_memory": "1",
+						"kernel.core_pattern":  "/tmp/core.%e",
+						"kernel.core_uses_pid": "1",
+					},
 					Files: []string{
 						"/file2",
 						"/dir/file3",
 					},
+					Firmware: []virtrun.FileMapping{
+						{Source: "/fw/blob.bin", Target: "vendor/dev.bin"},
+						{Source: "/fw/other.bin"},
+					},
+					Dirs: []virtrun.FileMapping{
+						{Source: "/fixtures", Target: "testdata/fixtures"},
+						{Source: "/golden"},
+					},
+					Trees: []virtrun.FileMapping{
+						{Source: "/srv/conf", Target: "/etc/app"},
+						{Source: "/srv/override", Target: "/etc/app"},
+					},
+					AllowedSourceDirs: []string{
+						"/srv",
+					},
+					Overlays: []string{
+						"/srv/base",
+						"/srv/layer",
+					},
+					PrependArchives: []string{
+						"/ucode.cpio",
+					},
+					VerifyModules:  true,
+					LibGraph:       "/tmp/libs.dot",
+					RequireStatic:  true,
+					MaxFileSize:    10,
+					MaxSize:        64,
+					KernelVersion:  "6.8.0",
+					Timezone:       "local",
+					Stdin:          "/tmp/input.txt",
+					WorkDir:        "/data",
+					Compression:    virtrun.CompressionZstd,
 					StandaloneInit: true,
 					Keep:           true,
+					CacheDir:       "/tmp/cache",
+				},
+				CastFile: "/tmp/run.cast",
+				ResultProcessors: []virtrun.ResultProcessor{
+					&report.JUnitWriter{
+						Path:      "/tmp/junit.xml",
+						SuiteName: "bin.test",
+					},
+					&report.TAPWriter{
+						Path: "/tmp/report.tap",
+					},
 				},
 				Qemu: virtrun.Qemu{
 					Kernel:        "/boot/this",
@@ -147,7 +613,82 @@ func TestFlags_ParseArgs(t *testing.T) {
 					TransportType: qemu.TransportTypeMMIO,
 					Memory:        269,
 					NoKVM:         true,
+					Accel:         "tcg,thread=multi",
+					MemLock:       true,
+					IdleTimeout:   2 * time.Minute,
 					SMP:           7,
+					RTC: qemu.RTC{
+						Base:  "2024-01-01",
+						Clock: qemu.RTCClockVM,
+					},
+					Display: "vnc=:1",
+					SMBIOS: []qemu.SMBIOS{
+						{
+							Type: 1,
+							Fields: map[string]string{
+								"manufacturer": "Acme",
+								"product":      "Rocket",
+							},
+						},
+					},
+					Seed:     &seed,
+					Snapshot: true,
+					Disks: []qemu.Disk{
+						{
+							Path:     "/srv/disk.qcow2",
+							Format:   "qcow2",
+							ReadOnly: true,
+						},
+						{Size: 64 << 20},
+					},
+					DiskMount: &virtrun.DiskMount{
+						Path:   "/mnt/disk",
+						FSType: "ext4",
+					},
+					Shares: []qemu.Share{
+						{HostPath: "/srv/fixtures", Tag: "fixtures"},
+						{
+							HostPath: "/srv/golden",
+							Tag:      "golden",
+							ReadOnly: true,
+						},
+					},
+					Network: &qemu.Network{
+						HostForwards: []qemu.HostForward{
+							{HostPort: 8080, GuestPort: 80, Protocol: "tcp"},
+							{
+								Protocol:  "udp",
+								HostAddr:  "127.0.0.1",
+								HostPort:  5353,
+								GuestPort: 53,
+							},
+						},
+					},
+					ExtraInitArgs: []string{
+						"-test.coverprofile=cover.out",
+						"plain,value",
+					},
+					ExtraKernelArgs: []string{
+						"earlyprintk=serial",
+						"nokaslr",
+					},
+					Env: []string{
+						"HOME=/tmp",
+						"GREETING=hello world",
+					},
+					EnvDeny: []string{
+						"AWS_*",
+					},
+
+					WaitForLine:        regexp.MustCompile("ready$"),
+					WaitForLineTimeout: 30 * time.Second,
+					Monitor: &qemu.ConsoleBackend{
+						Type: qemu.ConsoleBackendSocket,
+						Path: "/run/mon.sock",
+					},
+					QMPSocket: "/run/qmp.sock",
+					GDB:       ":1234",
+					GDBWait:   true,
 					InitArgs: []string{
 						"-test.paniconexit0",
 						"-test.v=true",
@@ -155,6 +696,28 @@ func TestFlags_ParseArgs(t *testing.T) {
 					},
 					Verbose:             true,
 					NoGoTestFlagRewrite: true,
+					NoTestBinaryCheck:   true,
+					InitVerbose:         true,
+					InitSkipMounts:      true,
+					InitReportResources: true,
+					InitPoweroff:        true,
+					InitUser: &sysinit.Credential{
+						UID: 1000,
+						GID: 100,
+					},
+					InitPanicBehavior: sysinit.PanicBehaviorReport,
+					Timeout:           5 * time.Minute,
+					MaxBootTime:       3 * time.Second,
+					ResourceLimits: qemu.ResourceLimits{
+						CPUTime:      10 * time.Minute,
+						AddressSpace: 4096,
+					},
+					OnReady: "curl localhost:8080",
+					OutputFilter: qemu.OutputFilter{
+						Exclude: regexp.MustCompile("callbacks suppressed"),
+					},
+					DumpGuestCore: true,
+					CrashDump:     "/tmp/vmcore",
 				},
 			},
 		},
@@ -176,6 +739,7 @@ func TestFlags_ParseArgs(t *testing.T) {
 					Kernel: "/boot/this",
 					CPU:    "max",
 					Memory: 256,
+					AddRNG: true,
 					SMP:    1,
 					InitArgs: []string{
 						"-test.paniconexit0",
@@ -186,6 +750,302 @@ func TestFlags_ParseArgs(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "output files",
+			args: []string{
+				"-kernel=/boot/this",
+				"-outputFile", "/tmp/cover.out:/host/cover.out",
+				"-outputFile", "/tmp/../var/trace.log:/host/trace.log",
+				"bin.test",
+			},
+			expectedSpec: &virtrun.Spec{
+				Initramfs: virtrun.Initramfs{
+					Binary: absBinPath,
+				},
+				Qemu: virtrun.Qemu{
+					Kernel:   "/boot/this",
+					CPU:      "max",
+					Memory:   256,
+					AddRNG:   true,
+					SMP:      1,
+					InitArgs: []string{},
+					OutputFiles: map[string]string{
+						"/tmp/cover.out": "/host/cover.out",
+						"/var/trace.log": "/host/trace.log",
+					},
+				},
+			},
+		},
+		{
+			name: "named consoles",
+			args: []string{
+				"-kernel=/boot/this",
+				"-console", "trace:/host/trace.log",
+				"-console", "events.v2:/host/events.json",
+				"bin.test",
+			},
+			expectedSpec: &virtrun.Spec{
+				Initramfs: virtrun.Initramfs{
+					Binary: absBinPath,
+				},
+				Qemu: virtrun.Qemu{
+					Kernel:   "/boot/this",
+					CPU:      "max",
+					Memory:   256,
+					AddRNG:   true,
+					SMP:      1,
+					InitArgs: []string{},
+					NamedConsoles: map[string]string{
+						"trace":     "/host/trace.log",
+						"events.v2": "/host/events.json",
+					},
+				},
+			},
+		},
+		{
+			name: "cover",
+			args: []string{
+				"-kernel=/boot/this",
+				"-cover", "/host/cover.out",
+				"bin.test",
+				"-test.v",
+			},
+			expectedSpec: &virtrun.Spec{
+				Initramfs: virtrun.Initramfs{
+					Binary: absBinPath,
+				},
+				Qemu: virtrun.Qemu{
+					Kernel:       "/boot/this",
+					CPU:          "max",
+					Memory:       256,
+					AddRNG:       true,
+					SMP:          1,
+					InitArgs:     []string{"-test.v"},
+					CoverProfile: "/host/cover.out",
+				},
+			},
+		},
+		{
+			name: "init binary",
+			args: []string{
+				"-kernel=/boot/this",
+				"-initBinary", "/my/init",
+				"bin.test",
+			},
+			expectedSpec: &virtrun.Spec{
+				Initramfs: virtrun.Initramfs{
+					Binary:     absBinPath,
+					InitBinary: "/my/init",
+				},
+				Qemu: virtrun.Qemu{
+					Kernel:   "/boot/this",
+					CPU:      "max",
+					Memory:   256,
+					AddRNG:   true,
+					SMP:      1,
+					InitArgs: []string{},
+				},
+			},
+		},
+		{
+			name: "network",
+			args: []string{
+				"-kernel=/boot/this",
+				"-network",
+				"bin.test",
+			},
+			expectedSpec: &virtrun.Spec{
+				Initramfs: virtrun.Initramfs{
+					Binary: absBinPath,
+				},
+				Qemu: virtrun.Qemu{
+					Kernel:   "/boot/this",
+					CPU:      "max",
+					Memory:   256,
+					AddRNG:   true,
+					SMP:      1,
+					InitArgs: []string{},
+					Network:  &qemu.Network{},
+				},
+			},
+		},
+		{
+			name: "disk extract",
+			args: []string{
+				"-kernel=/boot/this",
+				"-disk", "/srv/disk.img",
+				"-diskExtract", "/tmp/out",
+				"bin.test",
+			},
+			expectedSpec: &virtrun.Spec{
+				Initramfs: virtrun.Initramfs{
+					Binary: absBinPath,
+				},
+				Qemu: virtrun.Qemu{
+					Kernel:   "/boot/this",
+					CPU:      "max",
+					Memory:   256,
+					AddRNG:   true,
+					SMP:      1,
+					InitArgs: []string{},
+					Disks:    []qemu.Disk{{Path: "/srv/disk.img"}},
+				},
+				DiskExtractDir: "/tmp/out",
+			},
+		},
+		{
+			name: "binary from stdin",
+			args: []string{
+				"-kernel=/boot/this",
+				"-",
+				"-test.v",
+			},
+			expectedSpec: &virtrun.Spec{
+				Initramfs: virtrun.Initramfs{
+					Binary: virtrun.StdinBinary,
+				},
+				Qemu: virtrun.Qemu{
+					Kernel:   "/boot/this",
+					CPU:      "max",
+					Memory:   256,
+					AddRNG:   true,
+					SMP:      1,
+					InitArgs: []string{"-test.v"},
+				},
+			},
+		},
+		{
+			name: "smoke",
+			args: []string{
+				"-kernel=/boot/this",
+				"-smoke",
+			},
+			expectedSpec: &virtrun.Spec{
+				Qemu: virtrun.Qemu{
+					Kernel: "/boot/this",
+					CPU:    "max",
+					Memory: 256,
+					AddRNG: true,
+					SMP:    1,
+				},
+			},
+		},
+		{
+			name: "smoke with core dump flags",
+			args: []string{
+				"-kernel=/boot/this",
+				"-smoke",
+				"-corePattern", "/tmp/core.%e",
+				"-coreUsesPid",
+			},
+			expectedSpec: &virtrun.Spec{
+				Initramfs: virtrun.Initramfs{
+					Sysctls: map[string]string{
+						"kernel.core_pattern":  "/tmp/core.%e",
+						"kernel.core_uses_pid": "1",
+					},
+				},
+				Qemu: virtrun.Qemu{
+					Kernel: "/boot/this",
+					CPU:    "max",
+					Memory: 256,
+					AddRNG: true,
+					SMP:    1,
+				},
+			},
+		},
+		{
+			name: "dry build",
+			args: []string{
+				"-kernel=/boot/this",
+				"-dryBuild",
+				"bin.test",
+			},
+			expectedSpec: &virtrun.Spec{
+				Initramfs: virtrun.Initramfs{
+					Binary: absBinPath,
+				},
+				Qemu: virtrun.Qemu{
+					Kernel:   "/boot/this",
+					CPU:      "max",
+					Memory:   256,
+					AddRNG:   true,
+					SMP:      1,
+					InitArgs: []string{},
+				},
+			},
+			expectedDryBuildFlag: true,
+		},
+		{
+			name: "dry run",
+			args: []string{
+				"-kernel=/boot/this",
+				"-dryRun",
+				"bin.test",
+			},
+			expectedSpec: &virtrun.Spec{
+				Initramfs: virtrun.Initramfs{
+					Binary: absBinPath,
+				},
+				Qemu: virtrun.Qemu{
+					Kernel:   "/boot/this",
+					CPU:      "max",
+					Memory:   256,
+					AddRNG:   true,
+					SMP:      1,
+					InitArgs: []string{},
+				},
+			},
+			expectedDryRunFlag: true,
+		},
+		{
+			name: "shell",
+			args: []string{
+				"-kernel=/boot/this",
+				"-shell=/bin/busybox",
+				"-idleTimeout=1m",
+				"-timeout=5m",
+				"bin.test",
+				"other.file",
+			},
+			expectedSpec: &virtrun.Spec{
+				Initramfs: virtrun.Initramfs{
+					Binary: "/bin/busybox",
+					Files: []string{
+						absBinPath,
+						absOtherPath,
+					},
+				},
+				Qemu: virtrun.Qemu{
+					Kernel:   "/boot/this",
+					CPU:      "max",
+					Memory:   256,
+					AddRNG:   true,
+					SMP:      1,
+					InitArgs: []string{"sh", "-i"},
+				},
+			},
+		},
+		{
+			name: "shell without binary",
+			args: []string{
+				"-kernel=/boot/this",
+				"-shell=/bin/sh",
+			},
+			expectedSpec: &virtrun.Spec{
+				Initramfs: virtrun.Initramfs{
+					Binary: "/bin/sh",
+				},
+				Qemu: virtrun.Qemu{
+					Kernel:   "/boot/this",
+					CPU:      "max",
+					Memory:   256,
+					AddRNG:   true,
+					SMP:      1,
+					InitArgs: []string{"-i"},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -201,6 +1061,145 @@ func TestFlags_ParseArgs(t *testing.T) {
 
 			assert.Equal(t, tt.expectedSpec, flags.spec, "spec")
 			assert.Equal(t, tt.expectedDebugFlag, flags.Debug(), "debug flag")
+			assert.Equal(t, tt.expectedDryBuildFlag, flags.DryBuild(),
+				"dry build flag")
+			assert.Equal(t, tt.expectedDryRunFlag, flags.DryRun(),
+				"dry run flag")
+		})
+	}
+}
+
+func TestFlags_ParseArgs_Matrix(t *testing.T) {
+	absBinPath, err := AbsoluteFilePath("bin.test")
+	require.NoError(t, err)
+
+	flags := newFlags("test", io.Discard)
+
+	err = flags.ParseArgs([]string{
+		"-matrix", "/boot/amd64:bin.test",
+		"-matrix", "/boot/arm64:/bin.arm64",
+		"-matrixParallel", "3",
+		"-disk", "create:64M",
+		"-disk", "/srv/disk.img,readonly=on",
+		"--",
+		"-test.v",
+	})
+	require.NoError(t, err)
+
+	assert.True(t, flags.Matrix())
+	assert.Equal(t, matrixTargetList{
+		{Kernel: "/boot/amd64", Binary: absBinPath},
+		{Kernel: "/boot/arm64", Binary: "/bin.arm64"},
+	}, flags.matrixTargets)
+	assert.Equal(t, uint64(3), flags.matrixParallel)
+
+	expectedSpec := &virtrun.Spec{
+		Qemu: virtrun.Qemu{
+			CPU:      "max",
+			Memory:   256,
+			AddRNG:   true,
+			SMP:      1,
+			InitArgs: []string{"-test.v"},
+			Disks: []qemu.Disk{
+				{Size: 64 << 20},
+				{Path: "/srv/disk.img", ReadOnly: true},
+			},
+		},
+	}
+	assert.Equal(t, expectedSpec, flags.spec)
+}
+
+func TestFlags_ParseArgs_MatrixInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "missing binary",
+			args: []string{"-matrix", "/boot/this"},
+		},
+		{
+			name: "zero parallel",
+			args: []string{
+				"-matrix", "/boot/this:/bin",
+				"-matrixParallel", "0",
+			},
+		},
+		{
+			name: "with kernel",
+			args: []string{
+				"-matrix", "/boot/this:/bin",
+				"-kernel", "/boot/this",
+			},
+		},
+		{
+			name: "with smoke",
+			args: []string{"-matrix", "/boot/this:/bin", "-smoke"},
+		},
+		{
+			name: "with junit file",
+			args: []string{
+				"-matrix", "/boot/this:/bin",
+				"-junitFile", "/report.xml",
+			},
+		},
+		{
+			name: "with qmp socket",
+			args: []string{
+				"-matrix", "/boot/this:/bin",
+				"-qmp", "/run/qmp.sock",
+			},
+		},
+		{
+			name: "with gdb",
+			args: []string{
+				"-matrix", "/boot/this:/bin",
+				"-gdb", ":1234",
+			},
+		},
+		{
+			name: "with host forward",
+			args: []string{
+				"-matrix", "/boot/this:/bin",
+				"-hostfwd", "tcp::8080-:80",
+			},
+		},
+		{
+			name: "with monitor socket",
+			args: []string{
+				"-matrix", "/boot/this:/bin",
+				"-monitor", "socket:/run/mon.sock",
+			},
+		},
+		{
+			name: "with crash dump",
+			args: []string{
+				"-matrix", "/boot/this:/bin",
+				"-crashDump", "/tmp/vmcore",
+			},
+		},
+		{
+			name: "with lib graph",
+			args: []string{
+				"-matrix", "/boot/this:/bin",
+				"-libGraph", "/tmp/libs.dot",
+			},
+		},
+		{
+			name: "with writable disk image",
+			args: []string{
+				"-matrix", "/boot/this:/bin",
+				"-disk", "/srv/disk.img",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := newFlags("test", io.Discard)
+
+			err := flags.ParseArgs(tt.args)
+			require.ErrorIs(t, err, &ParseArgsError{})
 		})
 	}
 }
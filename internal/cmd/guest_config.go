@@ -0,0 +1,32 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"github.com/aibor/virtrun/sysinit"
+)
+
+// guestConfigValue reads a [sysinit.GuestConfig] from the JSON file at the
+// given path.
+type guestConfigValue struct {
+	Value **sysinit.GuestConfig
+	path  string
+}
+
+func (g *guestConfigValue) String() string {
+	return g.path
+}
+
+func (g *guestConfigValue) Set(s string) error {
+	guestCfg, err := sysinit.ReadGuestConfig(s)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	g.path = s
+	*g.Value = &guestCfg
+
+	return nil
+}
@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aibor/virtrun/sysinit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuestConfigValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guest.json")
+	content := `{"env": {"A": "1"}, "setupStepTimeout": "5s"}`
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	var guestCfg *sysinit.GuestConfig
+
+	value := &guestConfigValue{Value: &guestCfg}
+
+	require.NoError(t, value.Set(path))
+	assert.Equal(t, path, value.String())
+	assert.Equal(t, &sysinit.GuestConfig{
+		Env:              sysinit.EnvVars{"A": "1"},
+		SetupStepTimeout: "5s",
+	}, guestCfg)
+
+	err := value.Set(filepath.Join(t.TempDir(), "missing.json"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
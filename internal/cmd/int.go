@@ -43,3 +43,27 @@ func (u *limitedUintValue) Set(s string) error {
 
 	return nil
 }
+
+// optionalUintValue is an unsigned integer that is nil unless set.
+type optionalUintValue struct {
+	Value **uint64
+}
+
+func (u *optionalUintValue) String() string {
+	if u.Value == nil || *u.Value == nil {
+		return ""
+	}
+
+	return strconv.FormatUint(**u.Value, 10)
+}
+
+func (u *optionalUintValue) Set(s string) error {
+	value, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("parse: %w", err)
+	}
+
+	*u.Value = &value
+
+	return nil
+}
@@ -0,0 +1,135 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/aibor/virtrun/internal/virtrun"
+)
+
+// matrixTargetList is a list of kernel and binary pairs the same invocation
+// is run for. Values are given as "KERNEL:BINARY".
+type matrixTargetList []virtrun.MatrixTarget
+
+func (l *matrixTargetList) String() string {
+	targets := make([]string, 0, len(*l))
+
+	for _, target := range *l {
+		targets = append(targets, target.Kernel+":"+target.Binary)
+	}
+
+	return strings.Join(targets, ",")
+}
+
+func (l *matrixTargetList) Set(s string) error {
+	kernel, binary, found := strings.Cut(s, ":")
+	if !found {
+		return fmt.Errorf("%w: %q: want KERNEL:BINARY",
+			ErrInvalidMatrixTarget, s)
+	}
+
+	kernelPath, err := AbsoluteFilePath(kernel)
+	if err != nil {
+		return fmt.Errorf("kernel: %w", err)
+	}
+
+	binaryPath, err := AbsoluteFilePath(binary)
+	if err != nil {
+		return fmt.Errorf("binary: %w", err)
+	}
+
+	*l = append(*l, virtrun.MatrixTarget{
+		Kernel: kernelPath,
+		Binary: binaryPath,
+	})
+
+	return nil
+}
+
+// matrixFunc runs a [virtrun.Spec] for multiple targets. It matches
+// [virtrun.RunMatrix].
+type matrixFunc func(
+	ctx context.Context,
+	base *virtrun.Spec,
+	targets map[sys.Arch]virtrun.MatrixTarget,
+	parallel int,
+) virtrun.MatrixResults
+
+// runMatrix runs the given [virtrun.Spec] for all targets with at most
+// parallel runs at once.
+//
+// The architecture of each target is read from its binary, so each
+// architecture must be given only once. Once all runs are done, their output
+// is written to stdout, each line prefixed with the architecture. The
+// returned error joins the errors of all failed runs.
+func runMatrix(
+	ctx context.Context,
+	spec *virtrun.Spec,
+	targets []virtrun.MatrixTarget,
+	parallel int,
+	matrixFn matrixFunc,
+	stdout io.Writer,
+) error {
+	archTargets := make(map[sys.Arch]virtrun.MatrixTarget, len(targets))
+
+	for _, target := range targets {
+		arch, err := sys.ReadELFArch(target.Binary)
+		if err != nil {
+			return fmt.Errorf("read binary arch: %w", err)
+		}
+
+		if _, exists := archTargets[arch]; exists {
+			return fmt.Errorf("%w: %s", ErrDuplicateMatrixArch, arch)
+		}
+
+		err = Validate(ctx, virtrun.NewSpec(spec, target))
+		if err != nil {
+			return fmt.Errorf("validate %s: %w", arch, err)
+		}
+
+		archTargets[arch] = target
+	}
+
+	results := matrixFn(ctx, spec, archTargets, parallel)
+
+	for _, arch := range slices.Sorted(maps.Keys(results)) {
+		err := writePrefixedLines(stdout, "["+arch.String()+"] ",
+			results[arch].Output)
+		if err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+
+	return results.Err()
+}
+
+// writePrefixedLines writes each line of the output prefixed with the given
+// prefix. A missing trailing newline is added.
+func writePrefixedLines(w io.Writer, prefix string, output []byte) error {
+	for _, line := range bytes.SplitAfter(output, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		if !bytes.HasSuffix(line, []byte("\n")) {
+			line = append(line, '\n')
+		}
+
+		_, err := fmt.Fprintf(w, "%s%s", prefix, line)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
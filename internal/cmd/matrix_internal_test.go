@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func matrixTarget(t *testing.T, arch sys.Arch) virtrun.MatrixTarget {
+	t.Helper()
+
+	binary, removeFn, err := virtrun.WriteSmokeBinary(arch)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = removeFn() })
+
+	kernel := filepath.Join(t.TempDir(), "vmlinuz-"+arch.String())
+	require.NoError(t, os.WriteFile(kernel, []byte("kernel"), 0o600))
+
+	return virtrun.MatrixTarget{
+		Binary: binary,
+		Kernel: kernel,
+	}
+}
+
+func TestRunMatrix(t *testing.T) {
+	amd64 := matrixTarget(t, sys.AMD64)
+	arm64 := matrixTarget(t, sys.ARM64)
+
+	fakeMatrix := func(
+		_ context.Context,
+		_ *virtrun.Spec,
+		targets map[sys.Arch]virtrun.MatrixTarget,
+		parallel int,
+	) virtrun.MatrixResults {
+		assert.Equal(t, map[sys.Arch]virtrun.MatrixTarget{
+			sys.AMD64: amd64,
+			sys.ARM64: arm64,
+		}, targets)
+		assert.Equal(t, 2, parallel)
+
+		return virtrun.MatrixResults{
+			sys.AMD64: {
+				Result: &virtrun.Result{},
+				Output: []byte("first\nsecond"),
+			},
+			sys.ARM64: {
+				Result: &virtrun.Result{ExitCode: 2},
+				Output: []byte("failed\n"),
+				Err: &qemu.CommandError{
+					Err:      qemu.ErrGuestNonZeroExitCode,
+					Guest:    true,
+					ExitCode: 2,
+				},
+			},
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	err := runMatrix(
+		context.Background(),
+		&virtrun.Spec{},
+		[]virtrun.MatrixTarget{arm64, amd64},
+		2,
+		fakeMatrix,
+		&stdout,
+	)
+	require.ErrorIs(t, err, qemu.ErrGuestNonZeroExitCode)
+
+	expected := "[amd64] first\n[amd64] second\n[arm64] failed\n"
+	assert.Equal(t, expected, stdout.String())
+
+	exitCode := handleRunError(fmt.Errorf("matrix: %w", err), &stderr)
+	assert.Equal(t, 2, exitCode, "exit code")
+	assert.Empty(t, stderr.String(), "guest exit codes are not printed")
+}
+
+func TestRunMatrix_DuplicateArch(t *testing.T) {
+	target := matrixTarget(t, sys.AMD64)
+
+	err := runMatrix(
+		context.Background(),
+		&virtrun.Spec{},
+		[]virtrun.MatrixTarget{target, target},
+		2,
+		func(
+			context.Context,
+			*virtrun.Spec,
+			map[sys.Arch]virtrun.MatrixTarget,
+			int,
+		) virtrun.MatrixResults {
+			t.Fatal("must not run")
+			return nil
+		},
+		&bytes.Buffer{},
+	)
+	require.ErrorIs(t, err, ErrDuplicateMatrixArch)
+}
@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/virtrun"
+)
+
+// qemuVersionTimeout is the maximum duration for reading the QEMU version.
+const qemuVersionTimeout = 5 * time.Second
+
+// metadataSpec is the sanitized subset of the [virtrun.Spec] that is written
+// into the metadata file. Init args are left out as they may contain
+// sensitive information.
+type metadataSpec struct {
+	Executable    string   `json:"executable"`
+	Kernel        string   `json:"kernel"`
+	Machine       string   `json:"machine"`
+	CPU           string   `json:"cpu"`
+	Memory        uint64   `json:"memory"`
+	SMP           uint64   `json:"smp"`
+	TransportType string   `json:"transportType"`
+	NoKVM         bool     `json:"noKvm"`
+	Binary        string   `json:"binary"`
+	Files         []string `json:"files"`
+	Modules       []string `json:"modules"`
+}
+
+// metadata describes a single run. It is intended for CI systems to collect
+// information about runs. It is written into the metadata file and reported
+// with [OutputFormatJSON].
+type metadata struct {
+	Spec          metadataSpec `json:"spec"`
+	ExitCode      int          `json:"exitCode"`
+	Command       []string     `json:"command,omitempty"`
+	InitramfsPath string       `json:"initramfsPath,omitempty"`
+	Duration      string       `json:"duration"`
+	Panic         bool         `json:"panic"`
+	OOM           bool         `json:"oom"`
+	Oops          bool         `json:"oops"`
+	InitramfsSize int64        `json:"initramfsSize"`
+	QemuVersion   string       `json:"qemuVersion"`
+	Error         string       `json:"error,omitempty"`
+}
+
+func newMetadata(
+	spec *virtrun.Spec,
+	result *virtrun.Result,
+	qemuVersion string,
+	runErr error,
+) metadata {
+	data := metadata{
+		Spec: metadataSpec{
+			Executable:    spec.Qemu.Executable,
+			Kernel:        spec.Qemu.Kernel,
+			Machine:       spec.Qemu.Machine,
+			CPU:           spec.Qemu.CPU,
+			Memory:        spec.Qemu.Memory,
+			SMP:           spec.Qemu.SMP,
+			TransportType: spec.Qemu.TransportType.String(),
+			NoKVM:         spec.Qemu.NoKVM,
+			Binary:        spec.Initramfs.Binary,
+			Files:         spec.Initramfs.Files,
+			Modules:       spec.Initramfs.Modules,
+		},
+		ExitCode:      result.ExitCode,
+		Command:       result.Command,
+		InitramfsPath: result.InitramfsPath,
+		Duration:      result.Duration.String(),
+		Panic:         result.Panic,
+		OOM:           result.OOM,
+		Oops:          result.Oops,
+		InitramfsSize: result.InitramfsSize,
+		QemuVersion:   qemuVersion,
+	}
+
+	if runErr != nil {
+		data.Error = runErr.Error()
+	}
+
+	return data
+}
+
+// collectMetadata creates the [metadata] of a run including the version of
+// the QEMU executable used.
+func collectMetadata(
+	ctx context.Context,
+	spec *virtrun.Spec,
+	result *virtrun.Result,
+	runErr error,
+) metadata {
+	// The run context might be done already, like after a timeout, but the
+	// version is still of interest then.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx),
+		qemuVersionTimeout)
+	defer cancel()
+
+	// The QEMU version is best-effort only, as the executable might not even
+	// exist in case the run failed.
+	qemuVersion, err := qemu.Version(ctx, spec.Qemu.Executable)
+	if err != nil {
+		slog.Warn("Failed to read QEMU version", slog.Any("error", err))
+	}
+
+	return newMetadata(spec, result, qemuVersion, runErr)
+}
+
+func writeMetadataFile(path string, data metadata) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+
+	err = os.WriteFile(path, append(content, '\n'), 0o644) //nolint:gosec
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	return nil
+}
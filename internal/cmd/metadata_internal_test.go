@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMetadataFile(t *testing.T) {
+	spec := &virtrun.Spec{
+		Qemu: virtrun.Qemu{
+			Executable:    "/nonexistent/qemu-system-x86_64",
+			Kernel:        "/boot/vmlinuz",
+			Machine:       "q35",
+			CPU:           "max",
+			Memory:        256,
+			SMP:           2,
+			TransportType: qemu.TransportTypePCI,
+			InitArgs:      []string{"-secret=value"},
+		},
+		Initramfs: virtrun.Initramfs{
+			Binary: "/tmp/bin.test",
+			Files:  []string{"/tmp/file"},
+		},
+	}
+
+	result := &virtrun.Result{
+		ExitCode:      3,
+		Duration:      1500 * time.Millisecond,
+		InitramfsSize: 4096,
+		Panic:         true,
+	}
+
+	path := filepath.Join(t.TempDir(), "metadata.json")
+
+	data := collectMetadata(
+		context.Background(),
+		spec,
+		result,
+		qemu.ErrGuestPanic,
+	)
+
+	err := writeMetadataFile(path, data)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var actual map[string]any
+
+	require.NoError(t, json.Unmarshal(content, &actual))
+
+	expected := map[string]any{
+		"spec": map[string]any{
+			"executable":    "/nonexistent/qemu-system-x86_64",
+			"kernel":        "/boot/vmlinuz",
+			"machine":       "q35",
+			"cpu":           "max",
+			"memory":        float64(256),
+			"smp":           float64(2),
+			"transportType": "pci",
+			"noKvm":         false,
+			"binary":        "/tmp/bin.test",
+			"files":         []any{"/tmp/file"},
+			"modules":       nil,
+		},
+		"exitCode":      float64(3),
+		"duration":      "1.5s",
+		"panic":         true,
+		"oom":           false,
+		"oops":          false,
+		"initramfsSize": float64(4096),
+		"qemuVersion":   "",
+		"error":         qemu.ErrGuestPanic.Error(),
+	}
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestCollectMetadata_ContextDone(t *testing.T) {
+	executable := filepath.Join(t.TempDir(), "qemu-system-x86_64")
+	script := "#!/bin/sh\necho 'QEMU emulator version 9.1.0'\n"
+
+	err := os.WriteFile(executable, []byte(script), 0o755) //nolint:gosec
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	spec := &virtrun.Spec{
+		Qemu: virtrun.Qemu{Executable: executable},
+	}
+
+	data := collectMetadata(ctx, spec, &virtrun.Result{}, context.Canceled)
+	assert.Equal(t, "9.1.0", data.QemuVersion)
+}
@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aibor/virtrun/internal/qemu"
+)
+
+// monitorValue parses a QEMU monitor backend given as "pty" or
+// "socket:PATH".
+type monitorValue struct {
+	Value **qemu.ConsoleBackend
+}
+
+func (m *monitorValue) String() string {
+	if m.Value == nil || *m.Value == nil {
+		return ""
+	}
+
+	backend := *m.Value
+	if backend.Path == "" {
+		return string(backend.Type)
+	}
+
+	return string(backend.Type) + ":" + backend.Path
+}
+
+func (m *monitorValue) Set(s string) error {
+	typ, path, _ := strings.Cut(s, ":")
+
+	backend := &qemu.ConsoleBackend{
+		Type: qemu.ConsoleBackendType(typ),
+		Path: path,
+	}
+
+	switch {
+	case backend.Type == qemu.ConsoleBackendPty && path == "":
+	case backend.Type == qemu.ConsoleBackendSocket && path != "":
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidMonitor, s)
+	}
+
+	*m.Value = backend
+
+	return nil
+}
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aibor/virtrun/internal/qemu"
+)
+
+// networkValue is a boolean flag that enables the user mode network.
+type networkValue struct {
+	Value **qemu.Network
+}
+
+func (n *networkValue) IsBoolFlag() bool {
+	return true
+}
+
+func (n *networkValue) String() string {
+	return strconv.FormatBool(n.Value != nil && *n.Value != nil)
+}
+
+func (n *networkValue) Set(s string) error {
+	enabled, err := strconv.ParseBool(s)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	switch {
+	case !enabled:
+		*n.Value = nil
+	case *n.Value == nil:
+		*n.Value = &qemu.Network{}
+	}
+
+	return nil
+}
+
+// hostForwardList is a list of host forwards of the user mode network given
+// like the QEMU "hostfwd" option. Adding one enables the network.
+type hostForwardList struct {
+	Value **qemu.Network
+}
+
+func (h *hostForwardList) String() string {
+	if h.Value == nil || *h.Value == nil {
+		return ""
+	}
+
+	forwards := make([]string, 0, len((*h.Value).HostForwards))
+
+	for _, fwd := range (*h.Value).HostForwards {
+		forwards = append(forwards, fwd.String())
+	}
+
+	return strings.Join(forwards, ",")
+}
+
+func (h *hostForwardList) Set(s string) error {
+	fwd, err := qemu.ParseHostForward(s)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if *h.Value == nil {
+		*h.Value = &qemu.Network{}
+	}
+
+	(*h.Value).HostForwards = append((*h.Value).HostForwards, fwd)
+
+	return nil
+}
@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errReportedAsJSON marks run errors that are reported in the JSON output
+// already, so they are not printed again.
+var errReportedAsJSON = errors.New("reported as json")
+
+// OutputFormat is the format virtrun reports the outcome of a run in.
+type OutputFormat string
+
+const (
+	// OutputFormatText reports errors as human readable text on stderr. This
+	// is the default.
+	OutputFormatText OutputFormat = "text"
+
+	// OutputFormatJSON reports the outcome as a single JSON object on stdout
+	// once the run is done, see [metadata]. The guest's output is written to
+	// stderr instead, so stdout is parsable as a whole.
+	OutputFormatJSON OutputFormat = "json"
+)
+
+func (o *OutputFormat) String() string {
+	return string(*o)
+}
+
+func (o *OutputFormat) Set(s string) error {
+	switch OutputFormat(s) {
+	case OutputFormatText, OutputFormatJSON:
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidOutputFormat, s)
+	}
+
+	*o = OutputFormat(s)
+
+	return nil
+}
+
+// writeJSONOutput writes the [metadata] of the run as single line JSON
+// object.
+func writeJSONOutput(w io.Writer, data metadata) error {
+	err := json.NewEncoder(w).Encode(data)
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	return nil
+}
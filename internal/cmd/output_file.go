@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// OutputFileMap maps files in the guest to host paths, given as "guest:host".
+// The guest path must be absolute and must not contain ",", ":", quotes or
+// white space, as it is passed via the kernel command line.
+type OutputFileMap map[string]string
+
+func (m *OutputFileMap) String() string {
+	pairs := make([]string, 0, len(*m))
+
+	for _, guestPath := range slices.Sorted(maps.Keys(*m)) {
+		pairs = append(pairs, guestPath+":"+(*m)[guestPath])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (m *OutputFileMap) Set(s string) error {
+	guestPath, hostPath, found := strings.Cut(s, ":")
+	if !found || !path.IsAbs(guestPath) ||
+		strings.ContainsAny(guestPath, ",\"' \t\n") {
+		return fmt.Errorf("%w: %s", ErrInvalidOutputFile, s)
+	}
+
+	guestPath = path.Clean(guestPath)
+
+	if _, exists := (*m)[guestPath]; exists {
+		return fmt.Errorf("%w: duplicate guest path %s",
+			ErrInvalidOutputFile, guestPath)
+	}
+
+	hostPath, err := AbsoluteFilePath(hostPath)
+	if err != nil {
+		return err
+	}
+
+	if *m == nil {
+		*m = make(OutputFileMap)
+	}
+
+	(*m)[guestPath] = hostPath
+
+	return nil
+}
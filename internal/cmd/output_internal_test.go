@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJSONOutput(t *testing.T) {
+	spec := &virtrun.Spec{
+		Qemu: virtrun.Qemu{
+			Executable: "qemu-system-x86_64",
+			Kernel:     "/boot/vmlinuz",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		result   *virtrun.Result
+		runErr   error
+		expected map[string]any
+	}{
+		{
+			name: "success",
+			result: &virtrun.Result{
+				Command:       []string{"qemu-system-x86_64", "-kernel", "k"},
+				InitramfsPath: "/tmp/initramfs",
+				Duration:      1500 * time.Millisecond,
+			},
+			expected: map[string]any{
+				"exitCode":      float64(0),
+				"command":       []any{"qemu-system-x86_64", "-kernel", "k"},
+				"initramfsPath": "/tmp/initramfs",
+				"duration":      "1.5s",
+			},
+		},
+		{
+			name: "failure",
+			result: &virtrun.Result{
+				ExitCode: 3,
+				Command:  []string{"qemu-system-x86_64"},
+				Duration: time.Second,
+			},
+			runErr: &qemu.CommandError{
+				Err:      qemu.ErrGuestNonZeroExitCode,
+				Guest:    true,
+				ExitCode: 3,
+			},
+			expected: map[string]any{
+				"exitCode": float64(3),
+				"command":  []any{"qemu-system-x86_64"},
+				"duration": "1s",
+				"error":    "qemu guest: guest did not return exit code 0",
+			},
+		},
+		{
+			name:   "pre-run failure",
+			result: &virtrun.Result{},
+			runErr: errors.New("validate: kernel missing"),
+			expected: map[string]any{
+				"exitCode": float64(0),
+				"duration": "0s",
+				"error":    "validate: kernel missing",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+
+			data := newMetadata(spec, tt.result, "", tt.runErr)
+
+			err := writeJSONOutput(&stdout, data)
+			require.NoError(t, err)
+
+			assert.Equal(t, 1, bytes.Count(stdout.Bytes(), []byte("\n")),
+				"single line")
+
+			var actual map[string]any
+
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), &actual))
+
+			for key, value := range tt.expected {
+				assert.Equal(t, value, actual[key], key)
+			}
+
+			for _, key := range []string{"command", "initramfsPath", "error"} {
+				if _, ok := tt.expected[key]; !ok {
+					assert.NotContains(t, actual, key)
+				}
+			}
+
+			assert.Contains(t, actual, "spec")
+		})
+	}
+}
+
+func TestRun_JSONOutputValidateError(t *testing.T) {
+	t.Setenv("VIRTRUN_ARGS", "")
+
+	binary := filepath.Join(t.TempDir(), "bin.test")
+	require.NoError(t, os.WriteFile(binary, nil, 0o755)) //nolint:gosec
+
+	args := []string{
+		"virtrun",
+		"-kernel", filepath.Join(t.TempDir(), "nonexistent"),
+		"-output", "json",
+		binary,
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	err := run(args, nil, &stdout, &stderr)
+	require.ErrorIs(t, err, errReportedAsJSON)
+
+	var actual map[string]any
+
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &actual))
+	assert.Contains(t, actual["error"], "validate: ")
+}
+
+func TestHandleRunError_ReportedAsJSON(t *testing.T) {
+	var stderr bytes.Buffer
+
+	err := fmt.Errorf("run: %w", fmt.Errorf("%w: %w", errReportedAsJSON,
+		&qemu.CommandError{Err: qemu.ErrGuestPanic, Guest: true}))
+
+	exitCode := handleRunError(err, &stderr)
+	assert.Equal(t, -1, exitCode)
+	assert.Empty(t, stderr.String())
+}
+
+func TestOutputFormat_Set(t *testing.T) {
+	var format OutputFormat
+
+	require.NoError(t, format.Set("json"))
+	assert.Equal(t, OutputFormatJSON, format)
+
+	require.ErrorIs(t, format.Set("yaml"), ErrInvalidOutputFormat)
+	assert.Equal(t, OutputFormatJSON, format, "unchanged")
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"github.com/aibor/virtrun/sysinit"
+)
+
+// panicBehaviorValue parses a [sysinit.PanicBehavior].
+type panicBehaviorValue struct {
+	Value *sysinit.PanicBehavior
+}
+
+func (p *panicBehaviorValue) String() string {
+	if p.Value == nil {
+		return ""
+	}
+
+	return string(*p.Value)
+}
+
+func (p *panicBehaviorValue) Set(s string) error {
+	behavior, err := sysinit.ParsePanicBehavior(s)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	*p.Value = behavior
+
+	return nil
+}
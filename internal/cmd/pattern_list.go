@@ -0,0 +1,29 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PatternList is a list of shell patterns as supported by [path.Match].
+type PatternList []string
+
+func (l *PatternList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *PatternList) Set(s string) error {
+	_, err := path.Match(s, "")
+	if err != nil {
+		return fmt.Errorf("%s: %w", s, err)
+	}
+
+	*l = append(*l, s)
+
+	return nil
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+)
+
+type regexpValue struct {
+	Value **regexp.Regexp
+}
+
+func (r *regexpValue) String() string {
+	if r.Value == nil || *r.Value == nil {
+		return ""
+	}
+
+	return (*r.Value).String()
+}
+
+func (r *regexpValue) Set(s string) error {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return fmt.Errorf("compile: %w", err)
+	}
+
+	*r.Value = re
+
+	return nil
+}
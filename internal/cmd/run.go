@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os/signal"
 	"syscall"
 
@@ -24,11 +25,6 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 		return fmt.Errorf("parse args: %w", err)
 	}
 
-	err = Validate(flags.spec)
-	if err != nil {
-		return fmt.Errorf("validate: %w", err)
-	}
-
 	setupLogging(stderr, flags.Debug())
 
 	ctx, cancel := signal.NotifyContext(
@@ -41,9 +37,113 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
 	)
 	defer cancel()
 
-	err = virtrun.Run(ctx, flags.spec, stdin, stdout, stderr)
+	if flags.Smoke() {
+		return runSmoke(
+			ctx,
+			flags.spec,
+			virtrun.RunWithResult,
+			stdin,
+			stdout,
+			stderr,
+		)
+	}
+
+	if flags.Matrix() {
+		err := runMatrix(
+			ctx,
+			flags.spec,
+			flags.matrixTargets,
+			int(flags.matrixParallel),
+			virtrun.RunMatrix,
+			stdout,
+		)
+		if err != nil {
+			return fmt.Errorf("matrix: %w", err)
+		}
+
+		return nil
+	}
+
+	err = Validate(ctx, flags.spec)
 	if err != nil {
-		return fmt.Errorf("run: %w", err)
+		err = fmt.Errorf("validate: %w", err)
+
+		if flags.outputFormat == OutputFormatJSON {
+			return reportJSON(ctx, stdout, flags.spec, &virtrun.Result{}, err)
+		}
+
+		return err
+	}
+
+	if flags.DryRun() {
+		return runDryRun(ctx, flags.spec, stdin, stdout)
+	}
+
+	if flags.DryBuild() {
+		return runDryBuild(ctx, flags.spec, stdin, stdout)
+	}
+
+	guestStdout := stdout
+
+	// Keep stdout parsable as a whole.
+	if flags.outputFormat == OutputFormatJSON {
+		guestStdout = stderr
+	}
+
+	result, runErr := virtrun.RunWithResult(
+		ctx,
+		flags.spec,
+		stdin,
+		guestStdout,
+		stderr,
+	)
+	if runErr != nil {
+		runErr = fmt.Errorf("run: %w", runErr)
+	}
+
+	if flags.metadataFile != "" {
+		data := collectMetadata(ctx, flags.spec, result, runErr)
+
+		err = writeMetadataFile(flags.metadataFile, data)
+		if err != nil {
+			// Do not mask the more relevant run error.
+			if runErr == nil {
+				return fmt.Errorf("metadata: %w", err)
+			}
+
+			slog.Error("Failed to write metadata", slog.Any("error", err))
+		}
+	}
+
+	if flags.outputFormat == OutputFormatJSON {
+		return reportJSON(ctx, stdout, flags.spec, result, runErr)
+	}
+
+	return runErr
+}
+
+// reportJSON writes the outcome of the run as JSON object to the given writer.
+// The returned run error is marked as reported, so it is not printed again.
+func reportJSON(
+	ctx context.Context,
+	w io.Writer,
+	spec *virtrun.Spec,
+	result *virtrun.Result,
+	runErr error,
+) error {
+	data := collectMetadata(ctx, spec, result, runErr)
+
+	err := writeJSONOutput(w, data)
+
+	switch {
+	case err != nil && runErr == nil:
+		return fmt.Errorf("json output: %w", err)
+	case err != nil:
+		slog.Error("Failed to write JSON output", slog.Any("error", err))
+
+		return runErr
+	case runErr != nil:
+		return fmt.Errorf("%w: %w", errReportedAsJSON, runErr)
 	}
 
 	return nil
@@ -76,8 +176,9 @@ func handleRunError(err error, errWriter io.Writer) int {
 	}
 
 	// Do not print the error in case the guest process ran successfully and
-	// the guest properly communicated a non-zero exit code.
-	if errors.Is(err, qemu.ErrGuestNonZeroExitCode) {
+	// the guest properly communicated a non-zero exit code, or if the error
+	// has been reported in the JSON output already.
+	if isGuestNonZeroExitCode(err) || errors.Is(err, errReportedAsJSON) {
 		return exitCode
 	}
 
@@ -86,6 +187,28 @@ func handleRunError(err error, errWriter io.Writer) int {
 	return exitCode
 }
 
+// isGuestNonZeroExitCode returns true if the error is caused by the guest
+// communicating a non-zero exit code. If errors are joined, like those of
+// -matrix runs, all of them must be caused by it.
+func isGuestNonZeroExitCode(err error) bool {
+	for wrapped := err; wrapped != nil; wrapped = errors.Unwrap(wrapped) {
+		joined, ok := wrapped.(interface{ Unwrap() []error })
+		if !ok {
+			continue
+		}
+
+		for _, err := range joined.Unwrap() {
+			if !isGuestNonZeroExitCode(err) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return errors.Is(err, qemu.ErrGuestNonZeroExitCode)
+}
+
 func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 	err := run(args, stdin, stdout, stderr)
 	return handleRunError(err, stderr)
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleRunError(t *testing.T) {
+	tests := []struct {
+		name             string
+		err              error
+		expectedExitCode int
+		expectedOutput   string
+	}{
+		{
+			name: "no error",
+		},
+		{
+			name: "help",
+			err:  fmt.Errorf("parse args: %w", ErrHelp),
+		},
+		{
+			name: "parse args error",
+			err: fmt.Errorf("parse args: %w", &ParseArgsError{
+				msg: "no kernel given",
+			}),
+			expectedExitCode: -1,
+		},
+		{
+			name: "guest non-zero exit code",
+			err: fmt.Errorf("run: %w", &qemu.CommandError{
+				Err:      qemu.ErrGuestNonZeroExitCode,
+				Guest:    true,
+				ExitCode: 3,
+			}),
+			expectedExitCode: 3,
+		},
+		{
+			name: "matrix guest non-zero exit codes",
+			err: fmt.Errorf("matrix: %w", errors.Join(
+				fmt.Errorf("amd64: %w", &qemu.CommandError{
+					Err:      qemu.ErrGuestNonZeroExitCode,
+					Guest:    true,
+					ExitCode: 3,
+				}),
+				fmt.Errorf("arm64: %w", &qemu.CommandError{
+					Err:      qemu.ErrGuestNonZeroExitCode,
+					Guest:    true,
+					ExitCode: 4,
+				}),
+			)),
+			expectedExitCode: 3,
+		},
+		{
+			name: "matrix mixed errors",
+			err: fmt.Errorf("matrix: %w", errors.Join(
+				fmt.Errorf("amd64: %w", &qemu.CommandError{
+					Err:      qemu.ErrGuestNonZeroExitCode,
+					Guest:    true,
+					ExitCode: 3,
+				}),
+				fmt.Errorf("arm64: %w", errors.New("fail")),
+			)),
+			expectedExitCode: 3,
+			expectedOutput: "Error [virtrun]: matrix: amd64: qemu guest: " +
+				"guest did not return exit code 0\narm64: fail\n",
+		},
+		{
+			name: "guest panic",
+			err: fmt.Errorf("run: %w", &qemu.CommandError{
+				Err:   qemu.ErrGuestPanic,
+				Guest: true,
+			}),
+			expectedExitCode: -1,
+			expectedOutput: "Error [virtrun]: run: qemu guest: guest system " +
+				"panicked\n",
+		},
+		{
+			name:             "other error",
+			err:              fmt.Errorf("validate: %w", errors.New("fail")),
+			expectedExitCode: -1,
+			expectedOutput:   "Error [virtrun]: validate: fail\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+
+			exitCode := handleRunError(tt.err, &output)
+
+			assert.Equal(t, tt.expectedExitCode, exitCode, "exit code")
+			assert.Equal(t, tt.expectedOutput, output.String(), "output")
+		})
+	}
+}
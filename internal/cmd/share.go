@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aibor/virtrun/internal/qemu"
+)
+
+// shareReadOnlySuffix marks a share as read-only.
+const shareReadOnlySuffix = ":ro"
+
+// shareList is a list of host directories shared with the guest. Values are
+// given as "HOST:TAG" with an optional ":ro" suffix for read-only shares.
+type shareList []qemu.Share
+
+func (s *shareList) String() string {
+	shares := make([]string, 0, len(*s))
+
+	for _, share := range *s {
+		value := share.HostPath + ":" + share.Tag
+		if share.ReadOnly {
+			value += shareReadOnlySuffix
+		}
+
+		shares = append(shares, value)
+	}
+
+	return strings.Join(shares, ",")
+}
+
+func (s *shareList) Set(value string) error {
+	spec, readOnly := strings.CutSuffix(value, shareReadOnlySuffix)
+
+	source, tag, found := strings.Cut(spec, ":")
+	if !found || tag == "" {
+		return fmt.Errorf("%w: %s", ErrInvalidShare, value)
+	}
+
+	path, err := AbsoluteFilePath(source)
+	if err != nil {
+		return err
+	}
+
+	*s = append(*s, qemu.Share{
+		HostPath: path,
+		Tag:      tag,
+		ReadOnly: readOnly,
+	})
+
+	return nil
+}
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/aibor/virtrun/internal/virtrun"
+)
+
+const bytesPerMB = 1024 * 1024
+
+// ValidateSizeBudget checks the files that are added to the initramfs
+// against [virtrun.Initramfs.MaxFileSize] and [virtrun.Initramfs.MaxSize].
+//
+// The total size is estimated by summing up the sizes of the main binary,
+// the init binary, additional binaries, additional files, the files in
+// additional directories, trees and overlays, modules, firmware, prepend
+// archives, the stdin file and the shared libraries required by the binaries.
+// The error names the file that exceeded the budget. It returns nil if no
+// budget is set. A main binary read from stdin is not included.
+func ValidateSizeBudget(ctx context.Context, cfg virtrun.Initramfs) error {
+	if cfg.MaxFileSize == 0 && cfg.MaxSize == 0 {
+		return nil
+	}
+
+	binaries := append(slices.Clone(cfg.Binaries), cfg.Files...)
+	for _, mapping := range cfg.NamedFiles {
+		binaries = append(binaries, mapping.Source)
+	}
+
+	// A binary read from stdin is not known before the run, so it can not be
+	// accounted for.
+	if cfg.Binary != virtrun.StdinBinary {
+		binaries = append([]string{cfg.Binary}, binaries...)
+	}
+
+	if cfg.InitBinary != "" {
+		binaries = append(binaries, cfg.InitBinary)
+	}
+
+	libs, err := sys.CollectLibsFor(ctx, binaries...)
+	if err != nil {
+		return fmt.Errorf("collect libs: %w", err)
+	}
+
+	files := append([]string{}, binaries...)
+	files = append(files, cfg.Modules...)
+
+	for _, mapping := range cfg.Firmware {
+		files = append(files, mapping.Source)
+	}
+
+	files = append(files, cfg.PrependArchives...)
+
+	if cfg.Stdin != "" {
+		files = append(files, cfg.Stdin)
+	}
+
+	dirs := slices.Clone(cfg.Overlays)
+	for _, mapping := range slices.Concat(cfg.Dirs, cfg.Trees) {
+		dirs = append(dirs, mapping.Source)
+	}
+
+	for _, dir := range dirs {
+		dirFiles, err := regularFilesIn(dir)
+		if err != nil {
+			return fmt.Errorf("list dir: %w", err)
+		}
+
+		files = append(files, dirFiles...)
+	}
+
+	for lib := range libs.Libs() {
+		files = append(files, lib)
+	}
+
+	maxFileSize := cfg.MaxFileSize * bytesPerMB
+	maxSize := cfg.MaxSize * bytesPerMB
+
+	var total uint64
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("stat: %w", err)
+		}
+
+		size := uint64(info.Size()) //nolint:gosec
+		total += size
+
+		if maxFileSize > 0 && size > maxFileSize {
+			return fmt.Errorf("%w: %s has %d bytes, max %d MB",
+				ErrFileSizeExceeded, file, size, cfg.MaxFileSize)
+		}
+
+		if maxSize > 0 && total > maxSize {
+			return fmt.Errorf("%w: %s adds %d bytes to %d bytes, max %d MB",
+				ErrInitramfsSizeExceeded, file, size, total-size, cfg.MaxSize)
+		}
+	}
+
+	return nil
+}
+
+// regularFilesIn returns the paths of all regular files in the directory tree
+// at the given path. Symbolic links are not followed.
+func regularFilesIn(dir string) ([]string, error) {
+	var files []string
+
+	walkFunc := func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+
+		return nil
+	}
+
+	err := filepath.WalkDir(dir, walkFunc)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return files, nil
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSizeBudget(t *testing.T) {
+	tempDir := t.TempDir()
+
+	writeFile := func(name string, sizeMB int64) string {
+		path := filepath.Join(tempDir, name)
+
+		require.NoError(t, os.WriteFile(path, nil, 0o600))
+		require.NoError(t, os.Truncate(path, sizeMB*bytesPerMB))
+
+		return path
+	}
+
+	binary := writeFile("bin.test", 2)
+	small := writeFile("small", 1)
+	big := writeFile("big", 5)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "dir/sub"), 0o755))
+
+	nested := writeFile("dir/sub/nested", 5)
+
+	tests := []struct {
+		name        string
+		cfg         virtrun.Initramfs
+		expectedErr error
+		errContains string
+	}{
+		{
+			name: "no budget",
+			cfg: virtrun.Initramfs{
+				Binary: binary,
+				Files:  []string{big},
+			},
+		},
+		{
+			name: "under budget",
+			cfg: virtrun.Initramfs{
+				Binary:      binary,
+				Files:       []string{small},
+				MaxFileSize: 2,
+				MaxSize:     3,
+			},
+		},
+		{
+			name: "file over budget",
+			cfg: virtrun.Initramfs{
+				Binary:      binary,
+				Files:       []string{small, big},
+				MaxFileSize: 4,
+			},
+			expectedErr: ErrFileSizeExceeded,
+			errContains: big,
+		},
+		{
+			name: "total over budget",
+			cfg: virtrun.Initramfs{
+				Binary:  binary,
+				Files:   []string{small},
+				Modules: []string{big},
+				MaxSize: 7,
+			},
+			expectedErr: ErrInitramfsSizeExceeded,
+			errContains: big,
+		},
+		{
+			name: "firmware over total budget",
+			cfg: virtrun.Initramfs{
+				Binary: binary,
+				Firmware: []virtrun.FileMapping{
+					{Source: small},
+				},
+				MaxSize: 2,
+			},
+			expectedErr: ErrInitramfsSizeExceeded,
+			errContains: small,
+		},
+		{
+			name: "file in dir over budget",
+			cfg: virtrun.Initramfs{
+				Binary: binary,
+				Dirs: []virtrun.FileMapping{
+					{Source: filepath.Join(tempDir, "dir")},
+				},
+				MaxFileSize: 4,
+			},
+			expectedErr: ErrFileSizeExceeded,
+			errContains: nested,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSizeBudget(context.Background(), tt.cfg)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			if tt.errContains != "" {
+				assert.ErrorContains(t, err, tt.errContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/aibor/virtrun/internal/qemu"
+)
+
+// smbiosList is a list of SMBIOS tables, each given like the QEMU "-smbios"
+// argument.
+type smbiosList []qemu.SMBIOS
+
+func (s *smbiosList) String() string {
+	tables := make([]string, 0, len(*s))
+
+	for _, smbios := range *s {
+		tables = append(tables, smbios.String())
+	}
+
+	return strings.Join(tables, " ")
+}
+
+func (s *smbiosList) Set(value string) error {
+	var smbios qemu.SMBIOS
+
+	err := smbios.Set(value)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	*s = append(*s, smbios)
+
+	return nil
+}
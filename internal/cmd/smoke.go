@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/aibor/virtrun/internal/virtrun"
+)
+
+// smokeTimeout is used if no timeout for the smoke marker is given, so a
+// guest without working console does not hang forever.
+const smokeTimeout = 30 * time.Second
+
+// runFunc runs a [virtrun.Spec]. It matches [virtrun.RunWithResult].
+type runFunc func(
+	ctx context.Context,
+	spec *virtrun.Spec,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) (*virtrun.Result, error)
+
+// runSmoke runs the built-in smoke binary with the given [virtrun.Spec].
+//
+// The binary of the spec is replaced by the smoke binary for the host
+// architecture. The run succeeds if the guest prints [virtrun.SmokeMarker].
+// On failure, a hint about the likely culprit is printed to stderr.
+func runSmoke(
+	ctx context.Context,
+	spec *virtrun.Spec,
+	runFn runFunc,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) error {
+	path, removeFn, err := virtrun.WriteSmokeBinary(sys.Native)
+	if err != nil {
+		return fmt.Errorf("smoke binary: %w", err)
+	}
+	defer removeFn() //nolint:errcheck
+
+	spec.Initramfs.Binary = path
+	spec.Initramfs.StandaloneInit = false
+	spec.Qemu.WaitForLine = regexp.MustCompile(
+		regexp.QuoteMeta(virtrun.SmokeMarker),
+	)
+
+	if spec.Qemu.WaitForLineTimeout == 0 {
+		spec.Qemu.WaitForLineTimeout = smokeTimeout
+	}
+
+	err = Validate(ctx, spec)
+	if err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+
+	arch := sys.Native
+	slog.Debug("Smoke test",
+		slog.String("arch", arch.String()),
+		slog.String("kvm", string(arch.KVMStatus())),
+	)
+
+	_, err = runFn(ctx, spec, stdin, stdout, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, "Smoke test failed")
+
+		if hint := smokeHint(err); hint != "" {
+			fmt.Fprintf(stderr, "Hint: %s\n", hint)
+		}
+
+		if !spec.Qemu.NoKVM {
+			fmt.Fprintf(stderr, "KVM: %s\n", arch.KVMStatus())
+		}
+
+		return fmt.Errorf("smoke: %w", err)
+	}
+
+	fmt.Fprintln(stdout, "Smoke test passed")
+
+	return nil
+}
+
+// smokeHint returns a hint about the likely culprit of a failed smoke run.
+// It returns an empty string if there is no hint for the error.
+func smokeHint(err error) string {
+	var cmdErr *qemu.CommandError
+
+	switch {
+	case errors.Is(err, virtrun.ErrConsoleNotSupported):
+		return "the kernel lacks the console driver of the transport. " +
+			"Try another -transport or kernel"
+	case errors.Is(err, qemu.ErrWaitForLineTimeout),
+		errors.Is(err, qemu.ErrGuestIdleTimeout):
+		return "no output from the guest. Check the -transport matches " +
+			"the kernel's console support"
+	case errors.Is(err, qemu.ErrWaitForLineNotFound),
+		errors.Is(err, qemu.ErrGuestNoExitCodeFound):
+		return "guest output is incomplete. Check the -transport matches " +
+			"the kernel's console support"
+	case errors.Is(err, qemu.ErrGuestPanic):
+		return "the kernel panicked. Check the kernel supports initramfs " +
+			"and the -machine type"
+	case errors.Is(err, qemu.ErrGuestOom):
+		return "the guest ran out of memory. Increase -memory"
+	case errors.As(err, &cmdErr) && !cmdErr.Guest:
+		return "QEMU failed. Check -qemu-bin and -machine, or try -nokvm " +
+			"if KVM is the issue"
+	default:
+		return ""
+	}
+}
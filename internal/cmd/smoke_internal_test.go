@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func smokeSpec(t *testing.T) *virtrun.Spec {
+	t.Helper()
+
+	kernel := filepath.Join(t.TempDir(), "vmlinuz")
+	require.NoError(t, os.WriteFile(kernel, []byte("kernel"), 0o600))
+
+	return &virtrun.Spec{
+		Qemu: virtrun.Qemu{
+			Kernel: kernel,
+		},
+	}
+}
+
+func TestRunSmoke(t *testing.T) {
+	spec := smokeSpec(t)
+
+	var binary string
+
+	fakeRun := func(
+		_ context.Context,
+		spec *virtrun.Spec,
+		_ io.Reader,
+		stdout, _ io.Writer,
+	) (*virtrun.Result, error) {
+		binary = spec.Initramfs.Binary
+
+		arch, err := sys.ReadELFArch(binary)
+		if err != nil {
+			return nil, err
+		}
+
+		if arch != sys.Native {
+			return nil, fmt.Errorf("%w: %s", sys.ErrArchNotSupported, arch)
+		}
+
+		if !spec.Qemu.WaitForLine.MatchString(virtrun.SmokeMarker) {
+			return nil, qemu.ErrWaitForLineNotFound
+		}
+
+		fmt.Fprintln(stdout, virtrun.SmokeMarker)
+
+		return &virtrun.Result{}, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	err := runSmoke(
+		context.Background(),
+		spec,
+		fakeRun,
+		nil,
+		&stdout,
+		&stderr,
+	)
+	require.NoError(t, err)
+
+	expected := virtrun.SmokeMarker + "\nSmoke test passed\n"
+	assert.Equal(t, expected, stdout.String())
+	assert.Empty(t, stderr.String())
+	assert.Equal(t, smokeTimeout, spec.Qemu.WaitForLineTimeout)
+	assert.NoFileExists(t, binary, "smoke binary should be removed")
+}
+
+func TestRunSmoke_Failure(t *testing.T) {
+	spec := smokeSpec(t)
+
+	fakeRun := func(
+		context.Context,
+		*virtrun.Spec,
+		io.Reader,
+		io.Writer,
+		io.Writer,
+	) (*virtrun.Result, error) {
+		return &virtrun.Result{}, &qemu.CommandError{
+			Err:   qemu.ErrGuestPanic,
+			Guest: true,
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	err := runSmoke(
+		context.Background(),
+		spec,
+		fakeRun,
+		nil,
+		&stdout,
+		&stderr,
+	)
+	require.ErrorIs(t, err, qemu.ErrGuestPanic)
+
+	assert.Empty(t, stdout.String())
+	assert.Contains(t, stderr.String(), "Hint: the kernel panicked")
+}
+
+func TestSmokeHint(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "console not supported",
+			err:      virtrun.ErrConsoleNotSupported,
+			expected: "console driver",
+		},
+		{
+			name:     "no output",
+			err:      &qemu.CommandError{Err: qemu.ErrWaitForLineTimeout},
+			expected: "no output",
+		},
+		{
+			name:     "incomplete output",
+			err:      qemu.ErrGuestNoExitCodeFound,
+			expected: "incomplete",
+		},
+		{
+			name:     "oom",
+			err:      qemu.ErrGuestOom,
+			expected: "-memory",
+		},
+		{
+			name:     "host error",
+			err:      &qemu.CommandError{Err: errors.New("exit status 1")},
+			expected: "-nokvm",
+		},
+		{
+			name: "unknown",
+			err:  errors.New("unknown"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hint := smokeHint(tt.err)
+
+			if tt.expected == "" {
+				assert.Empty(t, hint)
+			} else {
+				assert.Contains(t, hint, tt.expected)
+			}
+		})
+	}
+}
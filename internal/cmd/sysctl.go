@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// sysctlKeyRE matches valid sysctl names in dotted notation, like
+// "kernel.core_pattern".
+var sysctlKeyRE = regexp.MustCompile(`^[a-z0-9_-]+(\.[a-z0-9_-]+)+$`)
+
+// SysctlMap is a map of kernel parameters by their dotted keys. Values are
+// given as "KEY=VALUE". Later values replace earlier ones with the same key.
+type SysctlMap map[string]string
+
+func (m *SysctlMap) String() string {
+	sysctls := make([]string, 0, len(*m))
+
+	for _, key := range slices.Sorted(maps.Keys(*m)) {
+		sysctls = append(sysctls, key+"="+(*m)[key])
+	}
+
+	return strings.Join(sysctls, ",")
+}
+
+func (m *SysctlMap) Set(s string) error {
+	key, value, found := strings.Cut(s, "=")
+	if !found || !sysctlKeyRE.MatchString(key) {
+		return fmt.Errorf("%s: %w", s, ErrInvalidSysctl)
+	}
+
+	if *m == nil {
+		*m = make(SysctlMap)
+	}
+
+	(*m)[key] = value
+
+	return nil
+}
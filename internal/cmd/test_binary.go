@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/aibor/virtrun/internal/virtrun"
+)
+
+// goTestFlagPrefix is the prefix of all flags a Go test binary accepts.
+const goTestFlagPrefix = "-test."
+
+// ValidateTestBinary checks that the main binary is a Go test binary, if Go
+// test flags are given as init args or a coverage profile is requested.
+//
+// The check is skipped if [virtrun.Qemu.NoTestBinaryCheck] is set or the main
+// binary is read from stdin.
+func ValidateTestBinary(spec *virtrun.Spec) error {
+	needsTestBinary := hasGoTestFlag(spec.Qemu.InitArgs) ||
+		spec.Qemu.CoverProfile != ""
+
+	if spec.Qemu.NoTestBinaryCheck ||
+		spec.Initramfs.Binary == virtrun.StdinBinary ||
+		!needsTestBinary {
+		return nil
+	}
+
+	isTest, err := sys.IsGoTestBinary(spec.Initramfs.Binary)
+	if err != nil {
+		return fmt.Errorf("detect test binary: %w", err)
+	}
+
+	if !isTest {
+		return fmt.Errorf("%w: %s", ErrNotGoTestBinary, spec.Initramfs.Binary)
+	}
+
+	return nil
+}
+
+func hasGoTestFlag(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, goTestFlagPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasCoverProfileFlag returns true if the args contain the Go test flag for
+// the coverage profile, as given by "go test -coverprofile".
+func hasCoverProfileFlag(args []string) bool {
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, "=")
+		if name == goTestFlagPrefix+"coverprofile" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTestBinary(t *testing.T) {
+	testBinary, err := os.Executable()
+	require.NoError(t, err)
+
+	nonTestBinary := "../sys/testdata/bin/main"
+	testFlags := []string{"-test.v=true"}
+
+	tests := []struct {
+		name        string
+		binary      string
+		initArgs    []string
+		cover       string
+		noCheck     bool
+		expectedErr error
+	}{
+		{
+			name:     "test binary with test flags",
+			binary:   testBinary,
+			initArgs: testFlags,
+		},
+		{
+			name:   "non-test binary without test flags",
+			binary: nonTestBinary,
+		},
+		{
+			name:        "non-test binary with test flags",
+			binary:      nonTestBinary,
+			initArgs:    testFlags,
+			expectedErr: ErrNotGoTestBinary,
+		},
+		{
+			name:        "non-test binary with cover profile",
+			binary:      nonTestBinary,
+			cover:       "/tmp/cover.out",
+			expectedErr: ErrNotGoTestBinary,
+		},
+		{
+			name:     "non-test binary with check disabled",
+			binary:   nonTestBinary,
+			initArgs: testFlags,
+			noCheck:  true,
+		},
+		{
+			name:     "stdin binary",
+			binary:   virtrun.StdinBinary,
+			initArgs: testFlags,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &virtrun.Spec{
+				Qemu: virtrun.Qemu{
+					InitArgs:          tt.initArgs,
+					CoverProfile:      tt.cover,
+					NoTestBinaryCheck: tt.noCheck,
+				},
+				Initramfs: virtrun.Initramfs{
+					Binary: tt.binary,
+				},
+			}
+
+			err := ValidateTestBinary(spec)
+			assert.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
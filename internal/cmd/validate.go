@@ -5,18 +5,29 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/aibor/virtrun/internal/virtrun"
 )
 
 // Validate file parameters of the given [Spec].
-func Validate(spec *virtrun.Spec) error {
+//
+// The files added to the initramfs are checked against the size budget, see
+// [ValidateSizeBudget].
+func Validate(ctx context.Context, spec *virtrun.Spec) error {
 	err := ValidateFilePath(spec.Qemu.Kernel)
 	if err != nil {
 		return fmt.Errorf("kernel file: %w", err)
 	}
 
+	for _, file := range spec.Initramfs.Binaries {
+		err := ValidateFilePath(file)
+		if err != nil {
+			return fmt.Errorf("additional binary: %w", err)
+		}
+	}
+
 	for _, file := range spec.Initramfs.Files {
 		err := ValidateFilePath(file)
 		if err != nil {
@@ -24,6 +35,48 @@ func Validate(spec *virtrun.Spec) error {
 		}
 	}
 
+	for _, mapping := range spec.Initramfs.NamedFiles {
+		err := ValidateFilePath(mapping.Source)
+		if err != nil {
+			return fmt.Errorf("additional file: %w", err)
+		}
+	}
+
+	for _, mapping := range spec.Initramfs.Dirs {
+		err := ValidateDirPath(mapping.Source)
+		if err != nil {
+			return fmt.Errorf("additional dir: %w", err)
+		}
+	}
+
+	for _, dir := range spec.Initramfs.AllowedSourceDirs {
+		err := ValidateDirPath(dir)
+		if err != nil {
+			return fmt.Errorf("allowed source dir: %w", err)
+		}
+	}
+
+	for _, mapping := range spec.Initramfs.Trees {
+		err := ValidateDirPath(mapping.Source)
+		if err != nil {
+			return fmt.Errorf("tree: %w", err)
+		}
+	}
+
+	for _, dir := range spec.Initramfs.Overlays {
+		err := ValidateDirPath(dir)
+		if err != nil {
+			return fmt.Errorf("overlay: %w", err)
+		}
+	}
+
+	for _, share := range spec.Qemu.Shares {
+		err := ValidateDirPath(share.HostPath)
+		if err != nil {
+			return fmt.Errorf("share: %w", err)
+		}
+	}
+
 	for _, file := range spec.Initramfs.Modules {
 		err := ValidateFilePath(file)
 		if err != nil {
@@ -31,10 +84,50 @@ func Validate(spec *virtrun.Spec) error {
 		}
 	}
 
-	err = ValidateFilePath(spec.Initramfs.Binary)
+	for _, mapping := range spec.Initramfs.Firmware {
+		err := ValidateFilePath(mapping.Source)
+		if err != nil {
+			return fmt.Errorf("firmware: %w", err)
+		}
+	}
+
+	for _, file := range spec.Initramfs.PrependArchives {
+		err := ValidateFilePath(file)
+		if err != nil {
+			return fmt.Errorf("prepend archive: %w", err)
+		}
+	}
+
+	if spec.Initramfs.InitBinary != "" {
+		err := ValidateFilePath(spec.Initramfs.InitBinary)
+		if err != nil {
+			return fmt.Errorf("init binary: %w", err)
+		}
+	}
+
+	if spec.Initramfs.Stdin != "" {
+		err := ValidateFilePath(spec.Initramfs.Stdin)
+		if err != nil {
+			return fmt.Errorf("stdin file: %w", err)
+		}
+	}
+
+	if spec.Initramfs.Binary != virtrun.StdinBinary {
+		err = ValidateFilePath(spec.Initramfs.Binary)
+		if err != nil {
+			return fmt.Errorf("main binary: %w", err)
+		}
+	}
+
+	err = ValidateTestBinary(spec)
 	if err != nil {
 		return fmt.Errorf("main binary: %w", err)
 	}
 
+	err = ValidateSizeBudget(ctx, spec.Initramfs)
+	if err != nil {
+		return fmt.Errorf("size budget: %w", err)
+	}
+
 	return nil
 }
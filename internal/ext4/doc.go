@@ -0,0 +1,12 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package ext4 provides a minimal read-only ext4 file system reader. It is
+// intended for extracting files from disk images written by the guest
+// without the need to mount them on the host, which requires privileges.
+//
+// Only the common cases are supported: regular files and directories using
+// extent trees. Files using inline data or legacy block maps are rejected.
+// The [FS] implements [fs.FS], so files can be extracted with [os.CopyFS].
+package ext4
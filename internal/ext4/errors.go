@@ -0,0 +1,24 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package ext4
+
+import "errors"
+
+var (
+	// ErrNoExt4 is returned if the image does not contain an ext4 file
+	// system.
+	ErrNoExt4 = errors.New("no ext4 file system")
+
+	// ErrUnsupported is returned if a feature of the file system or an inode
+	// is not supported.
+	ErrUnsupported = errors.New("unsupported")
+
+	// ErrNotDir is returned if a directory is expected but the file is
+	// something else.
+	ErrNotDir = errors.New("not a directory")
+
+	// ErrCorrupted is returned if on-disk structures are inconsistent.
+	ErrCorrupted = errors.New("corrupted file system")
+)
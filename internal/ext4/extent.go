@@ -0,0 +1,179 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package ext4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const (
+	extentMagic      = 0xF30A
+	extentHeaderSize = 12
+	extentEntrySize  = 12
+
+	// Extents longer than this are uninitialized and read as zeros.
+	extentMaxInitLen = 32768
+
+	// Limits the tree depth to prevent loops in corrupted images.
+	extentMaxDepth = 5
+)
+
+// extent maps a range of logical file blocks to physical blocks.
+type extent struct {
+	logical  int64
+	length   int64
+	physical int64
+	uninit   bool
+}
+
+// readExtents collects all leaf extents of the tree rooted in the given node
+// data.
+func (f *FS) readExtents(data []byte, depth int) ([]extent, error) {
+	le := binary.LittleEndian
+
+	if len(data) < extentHeaderSize || le.Uint16(data) != extentMagic {
+		return nil, fmt.Errorf("extent header: %w", ErrCorrupted)
+	}
+
+	entries := int(le.Uint16(data[2:]))
+	treeDepth := int(le.Uint16(data[6:]))
+
+	if depth > extentMaxDepth ||
+		len(data) < extentHeaderSize+entries*extentEntrySize {
+		return nil, fmt.Errorf("extent tree: %w", ErrCorrupted)
+	}
+
+	var extents []extent
+
+	for idx := range entries {
+		entry := data[extentHeaderSize+idx*extentEntrySize:]
+
+		if treeDepth == 0 {
+			length := int64(le.Uint16(entry[4:]))
+			uninit := length > extentMaxInitLen
+
+			if uninit {
+				length -= extentMaxInitLen
+			}
+
+			start := int64(le.Uint16(entry[6:]))<<32 |
+				int64(le.Uint32(entry[8:]))
+
+			extents = append(extents, extent{
+				logical:  int64(le.Uint32(entry)),
+				length:   length,
+				physical: start,
+				uninit:   uninit,
+			})
+
+			continue
+		}
+
+		leaf := int64(le.Uint16(entry[8:]))<<32 | int64(le.Uint32(entry[4:]))
+		block := make([]byte, f.blockSize)
+
+		_, err := f.r.ReadAt(block, leaf*f.blockSize)
+		if err != nil {
+			return nil, fmt.Errorf("read extent node: %w", err)
+		}
+
+		children, err := f.readExtents(block, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		extents = append(extents, children...)
+	}
+
+	return extents, nil
+}
+
+// extentReader reads file data mapped by extents. Holes and uninitialized
+// extents read as zeros.
+type extentReader struct {
+	fsys    *FS
+	extents []extent
+	size    int64
+}
+
+var _ io.ReaderAt = (*extentReader)(nil)
+
+func newExtentReader(
+	fsys *FS,
+	extents []extent,
+	size int64,
+) *extentReader {
+	slices.SortFunc(extents, func(a, b extent) int {
+		return int(a.logical - b.logical)
+	})
+
+	return &extentReader{
+		fsys:    fsys,
+		extents: extents,
+		size:    size,
+	}
+}
+
+func (r *extentReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	var err error
+
+	if remaining := r.size - off; int64(len(p)) > remaining {
+		p = p[:remaining]
+		err = io.EOF
+	}
+
+	read := 0
+	for read < len(p) {
+		n, readErr := r.readBlock(p[read:], off+int64(read))
+		if readErr != nil {
+			return read, readErr
+		}
+
+		read += n
+	}
+
+	return read, err
+}
+
+// readBlock reads up to the end of the block the offset is in.
+func (r *extentReader) readBlock(p []byte, off int64) (int, error) {
+	blockSize := r.fsys.blockSize
+	logical := off / blockSize
+	inBlock := off % blockSize
+
+	n := min(int64(len(p)), blockSize-inBlock)
+	p = p[:n]
+
+	for _, ext := range r.extents {
+		if logical < ext.logical || logical >= ext.logical+ext.length {
+			continue
+		}
+
+		if ext.uninit {
+			break
+		}
+
+		physical := (ext.physical+logical-ext.logical)*blockSize + inBlock
+
+		_, err := r.fsys.r.ReadAt(p, physical)
+		if err != nil {
+			return 0, fmt.Errorf("read block: %w", err)
+		}
+
+		return int(n), nil
+	}
+
+	// Not mapped or uninitialized, so it reads as zeros.
+	clear(p)
+
+	return int(n), nil
+}
@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package ext4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+const (
+	superblockOffset = 1024
+	superblockSize   = 1024
+	superblockMagic  = 0xEF53
+
+	rootInode = 2
+
+	minBlockSize    = 1024
+	maxLogBlockSize = 6 // 64 KiB
+	minInodeSize    = 128
+	minDescSize     = 32
+
+	incompat64Bit  = 0x80
+	incompatMetaBG = 0x10
+)
+
+var (
+	_ fs.FS        = (*FS)(nil)
+	_ fs.ReadDirFS = (*FS)(nil)
+)
+
+// FS is a read-only ext4 file system.
+type FS struct {
+	r io.ReaderAt
+
+	blockSize      int64
+	inodeSize      int64
+	inodesPerGroup uint32
+	descSize       int64
+	descTableStart int64
+}
+
+// New reads the superblock from the given image and returns a new [FS].
+func New(r io.ReaderAt) (*FS, error) {
+	sb := make([]byte, superblockSize)
+
+	_, err := r.ReadAt(sb, superblockOffset)
+	if err != nil {
+		return nil, fmt.Errorf("read superblock: %w", err)
+	}
+
+	le := binary.LittleEndian
+
+	if le.Uint16(sb[56:]) != superblockMagic {
+		return nil, ErrNoExt4
+	}
+
+	incompat := le.Uint32(sb[96:])
+	if incompat&incompatMetaBG != 0 {
+		return nil, fmt.Errorf("meta_bg: %w", ErrUnsupported)
+	}
+
+	logBlockSize := le.Uint32(sb[24:])
+	if logBlockSize > maxLogBlockSize {
+		return nil, fmt.Errorf("block size: %w", ErrCorrupted)
+	}
+
+	fsys := &FS{
+		r:              r,
+		blockSize:      minBlockSize << logBlockSize,
+		inodeSize:      minInodeSize,
+		inodesPerGroup: le.Uint32(sb[40:]),
+		descSize:       minDescSize,
+	}
+
+	// Revision 0 has fixed inode size.
+	if le.Uint32(sb[76:]) > 0 {
+		fsys.inodeSize = int64(le.Uint16(sb[88:]))
+	}
+
+	// The inode size is a power of two that fits into a block.
+	if fsys.inodeSize < minInodeSize || fsys.inodeSize > fsys.blockSize ||
+		fsys.inodeSize&(fsys.inodeSize-1) != 0 {
+		return nil, fmt.Errorf("inode size: %w", ErrCorrupted)
+	}
+
+	if incompat&incompat64Bit != 0 {
+		fsys.descSize = int64(le.Uint16(sb[254:]))
+	}
+
+	if fsys.inodesPerGroup == 0 || fsys.descSize < minDescSize {
+		return nil, ErrCorrupted
+	}
+
+	// The group descriptor table starts at the block after the superblock.
+	firstDataBlock := int64(le.Uint32(sb[20:]))
+	fsys.descTableStart = (firstDataBlock + 1) * fsys.blockSize
+
+	return fsys, nil
+}
+
+// Open opens the named file.
+func (f *FS) Open(name string) (fs.File, error) {
+	node, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.open()
+}
+
+// ReadDir reads the named directory and returns a list of directory entries
+// sorted by filename.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	node, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := node.readDir()
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	return entries, nil
+}
+
+// lookup resolves the given path starting at the root directory. Symbolic
+// links are not followed.
+func (f *FS) lookup(op, name string) (*node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	current, err := f.node(rootInode, ".")
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	if name == "." {
+		return current, nil
+	}
+
+	for _, elem := range strings.Split(name, "/") {
+		current, err = current.child(elem)
+		if err != nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+	}
+
+	return current, nil
+}
+
+// node reads the inode with the given number.
+func (f *FS) node(number uint32, name string) (*node, error) {
+	if number == 0 {
+		return nil, ErrCorrupted
+	}
+
+	group := int64((number - 1) / f.inodesPerGroup)
+	index := int64((number - 1) % f.inodesPerGroup)
+
+	desc := make([]byte, f.descSize)
+
+	_, err := f.r.ReadAt(desc, f.descTableStart+group*f.descSize)
+	if err != nil {
+		return nil, fmt.Errorf("read group descriptor: %w", err)
+	}
+
+	le := binary.LittleEndian
+
+	inodeTable := int64(le.Uint32(desc[8:]))
+	if f.descSize > minDescSize {
+		inodeTable |= int64(le.Uint32(desc[40:])) << 32
+	}
+
+	raw := make([]byte, f.inodeSize)
+
+	offset := inodeTable*f.blockSize + index*f.inodeSize
+
+	_, err = f.r.ReadAt(raw, offset)
+	if err != nil {
+		return nil, fmt.Errorf("read inode %d: %w", number, err)
+	}
+
+	return &node{
+		fsys:  f,
+		name:  name,
+		inode: parseInode(raw),
+	}, nil
+}
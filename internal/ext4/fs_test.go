@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package ext4_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aibor/virtrun/internal/ext4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readImage(t *testing.T, name string) []byte {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = file.Close() })
+
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	return data
+}
+
+func openImage(t *testing.T, name string) *ext4.FS {
+	t.Helper()
+
+	fsys, err := ext4.New(bytes.NewReader(readImage(t, name)))
+	require.NoError(t, err)
+
+	return fsys
+}
+
+func expectedSeq() string {
+	var builder strings.Builder
+	for i := 1; i <= 5000; i++ {
+		fmt.Fprintln(&builder, i)
+	}
+
+	return builder.String()
+}
+
+func expectedSparse() string {
+	data := make([]byte, 14*4096+len("chunk 7\n"))
+	for i := range 8 {
+		copy(data[i*2*4096:], fmt.Sprintf("chunk %d\n", i))
+	}
+
+	return string(data)
+}
+
+func TestFS(t *testing.T) {
+	for _, name := range []string{
+		"image-1024.ext4.gz",
+		"image-4096.ext4.gz",
+	} {
+		t.Run(name, func(t *testing.T) {
+			fsys := openImage(t, name)
+
+			err := fstest.TestFS(fsys,
+				"hello.txt",
+				"empty",
+				"emptydir",
+				"seq.txt",
+				"sparse",
+				"dir/nested/deep.txt",
+			)
+			require.NoError(t, err)
+
+			expected := map[string]string{
+				"hello.txt":           "hello world\n",
+				"empty":               "",
+				"seq.txt":             expectedSeq(),
+				"sparse":              expectedSparse(),
+				"dir/nested/deep.txt": "deep\n",
+			}
+
+			dst := t.TempDir()
+			require.NoError(t, os.CopyFS(dst, fsys))
+
+			for path, content := range expected {
+				actual, err := os.ReadFile(filepath.Join(dst, path))
+				require.NoError(t, err, path)
+				assert.Equal(t, content, string(actual), path)
+			}
+		})
+	}
+}
+
+func TestFS_Errors(t *testing.T) {
+	fsys := openImage(t, "image-4096.ext4.gz")
+
+	_, err := fsys.Open("nonexistent")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = fsys.Open("hello.txt/sub")
+	require.ErrorIs(t, err, ext4.ErrNotDir)
+
+	_, err = fsys.Open("/hello.txt")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestNew_NoExt4(t *testing.T) {
+	_, err := ext4.New(bytes.NewReader(make([]byte, 4096)))
+	require.ErrorIs(t, err, ext4.ErrNoExt4)
+}
+
+func TestNew_Corrupted(t *testing.T) {
+	const (
+		logBlockSizeOffset = 1024 + 24
+		inodeSizeOffset    = 1024 + 88
+	)
+
+	tests := []struct {
+		name   string
+		offset int
+		value  []byte
+	}{
+		{
+			name:   "block size too big",
+			offset: logBlockSizeOffset,
+			value:  []byte{7, 0, 0, 0},
+		},
+		{
+			name:   "block size overflow",
+			offset: logBlockSizeOffset,
+			value:  []byte{0xff, 0xff, 0xff, 0xff},
+		},
+		{
+			name:   "inode size zero",
+			offset: inodeSizeOffset,
+			value:  []byte{0, 0},
+		},
+		{
+			name:   "inode size too small",
+			offset: inodeSizeOffset,
+			value:  []byte{64, 0},
+		},
+		{
+			name:   "inode size bigger than block",
+			offset: inodeSizeOffset,
+			value:  []byte{0, 0x20},
+		},
+		{
+			name:   "inode size not power of two",
+			offset: inodeSizeOffset,
+			value:  []byte{200, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := readImage(t, "image-4096.ext4.gz")
+			copy(data[tt.offset:], tt.value)
+
+			_, err := ext4.New(bytes.NewReader(data))
+			require.ErrorIs(t, err, ext4.ErrCorrupted)
+		})
+	}
+}
@@ -0,0 +1,9 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+//go:build testdata
+
+//go:generate sh testdata/gen.sh
+
+package ext4_test
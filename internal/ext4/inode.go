@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package ext4
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"time"
+)
+
+const (
+	modeTypeMask = 0xF000
+	modeFIFO     = 0x1000
+	modeChar     = 0x2000
+	modeDir      = 0x4000
+	modeBlock    = 0x6000
+	modeRegular  = 0x8000
+	modeSymlink  = 0xA000
+	modeSocket   = 0xC000
+
+	modeSetuid = 0x800
+	modeSetgid = 0x400
+	modeSticky = 0x200
+
+	flagExtents    = 0x80000
+	flagInlineData = 0x10000000
+
+	inodeBlockSize = 60
+)
+
+// inode is the relevant subset of an on-disk ext4 inode.
+type inode struct {
+	mode  uint16
+	size  int64
+	mtime uint32
+	flags uint32
+	block [inodeBlockSize]byte
+}
+
+func parseInode(raw []byte) inode {
+	le := binary.LittleEndian
+
+	ino := inode{
+		mode:  le.Uint16(raw[0:]),
+		size:  int64(le.Uint32(raw[4:])) | int64(le.Uint32(raw[108:]))<<32,
+		mtime: le.Uint32(raw[16:]),
+		flags: le.Uint32(raw[32:]),
+	}
+
+	copy(ino.block[:], raw[40:40+inodeBlockSize])
+
+	return ino
+}
+
+// fileMode converts the on-disk mode into a [fs.FileMode].
+func (i inode) fileMode() fs.FileMode {
+	mode := fs.FileMode(i.mode) & fs.ModePerm
+
+	types := map[uint16]fs.FileMode{
+		modeFIFO:    fs.ModeNamedPipe,
+		modeChar:    fs.ModeDevice | fs.ModeCharDevice,
+		modeDir:     fs.ModeDir,
+		modeBlock:   fs.ModeDevice,
+		modeSymlink: fs.ModeSymlink,
+		modeSocket:  fs.ModeSocket,
+	}
+	mode |= types[i.mode&modeTypeMask]
+
+	if i.mode&modeSetuid != 0 {
+		mode |= fs.ModeSetuid
+	}
+
+	if i.mode&modeSetgid != 0 {
+		mode |= fs.ModeSetgid
+	}
+
+	if i.mode&modeSticky != 0 {
+		mode |= fs.ModeSticky
+	}
+
+	return mode
+}
+
+func (i inode) isDir() bool {
+	return i.mode&modeTypeMask == modeDir
+}
+
+func (i inode) isRegular() bool {
+	return i.mode&modeTypeMask == modeRegular
+}
+
+func (i inode) modTime() time.Time {
+	return time.Unix(int64(i.mtime), 0)
+}
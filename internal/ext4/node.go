@@ -0,0 +1,263 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package ext4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+const dirEntryHeaderSize = 8
+
+// dirEntryTypes maps the file type field of directory entries.
+var dirEntryTypes = map[byte]fs.FileMode{
+	1: 0,
+	2: fs.ModeDir,
+	3: fs.ModeDevice | fs.ModeCharDevice,
+	4: fs.ModeDevice,
+	5: fs.ModeNamedPipe,
+	6: fs.ModeSocket,
+	7: fs.ModeSymlink,
+}
+
+var (
+	_ fs.FileInfo    = (*fileInfo)(nil)
+	_ fs.DirEntry    = (*dirEntry)(nil)
+	_ fs.File        = (*file)(nil)
+	_ io.ReaderAt    = (*file)(nil)
+	_ fs.ReadDirFile = (*dir)(nil)
+)
+
+// node is a named inode.
+type node struct {
+	fsys  *FS
+	name  string
+	inode inode
+}
+
+// dataReader returns a reader for the data blocks of the inode.
+func (n *node) dataReader() (*extentReader, error) {
+	switch {
+	case n.inode.flags&flagInlineData != 0:
+		return nil, fmt.Errorf("inline data: %w", ErrUnsupported)
+	case n.inode.flags&flagExtents == 0:
+		return nil, fmt.Errorf("block map: %w", ErrUnsupported)
+	}
+
+	extents, err := n.fsys.readExtents(n.inode.block[:], 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return newExtentReader(n.fsys, extents, n.inode.size), nil
+}
+
+func (n *node) open() (fs.File, error) {
+	info := &fileInfo{name: n.name, inode: n.inode}
+
+	switch {
+	case n.inode.isDir():
+		entries, err := n.readDir()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: n.name, Err: err}
+		}
+
+		return &dir{info: info, entries: entries}, nil
+	case n.inode.isRegular():
+		reader, err := n.dataReader()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: n.name, Err: err}
+		}
+
+		return &file{
+			info:          info,
+			SectionReader: io.NewSectionReader(reader, 0, n.inode.size),
+		}, nil
+	default:
+		fileType := info.Mode().Type()
+		err := fmt.Errorf("file type %s: %w", fileType, ErrUnsupported)
+
+		return nil, &fs.PathError{Op: "open", Path: n.name, Err: err}
+	}
+}
+
+// readDir returns all entries of the directory except "." and "..", sorted
+// by name.
+func (n *node) readDir() ([]fs.DirEntry, error) {
+	if !n.inode.isDir() {
+		return nil, ErrNotDir
+	}
+
+	reader, err := n.dataReader()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, n.inode.size)
+
+	_, err = reader.ReadAt(data, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	le := binary.LittleEndian
+
+	var entries []fs.DirEntry
+
+	for offset := 0; offset+dirEntryHeaderSize <= len(data); {
+		number := le.Uint32(data[offset:])
+		recLen := int(le.Uint16(data[offset+4:]))
+		nameLen := int(data[offset+6])
+		fileType := data[offset+7]
+
+		if recLen < dirEntryHeaderSize ||
+			offset+dirEntryHeaderSize+nameLen > len(data) {
+			return nil, fmt.Errorf("directory entry: %w", ErrCorrupted)
+		}
+
+		name := string(data[offset+8 : offset+8+nameLen])
+		offset += recLen
+
+		// Unused entries, checksum tails and hash tree nodes have inode 0.
+		if number == 0 || name == "." || name == ".." {
+			continue
+		}
+
+		entry := &dirEntry{
+			fsys:   n.fsys,
+			name:   name,
+			number: number,
+		}
+
+		// The type is only present with the filetype feature. Otherwise, it
+		// is read from the inode on demand.
+		if typ, exists := dirEntryTypes[fileType]; exists {
+			entry.typ = &typ
+		}
+
+		entries = append(entries, entry)
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	return entries, nil
+}
+
+// child looks up the directory entry with the given name.
+func (n *node) child(name string) (*node, error) {
+	entries, err := n.readDir()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.Name() == name {
+			return entry.(*dirEntry).node() //nolint:forcetypeassert
+		}
+	}
+
+	return nil, fs.ErrNotExist
+}
+
+type dirEntry struct {
+	fsys   *FS
+	name   string
+	number uint32
+	typ    *fs.FileMode
+}
+
+func (e *dirEntry) node() (*node, error) {
+	return e.fsys.node(e.number, e.name)
+}
+
+func (e *dirEntry) Name() string   { return e.name }
+func (e *dirEntry) String() string { return fs.FormatDirEntry(e) }
+
+func (e *dirEntry) IsDir() bool {
+	return e.Type().IsDir()
+}
+
+// Type returns the type bits of the entry.
+func (e *dirEntry) Type() fs.FileMode {
+	if e.typ != nil {
+		return *e.typ
+	}
+
+	info, err := e.Info()
+	if err != nil {
+		return fs.ModeIrregular
+	}
+
+	return info.Mode().Type()
+}
+
+func (e *dirEntry) Info() (fs.FileInfo, error) {
+	node, err := e.node()
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileInfo{name: e.name, inode: node.inode}, nil
+}
+
+type fileInfo struct {
+	name  string
+	inode inode
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.inode.size }
+func (i *fileInfo) Mode() fs.FileMode  { return i.inode.fileMode() }
+func (i *fileInfo) ModTime() time.Time { return i.inode.modTime() }
+func (i *fileInfo) IsDir() bool        { return i.inode.isDir() }
+func (*fileInfo) Sys() any             { return nil }
+func (i *fileInfo) String() string     { return fs.FormatFileInfo(i) }
+
+type file struct {
+	*io.SectionReader
+	info *fileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (*file) Close() error                 { return nil }
+
+type dir struct {
+	info    *fileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (*dir) Close() error                 { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *dir) ReadDir(count int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	count = min(count, len(remaining))
+	d.offset += count
+
+	return remaining[:count], nil
+}
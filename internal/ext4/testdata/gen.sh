@@ -0,0 +1,36 @@
+#!/bin/sh
+# SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+#
+# SPDX-License-Identifier: GPL-3.0-or-later
+
+# Generates the ext4 image fixtures. Requires mkfs.ext4 from e2fsprogs.
+
+set -eu
+
+cd "$(dirname "$0")"
+
+src="$(mktemp -d)"
+trap 'rm -rf "$src"' EXIT
+
+mkdir -p "$src/dir/nested" "$src/emptydir"
+printf 'hello world\n' > "$src/hello.txt"
+printf 'deep\n' > "$src/dir/nested/deep.txt"
+: > "$src/empty"
+seq 1 5000 > "$src/seq.txt"
+
+# Data chunks separated by holes result in more extents than fit into the
+# inode, so an extent tree with index nodes is required.
+for i in 0 1 2 3 4 5 6 7; do
+	printf 'chunk %d\n' "$i" |
+		dd of="$src/sparse" bs=4096 seek=$((i * 2)) conv=notrunc 2>/dev/null
+done
+
+for bs in 1024 4096; do
+	img="image-$bs.ext4"
+	rm -f "$img" "$img.gz"
+	E2FSPROGS_FAKE_TIME=1700000000 mkfs.ext4 -q -F -b "$bs" \
+		-U 6f0c8f5e-3c59-4f4e-9a0f-0e7e1b1c6a11 \
+		-E root_owner=0:0,hash_seed=6f0c8f5e-3c59-4f4e-9a0f-0e7e1b1c6a11 \
+		-d "$src" "$img" 4M
+	gzip -9 -n "$img"
+done
@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"time"
 
 	"github.com/cavaliergopher/cpio"
 )
@@ -27,6 +28,11 @@ func NewCPIOFSWriter(w io.Writer) *CPIOFSWriter {
 //
 // It walks the directory tree starting at the root of the filesystem adding
 // each file to the tar archive while maintaining the directory structure.
+// All files are owned by uid and gid 0 and have no modification time, unless
+// the [fs.FileInfo] of a file is backed by a [cpio.Header]. This applies to
+// any [fs.FS], so modification times of host files are never kept. As the
+// walk is in lexical order, the same file tree always results in the same
+// archive.
 func (w *CPIOFSWriter) AddFS(fsys fs.FS) error {
 	return fs.WalkDir(fsys, ".", func( //nolint:wrapcheck
 		name string, d fs.DirEntry, err error,
@@ -53,6 +59,16 @@ func (w *CPIOFSWriter) AddFS(fsys fs.FS) error {
 		// archive.
 		header.Name = name
 
+		// The guest expects all files to be owned by root, independent of
+		// the user building the archive. Modification times are dropped, so
+		// the archive is reproducible. Only headers copied from another
+		// archive carry explicit ownership and times.
+		if _, explicit := info.Sys().(*cpio.Header); !explicit {
+			header.Uid = 0
+			header.Guid = 0
+			header.ModTime = time.Time{}
+		}
+
 		err = w.WriteHeader(header)
 		if err != nil {
 			return &PathError{
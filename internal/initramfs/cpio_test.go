@@ -10,8 +10,10 @@ import (
 	"io"
 	"io/fs"
 	"slices"
+	"syscall"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"github.com/aibor/virtrun/internal/initramfs"
 	"github.com/cavaliergopher/cpio"
@@ -79,3 +81,79 @@ func TestCPIOFSWriter_AddFS(t *testing.T) {
 
 	assert.Equal(t, sourceFS, extractedFS)
 }
+
+func TestCPIOFSWriter_AddFS_Ownership(t *testing.T) {
+	sourceFS := fstest.MapFS{
+		"host": &fstest.MapFile{
+			Data: []byte("content"),
+			Sys:  &syscall.Stat_t{Uid: 1000, Gid: 1000},
+		},
+		"explicit": &fstest.MapFile{
+			Data: []byte("content"),
+			Sys: &cpio.Header{
+				Name: "explicit",
+				Mode: cpio.TypeReg,
+				Size: 7,
+				Uid:  42,
+				Guid: 43,
+			},
+		},
+	}
+
+	var archive bytes.Buffer
+
+	w := initramfs.NewCPIOFSWriter(&archive)
+	require.NoError(t, w.AddFS(sourceFS))
+	require.NoError(t, w.Close())
+
+	owners := map[string][2]int{}
+
+	r := cpio.NewReader(&archive)
+
+	for {
+		hdr, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		require.NoError(t, err)
+
+		owners[hdr.Name] = [2]int{hdr.Uid, hdr.Guid}
+	}
+
+	expected := map[string][2]int{
+		".":        {0, 0},
+		"host":     {0, 0},
+		"explicit": {42, 43},
+	}
+
+	assert.Equal(t, expected, owners)
+}
+
+func TestCPIOFSWriter_AddFS_Reproducible(t *testing.T) {
+	write := func(modTime time.Time) []byte {
+		sourceFS := fstest.MapFS{
+			"dir/b": &fstest.MapFile{
+				Data:    []byte("b"),
+				ModTime: modTime,
+			},
+			"a": &fstest.MapFile{
+				Data:    []byte("a"),
+				ModTime: modTime,
+			},
+		}
+
+		var archive bytes.Buffer
+
+		w := initramfs.NewCPIOFSWriter(&archive)
+		require.NoError(t, w.AddFS(sourceFS))
+		require.NoError(t, w.Close())
+
+		return archive.Bytes()
+	}
+
+	first := write(time.Unix(1000, 0))
+	second := write(time.Unix(2000, 0))
+
+	assert.Equal(t, first, second, "archives should be identical")
+}
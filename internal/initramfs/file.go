@@ -149,6 +149,18 @@ func (f regularFile) open(info dirEntry) (fs.File, error) {
 	return o, nil
 }
 
+var _ file = (*permFile)(nil)
+
+// permFile is a [regularFile] with explicit permission bits.
+type permFile struct {
+	regularFile
+	perm fs.FileMode
+}
+
+func (f *permFile) mode() fs.FileMode {
+	return f.perm
+}
+
 var _ file = (*symbolicLink)(nil)
 
 type symbolicLink string
@@ -5,6 +5,7 @@
 package initramfs
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"path/filepath"
@@ -19,12 +20,15 @@ const (
 // FileOpenFunc returns an open [fs.File] or an error if opening fails.
 type FileOpenFunc func() (fs.File, error)
 
-// FSAdder defines the interface required to add files to a FS.
+// FSAdder defines the interface required to add files to a FS. Existing files
+// can be removed, so they can be replaced.
 type FSAdder interface {
 	Add(name string, openFn FileOpenFunc) error
+	AddMode(name string, perm fs.FileMode, openFn FileOpenFunc) error
 	Symlink(oldname, newname string) error
 	Mkdir(name string) error
 	MkdirAll(name string) error
+	RemoveAll(name string) error
 }
 
 var (
@@ -185,6 +189,35 @@ func (fsys *FS) Add(name string, openFn FileOpenFunc) error {
 	return nil
 }
 
+// AddMode creates a new regular file with the given name and permission bits.
+//
+// Same as [FS.Add], but files added with [FS.Add] always have the default
+// mode.
+func (fsys *FS) AddMode(
+	name string,
+	perm fs.FileMode,
+	openFn FileOpenFunc,
+) error {
+	if openFn == nil {
+		return &PathError{
+			Op:   "add",
+			Path: name,
+			Err:  fmt.Errorf("%w: openFunc is nil", ErrInvalidArgument),
+		}
+	}
+
+	err := fsys.add(name, &permFile{regularFile(openFn), perm.Perm()})
+	if err != nil {
+		return &PathError{
+			Op:   "add",
+			Path: name,
+			Err:  err,
+		}
+	}
+
+	return nil
+}
+
 // Symlink adds a new symbolic link that links to oldname at newname.
 //
 // It returns a [PathError] in case of errors.
@@ -203,6 +236,40 @@ func (fsys *FS) Symlink(oldname, newname string) error {
 	return nil
 }
 
+// RemoveAll removes the file with the given name along with anything it
+// contains.
+//
+// A symbolic link with the given name is removed itself, not its target. It
+// returns nil if the file does not exist. It returns a [PathError] in case of
+// errors.
+func (fsys *FS) RemoveAll(name string) error {
+	dirName, fileName := filepath.Split(clean(name))
+	if fileName == "" {
+		return &PathError{
+			Op:   "remove",
+			Path: name,
+			Err:  ErrFileInvalid,
+		}
+	}
+
+	parent, err := fsys.subDir(clean(dirName))
+	if errors.Is(err, ErrFileNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return &PathError{
+			Op:   "remove",
+			Path: name,
+			Err:  err,
+		}
+	}
+
+	delete(*parent, fileName)
+
+	return nil
+}
+
 func (fsys *FS) subDir(name string) (*directory, error) {
 	dEntry, err := fsys.find(name, symlinkDepth)
 	if err != nil {
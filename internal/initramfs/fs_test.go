@@ -131,6 +131,38 @@ func TestFS_Add(t *testing.T) {
 	}
 }
 
+func TestFS_AddMode(t *testing.T) {
+	testFS := fstest.MapFS{
+		"test": &fstest.MapFile{
+			Data: []byte("content"),
+			Mode: 0o600,
+		},
+	}
+
+	fsys := initramfs.New()
+
+	err := fsys.AddMode("script", 0o750, func() (fs.File, error) {
+		return testFS.Open("test")
+	})
+	require.NoError(t, err)
+
+	err = fsys.Add("default", func() (fs.File, error) {
+		return testFS.Open("test")
+	})
+	require.NoError(t, err)
+
+	info, err := fs.Stat(fsys, "script")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o750), info.Mode())
+
+	info, err = fs.Stat(fsys, "default")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o755), info.Mode())
+
+	err = fsys.AddMode("nil", 0o644, nil)
+	require.ErrorIs(t, err, initramfs.ErrInvalidArgument)
+}
+
 func TestFS_Mkdir(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -351,6 +383,86 @@ func TestFS_Symlink(t *testing.T) {
 	}
 }
 
+func TestFS_RemoveAll(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		prepare     func(fsys *initramfs.FS) error
+		expectedErr error
+	}{
+		{
+			name: "file",
+			path: "dir/file",
+			prepare: func(fsys *initramfs.FS) error {
+				return fsys.Add("dir/file", func() (fs.File, error) {
+					return nil, assert.AnError
+				})
+			},
+		},
+		{
+			name: "dir with content",
+			path: "dir",
+			prepare: func(fsys *initramfs.FS) error {
+				return fsys.MkdirAll("dir/sub")
+			},
+		},
+		{
+			name: "symlink to dir",
+			path: "link",
+			prepare: func(fsys *initramfs.FS) error {
+				return fsys.Symlink("dir", "link")
+			},
+		},
+		{
+			name: "not existing",
+			path: "dir/file",
+		},
+		{
+			name: "parent not a dir",
+			path: "file/sub",
+			prepare: func(fsys *initramfs.FS) error {
+				return fsys.Add("file", func() (fs.File, error) {
+					return nil, assert.AnError
+				})
+			},
+			expectedErr: initramfs.ErrFileNotDir,
+		},
+		{
+			name:        "root",
+			path:        "/",
+			expectedErr: initramfs.ErrFileInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := initramfs.New()
+
+			err := fsys.MkdirAll("dir")
+			require.NoError(t, err)
+
+			if tt.prepare != nil {
+				err := tt.prepare(fsys)
+				require.NoError(t, err)
+			}
+
+			err = fsys.RemoveAll(tt.path)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			if tt.expectedErr != nil {
+				return
+			}
+
+			_, err = fsys.Lstat(tt.path)
+			require.ErrorIs(t, err, initramfs.ErrFileNotExist)
+
+			_, err = fsys.Lstat("dir")
+			assert.Equal(t, tt.path != "dir", err == nil,
+				"only the given path should be removed")
+		})
+	}
+}
+
 func TestFS_ReadLink(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -61,6 +61,12 @@ func (fsys *readLinkFS) Lstat(name string) (fs.FileInfo, error) {
 // [ReadLinkFS] themself (planned for 1.25). See
 // https://github.com/golang/go/issues/49580
 func WithReadLinkNoFollowOpen(fsys fs.FS) fs.FS {
+	// File systems implementing it already, like [fstest.MapFS] since Go
+	// 1.25, may follow symbolic links on Open.
+	if rlFS, ok := fsys.(ReadLinkFS); ok {
+		return rlFS
+	}
+
 	return &readLinkFS{
 		FS: fsys,
 		readLinkFn: func(name string) (string, error) {
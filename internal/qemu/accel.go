@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Accel is the QEMU accelerator, as used for the QEMU "-accel" argument.
+//
+// It is given as accelerator name with optional comma separated "key=value"
+// options, like "tcg" or "tcg,thread=multi". Only known accelerators are
+// supported: kvm, tcg, hvf, whpx, nvmm and xen.
+type Accel string
+
+// knownAccels are the accelerators supported by [Accel].
+var knownAccels = []string{"kvm", "tcg", "hvf", "whpx", "nvmm", "xen"}
+
+// String returns the [Accel] as string.
+func (a *Accel) String() string {
+	return string(*a)
+}
+
+// Set sets the [Accel] to the given value.
+//
+// It returns [ErrAccelInvalid] if the string is not valid.
+func (a *Accel) Set(s string) error {
+	accel := Accel(s)
+
+	if err := accel.validate(); err != nil {
+		return err
+	}
+
+	*a = accel
+
+	return nil
+}
+
+// validate checks if the accelerator is known and the options are given as
+// "key=value". The empty accelerator is valid.
+func (a *Accel) validate() error {
+	if *a == "" {
+		return nil
+	}
+
+	name, options, hasOptions := strings.Cut(string(*a), ",")
+	if !slices.Contains(knownAccels, name) {
+		return fmt.Errorf("%w: unknown accelerator %s", ErrAccelInvalid, name)
+	}
+
+	if !hasOptions {
+		return nil
+	}
+
+	for _, option := range strings.Split(options, ",") {
+		key, value, found := strings.Cut(option, "=")
+		if !found || key == "" || value == "" {
+			return fmt.Errorf("%w: option %q", ErrAccelInvalid, option)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu_test
+
+import (
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccel_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    qemu.Accel
+		expectedErr error
+	}{
+		{
+			input:    "kvm",
+			expected: "kvm",
+		},
+		{
+			input:    "tcg",
+			expected: "tcg",
+		},
+		{
+			input:    "tcg,thread=multi",
+			expected: "tcg,thread=multi",
+		},
+		{
+			input:    "tcg,thread=single,tb-size=512",
+			expected: "tcg,thread=single,tb-size=512",
+		},
+		{
+			input:       "qemu",
+			expectedErr: qemu.ErrAccelInvalid,
+		},
+		{
+			input:       ",thread=multi",
+			expectedErr: qemu.ErrAccelInvalid,
+		},
+		{
+			input:       "tcg,thread",
+			expectedErr: qemu.ErrAccelInvalid,
+		},
+		{
+			input:       "tcg,thread=",
+			expectedErr: qemu.ErrAccelInvalid,
+		},
+		{
+			input:       "tcg,",
+			expectedErr: qemu.ErrAccelInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var actual qemu.Accel
+
+			err := actual.Set(tt.input)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -10,19 +10,26 @@ import (
 	"strings"
 )
 
-// Argument is a QEMU argument with or without value.
+// Argument is a QEMU argument with or without value. A value may be empty,
+// which is different from no value at all.
 //
 // Its name might be marked to be unique in a list of [CommandSpec].
 type Argument struct {
 	name          string
 	value         string
+	hasValue      bool
 	nonUniqueName bool
 }
 
 // String implements [fmt.Stringer].
 func (a Argument) String() string {
 	s := "-" + a.name
-	if a.value != "" {
+
+	switch {
+	case !a.hasValue:
+	case a.value == "":
+		s += ` ""`
+	default:
 		s += " " + a.value
 	}
 
@@ -34,11 +41,17 @@ func (a Argument) Name() string {
 	return a.name
 }
 
-// Value returns the value of the [Argument].
+// Value returns the value of the [Argument]. It is empty if the [Argument]
+// has no value, see [Argument.HasValue].
 func (a Argument) Value() string {
 	return a.value
 }
 
+// HasValue returns if the [Argument] has a value, even if it is empty.
+func (a Argument) HasValue() bool {
+	return a.hasValue
+}
+
 // UniqueName returns if the name of the [Argument] must be unique in an
 // [CommandSpec] list.
 func (a Argument) UniqueName() bool {
@@ -47,39 +60,62 @@ func (a Argument) UniqueName() bool {
 
 // Equal compares the [Argument]s.
 //
-// If the name is marked unique, only names are
-// compared. Otherwise name and value are compared.
+// If the name is marked unique, only names are compared, so a flag collides
+// with an [Argument] of the same name with value. Otherwise name and value
+// are compared.
 func (a Argument) Equal(b Argument) bool {
 	if a.name != b.name {
 		return false
 	}
 
 	if a.nonUniqueName {
-		return a.value == b.value
+		return a.hasValue == b.hasValue && a.value == b.value
 	}
 
 	return true
 }
 
+// FlagArg returns a new [Argument] with the given name and without value
+// that is marked as unique and so can be used in [CommandSpec] only once.
+func FlagArg(name string) Argument {
+	return Argument{
+		name: name,
+	}
+}
+
 // UniqueArg returns a new [Argument] with the given name that is marked as
 // unique and so can be used in [CommandSpec] only once.
+//
+// The values are joined with commas. If no value is given, the [Argument]
+// has no value, like one created with [FlagArg]. An empty value is passed as
+// empty argument.
 func UniqueArg(name string, value ...string) Argument {
 	return Argument{
-		name:  name,
-		value: strings.Join(value, ","),
+		name:     name,
+		value:    strings.Join(value, ","),
+		hasValue: len(value) > 0,
 	}
 }
 
 // RepeatableArg returns a new [Argument] with the given name that is not
 // unique and so can be used in [CommandSpec] multiple times.
+//
+// The values are handled like with [UniqueArg].
 func RepeatableArg(name string, value ...string) Argument {
 	return Argument{
 		name:          name,
 		value:         strings.Join(value, ","),
+		hasValue:      len(value) > 0,
 		nonUniqueName: true,
 	}
 }
 
+// escapeOptionValue escapes the given value for use in a comma separated
+// option list of an [Argument]. QEMU escapes commas by doubling them.
+func escapeOptionValue(value string) string {
+	return strings.ReplaceAll(value, ",", ",,")
+}
+
 // BuildArgumentStrings compiles the [Argument]s to into a slice of strings
 // which can be used with [exec.Command].
 //
@@ -100,7 +136,7 @@ func BuildArgumentStrings(args []Argument) ([]string, error) {
 
 		s = append(s, "-"+arg.name)
 
-		if arg.value != "" {
+		if arg.hasValue {
 			s = append(s, arg.value)
 		}
 	}
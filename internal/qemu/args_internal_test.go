@@ -20,6 +20,26 @@ func TestArgsValue(t *testing.T) {
 	assert.Equal(t, "some", a.Value())
 }
 
+func TestArgsHasValue(t *testing.T) {
+	a := Argument{value: "", hasValue: true}
+	b := Argument{}
+
+	assert.True(t, a.HasValue())
+	assert.False(t, b.HasValue())
+}
+
+func TestArgsString(t *testing.T) {
+	assert.Equal(t, "-t", Argument{name: "t"}.String())
+	assert.Equal(t, `-t ""`, Argument{name: "t", hasValue: true}.String())
+	assert.Equal(t, "-t 5",
+		Argument{name: "t", value: "5", hasValue: true}.String())
+}
+
+func TestEscapeOptionValue(t *testing.T) {
+	assert.Equal(t, "/tmp/a", escapeOptionValue("/tmp/a"))
+	assert.Equal(t, "/tmp/a,,b,,,,c", escapeOptionValue("/tmp/a,b,,c"))
+}
+
 func TestArgsUniqueName(t *testing.T) {
 	a := Argument{nonUniqueName: false}
 	b := Argument{nonUniqueName: true}
@@ -65,6 +85,22 @@ func TestArgsEqual(t *testing.T) {
 			b:           Argument{name: "t", value: "5", nonUniqueName: true},
 			assertEqual: assert.True,
 		},
+		{
+			name:        "same name flag and empty value",
+			a:           Argument{name: "t"},
+			b:           Argument{name: "t", hasValue: true},
+			assertEqual: assert.True,
+		},
+		{
+			name: "same non-unique name flag and empty value",
+			a:    Argument{name: "t", nonUniqueName: true},
+			b: Argument{
+				name:          "t",
+				hasValue:      true,
+				nonUniqueName: true,
+			},
+			assertEqual: assert.False,
+		},
 	}
 
 	for _, tt := range tests {
@@ -80,21 +116,30 @@ func TestUniqueArg(t *testing.T) {
 		name          string
 		value         []string
 		expectedValue string
+		expectedHas   bool
 	}{
 		{
-			name:          "empty",
+			name:          "none",
 			value:         nil,
 			expectedValue: "",
 		},
+		{
+			name:          "empty",
+			value:         []string{""},
+			expectedValue: "",
+			expectedHas:   true,
+		},
 		{
 			name:          "single",
 			value:         []string{"value"},
 			expectedValue: "value",
+			expectedHas:   true,
 		},
 		{
 			name:          "multi",
 			value:         []string{"value", "more", "really"},
 			expectedValue: "value,more,really",
+			expectedHas:   true,
 		},
 	}
 
@@ -103,6 +148,7 @@ func TestUniqueArg(t *testing.T) {
 			expected := Argument{
 				name:          "name",
 				value:         tt.expectedValue,
+				hasValue:      tt.expectedHas,
 				nonUniqueName: false,
 			}
 
@@ -118,21 +164,30 @@ func TestRepeatableArg(t *testing.T) {
 		name          string
 		value         []string
 		expectedValue string
+		expectedHas   bool
 	}{
 		{
-			name:          "empty",
+			name:          "none",
 			value:         nil,
 			expectedValue: "",
 		},
+		{
+			name:          "empty",
+			value:         []string{""},
+			expectedValue: "",
+			expectedHas:   true,
+		},
 		{
 			name:          "single",
 			value:         []string{"value"},
 			expectedValue: "value",
+			expectedHas:   true,
 		},
 		{
 			name:          "multi",
 			value:         []string{"value", "more", "really"},
 			expectedValue: "value,more,really",
+			expectedHas:   true,
 		},
 	}
 
@@ -140,13 +195,21 @@ func TestRepeatableArg(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			expected := Argument{
 				name:          "name",
-				value:         "value",
+				value:         tt.expectedValue,
+				hasValue:      tt.expectedHas,
 				nonUniqueName: true,
 			}
 
-			actual := RepeatableArg("name", "value")
+			actual := RepeatableArg("name", tt.value...)
 
 			assert.Equal(t, expected, actual)
 		})
 	}
 }
+
+func TestFlagArg(t *testing.T) {
+	expected := Argument{name: "name"}
+
+	assert.Equal(t, expected, FlagArg("name"))
+	assert.Equal(t, expected, UniqueArg("name"))
+}
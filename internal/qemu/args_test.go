@@ -38,6 +38,32 @@ func TestBuildArgumentStrings(t *testing.T) {
 			},
 			requireError: require.NoError,
 		},
+		{
+			name: "bare flag",
+			args: []qemu.Argument{
+				qemu.FlagArg("snapshot"),
+				qemu.FlagArg("no-reboot"),
+			},
+			expect: []string{
+				"-snapshot",
+				"-no-reboot",
+			},
+			requireError: require.NoError,
+		},
+		{
+			name: "empty value",
+			args: []qemu.Argument{
+				qemu.UniqueArg("empty", ""),
+				qemu.RepeatableArg("more", ""),
+				qemu.RepeatableArg("more"),
+			},
+			expect: []string{
+				"-empty", "",
+				"-more", "",
+				"-more",
+			},
+			requireError: require.NoError,
+		},
 		{
 			name: "collision",
 			args: []qemu.Argument{
@@ -46,6 +72,30 @@ func TestBuildArgumentStrings(t *testing.T) {
 			},
 			requireError: require.Error,
 		},
+		{
+			name: "flag collides with value",
+			args: []qemu.Argument{
+				qemu.FlagArg("snapshot"),
+				qemu.UniqueArg("snapshot", "on"),
+			},
+			requireError: require.Error,
+		},
+		{
+			name: "flag collides with flag",
+			args: []qemu.Argument{
+				qemu.FlagArg("snapshot"),
+				qemu.UniqueArg("snapshot"),
+			},
+			requireError: require.Error,
+		},
+		{
+			name: "repeated flag collides",
+			args: []qemu.Argument{
+				qemu.RepeatableArg("more"),
+				qemu.RepeatableArg("more"),
+			},
+			requireError: require.Error,
+		},
 	}
 
 	for _, tt := range tests {
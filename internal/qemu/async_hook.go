@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"sync"
+)
+
+// asyncHook runs a function at most once, concurrently to the output
+// processing. It is used for actions triggered by guest output, like once
+// the guest is ready.
+type asyncHook struct {
+	fn   func() error
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func newAsyncHook(fn func() error) *asyncHook {
+	return &asyncHook{
+		fn:   fn,
+		done: make(chan struct{}),
+	}
+}
+
+// start runs the function in a new goroutine, if it has not been started
+// yet. Once the function returned, onDone is called with its error.
+func (h *asyncHook) start(onDone func(err error)) {
+	h.once.Do(func() {
+		go func() {
+			defer close(h.done)
+
+			h.err = h.fn()
+			onDone(h.err)
+		}()
+	})
+}
+
+// wait waits for the function to return, if it has been started, and
+// returns its error.
+func (h *asyncHook) wait() error {
+	started := true
+
+	// Mark as started, so it is not started anymore after waiting.
+	h.once.Do(func() {
+		started = false
+	})
+
+	if !started {
+		return nil
+	}
+
+	<-h.done
+
+	return h.err
+}
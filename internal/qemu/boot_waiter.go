@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"bytes"
+	"sync/atomic"
+)
+
+// bootWaiter stops the deadline and calls the function, if any, once the
+// guest prints the boot marker line. The marker line itself is dropped.
+type bootWaiter struct {
+	marker   []byte
+	deadline *idleWatchdog
+	fn       func()
+	booted   atomic.Bool
+}
+
+// Expired returns true if the deadline expired before the boot marker line
+// has been found.
+func (w *bootWaiter) Expired() bool {
+	return w.deadline != nil && !w.booted.Load() && w.deadline.Expired()
+}
+
+// wrap returns a [lineParseFunc] that matches each line against the boot
+// marker before calling the given function.
+func (w *bootWaiter) wrap(fn lineParseFunc) lineParseFunc {
+	return func(data []byte) []byte {
+		if !w.booted.Load() && bytes.Equal(bytes.TrimSpace(data), w.marker) {
+			w.booted.Store(true)
+
+			if w.deadline != nil {
+				w.deadline.stop()
+			}
+
+			if w.fn != nil {
+				w.fn()
+			}
+
+			return nil
+		}
+
+		if fn == nil {
+			return data
+		}
+
+		return fn(data)
+	}
+}
@@ -9,11 +9,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"golang.org/x/sync/errgroup"
 )
@@ -43,37 +48,220 @@ type CommandSpec struct {
 	// Number of CPUs for the guest.
 	SMP uint64
 
+	// ACPI keeps ACPI enabled in the guest kernel even with a single CPU. It
+	// is required if the guest powers off instead of restarting.
+	ACPI bool
+
+	// Sockets the guest CPUs are distributed across. [CommandSpec.SMP] must
+	// be divisible by it. Zero uses QEMU's default.
+	Sockets uint64
+
+	// NUMANodes the guest CPUs and memory are distributed across evenly.
+	// [CommandSpec.SMP] and [CommandSpec.Memory] must be divisible by it.
+	// Less than 2 disables NUMA.
+	NUMANodes uint64
+
+	// HostCPUs the QEMU process including all vCPU threads is pinned to. If
+	// empty, the host scheduler is free to choose.
+	HostCPUs []int
+
 	// Memory for the machine in MB.
 	Memory uint64
 
+	// ResourceLimits are applied to the QEMU process itself.
+	ResourceLimits ResourceLimits
+
 	// Disable KVM support.
 	NoKVM bool
 
+	// Accel selects the accelerator explicitly, like "tcg,thread=multi". If
+	// set, it overrides the KVM handling, so NoKVM has no effect.
+	Accel Accel
+
+	// DumpGuestCore includes the guest memory in core dumps of the QEMU
+	// process itself.
+	DumpGuestCore bool
+
+	// CrashDump is the path the guest memory is dumped to as ELF core file
+	// on a guest kernel panic, for offline analysis with crash or gdb. The
+	// dump is triggered via QMP. The guest kernel waits after a panic
+	// instead of rebooting, so it can be dumped. The guest is shut down
+	// after the dump.
+	CrashDump string
+
+	// Lock the guest memory in host memory, so it is never swapped out.
+	// Requires a sufficient RLIMIT_MEMLOCK or CAP_IPC_LOCK on the host.
+	MemLock bool
+
+	// RTC defines the guest real time clock. If empty, QEMU's defaults are
+	// used.
+	RTC RTC
+
+	// Display defines the QEMU display. If empty, the display is disabled,
+	// so QEMU never tries to open a window, even on headless hosts.
+	Display Display
+
 	// Transport type for IO. This depends on machine type and the kernel.
 	// TransportTypeIsa should always work, but will give only one slot for
 	// microvm machine type. ARM type virt does not support ISA type at all.
 	TransportType TransportType
 
+	// SMBIOS are the SMBIOS tables the guest firmware provides. Each type may
+	// be given only once.
+	SMBIOS []SMBIOS
+
+	// Shares are host directories shared with the guest via virtio 9p. Their
+	// tags must be unique.
+	Shares []Share
+
+	// Disks are disk image files attached to the guest as virtio block
+	// devices.
+	Disks []Disk
+
+	// Network is a user mode network attached to the guest, if set.
+	Network *Network
+
+	// Snapshot makes all writes to Disks go to temporary overlays, so the
+	// image files are not modified.
+	Snapshot bool
+
+	// RNG adds a virtio-rng device that feeds the guest with entropy from
+	// the host, so the guest does not block on getrandom due to entropy
+	// starvation. It is ignored for microvm with ISA transport, which has
+	// no virtio devices.
+	RNG bool
+
+	// RNGSource is the path of a file or named pipe a virtio-rng device
+	// reads the entropy for the guest from, if set. It takes precedence over
+	// the host entropy of RNG.
+	RNGSource string
+
 	// ExtraArgs are  extra arguments that are passed to the QEMU command.
 	// They must not interfere with the essential arguments set by the command
 	// itself or an error will be returned on [Command.Run].
 	ExtraArgs []Argument
 
-	// Additional files attached to consoles besides the default one used for
+	// Consoles with explicit backends besides the default one used for
 	// stdout. They will be present in the guest system as "/dev/ttySx" or
-	// "/dev/hvcx" where x is the index of the slice + 1.
+	// "/dev/hvcx" where x is the index of the slice + 1. Their output is not
+	// processed. Stdio can not be used, as the default console uses it.
+	Consoles []ConsoleBackend
+
+	// Monitor attaches the QEMU human monitor to the given backend for
+	// manual control during the run. Only [ConsoleBackendPty] and
+	// [ConsoleBackendSocket] are supported, as stdio is used by the default
+	// console. If nil, the monitor is disabled.
+	Monitor *ConsoleBackend
+
+	// Additional files attached to consoles besides the default one used for
+	// stdout. The output is sanitized before written to the file. Files
+	// with the suffix ".gz" are gzip compressed by the host, so the guest
+	// must write uncompressed data. They will be present in the guest system
+	// as "/dev/ttySx" or "/dev/hvcx" where x is the index of the slice + 1 +
+	// number of [CommandSpec.Consoles].
 	AdditionalConsoles []string
 
+	// StdoutFilter filters the lines written to stdout. The exit code and
+	// error detection still see all lines.
+	StdoutFilter *OutputFilter
+
+	// ConsoleFilters filter the lines written to the files of
+	// [CommandSpec.AdditionalConsoles]. They are selected by the file path.
+	ConsoleFilters map[string]*OutputFilter
+
 	// Arguments to pass to the init binary.
 	InitArgs []string
 
+	// Environment variables for the init program given as "KEY=VALUE". They
+	// are passed via the kernel command line, which puts them into the
+	// environment of init. The kernel supports 32 variables at most.
+	Env []string
+
+	// KernelArgs are additional kernel command line parameters, like
+	// "earlyprintk=serial" or module options. They are appended after the
+	// generated parameters and before the [CommandSpec.InitArgs], so they
+	// take precedence for parameters the kernel evaluates last one wins,
+	// like "loglevel". A "console" parameter breaks the output processing,
+	// as the last one becomes the console of init.
+	KernelArgs []string
+
 	// Increase guest kernel logging.
 	Verbose bool
 
+	// IdleTimeout is the maximum duration between two lines of guest output.
+	// If exceeded, the guest is considered hung and is terminated. It is
+	// stopped once the exit code line is found. Zero disables the timeout.
+	IdleTimeout time.Duration
+
+	// Timeout is the maximum duration from start until the guest prints its
+	// exit code line. If exceeded, the guest is terminated and
+	// [ErrGuestTimeout] is returned. Unlike [CommandSpec.IdleTimeout], it is
+	// not reset by output. Zero disables the timeout.
+	Timeout time.Duration
+
+	// WaitForLine is a pattern the guest output is matched against. Once a
+	// line matches, the guest is shut down and the run is considered
+	// successful. This is intended for services that do not exit on their
+	// own.
+	WaitForLine *regexp.Regexp
+
+	// WaitForLineTimeout is the maximum duration to wait for a line matching
+	// [CommandSpec.WaitForLine]. Zero disables the timeout.
+	WaitForLineTimeout time.Duration
+
+	// BootMarker is the line the guest prints once it booted. The line is
+	// not printed to stdout. Required if [CommandSpec.MaxBootTime] is set.
+	BootMarker string
+
+	// MaxBootTime is the maximum duration from start until the guest prints
+	// the [CommandSpec.BootMarker]. Zero disables the check.
+	MaxBootTime time.Duration
+
+	// OnBooted is called synchronously once the guest prints the
+	// [CommandSpec.BootMarker]. It must return quickly, as it blocks the
+	// output processing. Requires [CommandSpec.BootMarker].
+	OnBooted func()
+
+	// OnReady is called once the guest is ready. That is when a line matches
+	// [CommandSpec.WaitForLine], if set, or when the guest prints the
+	// [CommandSpec.BootMarker] otherwise. It runs concurrently to the guest.
+	// With [CommandSpec.WaitForLine], the guest is shut down once it
+	// returns. If it returns an error, the guest is shut down and the error
+	// is returned by [Command.Run].
+	OnReady func() error
+
 	// ExitCodeFmt defines the format of the line communicating the exit code
 	// from the guest. It must contain exactly one integer verb
 	// (probably "%d").
 	ExitCodeFmt string
+
+	// CompletionPrefix defines the prefix of the optional line communicating
+	// structured completion information from the guest. The payload can be
+	// retrieved by [Command.Completion] after the run. Empty disables it.
+	CompletionPrefix string
+
+	// OopsMarker defines the line the guest prints if a kernel oops occurred.
+	// If found, the run fails with [ErrGuestOops]. Empty disables it.
+	OopsMarker string
+
+	// GDB is the TCP address the gdb stub listens on for debugging the guest
+	// kernel, if set.
+	GDB GDBAddress
+
+	// GDBWait freezes the guest CPUs at start until a debugger attached to
+	// the GDB stub continues them. As the guest may be paused for an
+	// arbitrary duration, all timeouts are disabled. Requires GDB.
+	GDBWait bool
+
+	// QMPSocket is the path of a unix socket a QMP server listens on for
+	// external control while the guest runs, if set. The file must not exist
+	// yet and its directory must exist. It is removed once the command is
+	// done.
+	QMPSocket string
+
+	// qmpSocket is the path of the unix socket the QMP server listens on. It
+	// is set by [NewCommand] if required.
+	qmpSocket string
 }
 
 // AddConsole adds an additional file to the QEMU command. This will be
@@ -81,7 +269,29 @@ type CommandSpec struct {
 // Console device number is starting at 1, as console 0 is the default stdout.
 func (c *CommandSpec) AddConsole(file string) string {
 	c.AdditionalConsoles = append(c.AdditionalConsoles, file)
-	return c.TransportType.ConsoleDeviceName(uint(len(c.AdditionalConsoles)))
+	return c.TransportType.ConsoleDeviceName(c.consoleCount())
+}
+
+// AddNamedConsoles adds an additional console for each of the given names
+// mapped to files, like [CommandSpec.AddConsole]. They are added in
+// lexicographic order of the names, so the same names always get the same
+// devices. It returns the absolute device paths in the guest by name.
+func (c *CommandSpec) AddNamedConsoles(
+	files map[string]string,
+) map[string]string {
+	devices := make(map[string]string, len(files))
+
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		c.AdditionalConsoles = append(c.AdditionalConsoles, files[name])
+		devices[name] = c.TransportType.ConsoleDevicePath(c.consoleCount())
+	}
+
+	return devices
+}
+
+// consoleCount returns the number of consoles besides the default one.
+func (c *CommandSpec) consoleCount() uint {
+	return uint(len(c.Consoles) + len(c.AdditionalConsoles))
 }
 
 // Validate checks for known incompatibilities.
@@ -92,21 +302,117 @@ func (c *CommandSpec) Validate() error {
 		}
 	}
 
+	for _, backend := range c.Consoles {
+		if err := backend.validateConsole(); err != nil {
+			return err
+		}
+	}
+
+	if c.Monitor != nil {
+		if err := c.Monitor.validateMonitor(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.RTC.validate(); err != nil {
+		return &ArgumentError{err.Error()}
+	}
+
+	if err := c.Display.validate(); err != nil {
+		return &ArgumentError{err.Error()}
+	}
+
+	if err := c.Accel.validate(); err != nil {
+		return &ArgumentError{err.Error()}
+	}
+
+	if c.QMPSocket != "" {
+		if err := validateQMPSocket(c.QMPSocket); err != nil {
+			return err
+		}
+	}
+
+	if err := c.GDB.validate(); err != nil {
+		return &ArgumentError{err.Error()}
+	}
+
+	if c.GDBWait && c.GDB == "" {
+		return &ArgumentError{"gdb wait requires gdb address"}
+	}
+
+	if err := c.validateTopology(); err != nil {
+		return err
+	}
+
+	if err := c.validateShares(); err != nil {
+		return err
+	}
+
+	if err := c.validateSMBIOS(); err != nil {
+		return err
+	}
+
+	if err := c.validateDisks(); err != nil {
+		return err
+	}
+
+	if err := c.validateNetwork(); err != nil {
+		return err
+	}
+
+	if err := c.ResourceLimits.validate(c.Memory); err != nil {
+		return err
+	}
+
+	if err := c.validateKernelArgs(); err != nil {
+		return err
+	}
+
+	if c.Timeout < 0 {
+		return &ArgumentError{"timeout must not be negative"}
+	}
+
+	if c.MaxBootTime > 0 && c.BootMarker == "" {
+		return &ArgumentError{"max boot time requires a boot marker"}
+	}
+
+	if c.OnBooted != nil && c.BootMarker == "" {
+		return &ArgumentError{"on booted requires a boot marker"}
+	}
+
+	if c.OnReady != nil && c.WaitForLine == nil && c.BootMarker == "" {
+		return &ArgumentError{
+			"on ready requires wait for line or a boot marker",
+		}
+	}
+
 	switch c.Machine {
 	case "microvm":
 		switch {
 		case c.TransportType == TransportTypePCI:
 			return &ArgumentError{"microvm does not support pci transport"}
 		case c.TransportType == TransportTypeISA &&
-			len(c.AdditionalConsoles) > 0:
+			c.consoleCount() > 0:
 			return &ArgumentError{
 				"microvm supports only one isa serial port, used for stdio",
 			}
+		case c.TransportType == TransportTypeISA && len(c.Shares) > 0:
+			return &ArgumentError{"microvm shares require virtio-mmio"}
+		case c.TransportType == TransportTypeISA && c.RNGSource != "":
+			return &ArgumentError{"microvm rng requires virtio-mmio"}
+		case c.TransportType == TransportTypeISA && len(c.Disks) > 0:
+			return &ArgumentError{"microvm disks require virtio-mmio"}
+		case c.TransportType == TransportTypeISA && c.Network != nil:
+			return &ArgumentError{"microvm network requires virtio-mmio"}
 		}
 	case "virt":
 		if c.TransportType == TransportTypeISA {
 			return &ArgumentError{"virt requires virtio-mmio"}
 		}
+
+		if c.RTC.DriftFix == RTCDriftFixSlew {
+			return &ArgumentError{"virt does not support rtc driftfix slew"}
+		}
 	case "q35", "pc":
 		if c.TransportType == TransportTypeMMIO {
 			return &ArgumentError{
@@ -125,8 +431,8 @@ func (c *CommandSpec) arguments() []Argument {
 		UniqueArg("initrd", c.Initramfs),
 	}
 
-	if c.Machine != "" {
-		args = append(args, UniqueArg("machine", c.Machine))
+	if machine := c.machineValue(); len(machine) > 0 {
+		args = append(args, UniqueArg("machine", machine...))
 	}
 
 	if c.CPU != "" {
@@ -134,15 +440,32 @@ func (c *CommandSpec) arguments() []Argument {
 	}
 
 	if c.SMP != 0 {
-		args = append(args, UniqueArg("smp", strconv.FormatUint(c.SMP, 10)))
+		args = append(args, UniqueArg("smp", c.smpValue()...))
 	}
 
 	if c.Memory != 0 {
 		args = append(args, UniqueArg("m", strconv.FormatUint(c.Memory, 10)))
 	}
 
-	if !c.NoKVM {
-		args = append(args, UniqueArg("enable-kvm", ""))
+	args = append(args, c.numaArgs()...)
+
+	switch {
+	case c.Accel != "":
+		args = append(args, UniqueArg("accel", string(c.Accel)))
+	case !c.NoKVM:
+		args = append(args, FlagArg("enable-kvm"))
+	}
+
+	if c.MemLock {
+		args = append(args, UniqueArg("overcommit", "mem-lock=on"))
+	}
+
+	if !c.RTC.IsZero() {
+		args = append(args, UniqueArg("rtc", c.RTC.String()))
+	}
+
+	for _, smbios := range c.SMBIOS {
+		args = append(args, RepeatableArg("smbios", smbios.String()))
 	}
 
 	sharedDevices := map[TransportType]string{
@@ -156,32 +479,75 @@ func (c *CommandSpec) arguments() []Argument {
 	// Add stdout console.
 	args = c.appendConsoleArgs(args, console{
 		id:      "stdio",
-		backend: "stdio",
+		backend: ConsoleBackend{Type: ConsoleBackendStdio},
 	})
 
+	for idx, backend := range c.Consoles {
+		args = c.appendConsoleArgs(args, console{
+			id:      fmt.Sprintf("con%d", idx),
+			backend: backend,
+		})
+	}
+
 	// Write console output to file descriptors. Those are provided by the
 	// [exec.Cmd.ExtraFiles].
 	for idx := range c.AdditionalConsoles {
 		// FDs 0, 1, 2 are standard in, out, err, so start at 3.
 		path := fdPath(minAdditionalFileDescriptor + idx)
 		args = c.appendConsoleArgs(args, console{
-			id:      fmt.Sprintf("con%d", idx),
-			backend: "file",
-			opts:    []string{"path=" + path},
+			id: fmt.Sprintf("con%d", len(c.Consoles)+idx),
+			backend: ConsoleBackend{
+				Type: ConsoleBackendFile,
+				Path: path,
+			},
 		})
 	}
 
+	args = append(args, c.shareArgs()...)
+
+	args = append(args, c.diskArgs()...)
+
+	args = append(args, c.networkArgs()...)
+
+	if c.Snapshot {
+		args = append(args, FlagArg("snapshot"))
+	}
+
+	args = append(args, c.rngArgs()...)
+
+	args = append(args, UniqueArg("display", c.Display.value()))
+
+	args = append(args, c.monitorArgs()...)
+
+	if c.qmpSocket != "" {
+		args = append(args, RepeatableArg("qmp",
+			"unix:"+escapeOptionValue(c.qmpSocket), "server=on",
+			"wait=off"))
+	}
+
+	// Separate from the internal socket, as a QMP server serves a single
+	// client at a time.
+	if c.QMPSocket != "" {
+		args = append(args, RepeatableArg("qmp",
+			"unix:"+escapeOptionValue(c.QMPSocket), "server=on",
+			"wait=off"))
+	}
+
+	if c.GDB != "" {
+		args = append(args, UniqueArg("gdb", c.GDB.value()))
+	}
+
+	if c.GDBWait {
+		args = append(args, FlagArg("S"))
+	}
+
 	args = append(args,
-		// Disable video output.
-		UniqueArg("display", "none"),
-		// Disable QEMU monitor.
-		UniqueArg("monitor", "none"),
 		// Guest must not reboot.
-		UniqueArg("no-reboot"),
+		FlagArg("no-reboot"),
 		// Disable all default devices.
-		UniqueArg("nodefaults"),
+		FlagArg("nodefaults"),
 		// Do not load any user config files.
-		UniqueArg("no-user-config"),
+		FlagArg("no-user-config"),
 	)
 
 	args = append(args, c.ExtraArgs...)
@@ -192,18 +558,82 @@ func (c *CommandSpec) arguments() []Argument {
 	return args
 }
 
+// rngArgs returns the random number generator backend and virtio-rng device
+// arguments for the RNGSource or RNG.
+func (c *CommandSpec) rngArgs() []Argument {
+	device := "virtio-rng-pci"
+	if c.TransportType == TransportTypeMMIO {
+		device = "virtio-rng-device"
+	}
+
+	if c.RNGSource == "" {
+		if !c.RNG || c.Machine == "microvm" &&
+			c.TransportType == TransportTypeISA {
+			return nil
+		}
+
+		// QEMU uses its builtin backend reading host entropy by default.
+		return []Argument{RepeatableArg("device", device)}
+	}
+
+	return []Argument{
+		RepeatableArg("object", "rng-random", "id=rng0",
+			"filename="+escapeOptionValue(c.RNGSource)),
+		RepeatableArg("device", device, "rng=rng0"),
+	}
+}
+
+// machineValue returns the value for the "-machine" argument.
+func (c *CommandSpec) machineValue() []string {
+	var value []string
+
+	if c.Machine != "" {
+		value = append(value, c.Machine)
+	}
+
+	if c.DumpGuestCore {
+		value = append(value, "dump-guest-core=on")
+	}
+
+	return value
+}
+
+// panicTimeout returns the value for the kernel's "panic" parameter. By
+// default, the guest reboots immediately, which terminates QEMU due to
+// "-no-reboot". For a crash dump, it waits forever instead.
+func (c *CommandSpec) panicTimeout() string {
+	if c.CrashDump != "" {
+		return "0"
+	}
+
+	return "-1"
+}
+
+// validateKernelArgs checks that each kernel arg is a single parameter that
+// does not end the kernel parameters.
+func (c *CommandSpec) validateKernelArgs() error {
+	for _, arg := range c.KernelArgs {
+		hasSpace := strings.ContainsFunc(arg, unicode.IsSpace)
+		if arg == "" || arg == "--" || hasSpace {
+			return &ArgumentError{fmt.Sprintf("invalid kernel arg: %q", arg)}
+		}
+	}
+
+	return nil
+}
+
 // kernelCmdlineArgs reruns the kernel cmdline arguments.
 func (c *CommandSpec) kernelCmdlineArgs() []string {
 	cmdline := []string{
 		"console=" + c.TransportType.ConsoleDeviceName(0),
-		"panic=-1",
+		"panic=" + c.panicTimeout(),
 		"mitigations=off",
 		"initcall_blacklist=ahci_pci_driver_init",
 	}
 
 	// ACPI is necessary for SMP. With a single CPU, we can disable it to speed
 	// up the boot considerably.
-	if c.SMP == 1 {
+	if c.SMP == 1 && !c.ACPI {
 		cmdline = append(cmdline, "acpi=off")
 	}
 
@@ -211,6 +641,12 @@ func (c *CommandSpec) kernelCmdlineArgs() []string {
 		cmdline = append(cmdline, "quiet")
 	}
 
+	for _, envVar := range c.Env {
+		cmdline = append(cmdline, quoteParam(envVar))
+	}
+
+	cmdline = append(cmdline, c.KernelArgs...)
+
 	if len(c.InitArgs) > 0 {
 		cmdline = append(cmdline, "--")
 		cmdline = append(cmdline, c.InitArgs...)
@@ -219,10 +655,35 @@ func (c *CommandSpec) kernelCmdlineArgs() []string {
 	return cmdline
 }
 
+// quoteParam quotes the value of the given "KEY=VALUE" pair if it contains
+// whitespace, so the kernel does not split it.
+func quoteParam(param string) string {
+	key, value, _ := strings.Cut(param, "=")
+	if !strings.ContainsAny(value, " \t") {
+		return param
+	}
+
+	return key + `="` + value + `"`
+}
+
+// monitorArgs returns the arguments for the QEMU monitor.
+func (c *CommandSpec) monitorArgs() []Argument {
+	if c.Monitor == nil {
+		// Disable QEMU monitor.
+		return []Argument{UniqueArg("monitor", "none")}
+	}
+
+	chardevOpts := c.Monitor.chardevOptions(monitorID)
+
+	return []Argument{
+		RepeatableArg("chardev", strings.Join(chardevOpts, ",")),
+		UniqueArg("monitor", "chardev:"+monitorID),
+	}
+}
+
 type console struct {
 	id      string
-	backend string
-	opts    []string
+	backend ConsoleBackend
 }
 
 func (c *CommandSpec) appendConsoleArgs(
@@ -240,9 +701,7 @@ func (c *CommandSpec) appendConsoleArgs(
 		return args
 	}
 
-	chardevOpts := []string{console.backend, "id=" + console.id}
-	chardevOpts = append(chardevOpts, console.opts...)
-
+	chardevOpts := console.backend.chardevOptions(console.id)
 	chardevArg := RepeatableArg("chardev", strings.Join(chardevOpts, ","))
 
 	return append(args, chardevArg, devArg)
@@ -255,8 +714,28 @@ func fdPath(fd int) string {
 type Command struct {
 	cmd          *exec.Cmd
 	stdoutParser stdoutParser
-
-	consoleOutput []string
+	stderrTail   tailWriter
+
+	consoleOutput      []string
+	consoleFilters     map[string]*OutputFilter
+	stdoutFilter       *OutputFilter
+	hostCPUs           []int
+	resourceLimits     ResourceLimits
+	idleTimeout        time.Duration
+	timeout            time.Duration
+	waitForLine        *regexp.Regexp
+	waitForLineTimeout time.Duration
+	bootMarker         string
+	maxBootTime        time.Duration
+	onReady            func() error
+	onBooted           func()
+	crashDump          func() error
+	scratchDisks       []Disk
+
+	// cancelErr is the error of the context that terminated the command. It
+	// is set by [exec.Cmd.Cancel] and must be read after [exec.Cmd.Wait]
+	// returned only.
+	cancelErr error
 
 	closer []io.Closer
 }
@@ -269,6 +748,24 @@ func NewCommand(ctx context.Context, spec CommandSpec) (*Command, error) {
 		return nil, err
 	}
 
+	if spec.CrashDump != "" {
+		spec.qmpSocket = qmpSocketPath()
+	}
+
+	scratchDisks := spec.assignScratchDisks()
+
+	if spec.GDBWait {
+		// The guest is paused until the debugger continues it, so any
+		// timeout would kill it while debugging.
+		spec.IdleTimeout = 0
+		spec.Timeout = 0
+		spec.MaxBootTime = 0
+		spec.WaitForLineTimeout = 0
+
+		slog.Warn("Guest CPUs paused until continued by gdb",
+			slog.String("address", string(spec.GDB)))
+	}
+
 	cmdArgs, err := BuildArgumentStrings(spec.arguments())
 	if err != nil {
 		return nil, err
@@ -279,24 +776,96 @@ func NewCommand(ctx context.Context, spec CommandSpec) (*Command, error) {
 	}
 
 	cmd := &Command{
-		cmd:           exec.CommandContext(ctx, spec.Executable, cmdArgs...),
-		consoleOutput: spec.AdditionalConsoles,
+		cmd:            exec.CommandContext(ctx, spec.Executable, cmdArgs...),
+		consoleOutput:  spec.AdditionalConsoles,
+		consoleFilters: spec.ConsoleFilters,
+		stdoutFilter:   spec.StdoutFilter,
+		hostCPUs:       spec.HostCPUs,
+		resourceLimits: spec.ResourceLimits,
+		idleTimeout:    spec.IdleTimeout,
+		timeout:        spec.Timeout,
 		stdoutParser: stdoutParser{
-			ExitCodeFmt: spec.ExitCodeFmt,
-			Verbose:     spec.Verbose,
+			ExitCodeFmt:      spec.ExitCodeFmt,
+			CompletionPrefix: spec.CompletionPrefix,
+			OopsMarker:       spec.OopsMarker,
+			Verbose:          spec.Verbose,
 		},
+
+		waitForLine:        spec.WaitForLine,
+		waitForLineTimeout: spec.WaitForLineTimeout,
+		bootMarker:         spec.BootMarker,
+		maxBootTime:        spec.MaxBootTime,
+		onReady:            spec.OnReady,
+		onBooted:           spec.OnBooted,
+		scratchDisks:       scratchDisks,
+	}
+
+	if spec.CrashDump != "" {
+		cmd.crashDump = func() error {
+			err := dumpGuestMemoryVia(spec.qmpSocket, spec.CrashDump)
+			if err == nil {
+				slog.Info("Guest memory dumped",
+					slog.String("path", spec.CrashDump))
+			}
+
+			return err
+		}
+		cmd.closer = append(cmd.closer, removeOnClose(spec.qmpSocket))
 	}
 
-	// The default cancel function set by [exec.CommandContext] sends SIGKILL
-	// to the process. This makes it impossible for QEMU to shutdown gracefully
-	// which messes up terminal stdio and leaves the terminal in a broken state.
-	cmd.cmd.Cancel = func() error {
-		return cmd.cmd.Process.Signal(os.Interrupt)
+	if spec.QMPSocket != "" {
+		cmd.closer = append(cmd.closer, removeOnClose(spec.QMPSocket))
 	}
 
+	cmd.setCancel(ctx)
+
 	return cmd, nil
 }
 
+// setCancel sets the function called once the given context is done.
+//
+// The default cancel function set by [exec.CommandContext] sends SIGKILL
+// to the process. This makes it impossible for QEMU to shutdown gracefully
+// which messes up terminal stdio and leaves the terminal in a broken state.
+func (c *Command) setCancel(ctx context.Context) {
+	c.cmd.Cancel = func() error {
+		c.cancelErr = ctx.Err()
+		return c.cmd.Process.Signal(os.Interrupt)
+	}
+}
+
+// start starts the command, pinned to the host CPUs if any are given, and
+// applies the resource limits. If the limits can not be applied, the process
+// is killed.
+func (c *Command) start() error {
+	err := c.startProcess()
+	if err != nil {
+		return err
+	}
+
+	err = c.resourceLimits.apply(c.cmd.Process.Pid)
+	if err != nil {
+		_ = c.cmd.Process.Kill()
+		_ = c.cmd.Wait()
+
+		return fmt.Errorf("resource limits: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Command) startProcess() error {
+	if len(c.hostCPUs) > 0 {
+		return startPinned(c.cmd, c.hostCPUs)
+	}
+
+	if err := c.cmd.Start(); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+
+	return nil
+}
+
 // String prints the human readable string representation of the command.
 //
 // It just wraps [exec.Command.String].
@@ -304,6 +873,25 @@ func (c *Command) String() string {
 	return c.cmd.String()
 }
 
+// Args returns the command line of the command, starting with the QEMU
+// executable.
+func (c *Command) Args() []string {
+	return slices.Clone(c.cmd.Args)
+}
+
+// StderrTail returns the last bytes written to stderr during [Command.Run],
+// bounded to a few KiB. Call it only after [Command.Run] returned.
+func (c *Command) StderrTail() []byte {
+	return c.stderrTail.Bytes()
+}
+
+// Completion returns the payload of the completion line communicated by the
+// guest, see [CommandSpec.CompletionPrefix]. It is nil if the guest did not
+// send one. Call it only after [Command.Run] returned.
+func (c *Command) Completion() []byte {
+	return c.stdoutParser.Completion()
+}
+
 // stdoutProcessor creates a new [consoleProcessor] with the command's
 // [stdoutParser].
 func (c *Command) stdoutProcessor(dst io.Writer) (*consoleProcessor, error) {
@@ -318,6 +906,10 @@ func (c *Command) stdoutProcessor(dst io.Writer) (*consoleProcessor, error) {
 		fn:  c.stdoutParser.Parse,
 	}
 
+	if !c.stdoutFilter.IsZero() {
+		processor.fn = c.stdoutFilter.wrap(processor.fn)
+	}
+
 	return processor, nil
 }
 
@@ -355,19 +947,30 @@ func (c *Command) close() {
 //
 // Output processors are setup and the command is executed. Returns without
 // error only if the guest system correctly communicated exit code 0. In any
-// other case, an error is returned. If the QEMU command itself failed,
-// a [CommandError] with the guest flag unset is returned. If the guest
-// returned an error or failed a [CommandError] with guest flag set is
-// returned.
+// other case, an error is returned. If the QEMU command itself failed before
+// the guest communicated its exit code, a [CommandError] with the guest flag
+// unset is returned, see [IsGuestFailure]. If the guest returned an error or
+// failed a [CommandError] with guest flag set is returned. If the context is
+// done before the guest finished, the output received so far is still written
+// and [ErrGuestTimeout] is returned along with the last output lines.
 func (c *Command) Run(stdin io.Reader, stdout, stderr io.Writer) error {
 	defer c.close()
 
+	// Created only now, so a command that is never run leaves no files.
+	for _, disk := range c.scratchDisks {
+		if err := createScratchDisk(disk); err != nil {
+			return err
+		}
+
+		c.closer = append(c.closer, removeOnClose(disk.Path))
+	}
+
 	var processors errgroup.Group
 
 	for _, path := range c.consoleOutput {
-		dst, err := os.Create(path)
+		dst, err := createOutputFile(path)
 		if err != nil {
-			return fmt.Errorf("output file: %w", err)
+			return err
 		}
 
 		c.closer = append(c.closer, dst)
@@ -377,40 +980,262 @@ func (c *Command) Run(stdin io.Reader, stdout, stderr io.Writer) error {
 			return err
 		}
 
+		if filter := c.consoleFilters[path]; !filter.IsZero() {
+			processor.fn = filter.wrap(processor.fn)
+		}
+
 		processors.Go(processor.run)
 	}
 
+	c.stderrTail = tailWriter{max: stderrTailSize}
+
 	c.cmd.Stdin = stdin
-	c.cmd.Stderr = stderr
+	c.cmd.Stderr = &c.stderrTail
+
+	if stderr != nil {
+		c.cmd.Stderr = io.MultiWriter(stderr, &c.stderrTail)
+	}
 
 	stdoutProcessor, err := c.stdoutProcessor(stdout)
 	if err != nil {
 		return err
 	}
 
-	if err := c.cmd.Start(); err != nil {
-		return fmt.Errorf("start: %w", err)
+	if err := c.start(); err != nil {
+		return err
+	}
+
+	tail := &outputTail{max: outputTailLines}
+	stdoutProcessor.fn = tail.wrap(stdoutProcessor.fn)
+
+	var watchdog *idleWatchdog
+
+	if c.idleTimeout > 0 {
+		watchdog = newIdleWatchdog(c.idleTimeout, c.interrupt)
+		defer watchdog.stop()
+
+		stdoutProcessor.fn = c.stopOnExitCode(watchdog,
+			watchdog.wrap(stdoutProcessor.fn))
+	}
+
+	var deadline *idleWatchdog
+
+	if c.timeout > 0 {
+		// Never reset, so it is a plain deadline.
+		deadline = newIdleWatchdog(c.timeout, c.interrupt)
+		defer deadline.stop()
+
+		stdoutProcessor.fn = c.stopOnExitCode(deadline, stdoutProcessor.fn)
+	}
+
+	var (
+		waiter       *lineWaiter
+		waitDeadline *idleWatchdog
+		ready        *asyncHook
+	)
+
+	if c.onReady != nil {
+		ready = newAsyncHook(c.onReady)
+	}
+
+	if c.waitForLine != nil {
+		waiter = &lineWaiter{pattern: c.waitForLine, fn: c.interrupt}
+		stdoutProcessor.fn = waiter.wrap(stdoutProcessor.fn)
+
+		if c.waitForLineTimeout > 0 {
+			// Never reset, so it is a plain deadline.
+			waitDeadline = newIdleWatchdog(c.waitForLineTimeout, c.interrupt)
+			defer waitDeadline.stop()
+		}
+
+		// Shut down only once the hook is done.
+		if ready != nil {
+			waiter.fn = func() {
+				if waitDeadline != nil {
+					waitDeadline.stop()
+				}
+
+				ready.start(func(error) { c.interrupt() })
+			}
+		}
+	}
+
+	var boot *bootWaiter
+
+	if c.bootMarker != "" {
+		boot = &bootWaiter{marker: []byte(c.bootMarker)}
+		stdoutProcessor.fn = boot.wrap(stdoutProcessor.fn)
+
+		startReady := ready != nil && waiter == nil
+
+		boot.fn = func() {
+			if c.onBooted != nil {
+				c.onBooted()
+			}
+
+			if startReady {
+				ready.start(func(err error) {
+					if err != nil {
+						c.interrupt()
+					}
+				})
+			}
+		}
+
+		if c.maxBootTime > 0 {
+			boot.deadline = newIdleWatchdog(c.maxBootTime, c.interrupt)
+			defer boot.deadline.stop()
+		}
+	}
+
+	var (
+		crashDump   *asyncHook
+		panicWaiter *lineWaiter
+	)
+
+	if c.crashDump != nil {
+		crashDump = newAsyncHook(c.crashDump)
+		panicWaiter = &lineWaiter{pattern: panicRE, fn: func() {
+			// The guest waits forever after the panic, so shut it down.
+			crashDump.start(func(error) { c.interrupt() })
+		}}
+		stdoutProcessor.fn = panicWaiter.wrap(stdoutProcessor.fn)
 	}
 
 	if err := stdoutProcessor.run(); err != nil {
 		return fmt.Errorf("stdout parser: %w", err)
 	}
 
-	if err := c.cmd.Wait(); err != nil {
-		return wrapExitError(err)
-	}
+	err = c.cmd.Wait()
 
-	// Close all FDs so processors stop.
+	// Close all FDs so processors stop. This flushes the output received so
+	// far, even if the guest did not finish.
 	for _, f := range c.cmd.ExtraFiles {
 		_ = f.Close()
 	}
 
-	err = processors.Wait()
+	processorsErr := processors.Wait()
+
+	var readyErr error
+	if ready != nil {
+		readyErr = ready.wait()
+	}
+
+	if crashDump != nil {
+		c.waitCrashDump(crashDump)
+	}
+
+	switch {
+	case readyErr != nil:
+		return fmt.Errorf("on ready: %w", readyErr)
+	case waiter != nil && waiter.Matched():
+		// The guest has been shut down on purpose, so ignore the exit status.
+		return nil
+	case panicWaiter != nil && panicWaiter.Matched():
+		// The guest has been shut down after the crash dump, so ignore the
+		// exit status.
+		return c.stdoutParser.GuestSuccessful()
+	case watchdog != nil && watchdog.Expired() &&
+		!c.stdoutParser.exitCodeFound:
+		return &CommandError{
+			Err:   ErrGuestIdleTimeout,
+			Guest: true,
+		}
+	case waitDeadline != nil && waitDeadline.Expired():
+		return &CommandError{
+			Err:   ErrWaitForLineTimeout,
+			Guest: true,
+		}
+	case boot != nil && boot.Expired():
+		return &CommandError{
+			Err:   ErrGuestBootTimeout,
+			Guest: true,
+		}
+	case deadline != nil && deadline.Expired() &&
+		!c.stdoutParser.exitCodeFound:
+		reason := fmt.Sprintf("timeout %s exceeded", c.timeout)
+		return c.terminatedError(reason, tail)
+	case c.cancelErr != nil:
+		return c.terminatedError(c.cancelErr.Error(), tail)
+	}
+
 	if err != nil {
-		return fmt.Errorf("processor wait: %w", err)
+		// The guest result is complete once the exit code has been
+		// communicated, so QEMU failing afterwards, like while shutting
+		// down, is not the cause of a failure.
+		if c.stdoutParser.exitCodeFound {
+			slog.Warn("QEMU failed after guest exit", slog.Any("error", err))
+
+			return c.stdoutParser.GuestSuccessful()
+		}
+
+		return wrapExitError(err)
+	}
+
+	if processorsErr != nil {
+		return fmt.Errorf("processor wait: %w", processorsErr)
+	}
+
+	err = c.stdoutParser.GuestSuccessful()
+	if err == nil && waiter != nil {
+		return &CommandError{
+			Err:   ErrWaitForLineNotFound,
+			Guest: true,
+		}
 	}
 
-	return c.stdoutParser.GuestSuccessful()
+	return err
+}
+
+// waitCrashDump waits for the crash dump, if it has been triggered, and logs
+// the result. A failed dump is not returned, so it does not mask the more
+// relevant guest panic error.
+func (c *Command) waitCrashDump(crashDump *asyncHook) {
+	err := crashDump.wait()
+	if err != nil {
+		slog.Error("Failed to dump guest memory", slog.Any("error", err))
+	}
+}
+
+// stopOnExitCode returns a [lineParseFunc] that stops the watchdog once the
+// given function found the exit code line.
+func (c *Command) stopOnExitCode(
+	watchdog *idleWatchdog,
+	fn lineParseFunc,
+) lineParseFunc {
+	return func(data []byte) []byte {
+		data = fn(data)
+
+		if c.stdoutParser.exitCodeFound {
+			watchdog.stop()
+		}
+
+		return data
+	}
+}
+
+// terminatedError returns the error for a command terminated by the host for
+// the given reason, like the context being done.
+//
+// It contains the last output lines and the exit code, if the guest
+// communicated one before it has been terminated.
+func (c *Command) terminatedError(reason string, tail *outputTail) error {
+	msg := reason
+	if c.stdoutParser.exitCodeFound {
+		msg += fmt.Sprintf(", exit code %d found", c.stdoutParser.exitCode)
+	}
+
+	return &CommandError{
+		Err: fmt.Errorf("%w (%s), last output:\n%s",
+			ErrGuestTimeout, msg, tail),
+		Guest:    true,
+		ExitCode: c.stdoutParser.exitCode,
+	}
+}
+
+// interrupt asks the running QEMU process to terminate gracefully.
+func (c *Command) interrupt() {
+	_ = c.cmd.Process.Signal(os.Interrupt)
 }
 
 func wrapExitError(err error) error {
@@ -5,13 +5,24 @@
 package qemu
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
+	"golang.org/x/sys/unix"
 )
 
 func TestCommandSpec_Arguments(t *testing.T) {
@@ -37,10 +48,357 @@ func TestCommandSpec_Arguments(t *testing.T) {
 			},
 			assert: assert.Subset,
 		},
+		{
+			name:   "default display none",
+			spec:   CommandSpec{},
+			expect: UniqueArg("display", "none"),
+			assert: assert.Contains,
+		},
+		{
+			name: "vnc display",
+			spec: CommandSpec{
+				Display: "vnc=:1",
+			},
+			expect: UniqueArg("display", "vnc=:1"),
+			assert: assert.Contains,
+		},
+		{
+			name: "smbios",
+			spec: CommandSpec{
+				SMBIOS: []SMBIOS{
+					{
+						Type: 1,
+						Fields: map[string]string{
+							"manufacturer": "Acme",
+							"product":      "Rocket",
+						},
+					},
+					{
+						Type:   2,
+						Fields: map[string]string{"serial": "42"},
+					},
+				},
+			},
+			expect: []Argument{
+				RepeatableArg("smbios",
+					"type=1,manufacturer=Acme,product=Rocket"),
+				RepeatableArg("smbios", "type=2,serial=42"),
+			},
+			assert: assert.Subset,
+		},
+		{
+			name: "shares pci",
+			spec: CommandSpec{
+				TransportType: TransportTypePCI,
+				Shares: []Share{
+					{HostPath: "/srv/fixtures", Tag: "fixtures"},
+					{HostPath: "/srv/a,b", Tag: "golden", ReadOnly: true},
+				},
+			},
+			expect: []Argument{
+				RepeatableArg("fsdev", "local", "id=share0",
+					"path=/srv/fixtures", "security_model=none"),
+				RepeatableArg("device", "virtio-9p-pci", "fsdev=share0",
+					"mount_tag=fixtures"),
+				RepeatableArg("fsdev", "local", "id=share1",
+					"path=/srv/a,,b", "security_model=none", "readonly=on"),
+				RepeatableArg("device", "virtio-9p-pci", "fsdev=share1",
+					"mount_tag=golden"),
+			},
+			assert: assert.Subset,
+		},
+		{
+			name: "shares mmio",
+			spec: CommandSpec{
+				TransportType: TransportTypeMMIO,
+				Shares: []Share{
+					{HostPath: "/srv/fixtures", Tag: "fixtures"},
+				},
+			},
+			expect: RepeatableArg("device", "virtio-9p-device",
+				"fsdev=share0", "mount_tag=fixtures"),
+			assert: assert.Contains,
+		},
+		{
+			name: "disks with throttling",
+			spec: CommandSpec{
+				TransportType: TransportTypePCI,
+				Disks: []Disk{
+					{
+						Path: "/tmp/a,b.img",
+						Throttle: DiskThrottle{
+							BPS:       1048576,
+							IOPSRead:  100,
+							IOPSWrite: 50,
+						},
+					},
+					{
+						Path:     "/tmp/ro.qcow2",
+						Format:   "qcow2",
+						ReadOnly: true,
+					},
+				},
+			},
+			expect: []Argument{
+				RepeatableArg("drive", "file=/tmp/a,,b.img", "if=none",
+					"id=disk0", "format=raw",
+					"throttling.bps-total=1048576",
+					"throttling.iops-read=100",
+					"throttling.iops-write=50"),
+				RepeatableArg("device", "virtio-blk-pci", "drive=disk0"),
+				RepeatableArg("drive", "file=/tmp/ro.qcow2", "if=none",
+					"id=disk1", "format=qcow2", "readonly=on"),
+				RepeatableArg("device", "virtio-blk-pci", "drive=disk1"),
+			},
+			assert: assert.Subset,
+		},
+		{
+			name: "disks mmio",
+			spec: CommandSpec{
+				TransportType: TransportTypeMMIO,
+				Disks:         []Disk{{Path: "/tmp/disk.img"}},
+			},
+			expect: RepeatableArg("device", "virtio-blk-device", "drive=disk0"),
+			assert: assert.Contains,
+		},
+		{
+			name: "network pci",
+			spec: CommandSpec{
+				TransportType: TransportTypePCI,
+				Network: &Network{
+					HostForwards: []HostForward{
+						{HostPort: 8080, GuestPort: 80},
+						{
+							Protocol:  "udp",
+							HostAddr:  "127.0.0.1",
+							HostPort:  5353,
+							GuestPort: 53,
+						},
+					},
+				},
+			},
+			expect: []Argument{
+				RepeatableArg("netdev", "user", "id=net0",
+					"hostfwd=tcp::8080-:80",
+					"hostfwd=udp:127.0.0.1:5353-:53"),
+				RepeatableArg("device", "virtio-net-pci", "netdev=net0"),
+			},
+			assert: assert.Subset,
+		},
+		{
+			name: "network mmio",
+			spec: CommandSpec{
+				TransportType: TransportTypeMMIO,
+				Network:       &Network{},
+			},
+			expect: []Argument{
+				RepeatableArg("netdev", "user", "id=net0"),
+				RepeatableArg("device", "virtio-net-device", "netdev=net0"),
+			},
+			assert: assert.Subset,
+		},
+		{
+			name: "rng source pci",
+			spec: CommandSpec{
+				TransportType: TransportTypePCI,
+				RNGSource:     "/tmp/rng,1",
+			},
+			expect: []Argument{
+				RepeatableArg("object", "rng-random", "id=rng0",
+					"filename=/tmp/rng,,1"),
+				RepeatableArg("device", "virtio-rng-pci", "rng=rng0"),
+			},
+			assert: assert.Subset,
+		},
+		{
+			name: "rng source mmio",
+			spec: CommandSpec{
+				TransportType: TransportTypeMMIO,
+				RNGSource:     "/tmp/rng",
+			},
+			expect: RepeatableArg("device", "virtio-rng-device", "rng=rng0"),
+			assert: assert.Contains,
+		},
+		{
+			name: "rng pci",
+			spec: CommandSpec{
+				TransportType: TransportTypePCI,
+				RNG:           true,
+			},
+			expect: RepeatableArg("device", "virtio-rng-pci"),
+			assert: assert.Contains,
+		},
+		{
+			name: "rng mmio",
+			spec: CommandSpec{
+				TransportType: TransportTypeMMIO,
+				RNG:           true,
+			},
+			expect: RepeatableArg("device", "virtio-rng-device"),
+			assert: assert.Contains,
+		},
+		{
+			name: "rng disabled",
+			spec: CommandSpec{
+				TransportType: TransportTypePCI,
+			},
+			expect: RepeatableArg("device", "virtio-rng-pci"),
+			assert: assert.NotContains,
+		},
+		{
+			name: "rng microvm isa",
+			spec: CommandSpec{
+				Machine:       "microvm",
+				TransportType: TransportTypeISA,
+				RNG:           true,
+			},
+			expect: RepeatableArg("device", "virtio-rng-pci"),
+			assert: assert.NotContains,
+		},
+		{
+			name: "rng with source",
+			spec: CommandSpec{
+				TransportType: TransportTypePCI,
+				RNG:           true,
+				RNGSource:     "/tmp/rng",
+			},
+			expect: RepeatableArg("device", "virtio-rng-pci"),
+			assert: assert.NotContains,
+		},
+		{
+			name: "numa topology",
+			spec: CommandSpec{
+				SMP:       4,
+				Sockets:   2,
+				NUMANodes: 2,
+				Memory:    512,
+			},
+			expect: []Argument{
+				UniqueArg("smp", "4", "sockets=2"),
+				UniqueArg("m", "512"),
+				RepeatableArg("object",
+					"memory-backend-ram,id=numa0,size=256M"),
+				RepeatableArg("numa", "node,nodeid=0,cpus=0-1,memdev=numa0"),
+				RepeatableArg("object",
+					"memory-backend-ram,id=numa1,size=256M"),
+				RepeatableArg("numa", "node,nodeid=1,cpus=2-3,memdev=numa1"),
+			},
+			assert: assert.Subset,
+		},
+		{
+			name: "single numa node",
+			spec: CommandSpec{
+				SMP:       2,
+				NUMANodes: 1,
+				Memory:    512,
+			},
+			expect: RepeatableArg("numa",
+				"node,nodeid=0,cpus=0-1,memdev=numa0"),
+			assert: assert.NotContains,
+		},
+		{
+			name: "crash dump",
+			spec: CommandSpec{
+				Machine:       "q35",
+				DumpGuestCore: true,
+				CrashDump:     "/tmp/core",
+				qmpSocket:     "/tmp/qmp.sock",
+			},
+			expect: []Argument{
+				UniqueArg("machine", "q35,dump-guest-core=on"),
+				RepeatableArg("qmp", "unix:/tmp/qmp.sock,server=on,wait=off"),
+			},
+			assert: assert.Subset,
+		},
+		{
+			name: "qmp socket",
+			spec: CommandSpec{
+				QMPSocket: "/run/qmp.sock",
+			},
+			expect: RepeatableArg("qmp",
+				"unix:/run/qmp.sock,server=on,wait=off"),
+			assert: assert.Contains,
+		},
+		{
+			name: "qmp socket with comma",
+			spec: CommandSpec{
+				QMPSocket: "/run/qmp,wait=on.sock",
+			},
+			expect: RepeatableArg("qmp",
+				"unix:/run/qmp,,wait=on.sock,server=on,wait=off"),
+			assert: assert.Contains,
+		},
+		{
+			name: "qmp socket with crash dump",
+			spec: CommandSpec{
+				CrashDump: "/tmp/core",
+				qmpSocket: "/tmp/qmp.sock",
+				QMPSocket: "/run/qmp.sock",
+			},
+			expect: []Argument{
+				RepeatableArg("qmp", "unix:/tmp/qmp.sock,server=on,wait=off"),
+				RepeatableArg("qmp", "unix:/run/qmp.sock,server=on,wait=off"),
+			},
+			assert: assert.Subset,
+		},
+		{
+			name: "gdb",
+			spec: CommandSpec{
+				GDB: ":1234",
+			},
+			expect: UniqueArg("gdb", "tcp::1234"),
+			assert: assert.Contains,
+		},
+		{
+			name: "gdb without wait",
+			spec: CommandSpec{
+				GDB: ":1234",
+			},
+			expect: FlagArg("S"),
+			assert: assert.NotContains,
+		},
+		{
+			name: "gdb with wait",
+			spec: CommandSpec{
+				GDB:     "localhost:1234",
+				GDBWait: true,
+			},
+			expect: []Argument{
+				UniqueArg("gdb", "tcp:localhost:1234"),
+				FlagArg("S"),
+			},
+			assert: assert.Subset,
+		},
+		{
+			name: "crash dump kernel waits on panic",
+			spec: CommandSpec{
+				CrashDump: "/tmp/core",
+				qmpSocket: "/tmp/qmp.sock",
+			},
+			expect: "panic=0",
+			assert: ArgumentValueAssertionFunc("append", assert.Contains),
+		},
+		{
+			name: "acpi off with single cpu",
+			spec: CommandSpec{
+				SMP: 1,
+			},
+			expect: "acpi=off",
+			assert: ArgumentValueAssertionFunc("append", assert.Contains),
+		},
+		{
+			name: "acpi kept with single cpu",
+			spec: CommandSpec{
+				SMP:  1,
+				ACPI: true,
+			},
+			expect: "acpi=off",
+			assert: ArgumentValueAssertionFunc("append", assert.NotContains),
+		},
 		{
 			name:   "yes-kvm",
 			spec:   CommandSpec{},
-			expect: UniqueArg("enable-kvm"),
+			expect: FlagArg("enable-kvm"),
 			assert: assert.Contains,
 		},
 		{
@@ -48,10 +406,50 @@ func TestCommandSpec_Arguments(t *testing.T) {
 			spec: CommandSpec{
 				NoKVM: true,
 			},
-			expect: UniqueArg("enable-kvm"),
+			expect: FlagArg("enable-kvm"),
 			assert: assert.NotContains,
 		},
-
+		{
+			name: "accel",
+			spec: CommandSpec{
+				Accel: "tcg,thread=multi",
+			},
+			expect: UniqueArg("accel", "tcg,thread=multi"),
+			assert: assert.Contains,
+		},
+		{
+			name: "accel overrides kvm",
+			spec: CommandSpec{
+				Accel: "tcg,thread=multi",
+			},
+			expect: FlagArg("enable-kvm"),
+			assert: assert.NotContains,
+		},
+		{
+			name: "mem-lock",
+			spec: CommandSpec{
+				MemLock: true,
+			},
+			expect: UniqueArg("overcommit", "mem-lock=on"),
+			assert: assert.Contains,
+		},
+		{
+			name:   "no-mem-lock",
+			spec:   CommandSpec{},
+			expect: UniqueArg("overcommit", "mem-lock=on"),
+			assert: assert.NotContains,
+		},
+		{
+			name: "rtc",
+			spec: CommandSpec{
+				RTC: RTC{
+					Clock:    RTCClockVM,
+					DriftFix: RTCDriftFixSlew,
+				},
+			},
+			expect: UniqueArg("rtc", "clock=vm,driftfix=slew"),
+			assert: assert.Contains,
+		},
 		{
 			name: "yes-verbose",
 			spec: CommandSpec{
@@ -79,6 +477,39 @@ func TestCommandSpec_Arguments(t *testing.T) {
 			expect: " -- first second third",
 			assert: ArgumentValueAssertionFunc("append", assert.Contains),
 		},
+		{
+			name: "env vars",
+			spec: CommandSpec{
+				Env: []string{
+					"PATH=/data:/bin",
+					"GREETING=hello world",
+				},
+				InitArgs: []string{"first"},
+			},
+			expect: `quiet PATH=/data:/bin GREETING="hello world" -- first`,
+			assert: ArgumentValueAssertionFunc("append", assert.Contains),
+		},
+		{
+			name: "kernel args",
+			spec: CommandSpec{
+				Env:        []string{"PATH=/data:/bin"},
+				KernelArgs: []string{"earlyprintk=serial", "loglevel=7"},
+				InitArgs:   []string{"first"},
+			},
+			expect: "quiet PATH=/data:/bin earlyprintk=serial loglevel=7 " +
+				"-- first",
+			assert: ArgumentValueAssertionFunc("append", assert.Contains),
+		},
+		{
+			name: "kernel args verbose",
+			spec: CommandSpec{
+				KernelArgs: []string{"earlyprintk=serial"},
+				Verbose:    true,
+			},
+			expect: "initcall_blacklist=ahci_pci_driver_init " +
+				"earlyprintk=serial",
+			assert: ArgumentValueAssertionFunc("append", assert.Contains),
+		},
 		{
 			name: "serial files virtio-mmio",
 			spec: CommandSpec{
@@ -119,6 +550,64 @@ func TestCommandSpec_Arguments(t *testing.T) {
 			},
 			assert: assert.Subset,
 		},
+		{
+			name: "console backends",
+			spec: CommandSpec{
+				Consoles: []ConsoleBackend{
+					{Type: ConsoleBackendPty},
+					{Type: ConsoleBackendSocket, Path: "/run/con.sock"},
+					{Type: ConsoleBackendFile, Path: "/output/raw,1"},
+				},
+				AdditionalConsoles: []string{
+					"/output/file1",
+				},
+				TransportType: TransportTypePCI,
+			},
+			expect: []Argument{
+				RepeatableArg("chardev", "pty,id=con0"),
+				RepeatableArg("device", "virtconsole,chardev=con0"),
+				RepeatableArg("chardev",
+					"socket,id=con1,path=/run/con.sock,server=on,wait=off"),
+				RepeatableArg("device", "virtconsole,chardev=con1"),
+				RepeatableArg("chardev", "file,id=con2,path=/output/raw,,1"),
+				RepeatableArg("device", "virtconsole,chardev=con2"),
+				RepeatableArg("chardev", "file,id=con3,path=/dev/fd/3"),
+				RepeatableArg("device", "virtconsole,chardev=con3"),
+			},
+			assert: assert.Subset,
+		},
+		{
+			name:   "no monitor",
+			spec:   CommandSpec{},
+			expect: UniqueArg("monitor", "none"),
+			assert: assert.Contains,
+		},
+		{
+			name: "monitor pty",
+			spec: CommandSpec{
+				Monitor: &ConsoleBackend{Type: ConsoleBackendPty},
+			},
+			expect: []Argument{
+				RepeatableArg("chardev", "pty,id=monitor"),
+				UniqueArg("monitor", "chardev:monitor"),
+			},
+			assert: assert.Subset,
+		},
+		{
+			name: "monitor socket",
+			spec: CommandSpec{
+				Monitor: &ConsoleBackend{
+					Type: ConsoleBackendSocket,
+					Path: "/run/mon.sock",
+				},
+			},
+			expect: []Argument{
+				RepeatableArg("chardev",
+					"socket,id=monitor,path=/run/mon.sock,server=on,wait=off"),
+				UniqueArg("monitor", "chardev:monitor"),
+			},
+			assert: assert.Subset,
+		},
 		{
 			name: "serial files isa-pci",
 			spec: CommandSpec{
@@ -174,8 +663,8 @@ func TestNewCommand(t *testing.T) {
 				cmd: exec.CommandContext(
 					context.Background(),
 					"test",
-					"-kernel",
-					"-initrd",
+					"-kernel", "",
+					"-initrd", "",
 					"-chardev", "stdio,id=stdio",
 					"-serial", "chardev:stdio",
 					"-chardev", "file,id=con0,path=/dev/fd/3",
@@ -215,11 +704,47 @@ func TestNewCommand(t *testing.T) {
 	}
 }
 
-func TestCommand_Run(t *testing.T) {
-	tempDir := t.TempDir()
+func TestNewCommand_Snapshot(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "disk.img")
 
-	tests := []struct {
-		name      string
+	require.NoError(t, os.WriteFile(image, nil, 0o600))
+
+	for _, snapshot := range []bool{false, true} {
+		t.Run(strconv.FormatBool(snapshot), func(t *testing.T) {
+			spec := CommandSpec{
+				Executable:    "test",
+				TransportType: TransportTypePCI,
+				Disks:         []Disk{{Path: image}},
+				Snapshot:      snapshot,
+				ExitCodeFmt:   "rrr",
+			}
+
+			cmd, err := NewCommand(context.Background(), spec)
+			require.NoError(t, err)
+
+			expected := 0
+			if snapshot {
+				expected = 1
+			}
+
+			count := 0
+
+			for _, arg := range cmd.cmd.Args {
+				if arg == "-snapshot" {
+					count++
+				}
+			}
+
+			assert.Equal(t, expected, count)
+		})
+	}
+}
+
+func TestCommand_Run(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name      string
 		cmd       Command
 		assertErr require.ErrorAssertionFunc
 	}{
@@ -270,6 +795,202 @@ func TestCommand_Run(t *testing.T) {
 				assert.Equal(t, ErrGuestNonZeroExitCode, cmdErr.Err)
 			},
 		},
+		{
+			name: "qemu failure",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c", "echo booting; exit 3"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				var cmdErr *CommandError
+				require.ErrorAs(t, err, &cmdErr)
+				assert.False(t, cmdErr.Guest)
+				assert.Equal(t, 3, cmdErr.ExitCode)
+				assert.False(t, IsGuestFailure(err))
+			},
+		},
+		{
+			name: "qemu crash",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c", "kill -SEGV $$"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				var cmdErr *CommandError
+				require.ErrorAs(t, err, &cmdErr)
+				assert.False(t, cmdErr.Guest)
+				assert.Equal(t, -1, cmdErr.ExitCode)
+				assert.ErrorContains(t, err, "segmentation fault")
+			},
+		},
+		{
+			name: "qemu failure after guest exit",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c", "echo 'rc: 42'; exit 3"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				var cmdErr *CommandError
+				require.ErrorAs(t, err, &cmdErr)
+				assert.True(t, cmdErr.Guest)
+				assert.Equal(t, 42, cmdErr.ExitCode)
+				assert.True(t, IsGuestFailure(err))
+				require.ErrorIs(t, err, ErrGuestNonZeroExitCode)
+			},
+		},
+		{
+			name: "qemu failure after guest success",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c", "echo 'rc: 0'; exit 3"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "idle timeout",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c",
+					"echo first; exec sleep 10"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+				idleTimeout: 100 * time.Millisecond,
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrGuestIdleTimeout)
+			},
+		},
+		{
+			name: "timeout",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c",
+					"while true; do echo alive; sleep 0.02; done"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+				idleTimeout: time.Second,
+				timeout:     100 * time.Millisecond,
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrGuestTimeout)
+				require.ErrorContains(t, err, "timeout 100ms exceeded")
+				require.ErrorContains(t, err, "alive")
+				assert.True(t, IsGuestFailure(err))
+			},
+		},
+		{
+			name: "exit code within timeout",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c", "echo 'rc: 0'; sleep 0.3"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+				timeout: 100 * time.Millisecond,
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "active output within idle timeout",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c",
+					"for i in 1 2 3 4 5; do echo $i; sleep 0.05; done; "+
+						"echo 'rc: 0'"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+				idleTimeout: 200 * time.Millisecond,
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "exit code within idle timeout",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c",
+					"echo 'rc: 0'; echo 'powering off'; sleep 0.3"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+				idleTimeout: 100 * time.Millisecond,
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "wait for line matched",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c",
+					"echo starting; echo 'service ready'; exec sleep 10"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+				waitForLine:        regexp.MustCompile(`ready$`),
+				waitForLineTimeout: 5 * time.Second,
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "wait for line timeout",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c",
+					"echo starting; exec sleep 10"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+				waitForLine:        regexp.MustCompile(`ready$`),
+				waitForLineTimeout: 100 * time.Millisecond,
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrWaitForLineTimeout)
+			},
+		},
+		{
+			name: "boot in time",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c",
+					"echo booting; echo BOOTED; echo 'rc: 0'"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+				bootMarker:  "BOOTED",
+				maxBootTime: 5 * time.Second,
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "boot too late",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c",
+					"echo booting; exec sleep 10"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+				bootMarker:  "BOOTED",
+				maxBootTime: 100 * time.Millisecond,
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrGuestBootTimeout)
+			},
+		},
+		{
+			name: "wait for line not found",
+			cmd: Command{
+				cmd: exec.Command("sh", "-c", "echo 'rc: 0'"),
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+				waitForLine: regexp.MustCompile(`ready$`),
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrWaitForLineNotFound)
+			},
+		},
 		{
 			name: "start error with consoles",
 			cmd: Command{
@@ -297,3 +1018,411 @@ func TestCommand_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestCommand_Run_CompressedOutput(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	path := filepath.Join(t.TempDir(), "out.gz")
+
+	cmd := Command{
+		cmd: exec.Command("sh", "-c",
+			"printf 'line one\\r\\nline two\\n' >&3; echo 'rc: 0'"),
+		stdoutParser: stdoutParser{
+			ExitCodeFmt: "rc: %d",
+		},
+		consoleOutput: []string{path},
+	}
+
+	require.NoError(t, cmd.Run(nil, nil, nil))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	assert.Equal(t, "line one\nline two\n", string(content))
+}
+
+func TestCommand_Run_Terminated(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	path := filepath.Join(t.TempDir(), "out")
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		500*time.Millisecond,
+	)
+	defer cancel()
+
+	cmd := Command{
+		cmd: exec.CommandContext(ctx, "sh", "-c",
+			"echo console >&3; echo first; echo 'rc: 3'; echo second; "+
+				"exec sleep 10"),
+		stdoutParser: stdoutParser{
+			ExitCodeFmt: "rc: %d",
+			Verbose:     true,
+		},
+		consoleOutput: []string{path},
+	}
+	cmd.setCancel(ctx)
+
+	var stdout bytes.Buffer
+
+	err := cmd.Run(nil, &stdout, nil)
+	require.ErrorIs(t, err, ErrGuestTimeout)
+	require.ErrorIs(t, err, &CommandError{})
+
+	assert.ErrorContains(t, err, "exit code 3 found")
+	assert.ErrorContains(t, err, "last output:\nfirst\nrc: 3\nsecond")
+
+	var cmdErr *CommandError
+	require.ErrorAs(t, err, &cmdErr)
+	assert.Equal(t, 3, cmdErr.ExitCode)
+
+	assert.Equal(t, "first\nrc: 3\nsecond\n", stdout.String())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "console\n", string(content))
+}
+
+func TestCommand_Run_OnReady(t *testing.T) {
+	errHook := errors.New("hook failed")
+
+	tests := []struct {
+		name       string
+		script     string
+		waitFor    *regexp.Regexp
+		bootMarker string
+		hookErr    error
+		assertErr  require.ErrorAssertionFunc
+	}{
+		{
+			name: "wait for line",
+			script: "echo starting; sleep 0.1; touch ready; echo ready; " +
+				"exec sleep 10",
+			waitFor:   regexp.MustCompile(`ready$`),
+			assertErr: require.NoError,
+		},
+		{
+			name: "boot marker",
+			script: "echo starting; sleep 0.1; touch ready; echo BOOTED; " +
+				"echo 'rc: 0'",
+			bootMarker: "BOOTED",
+			assertErr:  require.NoError,
+		},
+		{
+			name: "hook fails",
+			script: "echo starting; touch ready; echo BOOTED; " +
+				"exec sleep 10",
+			bootMarker: "BOOTED",
+			hookErr:    errHook,
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, errHook)
+				require.NotErrorIs(t, err, &CommandError{})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			var called atomic.Bool
+
+			execCmd := exec.Command("sh", "-c", tt.script)
+			execCmd.Dir = dir
+
+			cmd := Command{
+				cmd: execCmd,
+				stdoutParser: stdoutParser{
+					ExitCodeFmt: "rc: %d",
+				},
+				waitForLine: tt.waitFor,
+				bootMarker:  tt.bootMarker,
+				onReady: func() error {
+					called.Store(true)
+
+					// The file is created right before the guest is ready.
+					assert.FileExists(t, filepath.Join(dir, "ready"))
+
+					return tt.hookErr
+				},
+			}
+
+			err := cmd.Run(nil, io.Discard, nil)
+			tt.assertErr(t, err)
+
+			assert.True(t, called.Load(), "hook called")
+		})
+	}
+}
+
+func TestCommand_Run_Filter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out")
+
+	cmd := Command{
+		cmd: exec.Command("sh", "-c",
+			"echo 'console keep' >&3; echo 'console spam' >&3; "+
+				"echo keep; echo spam; echo 'rc: 0'"),
+		stdoutParser: stdoutParser{
+			ExitCodeFmt: "rc: %d",
+			Verbose:     true,
+		},
+		consoleOutput: []string{path},
+		consoleFilters: map[string]*OutputFilter{
+			path: {Exclude: regexp.MustCompile(`spam`)},
+		},
+		stdoutFilter: &OutputFilter{
+			Include: regexp.MustCompile(`keep`),
+		},
+	}
+
+	var stdout bytes.Buffer
+
+	err := cmd.Run(nil, &stdout, nil)
+	require.NoError(t, err, "exit code line is parsed although filtered")
+
+	assert.Equal(t, "keep\n", stdout.String())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "console keep\n", string(content))
+}
+
+func TestCommand_Run_OnBooted(t *testing.T) {
+	var booted atomic.Bool
+
+	cmd := Command{
+		cmd: exec.Command("sh", "-c", "echo BOOTED; echo 'rc: 0'"),
+		stdoutParser: stdoutParser{
+			ExitCodeFmt: "rc: %d",
+		},
+		bootMarker: "BOOTED",
+		onBooted: func() {
+			booted.Store(true)
+		},
+	}
+
+	err := cmd.Run(nil, io.Discard, nil)
+	require.NoError(t, err)
+
+	assert.True(t, booted.Load(), "on booted called")
+}
+
+func TestCommand_Run_StderrTail(t *testing.T) {
+	cmd := Command{
+		cmd: exec.Command("sh", "-c",
+			"echo 'diagnosis' >&2; echo 'rc: 0'"),
+		stdoutParser: stdoutParser{
+			ExitCodeFmt: "rc: %d",
+		},
+	}
+
+	var stderr bytes.Buffer
+
+	err := cmd.Run(nil, io.Discard, &stderr)
+	require.NoError(t, err)
+
+	assert.Equal(t, "diagnosis\n", stderr.String(), "stderr passed through")
+	assert.Equal(t, []byte("diagnosis\n"), cmd.StderrTail())
+}
+
+func TestCommand_Start_ResourceLimits(t *testing.T) {
+	cmd := Command{
+		cmd: exec.Command("sleep", "10"),
+		resourceLimits: ResourceLimits{
+			CPUTime:      1500 * time.Millisecond,
+			AddressSpace: 4096,
+		},
+	}
+
+	require.NoError(t, cmd.start())
+
+	t.Cleanup(func() {
+		_ = cmd.cmd.Process.Kill()
+		_ = cmd.cmd.Wait()
+	})
+
+	pid := cmd.cmd.Process.Pid
+
+	var cpuLimit, asLimit unix.Rlimit
+
+	require.NoError(t, unix.Prlimit(pid, unix.RLIMIT_CPU, nil, &cpuLimit))
+	require.NoError(t, unix.Prlimit(pid, unix.RLIMIT_AS, nil, &asLimit))
+
+	assert.Equal(t, unix.Rlimit{Cur: 2, Max: 2}, cpuLimit,
+		"cpu time should be rounded up to whole seconds")
+	assert.Equal(t, unix.Rlimit{Cur: 4096 << 20, Max: 4096 << 20}, asLimit)
+}
+
+func TestNewCommand_QMPSocketRemoved(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "qmp.sock")
+
+	cmd, err := NewCommand(context.Background(), CommandSpec{
+		Executable:    "qemu-system-x86_64",
+		TransportType: TransportTypePCI,
+		ExitCodeFmt:   "rc: %d",
+		QMPSocket:     socket,
+	})
+	require.NoError(t, err)
+
+	// Created by QEMU once it runs.
+	require.NoError(t, os.WriteFile(socket, nil, 0o600))
+
+	cmd.close()
+
+	assert.NoFileExists(t, socket)
+}
+
+func TestNewCommand_GDBWaitTimeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		wait     bool
+		expected time.Duration
+	}{
+		{
+			name:     "kept without wait",
+			expected: time.Minute,
+		},
+		{
+			name:     "disabled with wait",
+			wait:     true,
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := NewCommand(context.Background(), CommandSpec{
+				Executable:         "qemu-system-x86_64",
+				TransportType:      TransportTypePCI,
+				ExitCodeFmt:        "rc: %d",
+				GDB:                ":1234",
+				GDBWait:            tt.wait,
+				IdleTimeout:        time.Minute,
+				Timeout:            time.Minute,
+				MaxBootTime:        time.Minute,
+				BootMarker:         "booted",
+				WaitForLine:        regexp.MustCompile("ready"),
+				WaitForLineTimeout: time.Minute,
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, cmd.idleTimeout)
+			assert.Equal(t, tt.expected, cmd.timeout)
+			assert.Equal(t, tt.expected, cmd.maxBootTime)
+			assert.Equal(t, tt.expected, cmd.waitForLineTimeout)
+		})
+	}
+}
+
+func TestNewCommand_ScratchDisk(t *testing.T) {
+	disks := []Disk{{Size: 1 << 20}}
+
+	cmd, err := NewCommand(context.Background(), CommandSpec{
+		Executable:    "qemu-system-x86_64",
+		TransportType: TransportTypePCI,
+		ExitCodeFmt:   "rc: %d",
+		Disks:         disks,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, cmd.scratchDisks, 1)
+
+	path := cmd.scratchDisks[0].Path
+	assert.Equal(t, os.TempDir(), filepath.Dir(path))
+	assert.Contains(t, cmd.cmd.Args,
+		"file="+path+",if=none,id=disk0,format=raw")
+	assert.Empty(t, disks[0].Path, "given disks must not be modified")
+	assert.NoFileExists(t, path, "must be created on run only")
+}
+
+func TestCommand_Run_ScratchDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scratch.img")
+
+	cmd := Command{
+		cmd: exec.Command("sh", "-c",
+			"stat -c '%s %b' "+path+" && echo 'rc: 0'"),
+		stdoutParser: stdoutParser{
+			ExitCodeFmt: "rc: %d",
+		},
+		scratchDisks: []Disk{{Path: path, Size: 1 << 30}},
+	}
+
+	var stdout bytes.Buffer
+
+	err := cmd.Run(nil, &stdout, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "1073741824 0\n", stdout.String(),
+		"sparse image with full size")
+	assert.NoFileExists(t, path, "removed once done")
+}
+
+func TestCommand_Run_ScratchDiskExists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scratch.img")
+
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0o600))
+
+	cmd := Command{
+		cmd: exec.Command("true"),
+		stdoutParser: stdoutParser{
+			ExitCodeFmt: "rc: %d",
+		},
+		scratchDisks: []Disk{{Path: path, Size: 1 << 20}},
+	}
+
+	err := cmd.Run(nil, io.Discard, nil)
+	require.ErrorIs(t, err, os.ErrExist)
+
+	assert.FileExists(t, path, "foreign file must be kept")
+}
+
+func TestCommand_Run_CrashDump(t *testing.T) {
+	var dumped atomic.Bool
+
+	cmd := Command{
+		cmd: exec.Command("sh", "-c",
+			"echo '[    1.000000] Kernel panic - not syncing: test'; "+
+				"exec sleep 10"),
+		stdoutParser: stdoutParser{
+			ExitCodeFmt: "rc: %d",
+		},
+		crashDump: func() error {
+			dumped.Store(true)
+			return nil
+		},
+	}
+
+	start := time.Now()
+
+	err := cmd.Run(nil, io.Discard, nil)
+	require.ErrorIs(t, err, ErrGuestPanic)
+
+	assert.True(t, dumped.Load(), "dump triggered")
+	assert.Less(t, time.Since(start), 5*time.Second, "guest shut down")
+}
+
+func TestCommand_Run_CrashDumpFails(t *testing.T) {
+	cmd := Command{
+		cmd: exec.Command("sh", "-c",
+			"echo '[    1.000000] Kernel panic - not syncing: test'; "+
+				"exec sleep 10"),
+		stdoutParser: stdoutParser{
+			ExitCodeFmt: "rc: %d",
+		},
+		crashDump: func() error {
+			return ErrQMP
+		},
+	}
+
+	err := cmd.Run(nil, io.Discard, nil)
+	require.ErrorIs(t, err, ErrGuestPanic, "panic error is not masked")
+}
@@ -5,10 +5,15 @@
 package qemu_test
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aibor/virtrun/internal/qemu"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCommmandAddExtraFile(t *testing.T) {
@@ -20,3 +25,654 @@ func TestCommmandAddExtraFile(t *testing.T) {
 	assert.Equal(t, "hvc2", d2)
 	assert.Equal(t, []string{"test", "real"}, s.AdditionalConsoles)
 }
+
+func TestCommmandAddExtraFileAfterConsoles(t *testing.T) {
+	s := qemu.CommandSpec{
+		Consoles: []qemu.ConsoleBackend{
+			{Type: qemu.ConsoleBackendPty},
+		},
+	}
+
+	assert.Equal(t, "hvc2", s.AddConsole("test"))
+}
+
+func TestCommandSpec_AddNamedConsoles(t *testing.T) {
+	tests := []struct {
+		transportType qemu.TransportType
+		expected      map[string]string
+	}{
+		{
+			transportType: qemu.TransportTypeISA,
+			expected: map[string]string{
+				"events": "/dev/ttyS2",
+				"trace":  "/dev/ttyS3",
+			},
+		},
+		{
+			transportType: qemu.TransportTypePCI,
+			expected: map[string]string{
+				"events": "/dev/hvc2",
+				"trace":  "/dev/hvc3",
+			},
+		},
+		{
+			transportType: qemu.TransportTypeMMIO,
+			expected: map[string]string{
+				"events": "/dev/hvc2",
+				"trace":  "/dev/hvc3",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.transportType), func(t *testing.T) {
+			s := qemu.CommandSpec{TransportType: tt.transportType}
+			s.AddConsole("first")
+
+			actual := s.AddNamedConsoles(map[string]string{
+				"trace":  "/host/trace.log",
+				"events": "/host/events.json",
+			})
+
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, []string{
+				"first",
+				"/host/events.json",
+				"/host/trace.log",
+			}, s.AdditionalConsoles, "added in order of names")
+		})
+	}
+}
+
+func TestCommandSpec_ValidateConsoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend qemu.ConsoleBackend
+		valid   bool
+	}{
+		{
+			name:    "pty",
+			backend: qemu.ConsoleBackend{Type: qemu.ConsoleBackendPty},
+			valid:   true,
+		},
+		{
+			name: "socket",
+			backend: qemu.ConsoleBackend{
+				Type: qemu.ConsoleBackendSocket,
+				Path: "/run/con.sock",
+			},
+			valid: true,
+		},
+		{
+			name:    "socket without path",
+			backend: qemu.ConsoleBackend{Type: qemu.ConsoleBackendSocket},
+		},
+		{
+			name:    "file without path",
+			backend: qemu.ConsoleBackend{Type: qemu.ConsoleBackendFile},
+		},
+		{
+			name:    "stdio",
+			backend: qemu.ConsoleBackend{Type: qemu.ConsoleBackendStdio},
+		},
+		{
+			name:    "unknown",
+			backend: qemu.ConsoleBackend{Type: "vc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := qemu.CommandSpec{
+				TransportType: qemu.TransportTypePCI,
+				Consoles:      []qemu.ConsoleBackend{tt.backend},
+			}
+
+			err := spec.Validate()
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, &qemu.ArgumentError{})
+			}
+		})
+	}
+}
+
+func TestCommandSpec_ValidateMonitor(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend qemu.ConsoleBackend
+		valid   bool
+	}{
+		{
+			name:    "pty",
+			backend: qemu.ConsoleBackend{Type: qemu.ConsoleBackendPty},
+			valid:   true,
+		},
+		{
+			name: "socket",
+			backend: qemu.ConsoleBackend{
+				Type: qemu.ConsoleBackendSocket,
+				Path: "/run/mon.sock",
+			},
+			valid: true,
+		},
+		{
+			name:    "socket without path",
+			backend: qemu.ConsoleBackend{Type: qemu.ConsoleBackendSocket},
+		},
+		{
+			name:    "stdio",
+			backend: qemu.ConsoleBackend{Type: qemu.ConsoleBackendStdio},
+		},
+		{
+			name: "file",
+			backend: qemu.ConsoleBackend{
+				Type: qemu.ConsoleBackendFile,
+				Path: "/output/mon",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := qemu.CommandSpec{
+				TransportType: qemu.TransportTypePCI,
+				Monitor:       &tt.backend,
+			}
+
+			err := spec.Validate()
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, &qemu.ArgumentError{})
+			}
+		})
+	}
+}
+
+func TestCommandSpec_ValidateQMPSocket(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.sock")
+
+	require.NoError(t, os.WriteFile(existing, nil, 0o600))
+
+	tests := []struct {
+		name  string
+		path  string
+		valid bool
+	}{
+		{
+			name:  "creatable",
+			path:  filepath.Join(dir, "qmp.sock"),
+			valid: true,
+		},
+		{
+			name: "existing",
+			path: existing,
+		},
+		{
+			name: "missing directory",
+			path: filepath.Join(dir, "missing", "qmp.sock"),
+		},
+		{
+			name: "too long",
+			path: filepath.Join(dir, strings.Repeat("a", 108)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := qemu.CommandSpec{
+				TransportType: qemu.TransportTypePCI,
+				QMPSocket:     tt.path,
+			}
+
+			err := spec.Validate()
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, &qemu.ArgumentError{})
+			}
+		})
+	}
+}
+
+func TestCommandSpec_ValidateGDB(t *testing.T) {
+	tests := []struct {
+		name  string
+		gdb   qemu.GDBAddress
+		wait  bool
+		valid bool
+	}{
+		{
+			name:  "address",
+			gdb:   ":1234",
+			valid: true,
+		},
+		{
+			name:  "address with wait",
+			gdb:   ":1234",
+			wait:  true,
+			valid: true,
+		},
+		{
+			name: "invalid address",
+			gdb:  "1234",
+		},
+		{
+			name: "wait without address",
+			wait: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := qemu.CommandSpec{
+				TransportType: qemu.TransportTypePCI,
+				GDB:           tt.gdb,
+				GDBWait:       tt.wait,
+			}
+
+			err := spec.Validate()
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, &qemu.ArgumentError{})
+			}
+		})
+	}
+}
+
+func TestCommandSpec_ValidateShares(t *testing.T) {
+	tests := []struct {
+		name   string
+		shares []qemu.Share
+		valid  bool
+	}{
+		{
+			name: "valid",
+			shares: []qemu.Share{
+				{HostPath: "/srv/a", Tag: "a"},
+				{HostPath: "/srv/b", Tag: "b_2-x", ReadOnly: true},
+			},
+			valid: true,
+		},
+		{
+			name: "duplicate tag",
+			shares: []qemu.Share{
+				{HostPath: "/srv/a", Tag: "a"},
+				{HostPath: "/srv/b", Tag: "a"},
+			},
+		},
+		{
+			name:   "empty tag",
+			shares: []qemu.Share{{HostPath: "/srv/a"}},
+		},
+		{
+			name:   "invalid tag",
+			shares: []qemu.Share{{HostPath: "/srv/a", Tag: "a,b"}},
+		},
+		{
+			name: "tag too long",
+			shares: []qemu.Share{
+				{HostPath: "/srv/a", Tag: strings.Repeat("a", 32)},
+			},
+		},
+		{
+			name:   "empty host path",
+			shares: []qemu.Share{{Tag: "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := qemu.CommandSpec{
+				TransportType: qemu.TransportTypePCI,
+				Shares:        tt.shares,
+			}
+
+			err := spec.Validate()
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, &qemu.ArgumentError{})
+			}
+		})
+	}
+}
+
+func TestCommandSpec_ValidateDisks(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "disk.img")
+
+	require.NoError(t, os.WriteFile(image, nil, 0o600))
+
+	tests := []struct {
+		name  string
+		disk  qemu.Disk
+		valid bool
+	}{
+		{
+			name: "valid",
+			disk: qemu.Disk{
+				Path:   image,
+				Format: "qcow2",
+				Throttle: qemu.DiskThrottle{
+					BPSRead:  1 << 20,
+					BPSWrite: 1 << 19,
+					IOPS:     100,
+				},
+			},
+			valid: true,
+		},
+		{
+			name:  "scratch",
+			disk:  qemu.Disk{Size: 1 << 20},
+			valid: true,
+		},
+		{
+			name: "empty path",
+			disk: qemu.Disk{},
+		},
+		{
+			name: "missing image",
+			disk: qemu.Disk{Path: filepath.Join(dir, "missing.img")},
+		},
+		{
+			name: "directory image",
+			disk: qemu.Disk{Path: dir},
+		},
+		{
+			name: "path and size",
+			disk: qemu.Disk{Path: image, Size: 1 << 20},
+		},
+		{
+			name: "unknown format",
+			disk: qemu.Disk{Path: image, Format: "vmdk"},
+		},
+		{
+			name: "bps total with read",
+			disk: qemu.Disk{
+				Path:     image,
+				Throttle: qemu.DiskThrottle{BPS: 1, BPSRead: 1},
+			},
+		},
+		{
+			name: "iops total with write",
+			disk: qemu.Disk{
+				Path:     image,
+				Throttle: qemu.DiskThrottle{IOPS: 1, IOPSWrite: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := qemu.CommandSpec{
+				TransportType: qemu.TransportTypePCI,
+				Disks:         []qemu.Disk{tt.disk},
+			}
+
+			err := spec.Validate()
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, &qemu.ArgumentError{})
+			}
+		})
+	}
+}
+
+func TestCommandSpec_ValidateSMBIOS(t *testing.T) {
+	system := qemu.SMBIOS{
+		Type:   1,
+		Fields: map[string]string{"product": "Rocket"},
+	}
+
+	spec := qemu.CommandSpec{
+		TransportType: qemu.TransportTypePCI,
+		SMBIOS:        []qemu.SMBIOS{system},
+	}
+	require.NoError(t, spec.Validate())
+
+	spec.SMBIOS = append(spec.SMBIOS, system)
+	require.ErrorIs(t, spec.Validate(), &qemu.ArgumentError{})
+
+	spec.SMBIOS = []qemu.SMBIOS{{Type: 1}}
+	require.ErrorIs(t, spec.Validate(), &qemu.ArgumentError{})
+}
+
+func TestCommandSpec_ValidateRTC(t *testing.T) {
+	tests := []struct {
+		name    string
+		machine string
+		rtc     qemu.RTC
+		valid   bool
+	}{
+		{
+			name:    "slew on q35",
+			machine: "q35",
+			rtc:     qemu.RTC{DriftFix: qemu.RTCDriftFixSlew},
+			valid:   true,
+		},
+		{
+			name:    "slew on virt",
+			machine: "virt",
+			rtc:     qemu.RTC{DriftFix: qemu.RTCDriftFixSlew},
+		},
+		{
+			name:    "vm clock on virt",
+			machine: "virt",
+			rtc:     qemu.RTC{Clock: qemu.RTCClockVM},
+			valid:   true,
+		},
+		{
+			name:    "unknown clock",
+			machine: "q35",
+			rtc:     qemu.RTC{Clock: "wall"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := qemu.CommandSpec{
+				Machine:       tt.machine,
+				TransportType: qemu.TransportTypeMMIO,
+				RTC:           tt.rtc,
+			}
+
+			if tt.machine == "q35" {
+				spec.TransportType = qemu.TransportTypePCI
+			}
+
+			err := spec.Validate()
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, &qemu.ArgumentError{})
+			}
+		})
+	}
+}
+
+func TestCommandSpec_ValidateTopology(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  qemu.CommandSpec
+		valid bool
+	}{
+		{
+			name: "default",
+			spec: qemu.CommandSpec{
+				SMP:    3,
+				Memory: 256,
+			},
+			valid: true,
+		},
+		{
+			name: "sockets and numa nodes",
+			spec: qemu.CommandSpec{
+				SMP:       4,
+				Memory:    256,
+				Sockets:   2,
+				NUMANodes: 2,
+				HostCPUs:  []int{0, 2, 4, 6},
+			},
+			valid: true,
+		},
+		{
+			name: "smp not divisible by sockets",
+			spec: qemu.CommandSpec{
+				SMP:     3,
+				Memory:  256,
+				Sockets: 2,
+			},
+		},
+		{
+			name: "smp not divisible by numa nodes",
+			spec: qemu.CommandSpec{
+				SMP:       3,
+				Memory:    256,
+				NUMANodes: 2,
+			},
+		},
+		{
+			name: "memory not divisible by numa nodes",
+			spec: qemu.CommandSpec{
+				SMP:       2,
+				Memory:    255,
+				NUMANodes: 2,
+			},
+		},
+		{
+			name: "invalid host cpu",
+			spec: qemu.CommandSpec{
+				SMP:      1,
+				Memory:   256,
+				HostCPUs: []int{-1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.spec.TransportType = qemu.TransportTypePCI
+
+			err := tt.spec.Validate()
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, &qemu.ArgumentError{})
+			}
+		})
+	}
+}
+
+func TestCommandSpec_ValidateResourceLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits qemu.ResourceLimits
+		valid  bool
+	}{
+		{
+			name:  "none",
+			valid: true,
+		},
+		{
+			name: "valid",
+			limits: qemu.ResourceLimits{
+				CPUTime:      time.Minute,
+				AddressSpace: 4096,
+			},
+			valid: true,
+		},
+		{
+			name: "negative cpu time",
+			limits: qemu.ResourceLimits{
+				CPUTime: -time.Second,
+			},
+		},
+		{
+			name: "cpu time below a second",
+			limits: qemu.ResourceLimits{
+				CPUTime: 500 * time.Millisecond,
+			},
+		},
+		{
+			name: "address space not exceeding memory",
+			limits: qemu.ResourceLimits{
+				AddressSpace: 256,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := qemu.CommandSpec{
+				TransportType:  qemu.TransportTypePCI,
+				Memory:         256,
+				ResourceLimits: tt.limits,
+			}
+
+			err := spec.Validate()
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, &qemu.ArgumentError{})
+			}
+		})
+	}
+}
+
+func TestCommandSpec_ValidateKernelArgs(t *testing.T) {
+	spec := qemu.CommandSpec{
+		TransportType: qemu.TransportTypePCI,
+		KernelArgs:    []string{"earlyprintk=serial", "nokaslr"},
+	}
+	require.NoError(t, spec.Validate())
+
+	for _, arg := range []string{"", "--", "a b", "tab\there"} {
+		spec.KernelArgs = []string{arg}
+		require.ErrorIs(t, spec.Validate(), &qemu.ArgumentError{}, arg)
+	}
+}
+
+func TestCommandSpec_ValidateMaxBootTime(t *testing.T) {
+	spec := qemu.CommandSpec{
+		TransportType: qemu.TransportTypePCI,
+		MaxBootTime:   time.Second,
+	}
+
+	require.ErrorIs(t, spec.Validate(), &qemu.ArgumentError{})
+
+	spec.BootMarker = "BOOTED"
+
+	require.NoError(t, spec.Validate())
+}
+
+func TestCommandSpec_ValidateOnReady(t *testing.T) {
+	spec := qemu.CommandSpec{
+		TransportType: qemu.TransportTypePCI,
+		OnReady:       func() error { return nil },
+	}
+
+	require.ErrorIs(t, spec.Validate(), &qemu.ArgumentError{})
+
+	spec.BootMarker = "BOOTED"
+
+	require.NoError(t, spec.Validate())
+}
+
+func TestCommandSpec_ValidateOnBooted(t *testing.T) {
+	spec := qemu.CommandSpec{
+		TransportType: qemu.TransportTypePCI,
+		OnBooted:      func() {},
+	}
+
+	require.ErrorIs(t, spec.Validate(), &qemu.ArgumentError{})
+
+	spec.BootMarker = "BOOTED"
+
+	require.NoError(t, spec.Validate())
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+// monitorID is the id of the character device used for the QEMU monitor.
+const monitorID = "monitor"
+
+// ConsoleBackendType is the type of a QEMU character device backend used for
+// a console.
+type ConsoleBackendType string
+
+const (
+	// ConsoleBackendStdio connects the console to QEMU's stdio.
+	ConsoleBackendStdio ConsoleBackendType = "stdio"
+	// ConsoleBackendFile writes console output into a file.
+	ConsoleBackendFile ConsoleBackendType = "file"
+	// ConsoleBackendPty creates a new pseudo terminal for interactive use.
+	// QEMU prints the path of the pty on startup.
+	ConsoleBackendPty ConsoleBackendType = "pty"
+	// ConsoleBackendSocket listens on a unix socket for external consumers.
+	// QEMU does not wait for a client to connect.
+	ConsoleBackendSocket ConsoleBackendType = "socket"
+)
+
+// ConsoleBackend defines the host side of a console.
+type ConsoleBackend struct {
+	Type ConsoleBackendType
+
+	// Path is the file path for [ConsoleBackendFile] and the socket path for
+	// [ConsoleBackendSocket]. It is ignored for other types.
+	Path string
+}
+
+// validate checks if the type is known and the path is set if required.
+func (b ConsoleBackend) validate() error {
+	switch b.Type {
+	case ConsoleBackendFile, ConsoleBackendSocket:
+		if b.Path == "" {
+			return &ArgumentError{
+				"console backend " + string(b.Type) + " requires path",
+			}
+		}
+	case ConsoleBackendStdio, ConsoleBackendPty:
+	default:
+		return &ArgumentError{
+			"unknown console backend type: " + string(b.Type),
+		}
+	}
+
+	return nil
+}
+
+// validateConsole checks if the backend can be used for an additional
+// console.
+//
+// Stdio is used by the default console already and QEMU does not allow
+// another stdio character device.
+func (b ConsoleBackend) validateConsole() error {
+	if b.Type == ConsoleBackendStdio {
+		return &ArgumentError{
+			"console backend stdio is used by the default console already",
+		}
+	}
+
+	return b.validate()
+}
+
+// validateMonitor checks if the backend can be used for the QEMU monitor.
+//
+// The monitor requires input, so only interactive backends are supported.
+// Stdio is used by the default console already.
+func (b ConsoleBackend) validateMonitor() error {
+	switch b.Type {
+	case ConsoleBackendPty, ConsoleBackendSocket:
+		return b.validate()
+	case ConsoleBackendStdio, ConsoleBackendFile:
+	}
+
+	return &ArgumentError{
+		"monitor backend must be pty or socket: " + string(b.Type),
+	}
+}
+
+// chardevOptions returns the options for a QEMU "-chardev" argument with the
+// given id.
+func (b ConsoleBackend) chardevOptions(id string) []string {
+	opts := []string{string(b.Type), "id=" + id}
+
+	switch b.Type {
+	case ConsoleBackendFile:
+		opts = append(opts, "path="+escapeOptionValue(b.Path))
+	case ConsoleBackendSocket:
+		opts = append(opts, "path="+escapeOptionValue(b.Path), "server=on",
+			"wait=off")
+	case ConsoleBackendStdio, ConsoleBackendPty:
+		// No further options.
+	}
+
+	return opts
+}
@@ -6,6 +6,7 @@ package qemu
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -20,6 +21,9 @@ type lineParseFunc func([]byte) []byte
 // function returns non-nil data and dst is set, the output is written to dst.
 //
 // It can be used without a parse function set to just sanitize line endings.
+//
+// Lines are passed to the function as a whole, no matter how long they are.
+// So, long lines, like big JSON documents, are neither truncated nor split.
 type consoleProcessor struct {
 	dst io.Writer
 	src io.Reader
@@ -27,26 +31,42 @@ type consoleProcessor struct {
 }
 
 func (p consoleProcessor) run() error {
-	scanner := bufio.NewScanner(p.src)
-	for scanner.Scan() {
-		data := scanner.Bytes()
+	reader := bufio.NewReader(p.src)
+
+	for {
+		data, readErr := reader.ReadBytes('\n')
 
-		if p.fn != nil {
-			data = p.fn(data)
+		// A last line without line ending is processed as well.
+		if len(data) > 0 {
+			err := p.processLine(data)
+			if err != nil {
+				return err
+			}
 		}
 
-		err := p.writeLn(data)
-		if err != nil {
-			return err
+		switch {
+		case readErr == nil:
+			continue
+		case errors.Is(readErr, io.EOF), errors.Is(readErr, os.ErrClosed):
+			return nil
+		default:
+			//nolint:wrapcheck
+			return readErr
 		}
 	}
+}
 
-	if scanner.Err() != nil && !errors.Is(scanner.Err(), os.ErrClosed) {
-		//nolint:wrapcheck
-		return scanner.Err()
+// processLine strips the line ending from the given line, calls the parse
+// function, if any, and writes the result.
+func (p consoleProcessor) processLine(data []byte) error {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+
+	if p.fn != nil {
+		data = p.fn(data)
 	}
 
-	return nil
+	return p.writeLn(data)
 }
 
 func (p consoleProcessor) writeLn(data []byte) error {
@@ -6,6 +6,7 @@ package qemu
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -42,6 +43,11 @@ func TestConsoleProcessor_Run(t *testing.T) {
 			input:    "some first\nand second\nand third line",
 			expected: "some first\nand second\nand third line\n",
 		},
+		{
+			name:     "empty lines",
+			input:    "first\n\n\nlast\n",
+			expected: "first\n\n\nlast\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -60,3 +66,31 @@ func TestConsoleProcessor_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestConsoleProcessor_Run_LongLine(t *testing.T) {
+	line := strings.Repeat("0123456789abcdef", 64*1024)
+
+	var (
+		output bytes.Buffer
+		lines  []int
+	)
+
+	parser := stdoutParser{ExitCodeFmt: "rc: %d"}
+
+	processor := consoleProcessor{
+		dst: &output,
+		src: strings.NewReader(line + "\r\n" + line + "\nrc: 3\n"),
+		fn: func(data []byte) []byte {
+			lines = append(lines, len(data))
+			return parser.Parse(data)
+		},
+	}
+
+	err := processor.run()
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{len(line), len(line), len("rc: 3")}, lines)
+	assert.Equal(t, line+"\n"+line+"\n", output.String())
+	assert.True(t, parser.exitCodeFound)
+	assert.Equal(t, 3, parser.exitCode)
+}
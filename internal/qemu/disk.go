@@ -0,0 +1,313 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// diskCreatePrefix marks a scratch disk of the given size in [ParseDisk].
+const diskCreatePrefix = "create:"
+
+// diskSizeShifts are the binary shifts of the supported size suffixes.
+var diskSizeShifts = map[byte]uint{
+	'K': 10,
+	'M': 20,
+	'G': 30,
+	'T': 40,
+}
+
+// Disk is a disk image file attached to the guest as virtio block device.
+// The guest sees the disks as "/dev/vda", "/dev/vdb", ... in the order given.
+type Disk struct {
+	// Path is the path of the image file on the host. The file must exist,
+	// unless Size is set.
+	Path string
+
+	// Size is the size in bytes of a sparse raw scratch image, if not zero.
+	// It is created as temporary file once the command runs and removed once
+	// it is done. Path must be empty then, as it is set by [NewCommand].
+	Size uint64
+
+	// Format is the image format, like "raw" or "qcow2". Defaults to "raw".
+	Format string
+
+	// ReadOnly prevents the guest from writing to the disk.
+	ReadOnly bool
+
+	// Throttle limits the host I/O of the disk.
+	Throttle DiskThrottle
+}
+
+// DiskThrottle limits the host I/O of a [Disk] in bytes and operations per
+// second. Zero values are unlimited. The total limits can not be combined
+// with the respective read or write limits.
+type DiskThrottle struct {
+	BPS       uint64
+	BPSRead   uint64
+	BPSWrite  uint64
+	IOPS      uint64
+	IOPSRead  uint64
+	IOPSWrite uint64
+}
+
+// options returns the "-drive" throttling options of all non-zero limits.
+func (t DiskThrottle) options() []string {
+	limits := []struct {
+		name  string
+		value uint64
+	}{
+		{"bps-total", t.BPS},
+		{"bps-read", t.BPSRead},
+		{"bps-write", t.BPSWrite},
+		{"iops-total", t.IOPS},
+		{"iops-read", t.IOPSRead},
+		{"iops-write", t.IOPSWrite},
+	}
+
+	var options []string
+
+	for _, limit := range limits {
+		if limit.value == 0 {
+			continue
+		}
+
+		options = append(options,
+			"throttling."+limit.name+"="+strconv.FormatUint(limit.value, 10))
+	}
+
+	return options
+}
+
+// validate checks the total limits are not combined with read or write
+// limits, as QEMU rejects that.
+func (t DiskThrottle) validate() error {
+	if t.BPS > 0 && (t.BPSRead > 0 || t.BPSWrite > 0) {
+		return fmt.Errorf("%w: bps total combined with read or write",
+			ErrDiskInvalid)
+	}
+
+	if t.IOPS > 0 && (t.IOPSRead > 0 || t.IOPSWrite > 0) {
+		return fmt.Errorf("%w: iops total combined with read or write",
+			ErrDiskInvalid)
+	}
+
+	return nil
+}
+
+// ParseDisk parses a [Disk] given as "PATH" or "create:SIZE" for a scratch
+// disk, followed by optional comma separated options "format=FORMAT" and
+// "readonly=on". SIZE is a number of bytes with an optional K, M, G or T
+// suffix, like "64M".
+//
+// It returns [ErrDiskInvalid] if the string is not valid.
+func ParseDisk(s string) (Disk, error) {
+	var disk Disk
+
+	source, opts, hasOpts := strings.Cut(s, ",")
+
+	if size, found := strings.CutPrefix(source, diskCreatePrefix); found {
+		var err error
+
+		disk.Size, err = parseDiskSize(size)
+		if err != nil {
+			return disk, err
+		}
+	} else {
+		disk.Path = source
+	}
+
+	if hasOpts {
+		for _, opt := range strings.Split(opts, ",") {
+			key, value, _ := strings.Cut(opt, "=")
+
+			switch {
+			case key == "format":
+				disk.Format = value
+			case key == "readonly" && (value == "on" || value == "off"):
+				disk.ReadOnly = value == "on"
+			default:
+				return disk, fmt.Errorf("%w: unknown option: %s",
+					ErrDiskInvalid, opt)
+			}
+		}
+	}
+
+	return disk, disk.validate()
+}
+
+// parseDiskSize parses a size in bytes with an optional binary suffix.
+func parseDiskSize(s string) (uint64, error) {
+	var shift uint
+
+	if s != "" {
+		if suffixShift, found := diskSizeShifts[s[len(s)-1]]; found {
+			shift = suffixShift
+			s = s[:len(s)-1]
+		}
+	}
+
+	size, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || size == 0 || size > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("%w: size: %q", ErrDiskInvalid, s)
+	}
+
+	return size << shift, nil
+}
+
+// validate checks if the disk has either a path or a size, a known format and
+// valid throttling limits. Scratch disks must be raw images.
+func (d *Disk) validate() error {
+	switch {
+	case d.Size > math.MaxInt64:
+		return fmt.Errorf("%w: size too big", ErrDiskInvalid)
+	case d.Size > 0 && d.Path != "":
+		return fmt.Errorf("%w: path and size given", ErrDiskInvalid)
+	case d.Size == 0 && d.Path == "":
+		return fmt.Errorf("%w: empty path", ErrDiskInvalid)
+	}
+
+	switch d.Format {
+	case "", "raw":
+	case "qcow2":
+		if d.Size > 0 {
+			return fmt.Errorf("%w: scratch disks must be raw", ErrDiskInvalid)
+		}
+	default:
+		return fmt.Errorf("%w: format: %q", ErrDiskInvalid, d.Format)
+	}
+
+	return d.Throttle.validate()
+}
+
+// validateImage checks the image file of the disk exists and is not a
+// directory. Scratch disks are created on run, so they are not checked.
+func (d *Disk) validateImage() error {
+	if d.Size > 0 {
+		return nil
+	}
+
+	info, err := os.Stat(d.Path)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDiskInvalid, err)
+	}
+
+	if info.IsDir() {
+		return fmt.Errorf("%w: is a directory: %s", ErrDiskInvalid, d.Path)
+	}
+
+	return nil
+}
+
+// validateDisks checks all disks are valid and their image files exist.
+func (c *CommandSpec) validateDisks() error {
+	for _, disk := range c.Disks {
+		if err := disk.validate(); err != nil {
+			return &ArgumentError{err.Error()}
+		}
+
+		if err := disk.validateImage(); err != nil {
+			return &ArgumentError{err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// assignScratchDisks sets a unique temporary path for each scratch disk and
+// returns them. The disks are copied, so the given ones are not modified.
+func (c *CommandSpec) assignScratchDisks() []Disk {
+	var scratchDisks []Disk
+
+	c.Disks = slices.Clone(c.Disks)
+
+	for idx, disk := range c.Disks {
+		if disk.Size == 0 {
+			continue
+		}
+
+		c.Disks[idx].Path = scratchDiskPath()
+		scratchDisks = append(scratchDisks, c.Disks[idx])
+	}
+
+	return scratchDisks
+}
+
+// scratchDiskPath returns a unique path for a scratch disk image in the
+// temporary directory.
+func scratchDiskPath() string {
+	name := fmt.Sprintf("virtrun-disk-%d-%d.img", os.Getpid(), rand.Uint32())
+	return filepath.Join(os.TempDir(), name)
+}
+
+// createScratchDisk creates the sparse image file of the scratch disk. The
+// file must not exist yet. It is removed again, if it can not be sized.
+func createScratchDisk(disk Disk) error {
+	file, err := os.OpenFile(disk.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL,
+		0o600)
+	if err != nil {
+		return fmt.Errorf("create scratch disk: %w", err)
+	}
+
+	// Validated to fit.
+	size := int64(disk.Size) //nolint:gosec
+
+	err = errors.Join(file.Truncate(size), file.Close())
+	if err != nil {
+		_ = os.Remove(disk.Path)
+
+		return fmt.Errorf("size scratch disk: %w", err)
+	}
+
+	return nil
+}
+
+// diskArgs returns the drive and virtio block device arguments for the
+// disks.
+func (c *CommandSpec) diskArgs() []Argument {
+	device := "virtio-blk-pci"
+	if c.TransportType == TransportTypeMMIO {
+		device = "virtio-blk-device"
+	}
+
+	args := make([]Argument, 0, 2*len(c.Disks))
+
+	for idx, disk := range c.Disks {
+		id := fmt.Sprintf("disk%d", idx)
+
+		format := disk.Format
+		if format == "" {
+			format = "raw"
+		}
+
+		driveOpts := []string{
+			"file=" + escapeOptionValue(disk.Path),
+			"if=none",
+			"id=" + id,
+			"format=" + format,
+		}
+
+		if disk.ReadOnly {
+			driveOpts = append(driveOpts, "readonly=on")
+		}
+
+		driveOpts = append(driveOpts, disk.Throttle.options()...)
+
+		args = append(args,
+			RepeatableArg("drive", driveOpts...),
+			RepeatableArg("device", device, "drive="+id),
+		)
+	}
+
+	return args
+}
@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu_test
+
+import (
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDisk(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    qemu.Disk
+		expectedErr error
+	}{
+		{
+			input:    "disk.img",
+			expected: qemu.Disk{Path: "disk.img"},
+		},
+		{
+			input: "/tmp/disk.qcow2,format=qcow2,readonly=on",
+			expected: qemu.Disk{
+				Path:     "/tmp/disk.qcow2",
+				Format:   "qcow2",
+				ReadOnly: true,
+			},
+		},
+		{
+			input:    "create:4096",
+			expected: qemu.Disk{Size: 4096},
+		},
+		{
+			input:    "create:64M,format=raw",
+			expected: qemu.Disk{Size: 64 << 20, Format: "raw"},
+		},
+		{
+			input:    "create:2G,readonly=off",
+			expected: qemu.Disk{Size: 2 << 30},
+		},
+		{
+			input:       "",
+			expectedErr: qemu.ErrDiskInvalid,
+		},
+		{
+			input:       "create:",
+			expectedErr: qemu.ErrDiskInvalid,
+		},
+		{
+			input:       "create:0",
+			expectedErr: qemu.ErrDiskInvalid,
+		},
+		{
+			input:       "create:1X",
+			expectedErr: qemu.ErrDiskInvalid,
+		},
+		{
+			input:       "create:9000000T",
+			expectedErr: qemu.ErrDiskInvalid,
+		},
+		{
+			input:       "create:64M,format=qcow2",
+			expectedErr: qemu.ErrDiskInvalid,
+		},
+		{
+			input:       "disk.img,format=vmdk",
+			expectedErr: qemu.ErrDiskInvalid,
+		},
+		{
+			input:       "disk.img,readonly",
+			expectedErr: qemu.ErrDiskInvalid,
+		},
+		{
+			input:       "disk.img,cache=none",
+			expectedErr: qemu.ErrDiskInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			actual, err := qemu.ParseDisk(tt.input)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			if tt.expectedErr == nil {
+				assert.Equal(t, tt.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Display is the QEMU display, as used for the QEMU "-display" argument.
+//
+// Only the headless displays are supported: "none" and "vnc=<display>", like
+// "vnc=:1" or "vnc=localhost:1". Note that the guest needs a display device
+// for VNC to be useful, which must be added as extra argument.
+type Display string
+
+const (
+	// DisplayNone disables the display. This is the default.
+	DisplayNone Display = "none"
+
+	displayVNCPrefix = "vnc="
+)
+
+// String returns the [Display] as string.
+func (d *Display) String() string {
+	return string(*d)
+}
+
+// Set sets the [Display] to the given value.
+//
+// It returns [ErrDisplayInvalid] if the string is not valid.
+func (d *Display) Set(s string) error {
+	display := Display(s)
+
+	if err := display.validate(); err != nil {
+		return err
+	}
+
+	*d = display
+
+	return nil
+}
+
+// validate checks if the display is one of the supported displays.
+func (d *Display) validate() error {
+	switch {
+	case *d == "", *d == DisplayNone:
+		return nil
+	case strings.HasPrefix(string(*d), displayVNCPrefix):
+		if len(*d) > len(displayVNCPrefix) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrDisplayInvalid, *d)
+}
+
+// value returns the value for the "-display" argument.
+func (d *Display) value() string {
+	if *d == "" {
+		return string(DisplayNone)
+	}
+
+	return string(*d)
+}
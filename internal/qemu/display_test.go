@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu_test
+
+import (
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisplay_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    qemu.Display
+		expectedErr error
+	}{
+		{
+			input:    "none",
+			expected: qemu.DisplayNone,
+		},
+		{
+			input:    "vnc=:1",
+			expected: "vnc=:1",
+		},
+		{
+			input:    "vnc=localhost:1",
+			expected: "vnc=localhost:1",
+		},
+		{
+			input:       "vnc=",
+			expectedErr: qemu.ErrDisplayInvalid,
+		},
+		{
+			input:       "gtk",
+			expectedErr: qemu.ErrDisplayInvalid,
+		},
+		{
+			input:       "sdl",
+			expectedErr: qemu.ErrDisplayInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var actual qemu.Display
+
+			err := actual.Set(tt.input)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
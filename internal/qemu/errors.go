@@ -19,13 +19,70 @@ var (
 	// ErrGuestOom is returned if the guest system ran out of memory.
 	ErrGuestOom = errors.New("guest system ran out of memory")
 
+	// ErrGuestOops is returned if the guest reported a kernel oops, see
+	// [CommandSpec.OopsMarker].
+	ErrGuestOops = errors.New("guest kernel oops occurred")
+
 	// ErrGuestNonZeroExitCode is returned if the guest did not return exit
 	// code 0.
 	ErrGuestNonZeroExitCode = errors.New("guest did not return exit code 0")
 
+	// ErrGuestIdleTimeout is returned if the guest did not print any output
+	// within the idle timeout.
+	ErrGuestIdleTimeout = errors.New("guest output idle timeout exceeded")
+
+	// ErrGuestTimeout is returned if the guest is terminated because the
+	// context is done, like on host timeout or signal.
+	ErrGuestTimeout = errors.New("guest terminated by host")
+
+	// ErrWaitForLineTimeout is returned if no line matching the wait pattern
+	// has been printed by the guest within the timeout.
+	ErrWaitForLineTimeout = errors.New("timeout waiting for line")
+
+	// ErrWaitForLineNotFound is returned if the guest exited without
+	// printing a line matching the wait pattern.
+	ErrWaitForLineNotFound = errors.New("guest exited before line appeared")
+
+	// ErrGuestBootTimeout is returned if the guest did not print the boot
+	// marker within the max boot time.
+	ErrGuestBootTimeout = errors.New("guest boot time exceeded")
+
+	// ErrQMP is returned if the QMP server responds unexpectedly or with an
+	// error.
+	ErrQMP = errors.New("qmp error")
+
+	// ErrVersionNotFound is returned if the QEMU version can not be found in
+	// the version output.
+	ErrVersionNotFound = errors.New("qemu version not found")
+
 	// ErrTransportTypeInvalid is returned if a transport type is invalid.
 	ErrTransportTypeInvalid = errors.New("unknown transport type")
 
+	// ErrRTCInvalid is returned if a real time clock definition is invalid.
+	ErrRTCInvalid = errors.New("invalid rtc")
+
+	// ErrDisplayInvalid is returned if a display definition is invalid.
+	ErrDisplayInvalid = errors.New("invalid display")
+
+	// ErrAccelInvalid is returned if an accelerator definition is invalid.
+	ErrAccelInvalid = errors.New("invalid accelerator")
+
+	// ErrGDBAddressInvalid is returned if a gdb stub address is invalid.
+	ErrGDBAddressInvalid = errors.New("invalid gdb address")
+
+	// ErrSMBIOSInvalid is returned if an SMBIOS definition is invalid.
+	ErrSMBIOSInvalid = errors.New("invalid smbios")
+
+	// ErrShareInvalid is returned if a share definition is invalid.
+	ErrShareInvalid = errors.New("invalid share")
+
+	// ErrDiskInvalid is returned if a disk definition is invalid.
+	ErrDiskInvalid = errors.New("invalid disk")
+
+	// ErrHostForwardInvalid is returned if a host forward definition is
+	// invalid.
+	ErrHostForwardInvalid = errors.New("invalid host forward")
+
 	// ErrArgumentCollision is returned if two [Argument]s are considered equal.
 	ErrArgumentCollision = errors.New("colliding args")
 )
@@ -48,8 +105,18 @@ func (*ArgumentError) Is(other error) bool {
 
 // CommandError wraps any error occurred during Command execution.
 type CommandError struct {
-	Err      error
-	Guest    bool
+	Err error
+
+	// Guest is set if the failure is reported by or about the guest, like a
+	// non-zero exit code, a panic or a timeout. It is unset if the QEMU
+	// process itself failed, which is an exit with non-zero status without
+	// the guest having communicated its exit code, like on a crash of QEMU
+	// or invalid arguments.
+	Guest bool
+
+	// ExitCode is the exit code communicated by the guest if Guest is set.
+	// Otherwise, it is the exit code of the QEMU process, which is -1 if the
+	// process has been terminated by a signal.
 	ExitCode int
 }
 
@@ -73,3 +140,13 @@ func (*CommandError) Is(other error) bool {
 func (e *CommandError) Unwrap() error {
 	return e.Err
 }
+
+// IsGuestFailure returns true if the error is a [CommandError] caused by the
+// guest. It returns false for failures of the QEMU process itself and for
+// any other errors. This allows to tell failed tests apart from
+// infrastructure issues, like for retrying only the latter.
+func IsGuestFailure(err error) bool {
+	var cmdErr *CommandError
+
+	return errors.As(err, &cmdErr) && cmdErr.Guest
+}
@@ -5,6 +5,7 @@
 package qemu_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/aibor/virtrun/internal/qemu"
@@ -22,3 +23,52 @@ func TestCommandErrorIs(t *testing.T) {
 	assert.ErrorIs(t, error(&qemu.CommandError{}), &qemu.CommandError{})
 	assert.NotErrorIs(t, assert.AnError, &qemu.CommandError{})
 }
+
+func TestIsGuestFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "nil",
+			expected: false,
+		},
+		{
+			name:     "other error",
+			err:      assert.AnError,
+			expected: false,
+		},
+		{
+			name: "qemu failure",
+			err: &qemu.CommandError{
+				Err:      assert.AnError,
+				ExitCode: 1,
+			},
+			expected: false,
+		},
+		{
+			name: "guest failure",
+			err: &qemu.CommandError{
+				Err:      qemu.ErrGuestNonZeroExitCode,
+				Guest:    true,
+				ExitCode: 3,
+			},
+			expected: true,
+		},
+		{
+			name: "wrapped guest failure",
+			err: fmt.Errorf("run: %w", &qemu.CommandError{
+				Err:   qemu.ErrGuestPanic,
+				Guest: true,
+			}),
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, qemu.IsGuestFailure(tt.err))
+		})
+	}
+}
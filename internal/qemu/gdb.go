@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// GDBAddress is the TCP address the QEMU gdb stub listens on, given as
+// "[host]:port", like ":1234" or "localhost:1234". It is used for the QEMU
+// "-gdb" argument.
+type GDBAddress string
+
+// String returns the [GDBAddress] as string.
+func (a *GDBAddress) String() string {
+	return string(*a)
+}
+
+// Set sets the [GDBAddress] to the given value.
+//
+// It returns [ErrGDBAddressInvalid] if the string is not valid.
+func (a *GDBAddress) Set(s string) error {
+	address := GDBAddress(s)
+
+	if err := address.validate(); err != nil {
+		return err
+	}
+
+	*a = address
+
+	return nil
+}
+
+// validate checks if the address has a valid port and a host without
+// characters QEMU would interpret. The empty address is valid.
+func (a *GDBAddress) validate() error {
+	if *a == "" {
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(string(*a))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrGDBAddressInvalid, err)
+	}
+
+	if strings.Contains(host, ",") {
+		return fmt.Errorf("%w: invalid host %s", ErrGDBAddressInvalid, host)
+	}
+
+	num, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || num == 0 {
+		return fmt.Errorf("%w: invalid port %s", ErrGDBAddressInvalid, port)
+	}
+
+	return nil
+}
+
+// value returns the value for the "-gdb" argument.
+func (a *GDBAddress) value() string {
+	return "tcp:" + string(*a)
+}
@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu_test
+
+import (
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGDBAddress_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    qemu.GDBAddress
+		expectedErr error
+	}{
+		{
+			input:    ":1234",
+			expected: ":1234",
+		},
+		{
+			input:    "localhost:1234",
+			expected: "localhost:1234",
+		},
+		{
+			input:    "[::1]:1234",
+			expected: "[::1]:1234",
+		},
+		{
+			input:       "1234",
+			expectedErr: qemu.ErrGDBAddressInvalid,
+		},
+		{
+			input:       ":0",
+			expectedErr: qemu.ErrGDBAddressInvalid,
+		},
+		{
+			input:       ":65536",
+			expectedErr: qemu.ErrGDBAddressInvalid,
+		},
+		{
+			input:       ":gdb",
+			expectedErr: qemu.ErrGDBAddressInvalid,
+		},
+		{
+			input:       "a,server:1234",
+			expectedErr: qemu.ErrGDBAddressInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var actual qemu.GDBAddress
+
+			err := actual.Set(tt.input)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"regexp"
+	"sync/atomic"
+)
+
+// lineWaiter calls a function once a line matches the pattern.
+type lineWaiter struct {
+	pattern *regexp.Regexp
+	fn      func()
+	matched atomic.Bool
+}
+
+// Matched returns true if a line matched the pattern.
+func (w *lineWaiter) Matched() bool {
+	return w.matched.Load()
+}
+
+// wrap returns a [lineParseFunc] that matches each line against the pattern
+// before calling the given function.
+func (w *lineWaiter) wrap(fn lineParseFunc) lineParseFunc {
+	return func(data []byte) []byte {
+		if !w.matched.Load() && w.pattern.Match(data) {
+			w.matched.Store(true)
+			w.fn()
+		}
+
+		if fn == nil {
+			return data
+		}
+
+		return fn(data)
+	}
+}
@@ -0,0 +1,199 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// User mode network addresses QEMU uses by default. [NetworkGuestAddress] is
+// the first address its DHCP server hands out, so the guest can use it
+// statically. The gateway forwards to the host, so host services bound to the
+// loopback address are reachable from the guest via the gateway address.
+var (
+	NetworkGuestAddress = netip.MustParsePrefix("10.0.2.15/24")
+	NetworkGateway      = netip.MustParseAddr("10.0.2.2")
+	NetworkNameserver   = netip.MustParseAddr("10.0.2.3")
+)
+
+// Network is a user mode network (SLIRP) attached to the guest as virtio
+// network device. It gives the guest outbound access via the host's network
+// stack without requiring any privileges on the host.
+type Network struct {
+	// HostForwards forward ports of the host to the guest.
+	HostForwards []HostForward
+}
+
+// HostForward forwards connections to a port on the host to a port of the
+// guest. It is given like the QEMU "hostfwd" option:
+// "[tcp|udp]:[HOSTADDR]:HOSTPORT-[GUESTADDR]:GUESTPORT".
+type HostForward struct {
+	// Protocol is "tcp" or "udp". Empty is "tcp".
+	Protocol string
+
+	// HostAddr is the IPv4 host address to listen on. Empty listens on all
+	// addresses.
+	HostAddr string
+
+	// HostPort is the port to listen on the host.
+	HostPort uint16
+
+	// GuestAddr is the IPv4 address of the guest to forward to. Empty
+	// forwards to the guest's default address.
+	GuestAddr string
+
+	// GuestPort is the port of the guest to forward to.
+	GuestPort uint16
+}
+
+// ParseHostForward parses a [HostForward] given like the QEMU "hostfwd"
+// option, like "tcp::8080-:80" or "127.0.0.1:8080-:80".
+func ParseHostForward(s string) (HostForward, error) {
+	var fwd HostForward
+
+	host, guest, found := strings.Cut(s, "-")
+	if !found {
+		return fwd, fmt.Errorf("%w: missing guest part: %q",
+			ErrHostForwardInvalid, s)
+	}
+
+	hostParts := strings.Split(host, ":")
+	switch len(hostParts) {
+	case 2:
+		fwd.HostAddr = hostParts[0]
+	case 3:
+		fwd.Protocol = hostParts[0]
+		fwd.HostAddr = hostParts[1]
+	default:
+		return fwd, fmt.Errorf("%w: host part: %q", ErrHostForwardInvalid, s)
+	}
+
+	guestAddr, guestPort, found := strings.Cut(guest, ":")
+	if !found {
+		return fwd, fmt.Errorf("%w: guest part: %q", ErrHostForwardInvalid, s)
+	}
+
+	fwd.GuestAddr = guestAddr
+
+	var err error
+
+	fwd.HostPort, err = parsePort(hostParts[len(hostParts)-1])
+	if err != nil {
+		return fwd, fmt.Errorf("%w: host port: %w",
+			ErrHostForwardInvalid, err)
+	}
+
+	fwd.GuestPort, err = parsePort(guestPort)
+	if err != nil {
+		return fwd, fmt.Errorf("%w: guest port: %w",
+			ErrHostForwardInvalid, err)
+	}
+
+	return fwd, fwd.validate()
+}
+
+func parsePort(s string) (uint16, error) {
+	port, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	return uint16(port), nil
+}
+
+// String returns the forward in the format of the QEMU "hostfwd" option.
+func (f HostForward) String() string {
+	return fmt.Sprintf("%s:%s:%d-%s:%d",
+		f.protocol(), f.HostAddr, f.HostPort, f.GuestAddr, f.GuestPort)
+}
+
+func (f HostForward) protocol() string {
+	if f.Protocol == "" {
+		return "tcp"
+	}
+
+	return f.Protocol
+}
+
+// validate checks the protocol, the addresses and the ports of the forward.
+func (f HostForward) validate() error {
+	if f.protocol() != "tcp" && f.protocol() != "udp" {
+		return fmt.Errorf("%w: protocol: %q",
+			ErrHostForwardInvalid, f.Protocol)
+	}
+
+	for _, addr := range []string{f.HostAddr, f.GuestAddr} {
+		if addr == "" {
+			continue
+		}
+
+		ip, err := netip.ParseAddr(addr)
+		if err != nil {
+			return fmt.Errorf("%w: address: %w", ErrHostForwardInvalid, err)
+		}
+
+		if !ip.Is4() {
+			return fmt.Errorf("%w: address must be IPv4: %s",
+				ErrHostForwardInvalid, addr)
+		}
+	}
+
+	if f.HostPort == 0 || f.GuestPort == 0 {
+		return fmt.Errorf("%w: port must not be 0", ErrHostForwardInvalid)
+	}
+
+	return nil
+}
+
+// validateNetwork checks all host forwards are valid and their host ports are
+// unique per protocol.
+func (c *CommandSpec) validateNetwork() error {
+	if c.Network == nil {
+		return nil
+	}
+
+	hostPorts := make(map[string]bool, len(c.Network.HostForwards))
+
+	for _, fwd := range c.Network.HostForwards {
+		if err := fwd.validate(); err != nil {
+			return &ArgumentError{err.Error()}
+		}
+
+		hostPort := fmt.Sprintf("%s:%d", fwd.protocol(), fwd.HostPort)
+		if hostPorts[hostPort] {
+			return &ArgumentError{"duplicate host forward: " + hostPort}
+		}
+
+		hostPorts[hostPort] = true
+	}
+
+	return nil
+}
+
+// networkArgs returns the user mode network backend and virtio network device
+// arguments for the network, if any.
+func (c *CommandSpec) networkArgs() []Argument {
+	if c.Network == nil {
+		return nil
+	}
+
+	device := "virtio-net-pci"
+	if c.TransportType == TransportTypeMMIO {
+		device = "virtio-net-device"
+	}
+
+	netdevOpts := []string{"user", "id=net0"}
+	for _, fwd := range c.Network.HostForwards {
+		netdevOpts = append(netdevOpts, "hostfwd="+fwd.String())
+	}
+
+	return []Argument{
+		RepeatableArg("netdev", netdevOpts...),
+		RepeatableArg("device", device, "netdev=net0"),
+	}
+}
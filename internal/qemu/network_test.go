@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu_test
+
+import (
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHostForward(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected qemu.HostForward
+		valid    bool
+		str      string
+	}{
+		{
+			name:  "ports only",
+			value: ":8080-:80",
+			expected: qemu.HostForward{
+				HostPort:  8080,
+				GuestPort: 80,
+			},
+			valid: true,
+			str:   "tcp::8080-:80",
+		},
+		{
+			name:  "full",
+			value: "udp:127.0.0.1:5353-10.0.2.15:53",
+			expected: qemu.HostForward{
+				Protocol:  "udp",
+				HostAddr:  "127.0.0.1",
+				HostPort:  5353,
+				GuestAddr: "10.0.2.15",
+				GuestPort: 53,
+			},
+			valid: true,
+			str:   "udp:127.0.0.1:5353-10.0.2.15:53",
+		},
+		{
+			name:  "tcp",
+			value: "tcp::2222-:22",
+			expected: qemu.HostForward{
+				Protocol:  "tcp",
+				HostPort:  2222,
+				GuestPort: 22,
+			},
+			valid: true,
+			str:   "tcp::2222-:22",
+		},
+		{
+			name:  "missing guest part",
+			value: "tcp::8080",
+		},
+		{
+			name:  "missing guest port",
+			value: "tcp::8080-80",
+		},
+		{
+			name:  "unknown protocol",
+			value: "sctp::8080-:80",
+		},
+		{
+			name:  "host name",
+			value: "tcp:localhost:8080-:80",
+		},
+		{
+			name:  "ipv6",
+			value: "tcp:::1:8080-:80",
+		},
+		{
+			name:  "port out of range",
+			value: "tcp::65536-:80",
+		},
+		{
+			name:  "zero port",
+			value: "tcp::0-:80",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := qemu.ParseHostForward(tt.value)
+			if !tt.valid {
+				require.ErrorIs(t, err, qemu.ErrHostForwardInvalid)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, tt.str, actual.String())
+		})
+	}
+}
+
+func TestCommandSpec_ValidateNetwork(t *testing.T) {
+	fwd := qemu.HostForward{HostPort: 8080, GuestPort: 80}
+
+	spec := qemu.CommandSpec{
+		TransportType: qemu.TransportTypePCI,
+		Network:       &qemu.Network{HostForwards: []qemu.HostForward{fwd}},
+	}
+	require.NoError(t, spec.Validate())
+
+	udp := qemu.HostForward{Protocol: "udp", HostPort: 8080, GuestPort: 80}
+	spec.Network.HostForwards = append(spec.Network.HostForwards, udp)
+	require.NoError(t, spec.Validate(), "same port with other protocol")
+
+	spec.Network.HostForwards = append(spec.Network.HostForwards, fwd)
+	require.ErrorIs(t, spec.Validate(), &qemu.ArgumentError{})
+
+	spec.Network.HostForwards = []qemu.HostForward{{HostPort: 8080}}
+	require.ErrorIs(t, spec.Validate(), &qemu.ArgumentError{})
+
+	spec = qemu.CommandSpec{
+		Machine:       "microvm",
+		TransportType: qemu.TransportTypeISA,
+		Network:       &qemu.Network{},
+	}
+	require.ErrorIs(t, spec.Validate(), &qemu.ArgumentError{})
+}
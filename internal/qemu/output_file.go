@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// gzipSuffix is the file name suffix of output files that are compressed by
+// the host. The guest always writes uncompressed data.
+const gzipSuffix = ".gz"
+
+// gzipFile compresses all data written to the underlying file.
+type gzipFile struct {
+	*gzip.Writer
+	file *os.File
+}
+
+// Close flushes the compressed data and closes the file.
+func (f *gzipFile) Close() error {
+	return errors.Join(f.Writer.Close(), f.file.Close())
+}
+
+// createOutputFile creates the file with the given path for writing console
+// output into. If the path has the suffix ".gz", the output is gzip
+// compressed.
+func createOutputFile(path string) (io.WriteCloser, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("output file: %w", err)
+	}
+
+	if !strings.HasSuffix(path, gzipSuffix) {
+		return file, nil
+	}
+
+	return &gzipFile{
+		Writer: gzip.NewWriter(file),
+		file:   file,
+	}, nil
+}
@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import "regexp"
+
+// OutputFilter filters the lines of a console output stream.
+type OutputFilter struct {
+	// Include keeps only lines matching the pattern, if set.
+	Include *regexp.Regexp
+
+	// Exclude drops lines matching the pattern, if set. It is applied after
+	// [OutputFilter.Include].
+	Exclude *regexp.Regexp
+}
+
+// IsZero returns true if the filter does not drop any line.
+func (f *OutputFilter) IsZero() bool {
+	return f == nil || (f.Include == nil && f.Exclude == nil)
+}
+
+// keep returns true if the given line passes the filter.
+func (f *OutputFilter) keep(line []byte) bool {
+	if f.Include != nil && !f.Include.Match(line) {
+		return false
+	}
+
+	if f.Exclude != nil && f.Exclude.Match(line) {
+		return false
+	}
+
+	return true
+}
+
+// wrap returns a [lineParseFunc] that calls the given function first and
+// drops its result if it does not pass the filter. So, the given function
+// still sees every line.
+func (f *OutputFilter) wrap(fn lineParseFunc) lineParseFunc {
+	return func(data []byte) []byte {
+		if fn != nil {
+			data = fn(data)
+		}
+
+		if data == nil || !f.keep(data) {
+			return nil
+		}
+
+		return data
+	}
+}
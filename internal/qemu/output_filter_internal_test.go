@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputFilter_Wrap(t *testing.T) {
+	lines := []string{
+		"[    1.000000] kernel: rate limit: 5 callbacks suppressed",
+		"app: started",
+		"app: debug: tick",
+		"[    2.000000] kernel: eth0 up",
+	}
+
+	tests := []struct {
+		name     string
+		filter   OutputFilter
+		expected []string
+	}{
+		{
+			name:     "empty",
+			expected: lines,
+		},
+		{
+			name: "include",
+			filter: OutputFilter{
+				Include: regexp.MustCompile(`^app: `),
+			},
+			expected: []string{
+				"app: started",
+				"app: debug: tick",
+			},
+		},
+		{
+			name: "exclude",
+			filter: OutputFilter{
+				Exclude: regexp.MustCompile(`callbacks suppressed$`),
+			},
+			expected: []string{
+				"app: started",
+				"app: debug: tick",
+				"[    2.000000] kernel: eth0 up",
+			},
+		},
+		{
+			name: "include and exclude",
+			filter: OutputFilter{
+				Include: regexp.MustCompile(`^app: `),
+				Exclude: regexp.MustCompile(`debug`),
+			},
+			expected: []string{
+				"app: started",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen []string
+
+			fn := tt.filter.wrap(func(data []byte) []byte {
+				seen = append(seen, string(data))
+				return data
+			})
+
+			var actual []string
+
+			for _, line := range lines {
+				if data := fn([]byte(line)); data != nil {
+					actual = append(actual, string(data))
+				}
+			}
+
+			assert.Equal(t, tt.expected, actual)
+			assert.Equal(t, lines, seen, "wrapped function sees all lines")
+		})
+	}
+}
+
+func TestOutputFilter_IsZero(t *testing.T) {
+	var filter *OutputFilter
+
+	assert.True(t, filter.IsZero())
+	assert.True(t, (&OutputFilter{}).IsZero())
+	assert.False(t, (&OutputFilter{Exclude: regexp.MustCompile("x")}).IsZero())
+}
@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"bytes"
+	"slices"
+)
+
+// outputTailLines is the number of output lines kept for error messages.
+const outputTailLines = 10
+
+// outputTail keeps the last lines of the output for diagnosis.
+type outputTail struct {
+	lines [][]byte
+	max   int
+}
+
+// wrap returns a [lineParseFunc] that records each line before calling the
+// given function.
+func (t *outputTail) wrap(fn lineParseFunc) lineParseFunc {
+	return func(data []byte) []byte {
+		if len(t.lines) == t.max {
+			t.lines = slices.Delete(t.lines, 0, 1)
+		}
+
+		t.lines = append(t.lines, bytes.Clone(data))
+
+		if fn == nil {
+			return data
+		}
+
+		return fn(data)
+	}
+}
+
+// String returns the recorded lines separated by newlines.
+func (t *outputTail) String() string {
+	return string(bytes.Join(t.lines, []byte("\n")))
+}
@@ -0,0 +1,23 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputTail(t *testing.T) {
+	tail := &outputTail{max: 2}
+	fn := tail.wrap(nil)
+
+	for _, line := range []string{"one", "two", "three"} {
+		data := []byte(line)
+		assert.Equal(t, data, fn(data))
+	}
+
+	assert.Equal(t, "two\nthree", tail.String())
+}
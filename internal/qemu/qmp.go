@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// qmpTimeout is the maximum duration of a QMP session, including a guest
+// memory dump.
+const qmpTimeout = 2 * time.Minute
+
+// qmpCommand is a command sent to the QEMU machine protocol (QMP) server.
+type qmpCommand struct {
+	Execute   string `json:"execute"`
+	Arguments any    `json:"arguments,omitempty"`
+}
+
+// qmpResponse is any message received from the QMP server. Only one of the
+// fields is set.
+type qmpResponse struct {
+	Greeting *json.RawMessage `json:"QMP,omitempty"`
+	Return   *json.RawMessage `json:"return,omitempty"`
+	Error    *qmpError        `json:"error,omitempty"`
+	Event    string           `json:"event,omitempty"`
+}
+
+type qmpError struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+// dumpGuestMemoryArgs are the arguments of the QMP command
+// "dump-guest-memory".
+type dumpGuestMemoryArgs struct {
+	Paging   bool   `json:"paging"`
+	Protocol string `json:"protocol"`
+}
+
+// qmpClient is a minimal client for the QEMU machine protocol. It supports
+// synchronous commands only.
+type qmpClient struct {
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+// newQMPClient reads the server greeting from the given connection and
+// enables command mode.
+func newQMPClient(conn io.ReadWriter) (*qmpClient, error) {
+	client := &qmpClient{
+		enc: json.NewEncoder(conn),
+		dec: json.NewDecoder(conn),
+	}
+
+	var greeting qmpResponse
+
+	err := client.dec.Decode(&greeting)
+	if err != nil {
+		return nil, fmt.Errorf("read greeting: %w", err)
+	}
+
+	if greeting.Greeting == nil {
+		return nil, fmt.Errorf("%w: no greeting", ErrQMP)
+	}
+
+	err = client.execute("qmp_capabilities", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// execute sends the command and waits for its response. Events received in
+// the meantime are ignored.
+func (c *qmpClient) execute(command string, args any) error {
+	err := c.enc.Encode(qmpCommand{Execute: command, Arguments: args})
+	if err != nil {
+		return fmt.Errorf("send %s: %w", command, err)
+	}
+
+	for {
+		var response qmpResponse
+
+		err := c.dec.Decode(&response)
+		if err != nil {
+			return fmt.Errorf("read %s response: %w", command, err)
+		}
+
+		switch {
+		case response.Error != nil:
+			return fmt.Errorf("%w: %s: %s: %s", ErrQMP, command,
+				response.Error.Class, response.Error.Desc)
+		case response.Return != nil:
+			return nil
+		}
+	}
+}
+
+// dumpGuestMemory writes the guest memory as ELF core file to the given path.
+func (c *qmpClient) dumpGuestMemory(path string) error {
+	return c.execute("dump-guest-memory", dumpGuestMemoryArgs{
+		Paging:   false,
+		Protocol: "file:" + path,
+	})
+}
+
+// qmpSocketPath returns a new unique path for a QMP unix socket in the
+// default temp directory.
+func qmpSocketPath() string {
+	name := fmt.Sprintf("virtrun-qmp-%d-%d.sock", os.Getpid(), rand.Uint32())
+	return filepath.Join(os.TempDir(), name)
+}
+
+// maxSocketPathLen is the maximum length of a unix socket path, including the
+// terminating null byte.
+const maxSocketPathLen = 108
+
+// validateQMPSocket checks that a QMP server can listen on a unix socket at
+// the given path. The file must not exist yet and its directory must exist.
+func validateQMPSocket(path string) error {
+	if len(path) >= maxSocketPathLen {
+		return &ArgumentError{"qmp socket path too long: " + path}
+	}
+
+	_, err := os.Lstat(path)
+	if err == nil {
+		return &ArgumentError{"qmp socket exists already: " + path}
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return &ArgumentError{"qmp socket: " + err.Error()}
+	}
+
+	info, err := os.Stat(filepath.Dir(path))
+	if err != nil || !info.IsDir() {
+		return &ArgumentError{"qmp socket directory missing: " + path}
+	}
+
+	return nil
+}
+
+// removeOnClose is an [io.Closer] that removes the file with the path
+// ignoring if it does not exist.
+type removeOnClose string
+
+func (r removeOnClose) Close() error {
+	err := os.Remove(string(r))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove: %w", err)
+	}
+
+	return nil
+}
+
+// dumpGuestMemoryVia connects to the QMP server listening on the given unix
+// socket and dumps the guest memory to the given path.
+func dumpGuestMemoryVia(socket, path string) error {
+	conn, err := net.DialTimeout("unix", socket, qmpTimeout)
+	if err != nil {
+		return fmt.Errorf("dial qmp: %w", err)
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(qmpTimeout))
+	if err != nil {
+		return fmt.Errorf("set qmp deadline: %w", err)
+	}
+
+	client, err := newQMPClient(conn)
+	if err != nil {
+		return err
+	}
+
+	return client.dumpGuestMemory(path)
+}
@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQMPServer serves the given responses in order, one for each received
+// command. It returns the received commands once the client is done.
+func fakeQMPServer(
+	t *testing.T,
+	conn net.Conn,
+	greeting string,
+	responses ...string,
+) <-chan []string {
+	t.Helper()
+
+	received := make(chan []string, 1)
+
+	go func() {
+		defer close(received)
+		defer conn.Close()
+
+		var commands []string
+
+		defer func() { received <- commands }()
+
+		_, err := io.WriteString(conn, greeting+"\n")
+		if err != nil {
+			return
+		}
+
+		scanner := bufio.NewScanner(conn)
+
+		for _, response := range responses {
+			if !scanner.Scan() {
+				return
+			}
+
+			commands = append(commands, scanner.Text())
+
+			_, err := io.WriteString(conn, response+"\n")
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return received
+}
+
+func TestQMPClient_DumpGuestMemory(t *testing.T) {
+	const greeting = `{"QMP": {"version": {}, "capabilities": []}}`
+
+	tests := []struct {
+		name             string
+		greeting         string
+		responses        []string
+		expectedCommands []string
+		expectedErr      error
+	}{
+		{
+			name:     "success",
+			greeting: greeting,
+			responses: []string{
+				`{"return": {}}`,
+				`{"event": "STOP", "timestamp": {}}` + "\n" + `{"return": {}}`,
+			},
+			expectedCommands: []string{
+				`{"execute":"qmp_capabilities"}`,
+				`{"execute":"dump-guest-memory","arguments":` +
+					`{"paging":false,"protocol":"file:/tmp/core"}}`,
+			},
+		},
+		{
+			name:     "dump error",
+			greeting: greeting,
+			responses: []string{
+				`{"return": {}}`,
+				`{"error": {"class": "GenericError", "desc": "no space"}}`,
+			},
+			expectedCommands: []string{
+				`{"execute":"qmp_capabilities"}`,
+				`{"execute":"dump-guest-memory","arguments":` +
+					`{"paging":false,"protocol":"file:/tmp/core"}}`,
+			},
+			expectedErr: ErrQMP,
+		},
+		{
+			name:        "no greeting",
+			greeting:    `{"return": {}}`,
+			expectedErr: ErrQMP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+
+			received := fakeQMPServer(t, serverConn, tt.greeting,
+				tt.responses...)
+
+			client, err := newQMPClient(clientConn)
+			if err == nil {
+				err = client.dumpGuestMemory("/tmp/core")
+			}
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.NoError(t, clientConn.Close())
+
+			assert.Equal(t, tt.expectedCommands, <-received)
+		})
+	}
+}
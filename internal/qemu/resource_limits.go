@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const bytesPerMB = 1024 * 1024
+
+// ResourceLimits are resource limits of the QEMU process itself. They protect
+// the host from runaway emulation. Zero values leave the respective limit
+// unchanged.
+type ResourceLimits struct {
+	// CPUTime is the maximum CPU time the QEMU process may consume in total,
+	// summed up across all threads. It has a granularity of seconds. Once
+	// exceeded, the process is killed by the kernel.
+	CPUTime time.Duration
+
+	// AddressSpace is the maximum size of the virtual memory of the QEMU
+	// process in MB. It must be well above [CommandSpec.Memory], as QEMU maps
+	// the guest memory as well as its own libraries and thread stacks.
+	// Allocations exceeding it fail.
+	AddressSpace uint64
+}
+
+// IsZero returns true if no limit is set.
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
+func (l ResourceLimits) validate(memory uint64) error {
+	if l.CPUTime < 0 || (l.CPUTime > 0 && l.CPUTime < time.Second) {
+		return &ArgumentError{fmt.Sprintf(
+			"cpu time limit %s must be at least 1s", l.CPUTime,
+		)}
+	}
+
+	if l.AddressSpace > 0 && l.AddressSpace <= memory {
+		return &ArgumentError{fmt.Sprintf(
+			"address space limit %d MB must exceed memory %d MB",
+			l.AddressSpace, memory,
+		)}
+	}
+
+	return nil
+}
+
+// rlimits returns the rlimits for the set limits by resource. Soft and hard
+// limits are the same, so the process can not raise them.
+func (l ResourceLimits) rlimits() map[int]unix.Rlimit {
+	rlimits := make(map[int]unix.Rlimit, 2)
+
+	if l.CPUTime > 0 {
+		// Round up, so the process gets at least the given time.
+		seconds := uint64((l.CPUTime + time.Second - 1) / time.Second)
+		rlimits[unix.RLIMIT_CPU] = unix.Rlimit{Cur: seconds, Max: seconds}
+	}
+
+	if l.AddressSpace > 0 {
+		size := l.AddressSpace * bytesPerMB
+		rlimits[unix.RLIMIT_AS] = unix.Rlimit{Cur: size, Max: size}
+	}
+
+	return rlimits
+}
+
+// apply sets the limits on the process with the given pid.
+//
+// [exec.Cmd] has no way to set rlimits of the child before it executes. So,
+// they are set via prlimit(2) right after the start. The CPU time consumed
+// until then is accounted for anyway.
+func (l ResourceLimits) apply(pid int) error {
+	for resource, rlimit := range l.rlimits() {
+		err := unix.Prlimit(pid, resource, &rlimit, nil)
+		if err != nil {
+			return fmt.Errorf("set rlimit %d: %w", resource, err)
+		}
+	}
+
+	return nil
+}
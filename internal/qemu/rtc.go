@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RTCClock is the host clock the guest real time clock is derived from.
+type RTCClock string
+
+const (
+	// RTCClockHost follows the host's system time. This is QEMU's default.
+	RTCClockHost RTCClock = "host"
+	// RTCClockRT follows the host's monotonic clock and is not affected by
+	// changes of the host's system time.
+	RTCClockRT RTCClock = "rt"
+	// RTCClockVM follows the virtual clock of the guest. It does not advance
+	// while the guest is stopped.
+	RTCClockVM RTCClock = "vm"
+)
+
+// RTCDriftFix is the strategy for compensating lost timer interrupts.
+type RTCDriftFix string
+
+const (
+	// RTCDriftFixNone does not compensate lost interrupts.
+	RTCDriftFixNone RTCDriftFix = "none"
+	// RTCDriftFixSlew re-injects lost interrupts gradually. It is only
+	// supported by x86 machines.
+	RTCDriftFixSlew RTCDriftFix = "slew"
+)
+
+// rtcBaseDateTimeFormats are the supported formats for a fixed RTC base.
+var rtcBaseDateTimeFormats = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// RTC defines the guest real time clock. Empty fields are omitted, so QEMU
+// uses its defaults.
+type RTC struct {
+	// Base is the start time of the clock: "utc", "localtime" or a fixed
+	// date and time like "2006-01-02T15:04:05".
+	Base string
+
+	// Clock is the host clock the guest clock is derived from.
+	Clock RTCClock
+
+	// DriftFix is the strategy for compensating lost timer interrupts.
+	DriftFix RTCDriftFix
+}
+
+// IsZero returns true if no field is set.
+func (r *RTC) IsZero() bool {
+	return *r == RTC{}
+}
+
+// String returns the [RTC] as QEMU "-rtc" argument value.
+func (r *RTC) String() string {
+	var opts []string
+
+	if r.Base != "" {
+		opts = append(opts, "base="+r.Base)
+	}
+
+	if r.Clock != "" {
+		opts = append(opts, "clock="+string(r.Clock))
+	}
+
+	if r.DriftFix != "" {
+		opts = append(opts, "driftfix="+string(r.DriftFix))
+	}
+
+	return strings.Join(opts, ",")
+}
+
+// Set parses the given comma separated list of "key=value" pairs with the
+// keys "base", "clock" and "driftfix", like the QEMU "-rtc" argument.
+//
+// It returns [ErrRTCInvalid] if the string is not valid.
+func (r *RTC) Set(s string) error {
+	var rtc RTC
+
+	for _, opt := range strings.Split(s, ",") {
+		key, value, _ := strings.Cut(opt, "=")
+
+		switch key {
+		case "base":
+			rtc.Base = value
+		case "clock":
+			rtc.Clock = RTCClock(value)
+		case "driftfix":
+			rtc.DriftFix = RTCDriftFix(value)
+		default:
+			return fmt.Errorf("%w: unknown option: %s", ErrRTCInvalid, opt)
+		}
+	}
+
+	if err := rtc.validate(); err != nil {
+		return err
+	}
+
+	*r = rtc
+
+	return nil
+}
+
+// validate checks if all fields have known values.
+func (r *RTC) validate() error {
+	switch r.Base {
+	case "", "utc", "localtime":
+	default:
+		if !isRTCBaseDateTime(r.Base) {
+			return fmt.Errorf("%w: base: %s", ErrRTCInvalid, r.Base)
+		}
+	}
+
+	switch r.Clock {
+	case "", RTCClockHost, RTCClockRT, RTCClockVM:
+	default:
+		return fmt.Errorf("%w: clock: %s", ErrRTCInvalid, r.Clock)
+	}
+
+	switch r.DriftFix {
+	case "", RTCDriftFixNone, RTCDriftFixSlew:
+	default:
+		return fmt.Errorf("%w: driftfix: %s", ErrRTCInvalid, r.DriftFix)
+	}
+
+	return nil
+}
+
+func isRTCBaseDateTime(s string) bool {
+	for _, format := range rtcBaseDateTimeFormats {
+		if _, err := time.Parse(format, s); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
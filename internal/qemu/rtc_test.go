@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu_test
+
+import (
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRTC_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    qemu.RTC
+		expectedErr error
+	}{
+		{
+			input: "clock=vm",
+			expected: qemu.RTC{
+				Clock: qemu.RTCClockVM,
+			},
+		},
+		{
+			input: "base=utc,clock=host,driftfix=slew",
+			expected: qemu.RTC{
+				Base:     "utc",
+				Clock:    qemu.RTCClockHost,
+				DriftFix: qemu.RTCDriftFixSlew,
+			},
+		},
+		{
+			input: "driftfix=none,clock=rt,base=2006-01-02T15:04:05",
+			expected: qemu.RTC{
+				Base:     "2006-01-02T15:04:05",
+				Clock:    qemu.RTCClockRT,
+				DriftFix: qemu.RTCDriftFixNone,
+			},
+		},
+		{
+			input: "base=2006-01-02",
+			expected: qemu.RTC{
+				Base: "2006-01-02",
+			},
+		},
+		{
+			input:       "base=yesterday",
+			expectedErr: qemu.ErrRTCInvalid,
+		},
+		{
+			input:       "clock=wall",
+			expectedErr: qemu.ErrRTCInvalid,
+		},
+		{
+			input:       "driftfix=fast",
+			expectedErr: qemu.ErrRTCInvalid,
+		},
+		{
+			input:       "tick=1",
+			expectedErr: qemu.ErrRTCInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var actual qemu.RTC
+
+			err := actual.Set(tt.input)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestRTC_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		rtc      qemu.RTC
+		expected string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "clock only",
+			rtc: qemu.RTC{
+				Clock: qemu.RTCClockVM,
+			},
+			expected: "clock=vm",
+		},
+		{
+			name: "deterministic",
+			rtc: qemu.RTC{
+				Base:  "2006-01-02T15:04:05",
+				Clock: qemu.RTCClockVM,
+			},
+			expected: "base=2006-01-02T15:04:05,clock=vm",
+		},
+		{
+			name: "all",
+			rtc: qemu.RTC{
+				Base:     "localtime",
+				Clock:    qemu.RTCClockHost,
+				DriftFix: qemu.RTCDriftFixSlew,
+			},
+			expected: "base=localtime,clock=host,driftfix=slew",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.rtc.String())
+		})
+	}
+}
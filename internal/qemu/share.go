@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// shareTagPattern matches valid [Share] tags. The kernel limits 9p mount tags
+// to 31 bytes.
+var shareTagPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,31}$`)
+
+// Share is a host directory shared with the guest via virtio 9p. The guest
+// mounts it by its tag with file system type "9p".
+type Share struct {
+	// HostPath is the path of the directory on the host.
+	HostPath string
+
+	// Tag is the mount tag the guest uses as mount source. It must be unique
+	// among all shares of a [CommandSpec].
+	Tag string
+
+	// ReadOnly prevents the guest from modifying the shared directory.
+	ReadOnly bool
+}
+
+// validate checks if the share has a host path and a valid tag.
+func (s *Share) validate() error {
+	if s.HostPath == "" {
+		return fmt.Errorf("%w: empty host path", ErrShareInvalid)
+	}
+
+	if !shareTagPattern.MatchString(s.Tag) {
+		return fmt.Errorf("%w: tag: %q", ErrShareInvalid, s.Tag)
+	}
+
+	return nil
+}
+
+// validateShares checks all shares are valid and their tags are unique.
+func (c *CommandSpec) validateShares() error {
+	tags := make(map[string]bool, len(c.Shares))
+
+	for _, share := range c.Shares {
+		if err := share.validate(); err != nil {
+			return &ArgumentError{err.Error()}
+		}
+
+		if tags[share.Tag] {
+			return &ArgumentError{"duplicate share tag: " + share.Tag}
+		}
+
+		tags[share.Tag] = true
+	}
+
+	return nil
+}
+
+// shareArgs returns the file system device and virtio 9p device arguments for
+// the shares.
+func (c *CommandSpec) shareArgs() []Argument {
+	device := "virtio-9p-pci"
+	if c.TransportType == TransportTypeMMIO {
+		device = "virtio-9p-device"
+	}
+
+	args := make([]Argument, 0, 2*len(c.Shares))
+
+	for idx, share := range c.Shares {
+		id := fmt.Sprintf("share%d", idx)
+
+		fsdevOpts := []string{
+			"local",
+			"id=" + id,
+			"path=" + escapeOptionValue(share.HostPath),
+			"security_model=none",
+		}
+
+		if share.ReadOnly {
+			fsdevOpts = append(fsdevOpts, "readonly=on")
+		}
+
+		args = append(args,
+			RepeatableArg("fsdev", fsdevOpts...),
+			RepeatableArg("device",
+				device, "fsdev="+id, "mount_tag="+share.Tag),
+		)
+	}
+
+	return args
+}
@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// smbiosFields are the supported fields by SMBIOS table type in the order
+// they are rendered.
+var smbiosFields = map[int][]string{
+	// BIOS information.
+	0: {"vendor", "version", "date", "release", "uefi"},
+	// System information.
+	1: {
+		"manufacturer", "product", "version", "serial", "uuid", "sku",
+		"family",
+	},
+	// Baseboard information.
+	2: {"manufacturer", "product", "version", "serial", "asset", "location"},
+	// Chassis information.
+	3: {"manufacturer", "version", "serial", "asset", "sku"},
+}
+
+// SMBIOS defines the fields of an SMBIOS table the guest firmware provides.
+// The guest can read them from /sys/class/dmi/id, like the product name.
+type SMBIOS struct {
+	// Type is the SMBIOS table type. Types 0 to 3 are supported.
+	Type int
+
+	// Fields are the values of the table by QEMU field name, like
+	// "manufacturer" or "product".
+	Fields map[string]string
+}
+
+// String returns the [SMBIOS] as QEMU "-smbios" argument value.
+func (s *SMBIOS) String() string {
+	opts := []string{"type=" + strconv.Itoa(s.Type)}
+
+	for _, field := range smbiosFields[s.Type] {
+		value, exists := s.Fields[field]
+		if !exists {
+			continue
+		}
+
+		opts = append(opts, field+"="+escapeOptionValue(value))
+	}
+
+	return strings.Join(opts, ",")
+}
+
+// Set parses the given comma separated list of "key=value" pairs, like the
+// QEMU "-smbios" argument. The key "type" is required.
+//
+// It returns [ErrSMBIOSInvalid] if the string is not valid.
+func (s *SMBIOS) Set(value string) error {
+	smbios := SMBIOS{Type: -1, Fields: map[string]string{}}
+
+	for _, opt := range strings.Split(value, ",") {
+		key, val, _ := strings.Cut(opt, "=")
+
+		if key != "type" {
+			smbios.Fields[key] = val
+			continue
+		}
+
+		typ, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("%w: type: %s", ErrSMBIOSInvalid, val)
+		}
+
+		smbios.Type = typ
+	}
+
+	if err := smbios.validate(); err != nil {
+		return err
+	}
+
+	*s = smbios
+
+	return nil
+}
+
+// validate checks if the type is supported and all fields are known for the
+// type and have a value.
+func (s *SMBIOS) validate() error {
+	fields, exists := smbiosFields[s.Type]
+	if !exists {
+		return fmt.Errorf("%w: unsupported type: %d", ErrSMBIOSInvalid, s.Type)
+	}
+
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("%w: type %d: no fields", ErrSMBIOSInvalid, s.Type)
+	}
+
+	for field, value := range s.Fields {
+		if !slices.Contains(fields, field) {
+			return fmt.Errorf("%w: type %d: unknown field: %s",
+				ErrSMBIOSInvalid, s.Type, field)
+		}
+
+		if value == "" {
+			return fmt.Errorf("%w: type %d: empty field: %s",
+				ErrSMBIOSInvalid, s.Type, field)
+		}
+	}
+
+	return nil
+}
+
+// validateSMBIOS checks all SMBIOS tables are valid and each type is given
+// only once.
+func (c *CommandSpec) validateSMBIOS() error {
+	types := make(map[int]bool, len(c.SMBIOS))
+
+	for _, smbios := range c.SMBIOS {
+		if err := smbios.validate(); err != nil {
+			return &ArgumentError{err.Error()}
+		}
+
+		if types[smbios.Type] {
+			return &ArgumentError{
+				"duplicate smbios type: " + strconv.Itoa(smbios.Type),
+			}
+		}
+
+		types[smbios.Type] = true
+	}
+
+	return nil
+}
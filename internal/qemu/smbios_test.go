@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu_test
+
+import (
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMBIOS_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    qemu.SMBIOS
+		expectedErr error
+	}{
+		{
+			input: "type=1,manufacturer=Acme,product=Rocket",
+			expected: qemu.SMBIOS{
+				Type: 1,
+				Fields: map[string]string{
+					"manufacturer": "Acme",
+					"product":      "Rocket",
+				},
+			},
+		},
+		{
+			input: "vendor=Acme,type=0",
+			expected: qemu.SMBIOS{
+				Type:   0,
+				Fields: map[string]string{"vendor": "Acme"},
+			},
+		},
+		{
+			input:       "manufacturer=Acme",
+			expectedErr: qemu.ErrSMBIOSInvalid,
+		},
+		{
+			input:       "type=one,manufacturer=Acme",
+			expectedErr: qemu.ErrSMBIOSInvalid,
+		},
+		{
+			input:       "type=17,manufacturer=Acme",
+			expectedErr: qemu.ErrSMBIOSInvalid,
+		},
+		{
+			input:       "type=1",
+			expectedErr: qemu.ErrSMBIOSInvalid,
+		},
+		{
+			input:       "type=1,vendor=Acme",
+			expectedErr: qemu.ErrSMBIOSInvalid,
+		},
+		{
+			input:       "type=1,product=",
+			expectedErr: qemu.ErrSMBIOSInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var actual qemu.SMBIOS
+
+			err := actual.Set(tt.input)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestSMBIOS_String(t *testing.T) {
+	smbios := qemu.SMBIOS{
+		Type: 1,
+		Fields: map[string]string{
+			"product":      "Rocket, Mk II",
+			"manufacturer": "Acme",
+		},
+	}
+
+	assert.Equal(t, "type=1,manufacturer=Acme,product=Rocket,, Mk II",
+		smbios.String())
+}
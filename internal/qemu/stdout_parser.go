@@ -5,6 +5,7 @@
 package qemu
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
 )
@@ -21,12 +22,22 @@ var (
 // the src is closed. After use, the result can be retrieved by calling
 // [stdoutParser.Err]. It returns a [CommandError] with Guest flag set if either
 // an error is detected or the guest communicated a non zero exit code.
+//
+// If CompletionPrefix is set, the payload of a line with that prefix is
+// stored and can be retrieved by calling [stdoutParser.Completion]. The line
+// itself is not printed.
+//
+// If OopsMarker is set, a line equal to it is detected as kernel oops
+// reported by the guest.
 type stdoutParser struct {
-	ExitCodeFmt string
-	Verbose     bool
+	ExitCodeFmt      string
+	CompletionPrefix string
+	OopsMarker       string
+	Verbose          bool
 
 	exitCodeFound bool
 	exitCode      int
+	completion    []byte
 	err           error
 }
 
@@ -44,6 +55,12 @@ func (p *stdoutParser) Parse(data []byte) []byte {
 	case panicRE.MatchString(line):
 		p.err = ErrGuestPanic
 		return data
+	case p.OopsMarker != "" && line == p.OopsMarker:
+		p.err = ErrGuestOops
+		return data
+	case p.isCompletion(data):
+		p.completion = bytes.Clone(data[len(p.CompletionPrefix):])
+		return nil
 	case !p.exitCodeFound:
 		_, err := fmt.Sscanf(line, p.ExitCodeFmt, &p.exitCode)
 		p.exitCodeFound = err == nil
@@ -58,6 +75,18 @@ func (p *stdoutParser) Parse(data []byte) []byte {
 	return data
 }
 
+func (p *stdoutParser) isCompletion(data []byte) bool {
+	return p.CompletionPrefix != "" &&
+		!p.exitCodeFound &&
+		bytes.HasPrefix(data, []byte(p.CompletionPrefix))
+}
+
+// Completion returns the payload of the completion line. It is nil if no
+// completion line has been found.
+func (p *stdoutParser) Completion() []byte {
+	return p.completion
+}
+
 // GuestSuccessful returns nil if the guest ran successfully.
 //
 // Otherwise, it returns a [CommandError] with the guest flag set.
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStdoutParser_Process(t *testing.T) {
@@ -146,3 +147,124 @@ func TestStdoutParser_Process(t *testing.T) {
 		})
 	}
 }
+
+func TestStdoutParser_Completion(t *testing.T) {
+	exitCodeFmt := "exit code: %d"
+	prefix := "completion: "
+
+	tests := []struct {
+		name               string
+		input              []string
+		expected           []string
+		expectedCompletion []byte
+	}{
+		{
+			name: "completion and legacy exit code",
+			input: []string{
+				"something out",
+				prefix + `{"exitCode":0}`,
+				fmt.Sprintf(exitCodeFmt, 0),
+			},
+			expected: []string{
+				"something out",
+			},
+			expectedCompletion: []byte(`{"exitCode":0}`),
+		},
+		{
+			name: "legacy exit code only",
+			input: []string{
+				"something out",
+				fmt.Sprintf(exitCodeFmt, 0),
+			},
+			expected: []string{
+				"something out",
+			},
+		},
+		{
+			name: "completion after exit code",
+			input: []string{
+				fmt.Sprintf(exitCodeFmt, 0),
+				prefix + `{"exitCode":0}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actual []string
+
+			stdoutParser := stdoutParser{
+				ExitCodeFmt:      exitCodeFmt,
+				CompletionPrefix: prefix,
+			}
+
+			for _, line := range tt.input {
+				out := stdoutParser.Parse([]byte(line))
+				if out != nil {
+					actual = append(actual, string(out))
+				}
+			}
+
+			assert.True(t, stdoutParser.exitCodeFound, "exit code found")
+			assert.Equal(t, tt.expectedCompletion, stdoutParser.Completion())
+			assert.Equal(t, tt.expected, actual, "output")
+		})
+	}
+}
+
+func TestStdoutParser_Oops(t *testing.T) {
+	tests := []struct {
+		name        string
+		oopsMarker  string
+		input       []string
+		expectedErr error
+	}{
+		{
+			name:       "marker",
+			oopsMarker: "OOPS",
+			input: []string{
+				"[    1.234567] BUG: kernel NULL pointer dereference",
+				"OOPS",
+				"exit code: -3",
+			},
+			expectedErr: ErrGuestOops,
+		},
+		{
+			name:       "marker as part of line",
+			oopsMarker: "OOPS",
+			input: []string{
+				"some OOPS",
+				"exit code: 0",
+			},
+		},
+		{
+			name: "marker disabled",
+			input: []string{
+				"",
+				"exit code: 0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdoutParser := stdoutParser{
+				ExitCodeFmt: "exit code: %d",
+				OopsMarker:  tt.oopsMarker,
+			}
+
+			for _, line := range tt.input {
+				stdoutParser.Parse([]byte(line))
+			}
+
+			err := stdoutParser.GuestSuccessful()
+			if tt.expectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.True(t, IsGuestFailure(err))
+		})
+	}
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import "bytes"
+
+// stderrTailSize is the maximum number of bytes kept of the stderr output.
+const stderrTailSize = 4096
+
+// tailWriter is an [io.Writer] that keeps only the last max bytes written, so
+// it can capture output of unknown length with bounded memory.
+type tailWriter struct {
+	buf []byte
+	max int
+}
+
+// Write implements [io.Writer]. It never fails.
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+
+	if excess := len(w.buf) - w.max; excess > 0 {
+		w.buf = w.buf[excess:]
+	}
+
+	return len(p), nil
+}
+
+// Bytes returns a copy of the captured bytes.
+func (w *tailWriter) Bytes() []byte {
+	return bytes.Clone(w.buf)
+}
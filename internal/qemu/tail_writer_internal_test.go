@@ -0,0 +1,24 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTailWriter(t *testing.T) {
+	tail := &tailWriter{max: 5}
+
+	for _, data := range []string{"ab", "cdef", "gh"} {
+		n, err := tail.Write([]byte(data))
+		require.NoError(t, err)
+		assert.Equal(t, len(data), n)
+	}
+
+	assert.Equal(t, []byte("defgh"), tail.Bytes())
+}
@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// MaxHostCPUs is the number of CPUs a [unix.CPUSet] can hold. Host CPUs must
+// be lower.
+const MaxHostCPUs = 1024
+
+// validateTopology checks that the CPUs and the memory can be divided evenly
+// into the requested sockets and NUMA nodes.
+func (c *CommandSpec) validateTopology() error {
+	if c.Sockets > 1 && c.SMP%c.Sockets != 0 {
+		return &ArgumentError{fmt.Sprintf(
+			"smp %d not divisible into %d sockets", c.SMP, c.Sockets,
+		)}
+	}
+
+	if c.NUMANodes > 1 {
+		if c.SMP%c.NUMANodes != 0 {
+			return &ArgumentError{fmt.Sprintf(
+				"smp %d not divisible into %d numa nodes", c.SMP, c.NUMANodes,
+			)}
+		}
+
+		if c.Memory%c.NUMANodes != 0 {
+			return &ArgumentError{fmt.Sprintf(
+				"memory %d not divisible into %d numa nodes",
+				c.Memory, c.NUMANodes,
+			)}
+		}
+	}
+
+	for _, cpu := range c.HostCPUs {
+		if cpu < 0 || cpu >= MaxHostCPUs {
+			return &ArgumentError{fmt.Sprintf("invalid host cpu: %d", cpu)}
+		}
+	}
+
+	return nil
+}
+
+// smpValue returns the value for the "-smp" argument.
+func (c *CommandSpec) smpValue() []string {
+	value := []string{strconv.FormatUint(c.SMP, 10)}
+
+	if c.Sockets > 1 {
+		value = append(value, "sockets="+strconv.FormatUint(c.Sockets, 10))
+	}
+
+	return value
+}
+
+// numaArgs returns the arguments for the NUMA nodes. The CPUs and the memory
+// are distributed evenly across the nodes. Each node gets its own memory
+// backend.
+func (c *CommandSpec) numaArgs() []Argument {
+	if c.NUMANodes < 2 {
+		return nil
+	}
+
+	cpusPerNode := c.SMP / c.NUMANodes
+	memoryPerNode := c.Memory / c.NUMANodes
+
+	args := make([]Argument, 0, 2*c.NUMANodes)
+
+	for node := range c.NUMANodes {
+		id := "numa" + strconv.FormatUint(node, 10)
+
+		cpus := strconv.FormatUint(node*cpusPerNode, 10)
+		if cpusPerNode > 1 {
+			cpus += "-" + strconv.FormatUint((node+1)*cpusPerNode-1, 10)
+		}
+
+		args = append(args,
+			RepeatableArg("object",
+				"memory-backend-ram",
+				"id="+id,
+				"size="+strconv.FormatUint(memoryPerNode, 10)+"M",
+			),
+			RepeatableArg("numa",
+				"node",
+				"nodeid="+strconv.FormatUint(node, 10),
+				"cpus="+cpus,
+				"memdev="+id,
+			),
+		)
+	}
+
+	return args
+}
+
+// startPinned starts the command with its CPU affinity restricted to the
+// given host CPUs.
+//
+// The child process inherits the affinity of the thread it is forked from.
+// So, the affinity of the current OS thread is changed for the start and
+// restored afterwards. This way all threads QEMU spawns, including the vCPU
+// threads, are pinned right from the start.
+func startPinned(cmd *exec.Cmd, cpus []int) error {
+	runtime.LockOSThread()
+
+	var orig unix.CPUSet
+
+	err := unix.SchedGetaffinity(0, &orig)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("get cpu affinity: %w", err)
+	}
+
+	var set unix.CPUSet
+	for _, cpu := range cpus {
+		set.Set(cpu)
+	}
+
+	err = unix.SchedSetaffinity(0, &set)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("set cpu affinity: %w", err)
+	}
+
+	startErr := cmd.Start()
+
+	// If the affinity can not be restored, the thread stays locked, so it is
+	// terminated once the goroutine exits and never used for anything else.
+	if unix.SchedSetaffinity(0, &orig) == nil {
+		runtime.UnlockOSThread()
+	}
+
+	if startErr != nil {
+		return fmt.Errorf("start: %w", startErr)
+	}
+
+	return nil
+}
@@ -71,3 +71,9 @@ func (t *TransportType) ConsoleDeviceName(num uint) string {
 
 	return fmt.Sprintf(f, num)
 }
+
+// ConsoleDevicePath returns the absolute path of the console device in the
+// guest.
+func (t *TransportType) ConsoleDevicePath(num uint) string {
+	return "/dev/" + t.ConsoleDeviceName(num)
+}
@@ -41,6 +41,14 @@ func TestCommmandConsoleDeviceName(t *testing.T) {
 	}
 }
 
+func TestTransportType_ConsoleDevicePath(t *testing.T) {
+	isa := qemu.TransportTypeISA
+	assert.Equal(t, "/dev/ttyS1", isa.ConsoleDevicePath(1))
+
+	pci := qemu.TransportTypePCI
+	assert.Equal(t, "/dev/hvc2", pci.ConsoleDevicePath(2))
+}
+
 func TestTransportType_String(t *testing.T) {
 	tests := []struct {
 		input    qemu.TransportType
@@ -0,0 +1,44 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const versionPrefix = "QEMU emulator version "
+
+// Version returns the version of the given QEMU executable as reported by
+// its --version flag.
+func Version(ctx context.Context, executable string) (string, error) {
+	out, err := exec.CommandContext(ctx, executable, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("run %s: %w", executable, err)
+	}
+
+	return parseVersion(out)
+}
+
+func parseVersion(output []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line, found := strings.CutPrefix(scanner.Text(), versionPrefix)
+		if !found {
+			continue
+		}
+
+		// Version might be followed by package information in parentheses.
+		version, _, _ := strings.Cut(line, " ")
+
+		return version, nil
+	}
+
+	return "", ErrVersionNotFound
+}
@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name: "plain",
+			output: "QEMU emulator version 9.1.0\n" +
+				"Copyright (c) 2003-2024 Fabrice Bellard and the QEMU Project" +
+				" developers\n",
+			expected: "9.1.0",
+		},
+		{
+			name: "with package info",
+			output: "QEMU emulator version 8.2.2 (Debian 1:8.2.2+ds)\n" +
+				"Copyright (c) 2003-2023 Fabrice Bellard and the QEMU Project" +
+				" developers\n",
+			expected: "8.2.2",
+		},
+		{
+			name:        "unknown",
+			output:      "something else\n",
+			expectedErr: ErrVersionNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := parseVersion([]byte(tt.output))
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
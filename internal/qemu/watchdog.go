@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package qemu
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// idleWatchdog calls a function once if it has not been reset within the
+// timeout.
+type idleWatchdog struct {
+	timer   *time.Timer
+	timeout time.Duration
+	expired atomic.Bool
+	stopped atomic.Bool
+}
+
+// newIdleWatchdog creates a new [idleWatchdog] that is started immediately.
+func newIdleWatchdog(timeout time.Duration, fn func()) *idleWatchdog {
+	watchdog := &idleWatchdog{
+		timeout: timeout,
+	}
+
+	watchdog.timer = time.AfterFunc(timeout, func() {
+		watchdog.expired.Store(true)
+		fn()
+	})
+
+	return watchdog
+}
+
+// reset restarts the timeout, unless the watchdog expired or has been
+// stopped.
+func (w *idleWatchdog) reset() {
+	if !w.expired.Load() && !w.stopped.Load() {
+		w.timer.Reset(w.timeout)
+	}
+}
+
+// stop stops the watchdog without calling the function.
+func (w *idleWatchdog) stop() {
+	w.stopped.Store(true)
+	w.timer.Stop()
+}
+
+// Expired returns true if the timeout expired before the watchdog has been
+// stopped.
+func (w *idleWatchdog) Expired() bool {
+	return w.expired.Load()
+}
+
+// wrap returns a [lineParseFunc] that resets the watchdog for each line
+// before calling the given function.
+func (w *idleWatchdog) wrap(fn lineParseFunc) lineParseFunc {
+	return func(data []byte) []byte {
+		w.reset()
+
+		if fn == nil {
+			return data
+		}
+
+		return fn(data)
+	}
+}
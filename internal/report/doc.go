@@ -0,0 +1,8 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Package report provides [virtrun.ResultProcessor]s that write test reports
+// in common formats. The reports are built from verbose go test output
+// ("-test.v") of the guest.
+package report
@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aibor/virtrun/internal/virtrun"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes the test cases as JUnit XML report with a single test
+// suite with the given name.
+func WriteJUnit(
+	w io.Writer,
+	name string,
+	duration time.Duration,
+	cases []TestCase,
+) error {
+	suite := junitTestSuite{
+		Name:  name,
+		Tests: len(cases),
+		Time:  seconds(duration),
+		Cases: make([]junitTestCase, 0, len(cases)),
+	}
+
+	for _, testCase := range cases {
+		junitCase := junitTestCase{
+			Name:      testCase.Name,
+			Time:      seconds(testCase.Duration),
+			SystemOut: strings.Join(testCase.Output, "\n"),
+		}
+
+		switch testCase.Status {
+		case StatusFail:
+			suite.Failures++
+			junitCase.Failure = &junitMessage{Message: "Failed"}
+		case StatusSkip:
+			suite.Skipped++
+			junitCase.Skipped = &junitMessage{Message: "Skipped"}
+		case StatusPass:
+		}
+
+		suite.Cases = append(suite.Cases, junitCase)
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	err = encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}})
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	_, err = io.WriteString(w, "\n")
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	return nil
+}
+
+// JUnitWriter is a [virtrun.ResultProcessor] that writes a JUnit XML report
+// file.
+type JUnitWriter struct {
+	// Path of the report file.
+	Path string
+
+	// SuiteName is the name of the test suite in the report.
+	SuiteName string
+}
+
+var _ virtrun.ResultProcessor = (*JUnitWriter)(nil)
+
+// ProcessResult implements [virtrun.ResultProcessor].
+func (w *JUnitWriter) ProcessResult(
+	result *virtrun.Result,
+	output []byte,
+) error {
+	return writeFile(w.Path, func(dst io.Writer) error {
+		cases := ParseGoTestOutput(output)
+		return WriteJUnit(dst, w.SuiteName, result.Duration, cases)
+	})
+}
+
+// writeFile creates the file with the given path and writes to it with the
+// given function.
+func writeFile(path string, fn func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create report: %w", err)
+	}
+
+	err = fn(file)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("close report: %w", err)
+	}
+
+	return nil
+}
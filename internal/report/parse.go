@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the result status of a single test.
+type Status string
+
+const (
+	StatusPass Status = "PASS"
+	StatusFail Status = "FAIL"
+	StatusSkip Status = "SKIP"
+)
+
+// resultLineRE matches result lines of verbose go test output, like
+// "--- FAIL: TestFoo/sub (0.01s)". Results of sub-tests are indented.
+var resultLineRE = regexp.MustCompile(
+	`^(\s*)--- (PASS|FAIL|SKIP): (\S+) \((\d+(?:\.\d+)?)s\)$`,
+)
+
+// TestCase is the result of a single test.
+type TestCase struct {
+	Name     string
+	Status   Status
+	Duration time.Duration
+
+	// Output are the log lines printed with the result, without
+	// indentation.
+	Output []string
+}
+
+// ParseGoTestOutput parses verbose go test output and returns the test
+// cases in the order their results are printed.
+func ParseGoTestOutput(output []byte) []TestCase {
+	var (
+		cases      []TestCase
+		indent     string
+		collecting bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		match := resultLineRE.FindStringSubmatch(line)
+		if match != nil {
+			seconds, _ := strconv.ParseFloat(match[4], 64)
+
+			cases = append(cases, TestCase{
+				Name:     match[3],
+				Status:   Status(match[2]),
+				Duration: time.Duration(seconds * float64(time.Second)),
+			})
+			// Log lines are indented by 4 spaces relative to the result.
+			indent = match[1] + "    "
+			collecting = true
+
+			continue
+		}
+
+		if !collecting {
+			continue
+		}
+
+		logLine, found := strings.CutPrefix(line, indent)
+		if !found {
+			// Any other output ends the log lines of the test.
+			collecting = false
+			continue
+		}
+
+		last := &cases[len(cases)-1]
+		last.Output = append(last.Output, logLine)
+	}
+
+	return cases
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package report_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aibor/virtrun/internal/report"
+	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleOutput = `=== RUN   TestPass
+--- PASS: TestPass (0.00s)
+=== RUN   TestFail
+=== RUN   TestFail/sub
+    main_test.go:12: something went wrong
+    main_test.go:13: really
+--- FAIL: TestFail (0.01s)
+    --- FAIL: TestFail/sub (0.01s)
+        main_test.go:20: sub failed
+=== RUN   TestSkip
+    main_test.go:30: not today
+--- SKIP: TestSkip (0.00s)
+FAIL
+`
+
+func TestParseGoTestOutput(t *testing.T) {
+	expected := []report.TestCase{
+		{
+			Name:   "TestPass",
+			Status: report.StatusPass,
+		},
+		{
+			Name:     "TestFail",
+			Status:   report.StatusFail,
+			Duration: 10 * time.Millisecond,
+		},
+		{
+			Name:     "TestFail/sub",
+			Status:   report.StatusFail,
+			Duration: 10 * time.Millisecond,
+			Output:   []string{"main_test.go:20: sub failed"},
+		},
+		{
+			Name:   "TestSkip",
+			Status: report.StatusSkip,
+		},
+	}
+
+	actual := report.ParseGoTestOutput([]byte(sampleOutput))
+	assert.Equal(t, expected, actual)
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+
+	cases := report.ParseGoTestOutput([]byte(sampleOutput))
+
+	err := report.WriteJUnit(&buf, "main.test", 2*time.Second, cases)
+	require.NoError(t, err)
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="main.test" tests="4" failures="2" skipped="1" time="2.000">
+    <testcase name="TestPass" time="0.000"></testcase>
+    <testcase name="TestFail" time="0.010">
+      <failure message="Failed"></failure>
+    </testcase>
+    <testcase name="TestFail/sub" time="0.010">
+      <failure message="Failed"></failure>
+      <system-out>main_test.go:20: sub failed</system-out>
+    </testcase>
+    <testcase name="TestSkip" time="0.000">
+      <skipped message="Skipped"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriteTAP(t *testing.T) {
+	var buf bytes.Buffer
+
+	cases := report.ParseGoTestOutput([]byte(sampleOutput))
+
+	require.NoError(t, report.WriteTAP(&buf, cases))
+
+	expected := `TAP version 13
+1..4
+ok 1 - TestPass
+not ok 2 - TestFail
+not ok 3 - TestFail/sub
+# main_test.go:20: sub failed
+ok 4 - TestSkip # SKIP
+`
+
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWriters_ProcessResult(t *testing.T) {
+	dir := t.TempDir()
+	result := &virtrun.Result{Duration: time.Second}
+
+	processors := map[string]virtrun.ResultProcessor{
+		"junit.xml": &report.JUnitWriter{
+			Path:      filepath.Join(dir, "junit.xml"),
+			SuiteName: "main.test",
+		},
+		"report.tap": &report.TAPWriter{
+			Path: filepath.Join(dir, "report.tap"),
+		},
+	}
+
+	for name, processor := range processors {
+		err := processor.ProcessResult(result, []byte(sampleOutput))
+		require.NoError(t, err, name)
+
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err, name)
+		assert.Contains(t, string(content), "TestFail/sub", name)
+	}
+}
@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/aibor/virtrun/internal/virtrun"
+)
+
+// WriteTAP writes the test cases as TAP version 13 report. Output lines of
+// failed tests are written as diagnostic lines.
+func WriteTAP(w io.Writer, cases []TestCase) error {
+	buf := bufio.NewWriter(w)
+
+	fmt.Fprintln(buf, "TAP version 13")
+	fmt.Fprintf(buf, "1..%d\n", len(cases))
+
+	for idx, testCase := range cases {
+		number := idx + 1
+
+		switch testCase.Status {
+		case StatusFail:
+			fmt.Fprintf(buf, "not ok %d - %s\n", number, testCase.Name)
+
+			for _, line := range testCase.Output {
+				fmt.Fprintf(buf, "# %s\n", line)
+			}
+		case StatusSkip:
+			fmt.Fprintf(buf, "ok %d - %s # SKIP\n", number, testCase.Name)
+		case StatusPass:
+			fmt.Fprintf(buf, "ok %d - %s\n", number, testCase.Name)
+		}
+	}
+
+	err := buf.Flush()
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	return nil
+}
+
+// TAPWriter is a [virtrun.ResultProcessor] that writes a TAP report file.
+type TAPWriter struct {
+	// Path of the report file.
+	Path string
+}
+
+var _ virtrun.ResultProcessor = (*TAPWriter)(nil)
+
+// ProcessResult implements [virtrun.ResultProcessor].
+func (w *TAPWriter) ProcessResult(_ *virtrun.Result, output []byte) error {
+	return writeFile(w.Path, func(dst io.Writer) error {
+		return WriteTAP(dst, ParseGoTestOutput(output))
+	})
+}
@@ -6,7 +6,6 @@ package sys
 
 import (
 	"errors"
-	"os"
 	"runtime"
 )
 
@@ -15,12 +14,18 @@ type Arch string
 const (
 	AMD64   Arch = "amd64"
 	ARM64   Arch = "arm64"
+	ARM     Arch = "arm"
 	RISCV64 Arch = "riscv64"
 	Native  Arch = Arch(runtime.GOARCH)
 )
 
 var ErrArchNotSupported = errors.New("architecture not supported")
 
+// SupportedArches returns all supported architectures in stable order.
+func SupportedArches() []Arch {
+	return []Arch{AMD64, ARM64, ARM, RISCV64}
+}
+
 func (a *Arch) String() string {
 	return string(*a)
 }
@@ -30,20 +35,15 @@ func (a *Arch) IsNative() bool {
 }
 
 // KVMAvailable checks if KVM support is available for the given architecture.
+//
+// See [Arch.KVMStatus] for the reason if it is not.
 func (a *Arch) KVMAvailable() bool {
-	if !a.IsNative() {
-		return false
-	}
-
-	f, err := os.OpenFile("/dev/kvm", os.O_WRONLY, 0)
-	_ = f.Close()
-
-	return err == nil
+	return a.KVMStatus() == KVMUsable
 }
 
 func (a *Arch) Set(s string) error {
 	switch Arch(s) {
-	case AMD64, ARM64, RISCV64:
+	case AMD64, ARM64, ARM, RISCV64:
 		*a = Arch(s)
 	default:
 		return ErrArchNotSupported
@@ -13,7 +13,10 @@ import (
 // ReadELFArch returns the [sys.Arch] of the given ELF file.
 //
 // It returns an error if the ELF file is not for Linux or is for an
-// unsupported architecture.
+// unsupported architecture. The architecture is determined by the machine
+// type along with class and byte order, as they must match as well. So, 32 bit
+// ARM (EABI) is supported little-endian only, and 32 bit ABIs of 64 bit
+// machines, like x32, are not supported.
 func ReadELFArch(fileName string) (Arch, error) {
 	file, err := elfOpen(fileName)
 	if err != nil {
@@ -28,15 +31,33 @@ func ReadELFArch(fileName string) (Arch, error) {
 		return "", fmt.Errorf("%w: %s", ErrOSABINotSupported, file.OSABI)
 	}
 
-	switch file.Machine {
+	arch, class := elfMachineArch(file.Machine)
+	if arch == "" {
+		return "", fmt.Errorf("%w: %s", ErrMachineNotSupported, file.Machine)
+	}
+
+	if file.Class != class || file.Data != elf.ELFDATA2LSB {
+		return "", fmt.Errorf("%w: %s %s %s", ErrMachineNotSupported,
+			file.Machine, file.Class, file.Data)
+	}
+
+	return arch, nil
+}
+
+// elfMachineArch returns the [Arch] for the given ELF machine type along with
+// the ELF class it requires. The [Arch] is empty for unsupported types.
+func elfMachineArch(machine elf.Machine) (Arch, elf.Class) {
+	switch machine {
 	case elf.EM_X86_64:
-		return AMD64, nil
+		return AMD64, elf.ELFCLASS64
 	case elf.EM_AARCH64:
-		return ARM64, nil
+		return ARM64, elf.ELFCLASS64
+	case elf.EM_ARM:
+		return ARM, elf.ELFCLASS32
 	case elf.EM_RISCV:
-		return RISCV64, nil
+		return RISCV64, elf.ELFCLASS64
 	default:
-		return "", fmt.Errorf("%w: %s", ErrMachineNotSupported, file.Machine)
+		return "", elf.ELFCLASSNONE
 	}
 }
 
@@ -5,7 +5,9 @@
 package sys_test
 
 import (
+	"debug/elf"
 	"io/fs"
+	"path/filepath"
 	"testing"
 
 	"github.com/aibor/virtrun/internal/sys"
@@ -29,6 +31,11 @@ func TestReadArch(t *testing.T) {
 			expected:  sys.ARM64,
 			assertErr: require.NoError,
 		},
+		{
+			name:      "arm",
+			expected:  sys.ARM,
+			assertErr: require.NoError,
+		},
 		{
 			name:      "riscv64",
 			expected:  sys.RISCV64,
@@ -51,3 +58,96 @@ func TestReadArch(t *testing.T) {
 		})
 	}
 }
+
+func TestReadArch_Header(t *testing.T) {
+	tests := []struct {
+		name        string
+		class       elf.Class
+		data        elf.Data
+		machine     elf.Machine
+		expected    sys.Arch
+		expectedErr error
+	}{
+		{
+			name:     "amd64",
+			class:    elf.ELFCLASS64,
+			data:     elf.ELFDATA2LSB,
+			machine:  elf.EM_X86_64,
+			expected: sys.AMD64,
+		},
+		{
+			name:        "x32",
+			class:       elf.ELFCLASS32,
+			data:        elf.ELFDATA2LSB,
+			machine:     elf.EM_X86_64,
+			expectedErr: sys.ErrMachineNotSupported,
+		},
+		{
+			name:     "arm64",
+			class:    elf.ELFCLASS64,
+			data:     elf.ELFDATA2LSB,
+			machine:  elf.EM_AARCH64,
+			expected: sys.ARM64,
+		},
+		{
+			name:        "arm64 ilp32",
+			class:       elf.ELFCLASS32,
+			data:        elf.ELFDATA2LSB,
+			machine:     elf.EM_AARCH64,
+			expectedErr: sys.ErrMachineNotSupported,
+		},
+		{
+			name:        "arm64 big-endian",
+			class:       elf.ELFCLASS64,
+			data:        elf.ELFDATA2MSB,
+			machine:     elf.EM_AARCH64,
+			expectedErr: sys.ErrMachineNotSupported,
+		},
+		{
+			name:     "arm",
+			class:    elf.ELFCLASS32,
+			data:     elf.ELFDATA2LSB,
+			machine:  elf.EM_ARM,
+			expected: sys.ARM,
+		},
+		{
+			name:        "arm big-endian",
+			class:       elf.ELFCLASS32,
+			data:        elf.ELFDATA2MSB,
+			machine:     elf.EM_ARM,
+			expectedErr: sys.ErrMachineNotSupported,
+		},
+		{
+			name:     "riscv64",
+			class:    elf.ELFCLASS64,
+			data:     elf.ELFDATA2LSB,
+			machine:  elf.EM_RISCV,
+			expected: sys.RISCV64,
+		},
+		{
+			name:        "riscv32",
+			class:       elf.ELFCLASS32,
+			data:        elf.ELFDATA2LSB,
+			machine:     elf.EM_RISCV,
+			expectedErr: sys.ErrMachineNotSupported,
+		},
+		{
+			name:        "unsupported machine",
+			class:       elf.ELFCLASS32,
+			data:        elf.ELFDATA2LSB,
+			machine:     elf.EM_386,
+			expectedErr: sys.ErrMachineNotSupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "binary")
+			sys.WriteELFHeaderFile(t, path, tt.class, tt.data, tt.machine)
+
+			actual, err := sys.ReadELFArch(path)
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
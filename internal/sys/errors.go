@@ -20,4 +20,8 @@ var (
 	// ErrMachineNotSupported is returned if the machine type of an ELF file
 	// is not supported.
 	ErrMachineNotSupported = errors.New("machine type not supported")
+
+	// ErrNoKernelVersion is returned if no kernel version can be found in a
+	// kernel image or kernel module file.
+	ErrNoKernelVersion = errors.New("no kernel version found")
 )
@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sys
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// goTestMarker is the name of the function of the testing package that runs
+// each test. Function names are kept in the Go pclntab, so it is present even
+// in stripped binaries.
+const goTestMarker = "testing.tRunner"
+
+// IsGoTestBinary returns true if the file with the given path is a Go test
+// binary, as built by "go test -c".
+func IsGoTestBinary(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("open binary: %w", err)
+	}
+	defer file.Close()
+
+	found, err := containsBytes(file, []byte(goTestMarker))
+	if err != nil {
+		return false, fmt.Errorf("read binary: %w", err)
+	}
+
+	return found, nil
+}
+
+// containsBytes returns true if the reader contains the given pattern. It
+// reads in chunks, so the content is never held in memory completely.
+func containsBytes(r io.Reader, pattern []byte) (bool, error) {
+	const chunkSize = 1 << 20
+
+	buf := make([]byte, chunkSize+len(pattern))
+	kept := 0
+
+	for {
+		n, err := r.Read(buf[kept:])
+		data := buf[:kept+n]
+
+		if bytes.Contains(data, pattern) {
+			return true, nil
+		}
+
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+
+		if err != nil {
+			return false, err //nolint:wrapcheck
+		}
+
+		// Keep the tail, as the pattern might span across chunks.
+		kept = min(len(pattern)-1, len(data))
+		copy(buf, data[len(data)-kept:])
+	}
+}
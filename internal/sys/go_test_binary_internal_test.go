@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sys
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsGoTestBinary(t *testing.T) {
+	testBinary, err := os.Executable()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected bool
+	}{
+		{
+			name:     "test binary",
+			path:     testBinary,
+			expected: true,
+		},
+		{
+			name: "non-test binary",
+			path: "testdata/bin/main",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := IsGoTestBinary(tt.path)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestContainsBytes_AcrossReads(t *testing.T) {
+	data := []byte("some testing.tRunner data")
+
+	// Reads byte by byte, so the pattern always spans several reads.
+	found, err := containsBytes(
+		iotest.OneByteReader(bytes.NewReader(data)),
+		[]byte(goTestMarker),
+	)
+	require.NoError(t, err)
+	assert.True(t, found)
+}
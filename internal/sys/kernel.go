@@ -0,0 +1,268 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sys
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	// x86 boot protocol header offsets. See
+	// https://docs.kernel.org/arch/x86/boot.html
+	bzImageSetupOffset       = 0x200
+	bzImageMagicOffset       = 0x202
+	bzImageVersionPtrOffset  = 0x20e
+	bzImageMagic             = "HdrS"
+	linuxVersionBannerPrefix = "Linux version "
+	gzipMagic                = "\x1f\x8b"
+
+	moduleExtPlain = ".ko"
+	moduleExtGzip  = ".ko.gz"
+	moduleExtXz    = ".ko.xz"
+	moduleExtZstd  = ".ko.zst"
+)
+
+// ReadKernelVersion returns the release version of the kernel image file with
+// the given path, like "6.8.0-40-generic".
+//
+// It supports x86 bzImage files that have the version in their boot header.
+// For other images, the version banner is searched for in the file. Gzip
+// compressed images are decompressed on the fly. [ErrNoKernelVersion] is
+// returned if no version can be found.
+func ReadKernelVersion(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open kernel: %w", err)
+	}
+	defer file.Close()
+
+	version, err := readBzImageVersion(file)
+	if err == nil {
+		return version, nil
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", fmt.Errorf("seek kernel: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+
+	magic, err := reader.Peek(len(gzipMagic))
+	if err == nil && string(magic) == gzipMagic {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return "", fmt.Errorf("gzip reader: %w", err)
+		}
+		defer gzipReader.Close()
+
+		return readBannerVersion(bufio.NewReader(gzipReader))
+	}
+
+	return readBannerVersion(reader)
+}
+
+func readBzImageVersion(r io.ReaderAt) (string, error) {
+	magic := make([]byte, len(bzImageMagic))
+
+	_, err := r.ReadAt(magic, bzImageMagicOffset)
+	if err != nil || string(magic) != bzImageMagic {
+		return "", ErrNoKernelVersion
+	}
+
+	ptr := make([]byte, 2)
+
+	_, err = r.ReadAt(ptr, bzImageVersionPtrOffset)
+	if err != nil {
+		return "", ErrNoKernelVersion
+	}
+
+	offset := int64(binary.LittleEndian.Uint16(ptr)) + bzImageSetupOffset
+	reader := bufio.NewReader(io.NewSectionReader(r, offset, 1<<10))
+
+	line, err := reader.ReadString(0)
+	if err != nil {
+		return "", ErrNoKernelVersion
+	}
+
+	return firstField(line)
+}
+
+func readBannerVersion(r io.ByteReader) (string, error) {
+	prefix := []byte(linuxVersionBannerPrefix)
+	matched := 0
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", ErrNoKernelVersion
+		}
+
+		switch {
+		case b == prefix[matched]:
+			matched++
+		case b == prefix[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+
+		if matched < len(prefix) {
+			continue
+		}
+
+		var version strings.Builder
+
+		for {
+			b, err := r.ReadByte()
+			if err != nil || b == ' ' || b == 0 {
+				break
+			}
+
+			version.WriteByte(b)
+		}
+
+		// Skip format strings like "Linux version %s".
+		if version.Len() == 0 || strings.Contains(version.String(), "%") {
+			matched = 0
+			continue
+		}
+
+		return version.String(), nil
+	}
+}
+
+// ReadModuleVersion returns the kernel release version the kernel module file
+// with the given path has been built for.
+//
+// It is read from the "vermagic" field of the module info. The module file
+// may be compressed, see [ReadModule].
+func ReadModuleVersion(path string) (string, error) {
+	data, err := ReadModule(path)
+	if err != nil {
+		return "", err
+	}
+
+	elfFile, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("parse module: %w", err)
+	}
+	defer elfFile.Close()
+
+	section := elfFile.Section(".modinfo")
+	if section == nil {
+		return "", ErrNoKernelVersion
+	}
+
+	modinfo, err := section.Data()
+	if err != nil {
+		return "", fmt.Errorf("read modinfo: %w", err)
+	}
+
+	return parseVermagic(modinfo)
+}
+
+// ReadModule returns the content of the kernel module file with the given
+// path.
+//
+// Plain modules and gzip, xz and zstd compressed modules are supported, which
+// are decompressed. For other file types an error wrapping
+// [errors.ErrUnsupported] is returned.
+func ReadModule(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open module: %w", err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+
+	switch {
+	case strings.HasSuffix(path, moduleExtPlain):
+	case strings.HasSuffix(path, moduleExtGzip):
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("gzip reader: %w", err)
+		}
+		defer gzipReader.Close()
+
+		reader = gzipReader
+	case strings.HasSuffix(path, moduleExtXz):
+		xzReader, err := xz.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("xz reader: %w", err)
+		}
+
+		reader = xzReader
+	case strings.HasSuffix(path, moduleExtZstd):
+		zstdReader, err := zstd.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("zstd reader: %w", err)
+		}
+		defer zstdReader.Close()
+
+		reader = zstdReader
+	default:
+		return nil, fmt.Errorf("module %s: %w", path, errors.ErrUnsupported)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read module: %w", err)
+	}
+
+	return data, nil
+}
+
+// PlainModuleName returns the file name of the kernel module file with the
+// given path once decompressed by [ReadModule], so with the compression
+// extension removed. The second return value is true if the file is
+// compressed.
+func PlainModuleName(path string) (string, bool) {
+	name := filepath.Base(path)
+
+	for _, ext := range []string{moduleExtGzip, moduleExtXz, moduleExtZstd} {
+		if base, found := strings.CutSuffix(name, ext); found {
+			return base + moduleExtPlain, true
+		}
+	}
+
+	return name, false
+}
+
+// parseVermagic returns the kernel release from the "vermagic" field of the
+// given NUL separated module info.
+func parseVermagic(modinfo []byte) (string, error) {
+	for _, field := range bytes.Split(modinfo, []byte{0}) {
+		value, found := bytes.CutPrefix(field, []byte("vermagic="))
+		if found {
+			return firstField(string(value))
+		}
+	}
+
+	return "", ErrNoKernelVersion
+}
+
+func firstField(s string) (string, error) {
+	fields := strings.Fields(strings.TrimRight(s, "\x00"))
+	if len(fields) == 0 {
+		return "", ErrNoKernelVersion
+	}
+
+	return fields[0], nil
+}
@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sys
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KernelConfig is a parsed kernel build configuration. It maps option names,
+// like "CONFIG_VIRTIO_CONSOLE", to their values, like "y" or "m". Options that
+// are not set are absent.
+type KernelConfig map[string]string
+
+// Builtin returns true if the option is compiled into the kernel.
+func (c KernelConfig) Builtin(option string) bool {
+	return c[option] == "y"
+}
+
+// kernelImagePrefixes are common file name prefixes of kernel images that are
+// followed by the release version.
+var kernelImagePrefixes = []string{
+	"vmlinuz-",
+	"vmlinux-",
+	"bzImage-",
+	"Image-",
+}
+
+// FindKernelConfig returns the path of the build configuration file belonging
+// to the kernel image with the given path.
+//
+// It looks for distribution style "config-<version>" files next to images
+// named like "vmlinuz-<version>" and for ".config" files of kernel source
+// trees the image is built in. An error wrapping [fs.ErrNotExist] is returned
+// if none is found.
+func FindKernelConfig(kernelPath string) (string, error) {
+	dir, base := filepath.Split(kernelPath)
+
+	var candidates []string
+
+	for _, prefix := range kernelImagePrefixes {
+		if version, found := strings.CutPrefix(base, prefix); found {
+			candidates = append(candidates,
+				filepath.Join(dir, "config-"+version))
+		}
+	}
+
+	candidates = append(candidates,
+		filepath.Join(dir, ".config"),
+		// Images built in kernel source trees end up in "arch/*/boot/".
+		filepath.Join(dir, "..", "..", "..", ".config"),
+	)
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err == nil && info.Mode().IsRegular() {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("config for %s: %w", kernelPath, fs.ErrNotExist)
+}
+
+// ReadKernelConfig reads the kernel build configuration file with the given
+// path. Gzip compressed files, like "/proc/config.gz", are supported.
+func ReadKernelConfig(path string) (KernelConfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open kernel config: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	magic, err := reader.Peek(len(gzipMagic))
+	if err == nil && string(magic) == gzipMagic {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("gzip kernel config: %w", err)
+		}
+		defer gzipReader.Close()
+
+		return ParseKernelConfig(gzipReader)
+	}
+
+	return ParseKernelConfig(reader)
+}
+
+// ParseKernelConfig parses kernel build configuration in the format of
+// kernel ".config" files.
+func ParseKernelConfig(r io.Reader) (KernelConfig, error) {
+	config := KernelConfig{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Comments include "# CONFIG_FOO is not set" lines.
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, found := strings.Cut(line, "=")
+		if !found || !strings.HasPrefix(name, "CONFIG_") {
+			continue
+		}
+
+		config[name] = strings.Trim(value, `"`)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read kernel config: %w", err)
+	}
+
+	return config, nil
+}
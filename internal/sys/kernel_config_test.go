@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sys_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleKernelConfig = `#
+# Automatically generated file; DO NOT EDIT.
+#
+CONFIG_VIRTIO_PCI=y
+CONFIG_VIRTIO_CONSOLE=m
+# CONFIG_VIRTIO_MMIO is not set
+CONFIG_LOCALVERSION="-custom"
+`
+
+func TestParseKernelConfig(t *testing.T) {
+	config, err := sys.ParseKernelConfig(strings.NewReader(sampleKernelConfig))
+	require.NoError(t, err)
+
+	expected := sys.KernelConfig{
+		"CONFIG_VIRTIO_PCI":     "y",
+		"CONFIG_VIRTIO_CONSOLE": "m",
+		"CONFIG_LOCALVERSION":   "-custom",
+	}
+
+	assert.Equal(t, expected, config)
+	assert.True(t, config.Builtin("CONFIG_VIRTIO_PCI"))
+	assert.False(t, config.Builtin("CONFIG_VIRTIO_CONSOLE"))
+	assert.False(t, config.Builtin("CONFIG_VIRTIO_MMIO"))
+}
+
+func TestReadKernelConfig_Gzip(t *testing.T) {
+	var gzipped bytes.Buffer
+
+	gzipWriter := gzip.NewWriter(&gzipped)
+	_, err := gzipWriter.Write([]byte(sampleKernelConfig))
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+
+	path := filepath.Join(t.TempDir(), "config.gz")
+	require.NoError(t, os.WriteFile(path, gzipped.Bytes(), 0o600))
+
+	config, err := sys.ReadKernelConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "y", config["CONFIG_VIRTIO_PCI"])
+}
+
+func TestFindKernelConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       []string
+		kernel      string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "distribution style",
+			files:    []string{"boot/vmlinuz-6.8.0", "boot/config-6.8.0"},
+			kernel:   "boot/vmlinuz-6.8.0",
+			expected: "boot/config-6.8.0",
+		},
+		{
+			name: "source tree",
+			files: []string{
+				"linux/arch/x86/boot/bzImage",
+				"linux/.config",
+			},
+			kernel:   "linux/arch/x86/boot/bzImage",
+			expected: "linux/.config",
+		},
+		{
+			name:        "missing",
+			files:       []string{"boot/vmlinuz-6.8.0", "boot/config-6.9.0"},
+			kernel:      "boot/vmlinuz-6.8.0",
+			expectedErr: fs.ErrNotExist,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			for _, file := range tt.files {
+				path := filepath.Join(dir, file)
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+				require.NoError(t, os.WriteFile(path, nil, 0o600))
+			}
+
+			actual, err := sys.FindKernelConfig(filepath.Join(dir, tt.kernel))
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			if tt.expectedErr == nil {
+				expected := filepath.Join(dir, tt.expected)
+				assert.Equal(t, expected, filepath.Clean(actual))
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sys_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadKernelVersion(t *testing.T) {
+	banner := "\x00Linux version %s\x00Linux version 6.1.0-arm64 (me@host)\x00"
+
+	bzImage := make([]byte, 0x400)
+	copy(bzImage[0x202:], "HdrS")
+	binary.LittleEndian.PutUint16(bzImage[0x20e:], 0x100)
+	copy(bzImage[0x300:], "6.8.0-40-generic (buildd@lcy02) #40\x00")
+
+	var gzipped bytes.Buffer
+
+	gzipWriter := gzip.NewWriter(&gzipped)
+	_, err := gzipWriter.Write([]byte(banner))
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+
+	tests := []struct {
+		name        string
+		content     []byte
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "bzImage",
+			content:  bzImage,
+			expected: "6.8.0-40-generic",
+		},
+		{
+			name:     "plain banner",
+			content:  []byte(banner),
+			expected: "6.1.0-arm64",
+		},
+		{
+			name:     "gzip banner",
+			content:  gzipped.Bytes(),
+			expected: "6.1.0-arm64",
+		},
+		{
+			name:        "no version",
+			content:     []byte("some random content"),
+			expectedErr: sys.ErrNoKernelVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "vmlinuz")
+			require.NoError(t, os.WriteFile(path, tt.content, 0o600))
+
+			actual, err := sys.ReadKernelVersion(path)
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestReadModuleVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileName    string
+		modinfo     string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "plain",
+			fileName: "mod.ko",
+			modinfo:  "license=GPL\x00vermagic=6.8.0-40-generic SMP mod_unload\x00",
+			expected: "6.8.0-40-generic",
+		},
+		{
+			name:        "no vermagic",
+			fileName:    "mod.ko",
+			modinfo:     "license=GPL\x00",
+			expectedErr: sys.ErrNoKernelVersion,
+		},
+		{
+			name:     "gzip",
+			fileName: "mod.ko.gz",
+			modinfo:  "vermagic=6.8.0-40-generic\x00",
+			expected: "6.8.0-40-generic",
+		},
+		{
+			name:     "xz",
+			fileName: "mod.ko.xz",
+			modinfo:  "vermagic=6.8.0-40-generic\x00",
+			expected: "6.8.0-40-generic",
+		},
+		{
+			name:     "zstd",
+			fileName: "mod.ko.zst",
+			modinfo:  "vermagic=6.8.0-40-generic\x00",
+			expected: "6.8.0-40-generic",
+		},
+		{
+			name:        "unsupported compression",
+			fileName:    "mod.ko.bz2",
+			modinfo:     "vermagic=6.8.0-40-generic\x00",
+			expectedErr: errors.ErrUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.fileName)
+			sys.WriteModuleFile(t, path, tt.modinfo)
+
+			actual, err := sys.ReadModuleVersion(path)
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestPlainModuleName(t *testing.T) {
+	tests := []struct {
+		path               string
+		expectedName       string
+		expectedCompressed bool
+	}{
+		{
+			path:         "/lib/modules/mod.ko",
+			expectedName: "mod.ko",
+		},
+		{
+			path:               "/lib/modules/mod.ko.gz",
+			expectedName:       "mod.ko",
+			expectedCompressed: true,
+		},
+		{
+			path:               "/lib/modules/mod.ko.xz",
+			expectedName:       "mod.ko",
+			expectedCompressed: true,
+		},
+		{
+			path:               "/lib/modules/mod.ko.zst",
+			expectedName:       "mod.ko",
+			expectedCompressed: true,
+		},
+		{
+			path:         "/lib/modules/mod.ko.bz2",
+			expectedName: "mod.ko.bz2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			name, compressed := sys.PlainModuleName(tt.path)
+			assert.Equal(t, tt.expectedName, name)
+			assert.Equal(t, tt.expectedCompressed, compressed)
+		})
+	}
+}
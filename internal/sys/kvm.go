@@ -0,0 +1,58 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sys
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+const kvmDevice = "/dev/kvm"
+
+// KVMStatus describes if KVM can be used and the reason if not.
+type KVMStatus string
+
+const (
+	// KVMUsable is returned if KVM can be used.
+	KVMUsable KVMStatus = "kvm usable"
+	// KVMArchNotNative is returned if the arch is not the host's arch.
+	KVMArchNotNative KVMStatus = "arch is not native"
+	// KVMDeviceMissing is returned if the KVM device file does not exist.
+	KVMDeviceMissing KVMStatus = kvmDevice + " does not exist"
+	// KVMPermissionDenied is returned if the KVM device file can not be
+	// opened due to missing permissions.
+	KVMPermissionDenied KVMStatus = "permission denied for " + kvmDevice
+	// KVMDeviceError is returned if the KVM device file can not be opened
+	// for other reasons.
+	KVMDeviceError KVMStatus = kvmDevice + " can not be opened"
+)
+
+// KVMStatus checks if KVM can be used for the given architecture.
+func (a *Arch) KVMStatus() KVMStatus {
+	if !a.IsNative() {
+		return KVMArchNotNative
+	}
+
+	f, err := os.OpenFile(kvmDevice, os.O_WRONLY, 0)
+	if err == nil {
+		_ = f.Close()
+	}
+
+	return kvmStatusFor(err)
+}
+
+func kvmStatusFor(openErr error) KVMStatus {
+	switch {
+	case openErr == nil:
+		return KVMUsable
+	case errors.Is(openErr, fs.ErrNotExist):
+		return KVMDeviceMissing
+	case errors.Is(openErr, fs.ErrPermission):
+		return KVMPermissionDenied
+	default:
+		return KVMDeviceError
+	}
+}
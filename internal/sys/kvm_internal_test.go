@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sys
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKVMStatusFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected KVMStatus
+	}{
+		{
+			name:     "usable",
+			expected: KVMUsable,
+		},
+		{
+			name: "missing",
+			err: &fs.PathError{
+				Op:   "open",
+				Path: kvmDevice,
+				Err:  fs.ErrNotExist,
+			},
+			expected: KVMDeviceMissing,
+		},
+		{
+			name: "permission denied",
+			err: &fs.PathError{
+				Op:   "open",
+				Path: kvmDevice,
+				Err:  fs.ErrPermission,
+			},
+			expected: KVMPermissionDenied,
+		},
+		{
+			name:     "other",
+			err:      errors.New("device busy"),
+			expected: KVMDeviceError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, kvmStatusFor(tt.err))
+		})
+	}
+}
+
+func TestArch_KVMStatus_NonNative(t *testing.T) {
+	arch := ARM64
+	if Native == ARM64 {
+		arch = AMD64
+	}
+
+	assert.Equal(t, KVMArchNotNative, arch.KVMStatus())
+}
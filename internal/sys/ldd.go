@@ -27,6 +27,10 @@ const lddTimeout = 5 * time.Second
 // an ELF file. [ErrNoInterpreter] is returned if no interpreter path is found
 // in the ELF file. This is the case if the binary was statically linked.
 //
+// The interpreter is read from the PT_INTERP program header, so non-glibc
+// loaders like musl's "/lib/ld-musl-x86_64.so.1" are supported as well. The
+// interpreter is always part of the returned paths.
+//
 // The objects are searched for in the usual search paths of the
 // file's interpreter. Note that the dynamic linker consumes the environment
 // variable LD_LIBRARY_PATH, so it can be used to add additional search paths.
@@ -94,6 +94,19 @@ func TestLdInfosParseFrom(t *testing.T) {
 				"/usr/lib64/ld-linux-x86-64.so.2",
 			},
 		},
+		{
+			name: "musl",
+			//nolint:lll
+			// $ ldd /bin/busybox
+			lines: []string{
+				"	/lib/ld-musl-x86_64.so.1 (0x7f2c1a9c4000)",
+				"	libc.musl-x86_64.so.1 => /lib/ld-musl-x86_64.so.1 (0x7f2c1a9c4000)",
+			},
+			paths: []string{
+				"/lib/ld-musl-x86_64.so.1",
+				"/lib/ld-musl-x86_64.so.1",
+			},
+		},
 	}
 
 	for _, tt := range tests {
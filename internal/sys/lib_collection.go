@@ -5,9 +5,11 @@
 package sys
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"iter"
 	"maps"
 	"path/filepath"
@@ -19,6 +21,9 @@ import (
 type LibCollection struct {
 	libs        map[string]int
 	searchPaths map[string]int
+
+	// deps maps each ELF file to the libraries it requires directly.
+	deps map[string]map[string]bool
 }
 
 func (c *LibCollection) Libs() iter.Seq[string] {
@@ -41,6 +46,27 @@ func (c *LibCollection) SearchPaths() iter.Seq[string] {
 	}
 }
 
+// DynamicallyLinked returns those of the given files that are dynamically
+// linked ELF files, in the given order. The files must have been given to
+// [CollectLibsFor]. Statically linked ELF files and other files are omitted.
+func (c *LibCollection) DynamicallyLinked(files ...string) ([]string, error) {
+	var dynamic []string
+
+	for _, name := range files {
+		absName, err := filepath.Abs(name)
+		if err != nil {
+			return nil, fmt.Errorf("absolute path: %w", err)
+		}
+
+		// Only dynamically linked files have their dependencies collected.
+		if _, exists := c.deps[absName]; exists {
+			dynamic = append(dynamic, name)
+		}
+	}
+
+	return dynamic, nil
+}
+
 // CollectLibsFor recursively resolves the dynamically linked shared objects of
 // all given ELF files.
 //
@@ -52,19 +78,18 @@ func CollectLibsFor(
 	collection := LibCollection{
 		libs:        make(map[string]int),
 		searchPaths: make(map[string]int),
+		deps:        make(map[string]map[string]bool),
 	}
 
 	for _, name := range files {
-		err := collectLibsFor(ctx, collection.libs, name)
+		err := collectLibsFor(ctx, collection.libs, collection.deps, name)
 		if err != nil {
 			return collection, fmt.Errorf("[%s]: %w", name, err)
 		}
 	}
 
 	for name := range collection.libs {
-		dir, _ := filepath.Split(name)
-
-		err := collectSearchPathsFor(collection.searchPaths, dir)
+		err := collectSearchPathsForLib(collection.searchPaths, name)
 		if err != nil {
 			return collection, fmt.Errorf("[%s]: %w", name, err)
 		}
@@ -76,6 +101,7 @@ func CollectLibsFor(
 func collectLibsFor(
 	ctx context.Context,
 	libs map[string]int,
+	deps map[string]map[string]bool,
 	name string,
 ) error {
 	// For each regular file, try to get linked shared objects.
@@ -92,6 +118,8 @@ func collectLibsFor(
 		return err
 	}
 
+	absPaths := make([]string, 0, len(paths))
+
 	for _, p := range paths {
 		absPath, err := filepath.Abs(p)
 		if err != nil {
@@ -99,11 +127,125 @@ func collectLibsFor(
 		}
 
 		libs[absPath]++
+
+		absPaths = append(absPaths, absPath)
+	}
+
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		return fmt.Errorf("absolute path: %w", err)
+	}
+
+	return collectDepsFor(deps, absName, absPaths)
+}
+
+// collectDepsFor adds the direct dependencies of the given file and all of
+// its libraries to deps.
+//
+// The libraries required by an ELF file are given by their soname. They are
+// resolved to the paths found for the file, as the dynamic linker looks up
+// libraries by file name.
+func collectDepsFor(
+	deps map[string]map[string]bool,
+	name string,
+	libs []string,
+) error {
+	bySoname := make(map[string]string, len(libs))
+	for _, lib := range libs {
+		bySoname[filepath.Base(lib)] = lib
+	}
+
+	for _, file := range append([]string{name}, libs...) {
+		if _, exists := deps[file]; exists {
+			continue
+		}
+
+		sonames, err := importedLibraries(file)
+		if err != nil {
+			return err
+		}
+
+		deps[file] = make(map[string]bool, len(sonames))
+
+		for _, soname := range sonames {
+			if lib, exists := bySoname[soname]; exists {
+				deps[file][lib] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// importedLibraries returns the sonames of the libraries the ELF file with
+// the given name requires directly. Non ELF files have none.
+func importedLibraries(name string) ([]string, error) {
+	elfFile, err := elfOpen(name)
+	if err != nil {
+		if errors.Is(err, ErrNotELFFile) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer elfFile.Close()
+
+	sonames, err := elfFile.ImportedLibraries()
+	if err != nil {
+		return nil, fmt.Errorf("read imported libraries: %w", err)
+	}
+
+	return sonames, nil
+}
+
+// WriteDOT writes the dependency graph of the collected files in graphviz
+// DOT format. Each ELF file is connected to the libraries it requires
+// directly.
+func (c *LibCollection) WriteDOT(w io.Writer) error {
+	var buf bytes.Buffer
+
+	buf.WriteString("digraph libs {\n")
+
+	for _, file := range slices.Sorted(maps.Keys(c.deps)) {
+		fmt.Fprintf(&buf, "\t%q;\n", file)
+
+		for _, lib := range slices.Sorted(maps.Keys(c.deps[file])) {
+			fmt.Fprintf(&buf, "\t%q -> %q;\n", file, lib)
+		}
+	}
+
+	buf.WriteString("}\n")
+
+	_, err := buf.WriteTo(w)
+	if err != nil {
+		return fmt.Errorf("write dot: %w", err)
 	}
 
 	return nil
 }
 
+// collectSearchPathsForLib collects the search paths for the directory of the
+// given library. If the library itself is a symbolic link into another
+// directory, like the musl dynamic loader on some distributions, the search
+// paths for the directory of the link target are collected as well.
+func collectSearchPathsForLib(paths map[string]int, name string) error {
+	err := collectSearchPathsFor(paths, filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+
+	realName, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return fmt.Errorf("resolve symlinks: %w", err)
+	}
+
+	if realName == name {
+		return nil
+	}
+
+	return collectSearchPathsFor(paths, filepath.Dir(realName))
+}
+
 func collectSearchPathsFor(paths map[string]int, dir string) error {
 	dir = filepath.Clean(dir)
 	if dir == "" {
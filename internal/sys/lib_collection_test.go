@@ -5,8 +5,13 @@
 package sys_test
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/aibor/virtrun/internal/sys"
@@ -43,3 +48,90 @@ func TestLibCollection_CollectLibsFor(t *testing.T) {
 		assert.Contains(t, actual, expected, name)
 	}
 }
+
+func TestLibCollection_DynamicallyLinked(t *testing.T) {
+	// The pre-built init programs are statically linked.
+	static := "../virtrun/bin/amd64"
+
+	collection, err := sys.CollectLibsFor(
+		context.Background(),
+		"testdata/bin/main",
+		static,
+		"testdata/src/defs.h",
+	)
+	require.NoError(t, err)
+
+	actual, err := collection.DynamicallyLinked(
+		static,
+		"testdata/bin/main",
+		"testdata/src/defs.h",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testdata/bin/main"}, actual)
+}
+
+func TestLibCollection_WriteDOT(t *testing.T) {
+	collection, err := sys.CollectLibsFor(
+		context.Background(),
+		"testdata/bin/main",
+	)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	require.NoError(t, collection.WriteDOT(&buf))
+
+	main := sys.MustAbsPath(t, "testdata/bin/main")
+	libfunc1 := sys.MustAbsPath(t, "testdata/lib/libfunc1.so")
+	libfunc2 := sys.MustAbsPath(t, "testdata/lib/libfunc2.so")
+	libfunc3 := sys.MustAbsPath(t, "testdata/lib/libfunc3.so")
+
+	expectedLines := []string{
+		"digraph libs {",
+		fmt.Sprintf("\t%q;", main),
+		fmt.Sprintf("\t%q -> %q;", main, libfunc2),
+		fmt.Sprintf("\t%q -> %q;", main, libfunc3),
+		fmt.Sprintf("\t%q;", libfunc3),
+		fmt.Sprintf("\t%q -> %q;", libfunc3, libfunc1),
+		fmt.Sprintf("\t%q;", libfunc1),
+		"}",
+	}
+
+	lines := strings.Split(buf.String(), "\n")
+
+	for _, expected := range expectedLines {
+		assert.Contains(t, lines, expected)
+	}
+
+	assert.NotContains(t, lines, fmt.Sprintf("\t%q -> %q;", main, libfunc1),
+		"transitive libs must not be connected directly")
+}
+
+func TestLibCollection_CollectLibsFor_Musl(t *testing.T) {
+	tempDir := t.TempDir()
+	libDir := filepath.Join(tempDir, "lib")
+	realDir := filepath.Join(tempDir, "usr", "lib", "musl")
+	loader := filepath.Join(libDir, "ld-musl-x86_64.so.1")
+	realLoader := filepath.Join(realDir, "libc.so")
+	binary := filepath.Join(tempDir, "main")
+
+	require.NoError(t, os.MkdirAll(libDir, 0o755))
+	require.NoError(t, os.MkdirAll(realDir, 0o755))
+
+	// Fake loader printing the output format of the musl loader.
+	script := "#!/bin/sh\n" +
+		"printf '\\t%s (0x7f2c1a9c4000)\\n' " + loader + "\n" +
+		"printf '\\tlibc.musl-x86_64.so.1 => %s (0x7f2c1a9c4000)\\n' " +
+		loader + "\n"
+	require.NoError(t, os.WriteFile(realLoader, []byte(script), 0o755))
+	require.NoError(t, os.Symlink(realLoader, loader))
+
+	sys.WriteInterpreterFile(t, binary, loader)
+
+	collection, err := sys.CollectLibsFor(context.Background(), binary)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{loader}, slices.Collect(collection.Libs()))
+	assert.Equal(t, []string{libDir, realDir},
+		slices.Collect(collection.SearchPaths()))
+}
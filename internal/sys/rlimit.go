@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sys
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// RlimitInfinity is the value of an unlimited resource limit.
+const RlimitInfinity = unix.RLIM_INFINITY
+
+// MemLockLimit returns the current soft limit of locked memory in bytes.
+func MemLockLimit() (uint64, error) {
+	var rlimit unix.Rlimit
+
+	err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &rlimit)
+	if err != nil {
+		return 0, fmt.Errorf("getrlimit: %w", err)
+	}
+
+	return rlimit.Cur, nil
+}
+
+// IsPrivileged returns true if the process runs as root and is not subject
+// to most resource limits.
+func IsPrivileged() bool {
+	return os.Geteuid() == 0
+}
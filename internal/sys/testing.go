@@ -5,12 +5,21 @@
 package sys
 
 import (
+	"bytes"
+	"compress/gzip"
+	"debug/elf"
+	"encoding/binary"
+	"io"
+	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
 )
 
 func AssertContainsPaths(tb testing.TB, actual, expected []string) bool {
@@ -51,3 +60,187 @@ func MustAbsPath(tb testing.TB, path string) string {
 
 	return abs
 }
+
+// WriteModuleFile writes a minimal relocatable ELF file with the given
+// NUL separated module info as ".modinfo" section to the given path. If the
+// path has a ".ko.gz", ".ko.xz" or ".ko.zst" extension, the file is
+// compressed accordingly.
+func WriteModuleFile(tb testing.TB, path string, modinfo string) {
+	tb.Helper()
+
+	const (
+		headerSize  = 64
+		sectionSize = 64
+	)
+
+	shstrtab := "\x00.modinfo\x00.shstrtab\x00"
+	modinfoOffset := uint64(headerSize)
+	shstrtabOffset := modinfoOffset + uint64(len(modinfo))
+	shOffset := (shstrtabOffset + uint64(len(shstrtab)) + 7) &^ 7
+
+	var buf bytes.Buffer
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_REL),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     shOffset,
+		Ehsize:    headerSize,
+		Shentsize: sectionSize,
+		Shnum:     3,
+		Shstrndx:  2,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	sections := []elf.Section64{
+		{},
+		{
+			Name:      1,
+			Type:      uint32(elf.SHT_PROGBITS),
+			Off:       modinfoOffset,
+			Size:      uint64(len(modinfo)),
+			Addralign: 1,
+		},
+		{
+			Name:      10,
+			Type:      uint32(elf.SHT_STRTAB),
+			Off:       shstrtabOffset,
+			Size:      uint64(len(shstrtab)),
+			Addralign: 1,
+		},
+	}
+
+	require.NoError(tb, binary.Write(&buf, binary.LittleEndian, header))
+	buf.WriteString(modinfo)
+	buf.WriteString(shstrtab)
+	buf.Write(make([]byte, shOffset-uint64(buf.Len())))
+	require.NoError(tb, binary.Write(&buf, binary.LittleEndian, sections))
+
+	data := compressModule(tb, path, buf)
+	require.NoError(tb, os.WriteFile(path, data, 0o600))
+}
+
+func compressModule(tb testing.TB, path string, data bytes.Buffer) []byte {
+	tb.Helper()
+
+	var (
+		compressed bytes.Buffer
+		writer     io.WriteCloser
+		err        error
+	)
+
+	switch {
+	case strings.HasSuffix(path, moduleExtGzip):
+		writer = gzip.NewWriter(&compressed)
+	case strings.HasSuffix(path, moduleExtXz):
+		writer, err = xz.NewWriter(&compressed)
+	case strings.HasSuffix(path, moduleExtZstd):
+		writer, err = zstd.NewWriter(&compressed)
+	default:
+		return data.Bytes()
+	}
+
+	require.NoError(tb, err)
+
+	_, err = data.WriteTo(writer)
+	require.NoError(tb, err)
+	require.NoError(tb, writer.Close())
+
+	return compressed.Bytes()
+}
+
+// WriteInterpreterFile writes a minimal ELF executable with the given ELF
+// interpreter path as PT_INTERP program header to the given path.
+func WriteInterpreterFile(tb testing.TB, path string, interpreter string) {
+	tb.Helper()
+
+	const (
+		headerSize     = 64
+		progHeaderSize = 56
+	)
+
+	interp := interpreter + "\x00"
+
+	var buf bytes.Buffer
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Phoff:     headerSize,
+		Ehsize:    headerSize,
+		Phentsize: progHeaderSize,
+		Phnum:     1,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	prog := elf.Prog64{
+		Type:   uint32(elf.PT_INTERP),
+		Flags:  uint32(elf.PF_R),
+		Off:    headerSize + progHeaderSize,
+		Filesz: uint64(len(interp)),
+		Memsz:  uint64(len(interp)),
+		Align:  1,
+	}
+
+	require.NoError(tb, binary.Write(&buf, binary.LittleEndian, header))
+	require.NoError(tb, binary.Write(&buf, binary.LittleEndian, prog))
+	buf.WriteString(interp)
+
+	require.NoError(tb, os.WriteFile(path, buf.Bytes(), 0o600))
+}
+
+// WriteELFHeaderFile writes an ELF file that consists of the ELF header with
+// the given class, byte order and machine type only to the given path.
+func WriteELFHeaderFile(
+	tb testing.TB,
+	path string,
+	class elf.Class,
+	data elf.Data,
+	machine elf.Machine,
+) {
+	tb.Helper()
+
+	var byteOrder binary.ByteOrder = binary.LittleEndian
+	if data == elf.ELFDATA2MSB {
+		byteOrder = binary.BigEndian
+	}
+
+	var ident [elf.EI_NIDENT]byte
+	copy(ident[:], elf.ELFMAG)
+	ident[elf.EI_CLASS] = byte(class)
+	ident[elf.EI_DATA] = byte(data)
+	ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	var header any
+
+	switch class {
+	case elf.ELFCLASS32:
+		header = elf.Header32{
+			Ident:   ident,
+			Type:    uint16(elf.ET_EXEC),
+			Machine: uint16(machine),
+			Version: uint32(elf.EV_CURRENT),
+			Ehsize:  52,
+		}
+	default:
+		header = elf.Header64{
+			Ident:   ident,
+			Type:    uint16(elf.ET_EXEC),
+			Machine: uint16(machine),
+			Version: uint32(elf.EV_CURRENT),
+			Ehsize:  64,
+		}
+	}
+
+	var buf bytes.Buffer
+
+	require.NoError(tb, binary.Write(&buf, byteOrder, header))
+	require.NoError(tb, os.WriteFile(path, buf.Bytes(), 0o600))
+}
@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// cachedArchivePrefix is the name prefix of archive files in the cache dir.
+const cachedArchivePrefix = "initramfs-"
+
+// archiveCacheKey returns the key the archive of the given file tree is
+// cached with.
+//
+// The key is the SHA-256 hash of the compression, the names and types of all
+// entries of the tree, the symbolic link targets and the input files. Input
+// files are the prepend archives and the host files regular files are read
+// from. They are hashed by their absolute path, size, modification time, mode
+// and content, so changing or touching any of them results in a new key.
+// Regular files without host file, like the init program and the guest
+// config, are hashed by content only.
+func archiveCacheKey(
+	fsys *archiveFS,
+	compression Compression,
+	prepend []string,
+) (string, error) {
+	hash := sha256.New()
+
+	_, _ = fmt.Fprintf(hash, "compression %s\n",
+		cmp.Or(compression, CompressionNone))
+
+	for _, path := range prepend {
+		err := hashInputFile(hash, path)
+		if err != nil {
+			return "", fmt.Errorf("prepend archive: %w", err)
+		}
+	}
+
+	err := fs.WalkDir(fsys, ".", func(
+		name string, d fs.DirEntry, err error,
+	) error {
+		if err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(hash, "entry %q %s\n", name, d.Type())
+
+		switch d.Type() {
+		case fs.ModeSymlink:
+			target, err := fsys.ReadLink(name)
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			_, _ = fmt.Fprintf(hash, "link %q\n", target)
+		case 0:
+			source, ok := fsys.sources[name]
+			if ok {
+				return hashInputFile(hash, source)
+			}
+
+			return hashFSFile(hash, fsys, name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("hash file tree: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashInputFile writes the identity and the content of the host file at the
+// given path.
+func hashInputFile(w io.Writer, path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	file, err := os.Open(absPath)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	_, _ = fmt.Fprintf(w, "input %q %d %d %s\n", absPath, info.Size(),
+		info.ModTime().UnixNano(), info.Mode())
+
+	_, err = io.Copy(w, file)
+
+	return err //nolint:wrapcheck
+}
+
+// hashFSFile writes the content of the named file of the given [fs.FS].
+func hashFSFile(w io.Writer, fsys fs.FS, name string) error {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+
+	return err //nolint:wrapcheck
+}
+
+// cachedInitramfsArchive returns the path of the archive of the given file
+// tree in the [Initramfs.CacheDir].
+//
+// The archive is only written if it is not cached yet. It is written to a
+// temporary file first and renamed once complete, so concurrent runs never
+// see partially written archives. The returned cleanup function never
+// removes the cached archive.
+func cachedInitramfsArchive(
+	irfs *archiveFS,
+	cfg Initramfs,
+) (string, func() error, error) {
+	key, err := archiveCacheKey(irfs, cfg.Compression, cfg.PrependArchives)
+	if err != nil {
+		return "", nil, fmt.Errorf("cache key: %w", err)
+	}
+
+	path := filepath.Join(cfg.CacheDir, cachedArchivePrefix+key)
+
+	removeFn := func() error {
+		slog.Debug("Keep cached initramfs archive", slog.String("path", path))
+		return nil
+	}
+
+	if cfg.Keep {
+		removeFn = func() error {
+			slog.Info("Keep initramfs archive", slog.String("path", path))
+			return nil
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err == nil && info.Mode().IsRegular() {
+		slog.Debug("Use cached initramfs archive", slog.String("path", path))
+		return path, removeFn, nil
+	}
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", nil, fmt.Errorf("cached archive: %w", err)
+	}
+
+	err = os.MkdirAll(cfg.CacheDir, 0o755)
+	if err != nil {
+		return "", nil, fmt.Errorf("create cache dir: %w", err)
+	}
+
+	tempPath, err := writeFSToTempFile(irfs, cfg.CacheDir, cfg.Compression,
+		cfg.progress, cfg.PrependArchives...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = os.Rename(tempPath, path)
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return "", nil, fmt.Errorf("cache archive: %w", err)
+	}
+
+	slog.Debug("Cached initramfs archive", slog.String("path", path))
+
+	return path, removeFn, nil
+}
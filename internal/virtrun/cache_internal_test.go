@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildInitramfsArchive_Cache(t *testing.T) {
+	arch, err := sys.ReadELFArch("../sys/testdata/bin/main")
+	require.NoError(t, err)
+
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	tree := writeTree(t, map[string]string{"app.conf": "first"})
+	dataFile := filepath.Join(tree, "app.conf")
+
+	cfg := Initramfs{
+		Binary:   "../sys/testdata/bin/main",
+		Trees:    []FileMapping{{Source: tree, Target: "/etc"}},
+		CacheDir: cacheDir,
+	}
+
+	build := func() (string, os.FileInfo) {
+		path, removeFn, err := BuildInitramfsArchive(
+			context.Background(),
+			cfg,
+			initProgOpenFunc(arch),
+		)
+		require.NoError(t, err)
+		require.NoError(t, removeFn())
+
+		info, err := os.Stat(path)
+		require.NoError(t, err, "cached archive must not be removed")
+
+		return path, info
+	}
+
+	cacheEntries := func() int {
+		entries, err := os.ReadDir(cacheDir)
+		require.NoError(t, err)
+
+		return len(entries)
+	}
+
+	firstPath, firstInfo := build()
+	assert.Equal(t, cacheDir, filepath.Dir(firstPath))
+	assert.Equal(t, 1, cacheEntries())
+
+	t.Run("hit", func(t *testing.T) {
+		path, info := build()
+		assert.Equal(t, firstPath, path)
+		assert.True(t, os.SameFile(firstInfo, info), "must not be rewritten")
+		assert.Equal(t, 1, cacheEntries())
+	})
+
+	modTime := time.Now().Add(time.Hour)
+
+	t.Run("miss with changed modification time", func(t *testing.T) {
+		require.NoError(t, os.Chtimes(dataFile, modTime, modTime))
+
+		path, _ := build()
+		assert.NotEqual(t, firstPath, path)
+		assert.Equal(t, 2, cacheEntries())
+	})
+
+	t.Run("miss with changed content only", func(t *testing.T) {
+		before, _ := build()
+
+		require.NoError(t, os.WriteFile(dataFile, []byte("FIRST"), 0o600))
+		require.NoError(t, os.Chtimes(dataFile, modTime, modTime))
+
+		path, _ := build()
+		assert.NotEqual(t, before, path)
+		assert.Equal(t, 3, cacheEntries())
+	})
+
+	t.Run("miss with changed size", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(dataFile, []byte("second"), 0o600))
+		require.NoError(t, os.Chtimes(dataFile, modTime, modTime))
+
+		path, _ := build()
+		assert.NotEqual(t, firstPath, path)
+		assert.Equal(t, 4, cacheEntries())
+	})
+
+	t.Run("miss with changed compression", func(t *testing.T) {
+		cfg.Compression = CompressionGzip
+		defer func() { cfg.Compression = "" }()
+
+		path, _ := build()
+		assert.NotEqual(t, firstPath, path)
+		assert.Equal(t, 5, cacheEntries())
+	})
+}
+
+func TestBuildInitramFS_Sources(t *testing.T) {
+	tree := writeTree(t, map[string]string{"conf/app.conf": "content"})
+
+	cfg := Initramfs{
+		Binary: "/tmp/main",
+		Files:  []string{"/tmp/file"},
+		Trees:  []FileMapping{{Source: tree, Target: "/etc"}},
+	}
+
+	initFn := func(b *fsBuilder, name string) error {
+		return b.add(name, func() (fs.File, error) {
+			return nil, assert.AnError
+		})
+	}
+
+	irfs, err := buildInitramFS(cfg, sys.LibCollection{}, initFn)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/tmp/main", irfs.sources["main"])
+	assert.Equal(t, "/tmp/file", irfs.sources["data/file"])
+	assert.Equal(t, filepath.Join(tree, "conf/app.conf"),
+		irfs.sources["etc/conf/app.conf"])
+	assert.NotContains(t, irfs.sources, "init", "created by virtrun")
+}
+
+func TestArchiveCacheKey_DefaultCompression(t *testing.T) {
+	irfs, err := buildInitramfsArchive(
+		context.Background(),
+		Initramfs{Binary: "../sys/testdata/bin/main", StandaloneInit: true},
+		nil,
+	)
+	require.NoError(t, err)
+
+	empty, err := archiveCacheKey(irfs, "", nil)
+	require.NoError(t, err)
+
+	none, err := archiveCacheKey(irfs, CompressionNone, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, none, empty)
+}
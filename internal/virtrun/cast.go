@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	castVersion = 2
+	castWidth   = 80
+	castHeight  = 24
+)
+
+// castHeader is the header line of an asciinema v2 cast file.
+type castHeader struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title,omitempty"`
+}
+
+// castWriter writes everything written to it as timed output events into an
+// asciinema v2 cast file.
+type castWriter struct {
+	dst   io.Writer
+	start time.Time
+	now   func() time.Time
+}
+
+// newCastWriter writes the cast header into dst and returns a [castWriter]
+// that writes output events relative to the current time into dst.
+func newCastWriter(
+	dst io.Writer,
+	title string,
+	now func() time.Time,
+) (*castWriter, error) {
+	start := now()
+
+	header, err := json.Marshal(castHeader{
+		Version:   castVersion,
+		Width:     castWidth,
+		Height:    castHeight,
+		Timestamp: start.Unix(),
+		Title:     title,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal cast header: %w", err)
+	}
+
+	_, err = fmt.Fprintf(dst, "%s\n", header)
+	if err != nil {
+		return nil, fmt.Errorf("write cast header: %w", err)
+	}
+
+	return &castWriter{
+		dst:   dst,
+		start: start,
+		now:   now,
+	}, nil
+}
+
+// Write writes p as a single output event.
+func (w *castWriter) Write(p []byte) (int, error) {
+	elapsed := w.now().Sub(w.start).Seconds()
+
+	event, err := json.Marshal([]any{elapsed, "o", string(p)})
+	if err != nil {
+		return 0, fmt.Errorf("marshal cast event: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w.dst, "%s\n", event)
+	if err != nil {
+		return 0, fmt.Errorf("write cast event: %w", err)
+	}
+
+	return len(p), nil
+}
+
+// createCastFile creates the cast file at the given path and returns a
+// [castWriter] writing into it along with a function that closes the file.
+func createCastFile(
+	path string,
+	title string,
+) (*castWriter, func() error, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create cast file: %w", err)
+	}
+
+	writer, err := newCastWriter(file, title, time.Now)
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+
+	return writer, file.Close, nil
+}
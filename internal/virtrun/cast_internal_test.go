@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCastWriter(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	now := start
+
+	var buf bytes.Buffer
+
+	writer, err := newCastWriter(&buf, "bin.test", func() time.Time {
+		return now
+	})
+	require.NoError(t, err)
+
+	_, err = writer.Write([]byte("first\n"))
+	require.NoError(t, err)
+
+	now = now.Add(1500 * time.Millisecond)
+
+	n, err := writer.Write([]byte("second \"quoted\"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 16, n)
+
+	scanner := bufio.NewScanner(&buf)
+
+	require.True(t, scanner.Scan())
+
+	var header castHeader
+
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &header))
+	assert.Equal(t, castHeader{
+		Version:   2,
+		Width:     80,
+		Height:    24,
+		Timestamp: 1700000000,
+		Title:     "bin.test",
+	}, header)
+
+	expectedEvents := [][]any{
+		{0.0, "o", "first\n"},
+		{1.5, "o", "second \"quoted\"\n"},
+	}
+
+	for _, expected := range expectedEvents {
+		require.True(t, scanner.Scan())
+
+		var event []any
+
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		assert.Equal(t, expected, event)
+	}
+
+	assert.False(t, scanner.Scan(), "no more lines")
+}
+
+func TestCreateCastFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.cast")
+
+	writer, closeFn, err := createCastFile(path, "bin.test")
+	require.NoError(t, err)
+
+	_, err = writer.Write([]byte("output\n"))
+	require.NoError(t, err)
+	require.NoError(t, closeFn())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	lines := bytes.Split(bytes.TrimSpace(content), []byte("\n"))
+	require.Len(t, lines, 2)
+	assert.Contains(t, string(lines[0]), `"version":2`)
+	assert.Contains(t, string(lines[1]), `"o","output\n"`)
+}
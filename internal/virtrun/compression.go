@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CompressionNone writes the archive uncompressed. This is the default.
+	CompressionNone Compression = "none"
+	// CompressionGzip compresses the archive with gzip. Requires a kernel
+	// built with CONFIG_RD_GZIP.
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd compresses the archive with zstd. Requires a kernel
+	// built with CONFIG_RD_ZSTD.
+	CompressionZstd Compression = "zstd"
+)
+
+// Compression is the compression format of the initramfs archive. The empty
+// value is the same as [CompressionNone].
+type Compression string
+
+func (c *Compression) isKnown() bool {
+	knownCompressions := []Compression{
+		"",
+		CompressionNone,
+		CompressionGzip,
+		CompressionZstd,
+	}
+
+	return slices.Contains(knownCompressions, *c)
+}
+
+// String returns the [Compression]'s underlying string value.
+func (c *Compression) String() string {
+	return string(*c)
+}
+
+// Set parses the given string and sets the receiving [Compression].
+//
+// It returns [ErrCompressionUnknown] if the string does not represent a valid
+// [Compression].
+func (c *Compression) Set(s string) error {
+	compression := Compression(s)
+
+	if !compression.isKnown() {
+		return fmt.Errorf("%w: %s", ErrCompressionUnknown, s)
+	}
+
+	*c = compression
+
+	return nil
+}
+
+// nopWriteCloser wraps an [io.Writer] with a no-op Close method.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// newWriter returns a writer that compresses all data written into the given
+// writer. The returned writer must be closed to flush all data. Closing it
+// does not close the given writer.
+func (c *Compression) newWriter(w io.Writer) (io.WriteCloser, error) {
+	switch *c {
+	case "", CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		writer, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+
+		return writer, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrCompressionUnknown, *c)
+	}
+}
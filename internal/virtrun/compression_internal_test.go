@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    Compression
+		expectedErr error
+	}{
+		{
+			input:    "none",
+			expected: CompressionNone,
+		},
+		{
+			input:    "gzip",
+			expected: CompressionGzip,
+		},
+		{
+			input:    "zstd",
+			expected: CompressionZstd,
+		},
+		{
+			input:       "xz",
+			expectedErr: ErrCompressionUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var actual Compression
+
+			err := actual.Set(tt.input)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestWriteFSToTempFile_Compression(t *testing.T) {
+	tests := []struct {
+		compression Compression
+		magic       []byte
+		decompress  func(io.Reader) (io.Reader, error)
+	}{
+		{
+			compression: "",
+			magic:       []byte(cpioMagicNewc),
+			decompress: func(r io.Reader) (io.Reader, error) {
+				return r, nil
+			},
+		},
+		{
+			compression: CompressionNone,
+			magic:       []byte(cpioMagicNewc),
+			decompress: func(r io.Reader) (io.Reader, error) {
+				return r, nil
+			},
+		},
+		{
+			compression: CompressionGzip,
+			magic:       []byte{0x1f, 0x8b},
+			decompress: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		{
+			compression: CompressionZstd,
+			magic:       []byte{0x28, 0xb5, 0x2f, 0xfd},
+			decompress: func(r io.Reader) (io.Reader, error) {
+				return zstd.NewReader(r)
+			},
+		},
+	}
+
+	fsys := fstest.MapFS{
+		"main": &fstest.MapFile{Data: []byte("main")},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.compression), func(t *testing.T) {
+			path, err := writeFSToTempFile(fsys, t.TempDir(), tt.compression,
+				nil)
+			require.NoError(t, err)
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			assert.True(t, bytes.HasPrefix(content, tt.magic),
+				"magic bytes: % x", content[:len(tt.magic)])
+
+			reader, err := tt.decompress(bytes.NewReader(content))
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{".", "main"}, readCPIONames(t, reader))
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path"
+
+	"github.com/aibor/virtrun/internal/ext4"
+	"github.com/aibor/virtrun/sysinit"
+)
+
+// DiskMount is the mount point of the first of [Qemu.Disks] in the guest.
+type DiskMount struct {
+	// Path is the absolute path the disk is mounted at in the guest.
+	Path string
+
+	// FSType is the file system type of the disk, like "ext4". The guest
+	// kernel must support it.
+	FSType sysinit.FSType
+}
+
+// applyDiskMount adds a mount point for the first of [Qemu.Disks] to
+// [Initramfs.GuestConfig], if [Qemu.DiskMount] is set, so the guest's init
+// mounts it. A given guest config is copied, not modified.
+func (s *Spec) applyDiskMount() error {
+	mount := s.Qemu.DiskMount
+	if mount == nil {
+		return nil
+	}
+
+	if len(s.Qemu.Disks) == 0 {
+		return fmt.Errorf("%w: no disk", ErrDiskMountInvalid)
+	}
+
+	if !path.IsAbs(mount.Path) || mount.FSType == "" {
+		return fmt.Errorf("%w: %s:%s", ErrDiskMountInvalid, mount.Path,
+			mount.FSType)
+	}
+
+	guestCfg := sysinit.GuestConfig{}
+	if s.Initramfs.GuestConfig != nil {
+		guestCfg = *s.Initramfs.GuestConfig
+	}
+
+	guestCfg.Mounts = maps.Clone(guestCfg.Mounts)
+	if guestCfg.Mounts == nil {
+		guestCfg.Mounts = make(sysinit.MountPoints, 1)
+	}
+
+	guestCfg.Mounts[mount.Path] = sysinit.DiskMountOptions(
+		mount.FSType,
+		s.Qemu.Disks[0].ReadOnly,
+	)
+
+	s.Initramfs.GuestConfig = &guestCfg
+
+	return nil
+}
+
+// verifyDiskExtract verifies the first of [Qemu.Disks] can be extracted, if
+// [Spec.DiskExtractDir] is set. Only writable raw image files are written by
+// the guest and readable on the host.
+func (s *Spec) verifyDiskExtract() error {
+	if s.DiskExtractDir == "" {
+		return nil
+	}
+
+	if len(s.Qemu.Disks) == 0 {
+		return fmt.Errorf("%w: no disk", ErrDiskExtractInvalid)
+	}
+
+	disk := s.Qemu.Disks[0]
+
+	switch {
+	case disk.Size > 0:
+		return fmt.Errorf("%w: scratch disk", ErrDiskExtractInvalid)
+	case disk.ReadOnly, s.Qemu.Snapshot:
+		return fmt.Errorf("%w: not writable", ErrDiskExtractInvalid)
+	case disk.Format != "" && disk.Format != "raw":
+		return fmt.Errorf("%w: format %s", ErrDiskExtractInvalid, disk.Format)
+	}
+
+	return nil
+}
+
+// extractDisk copies the files of the ext4 file system of the first of
+// [Qemu.Disks] into [Spec.DiskExtractDir], without mounting it.
+func (s *Spec) extractDisk() error {
+	file, err := os.Open(s.Qemu.Disks[0].Path)
+	if err != nil {
+		return fmt.Errorf("extract disk: %w", err)
+	}
+	defer file.Close()
+
+	fsys, err := ext4.New(file)
+	if err != nil {
+		return fmt.Errorf("extract disk: %w", err)
+	}
+
+	err = os.CopyFS(s.DiskExtractDir, fsys)
+	if err != nil {
+		return fmt.Errorf("extract disk: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/ext4"
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/sysinit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec_ApplyDiskMount(t *testing.T) {
+	t.Run("no mount", func(t *testing.T) {
+		spec := &Spec{
+			Qemu: Qemu{
+				Disks: []qemu.Disk{{Path: "/tmp/disk.img"}},
+			},
+		}
+
+		require.NoError(t, spec.applyDiskMount())
+		assert.Nil(t, spec.Initramfs.GuestConfig)
+	})
+
+	t.Run("mount", func(t *testing.T) {
+		given := &sysinit.GuestConfig{
+			Mounts: sysinit.MountPoints{
+				"/mnt/scratch": {FSType: sysinit.FSTypeTmp},
+			},
+		}
+
+		spec := &Spec{
+			Qemu: Qemu{
+				Disks: []qemu.Disk{
+					{Path: "/tmp/disk.img", ReadOnly: true},
+					{Size: 1 << 20},
+				},
+				DiskMount: &DiskMount{Path: "/mnt/disk", FSType: "ext4"},
+			},
+			Initramfs: Initramfs{
+				GuestConfig: given,
+			},
+		}
+
+		require.NoError(t, spec.applyDiskMount())
+
+		require.NotNil(t, spec.Initramfs.GuestConfig)
+		assert.Equal(t, sysinit.MountPoints{
+			"/mnt/scratch": {FSType: sysinit.FSTypeTmp},
+			"/mnt/disk": {
+				FSType: "ext4",
+				Source: sysinit.FirstDisk,
+				Flags:  sysinit.MountFlagReadOnly,
+			},
+		}, spec.Initramfs.GuestConfig.Mounts)
+		assert.Len(t, given.Mounts, 1, "given config must not be modified")
+	})
+
+	t.Run("no disk", func(t *testing.T) {
+		spec := &Spec{
+			Qemu: Qemu{
+				DiskMount: &DiskMount{Path: "/mnt/disk", FSType: "ext4"},
+			},
+		}
+
+		require.ErrorIs(t, spec.applyDiskMount(), ErrDiskMountInvalid)
+	})
+
+	t.Run("relative path", func(t *testing.T) {
+		spec := &Spec{
+			Qemu: Qemu{
+				Disks:     []qemu.Disk{{Path: "/tmp/disk.img"}},
+				DiskMount: &DiskMount{Path: "mnt/disk", FSType: "ext4"},
+			},
+		}
+
+		require.ErrorIs(t, spec.applyDiskMount(), ErrDiskMountInvalid)
+	})
+}
+
+func TestSpec_VerifyDiskExtract(t *testing.T) {
+	tests := []struct {
+		name        string
+		qemu        Qemu
+		expectedErr error
+	}{
+		{
+			name: "image",
+			qemu: Qemu{Disks: []qemu.Disk{{Path: "/tmp/disk.img"}}},
+		},
+		{
+			name: "raw image",
+			qemu: Qemu{
+				Disks: []qemu.Disk{{Path: "/tmp/disk.img", Format: "raw"}},
+			},
+		},
+		{
+			name:        "no disk",
+			expectedErr: ErrDiskExtractInvalid,
+		},
+		{
+			name:        "scratch disk",
+			qemu:        Qemu{Disks: []qemu.Disk{{Size: 1 << 20}}},
+			expectedErr: ErrDiskExtractInvalid,
+		},
+		{
+			name: "read-only",
+			qemu: Qemu{
+				Disks: []qemu.Disk{{Path: "/tmp/disk.img", ReadOnly: true}},
+			},
+			expectedErr: ErrDiskExtractInvalid,
+		},
+		{
+			name: "snapshot",
+			qemu: Qemu{
+				Disks:    []qemu.Disk{{Path: "/tmp/disk.img"}},
+				Snapshot: true,
+			},
+			expectedErr: ErrDiskExtractInvalid,
+		},
+		{
+			name: "qcow2",
+			qemu: Qemu{
+				Disks: []qemu.Disk{{Path: "/tmp/disk.img", Format: "qcow2"}},
+			},
+			expectedErr: ErrDiskExtractInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &Spec{Qemu: tt.qemu, DiskExtractDir: "/tmp/out"}
+			require.ErrorIs(t, spec.verifyDiskExtract(), tt.expectedErr)
+		})
+	}
+}
+
+func TestSpec_ExtractDisk(t *testing.T) {
+	src, err := os.Open("../ext4/testdata/image-4096.ext4.gz")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = src.Close() })
+
+	reader, err := gzip.NewReader(src)
+	require.NoError(t, err)
+
+	imagePath := filepath.Join(t.TempDir(), "disk.img")
+
+	image, err := os.Create(imagePath)
+	require.NoError(t, err)
+
+	_, err = io.Copy(image, reader) //nolint:gosec
+	require.NoError(t, err)
+	require.NoError(t, image.Close())
+
+	spec := &Spec{
+		Qemu:           Qemu{Disks: []qemu.Disk{{Path: imagePath}}},
+		DiskExtractDir: filepath.Join(t.TempDir(), "out"),
+	}
+
+	require.NoError(t, spec.extractDisk())
+
+	content, err := os.ReadFile(
+		filepath.Join(spec.DiskExtractDir, "dir/nested/deep.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "deep\n", string(content))
+
+	t.Run("no ext4", func(t *testing.T) {
+		spec := &Spec{
+			Qemu: Qemu{Disks: []qemu.Disk{{Path: "disk.go"}}},
+		}
+
+		require.ErrorIs(t, spec.extractDisk(), ext4.ErrNoExt4)
+	})
+}
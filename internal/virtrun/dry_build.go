@@ -0,0 +1,152 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/aibor/virtrun/internal/initramfs"
+	"github.com/aibor/virtrun/internal/sys"
+)
+
+// InitramfsEntry is a single entry of the initramfs file tree.
+type InitramfsEntry struct {
+	// Path is the path of the entry in the archive.
+	Path string
+
+	// Type is the type of the entry, like [fs.ModeDir] or [fs.ModeSymlink].
+	// It is zero for regular files.
+	Type fs.FileMode
+
+	// Target is the target of a symbolic link. It is empty for any other
+	// type.
+	Target string
+
+	// Size is the size of a regular file in bytes. It is zero for any other
+	// type.
+	Size int64
+}
+
+// String returns the entry in the format of a long directory listing.
+func (e InitramfsEntry) String() string {
+	var typ string
+
+	switch e.Type {
+	case fs.ModeDir:
+		typ = "d"
+	case fs.ModeSymlink:
+		typ = "l"
+	default:
+		typ = "-"
+	}
+
+	switch {
+	case e.Target != "":
+		return fmt.Sprintf("%s %s -> %s", typ, e.Path, e.Target)
+	case e.Type.IsRegular():
+		return fmt.Sprintf("%s %s (%d bytes)", typ, e.Path, e.Size)
+	default:
+		return fmt.Sprintf("%s %s", typ, e.Path)
+	}
+}
+
+// DryBuild resolves the shared libraries and builds the initramfs file tree
+// for the given [Spec] like [Run] does, without writing the archive.
+//
+// It returns the entries the archive would contain in the order they would
+// be written. As no archive is written, the source files are not read, apart
+// from the ELF files whose libraries are resolved. This is useful for
+// diagnosing missing libraries quickly.
+func DryBuild(
+	ctx context.Context,
+	spec *Spec,
+	stdin io.Reader,
+) ([]InitramfsEntry, error) {
+	if spec.Initramfs.Binary == StdinBinary {
+		cleanupFn, err := spec.spoolStdinBinary(stdin)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanupFn()
+	}
+
+	arch, err := sys.ReadELFArch(spec.Initramfs.Binary)
+	if err != nil {
+		return nil, fmt.Errorf("read main binary arch: %w", err)
+	}
+
+	return dryBuildInitramfs(ctx, spec, arch)
+}
+
+// dryBuildInitramfs builds the initramfs file tree for the given [Spec] and
+// architecture and lists its entries.
+func dryBuildInitramfs(
+	ctx context.Context,
+	spec *Spec,
+	arch sys.Arch,
+) ([]InitramfsEntry, error) {
+	err := spec.prepareInitramfs()
+	if err != nil {
+		return nil, err
+	}
+
+	initFn, err := initOpenFunc(spec.Initramfs, arch)
+	if err != nil {
+		return nil, err
+	}
+
+	irfs, err := buildInitramfsArchive(ctx, spec.Initramfs, initFn)
+	if err != nil {
+		return nil, err
+	}
+
+	return listInitramfs(irfs.FS)
+}
+
+// listInitramfs returns the entries of the given [initramfs.FS] in the order
+// [initramfs.CPIOFSWriter.AddFS] writes them.
+func listInitramfs(irfs *initramfs.FS) ([]InitramfsEntry, error) {
+	var entries []InitramfsEntry
+
+	err := fs.WalkDir(irfs, ".", func(
+		name string, d fs.DirEntry, err error,
+	) error {
+		if err != nil {
+			return err
+		}
+
+		entry := InitramfsEntry{
+			Path: name,
+			Type: d.Type(),
+		}
+
+		switch {
+		case entry.Type == fs.ModeSymlink:
+			entry.Target, err = irfs.ReadLink(name)
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+		case entry.Type.IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			entry.Size = info.Size()
+		}
+
+		entries = append(entries, entry)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list initramfs: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/sys"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitramfsEntry_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    InitramfsEntry
+		expected string
+	}{
+		{
+			name:     "regular file",
+			entry:    InitramfsEntry{Path: "main", Size: 1234},
+			expected: "- main (1234 bytes)",
+		},
+		{
+			name:     "directory",
+			entry:    InitramfsEntry{Path: "lib", Type: fs.ModeDir},
+			expected: "d lib",
+		},
+		{
+			name: "symbolic link",
+			entry: InitramfsEntry{
+				Path:   "init",
+				Type:   fs.ModeSymlink,
+				Target: "main",
+			},
+			expected: "l init -> main",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.entry.String())
+		})
+	}
+}
+
+func TestDryBuild(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	spec := &Spec{
+		Initramfs: Initramfs{
+			Binary: "../sys/testdata/bin/main",
+			Files:  []string{"../sys/testdata/lib/libfunc2.so"},
+		},
+	}
+
+	entries, err := DryBuild(context.Background(), spec, nil)
+	require.NoError(t, err)
+
+	written, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, written, "no archive should be written")
+
+	libInfo, err := os.Stat("../sys/testdata/lib/libfunc2.so")
+	require.NoError(t, err)
+
+	assert.Contains(t, entries, InitramfsEntry{
+		Path: "data/libfunc2.so",
+		Size: libInfo.Size(),
+	})
+	assert.Contains(t, entries, InitramfsEntry{Path: "lib", Type: fs.ModeDir})
+
+	arch, err := sys.ReadELFArch(spec.Initramfs.Binary)
+	require.NoError(t, err)
+
+	path, removeFn, err := BuildInitramfsArchive(
+		context.Background(),
+		spec.Initramfs,
+		initProgOpenFunc(arch),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = removeFn() })
+
+	archive, err := os.Open(path)
+	require.NoError(t, err)
+	defer archive.Close()
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+
+	assert.Equal(t, readCPIONames(t, archive), paths,
+		"entries should match the archive")
+}
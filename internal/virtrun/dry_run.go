@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aibor/virtrun/internal/sys"
+)
+
+// DryRunInitramfsPath is the placeholder for the initramfs archive path in the
+// QEMU command returned by [DryRun], as no archive is written.
+const DryRunInitramfsPath = "initramfs.cpio"
+
+// DryRunResult is what a run of a [Spec] would use.
+type DryRunResult struct {
+	// Command is the QEMU command line, starting with the executable. The
+	// initramfs archive is referenced by [DryRunInitramfsPath].
+	Command []string
+
+	// Initramfs are the entries the initramfs archive would contain, in the
+	// order they would be written.
+	Initramfs []InitramfsEntry
+}
+
+// DryRun resolves the QEMU command and builds the initramfs file tree for the
+// given [Spec] like [Run] does, without writing the archive or running QEMU.
+//
+// Like [DryBuild], the source files are not read, apart from the ELF files
+// whose libraries are resolved.
+func DryRun(
+	ctx context.Context,
+	spec *Spec,
+	stdin io.Reader,
+) (*DryRunResult, error) {
+	if spec.Initramfs.Binary == StdinBinary {
+		cleanupFn, err := spec.spoolStdinBinary(stdin)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanupFn()
+	}
+
+	arch, err := sys.ReadELFArch(spec.Initramfs.Binary)
+	if err != nil {
+		return nil, fmt.Errorf("read main binary arch: %w", err)
+	}
+
+	err = spec.Qemu.addDefaultsFor(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	err = spec.Qemu.verifyConsoleSupport()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := dryBuildInitramfs(ctx, spec, arch)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, err := NewQemuCommand(ctx, spec.Qemu, DryRunInitramfsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DryRunResult{
+		Command:   cmd.Args(),
+		Initramfs: entries,
+	}
+
+	return result, nil
+}
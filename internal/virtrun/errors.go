@@ -3,3 +3,65 @@
 // SPDX-License-Identifier: GPL-3.0-or-later
 
 package virtrun
+
+import "errors"
+
+var (
+	// ErrNotCPIOArchive is returned if a file is expected to be an
+	// uncompressed CPIO archive but is not.
+	ErrNotCPIOArchive = errors.New("not an uncompressed CPIO archive")
+
+	// ErrModuleVersionMismatch is returned if a kernel module is not built
+	// for the guest kernel.
+	ErrModuleVersionMismatch = errors.New("module version mismatch")
+
+	// ErrConsoleNotSupported is returned if the kernel does not support the
+	// console device of the transport type.
+	ErrConsoleNotSupported = errors.New("kernel lacks console support")
+
+	// ErrArchMismatch is returned if a binary is not built for the
+	// architecture it is supposed to run on.
+	ErrArchMismatch = errors.New("architecture mismatch")
+
+	// ErrTimezoneUnknown is returned if a timezone can not be determined or
+	// its zoneinfo file can not be found.
+	ErrTimezoneUnknown = errors.New("unknown timezone")
+
+	// ErrCompressionUnknown is returned if an archive compression format is
+	// not supported.
+	ErrCompressionUnknown = errors.New("unknown compression")
+
+	// ErrBinariesStandalone is returned if additional binaries are given in
+	// standalone mode, where no init program runs them.
+	ErrBinariesStandalone = errors.New(
+		"additional binaries not supported in standalone mode")
+
+	// ErrInitBinaryStandalone is returned if a custom init binary is given
+	// in standalone mode, where the main binary is the init.
+	ErrInitBinaryStandalone = errors.New(
+		"init binary not supported in standalone mode")
+
+	// ErrSourceNotAllowed is returned if a file source is not within the
+	// allowed source directories.
+	ErrSourceNotAllowed = errors.New("source not allowed")
+
+	// ErrInitInvalid is returned if the initramfs would not contain an
+	// executable init file the kernel can run.
+	ErrInitInvalid = errors.New("invalid init")
+
+	// ErrDynamicallyLinked is returned if files are dynamically linked ELF
+	// files, but static ones are required.
+	ErrDynamicallyLinked = errors.New("dynamically linked")
+
+	// ErrDiskMountInvalid is returned if the disk mount point is not an
+	// absolute path with a file system type or there is no disk to mount.
+	ErrDiskMountInvalid = errors.New("invalid disk mount")
+
+	// ErrDiskExtractInvalid is returned if the disk to extract files from is
+	// not a writable raw image file.
+	ErrDiskExtractInvalid = errors.New("invalid disk to extract")
+
+	// ErrWorkDirInvalid is returned if the working directory is not an
+	// absolute path of a directory in the initramfs.
+	ErrWorkDirInvalid = errors.New("invalid working directory")
+)
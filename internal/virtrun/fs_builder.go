@@ -5,13 +5,18 @@
 package virtrun
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aibor/virtrun/internal/initramfs"
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/aibor/virtrun/sysinit"
 )
 
 type nameFunc func(idx int, path string) string
@@ -20,19 +25,44 @@ func baseName(_ int, path string) string {
 	return filepath.Base(path)
 }
 
-func modName(idx int, path string) string {
+func indexedName(idx int, path string) string {
 	return fmt.Sprintf("%04d-%s", idx, filepath.Base(path))
 }
 
 type fsBuilder struct {
 	fs initramfs.FSAdder
+
+	// sources maps the names of regular files to the host files they are
+	// read from, if any.
+	sources map[string]string
+}
+
+// setSource records the host file the regular file with the given name is
+// read from.
+func (b *fsBuilder) setSource(name, source string) {
+	if b.sources == nil {
+		b.sources = make(map[string]string)
+	}
+
+	b.sources[fsName(name)] = source
+}
+
+// fsName returns the name as used by [initramfs.FS].
+func fsName(name string) string {
+	return strings.TrimPrefix(filepath.Clean(name), string(filepath.Separator))
 }
 
 func (b *fsBuilder) mkdirAll(dir string) error {
 	return b.fs.MkdirAll(dir) //nolint:wrapcheck
 }
 
+func (b *fsBuilder) removeAll(name string) error {
+	return b.fs.RemoveAll(name) //nolint:wrapcheck
+}
+
 func (b *fsBuilder) add(name string, openFn initramfs.FileOpenFunc) error {
+	delete(b.sources, fsName(name))
+
 	return b.fs.Add(name, openFn) //nolint:wrapcheck
 }
 
@@ -41,9 +71,16 @@ func (b *fsBuilder) symlink(target, name string) error {
 }
 
 func (b *fsBuilder) addFilePathAs(name, source string) error {
-	return b.add(name, func() (fs.File, error) {
+	err := b.add(name, func() (fs.File, error) {
 		return os.Open(source)
 	})
+	if err != nil {
+		return err
+	}
+
+	b.setSource(name, source)
+
+	return nil
 }
 
 func (b *fsBuilder) addFilesTo(dir string, files []string, fn nameFunc) error {
@@ -64,6 +101,149 @@ func (b *fsBuilder) addFilesTo(dir string, files []string, fn nameFunc) error {
 	return nil
 }
 
+// addModulesTo adds the given kernel module files to dir with indexed names.
+// Compressed modules are decompressed and named with plain ".ko" extension.
+func (b *fsBuilder) addModulesTo(dir string, modules []string) error {
+	err := b.mkdirAll(dir)
+	if err != nil {
+		return err
+	}
+
+	for idx, path := range modules {
+		plainName, compressed := sys.PlainModuleName(path)
+		name := filepath.Join(dir, indexedName(idx, plainName))
+
+		if !compressed {
+			err := b.addFilePathAs(name, path)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err := b.add(name, func() (fs.File, error) {
+			data, err := sys.ReadModule(path)
+			if err != nil {
+				return nil, err //nolint:wrapcheck
+			}
+
+			return newMemFile(name, data), nil
+		})
+		if err != nil {
+			return err
+		}
+
+		b.setSource(name, path)
+	}
+
+	return nil
+}
+
+func (b *fsBuilder) addFileMappingsTo(
+	dir string,
+	mappings []FileMapping,
+) error {
+	for _, mapping := range mappings {
+		name := filepath.Join(dir, mapping.target())
+
+		err := b.mkdirAll(filepath.Dir(name))
+		if err != nil {
+			return err
+		}
+
+		err = b.addFilePathAs(name, mapping.Source)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *fsBuilder) addDirsTo(dir string, mappings []FileMapping) error {
+	for _, mapping := range mappings {
+		name := filepath.Join(dir, mapping.target())
+
+		err := b.mkdirAll(filepath.Dir(name))
+		if err != nil {
+			return err
+		}
+
+		err = b.addDirTo(name, mapping.Source)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addDirTo recreates the directory tree at the host path src at dest.
+//
+// Relative paths and file permissions are preserved. Symbolic links are
+// copied as links, not dereferenced. Any other file types are rejected.
+func (b *fsBuilder) addDirTo(dest, src string) error {
+	walkFunc := func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		name := filepath.Join(dest, path)
+		source := filepath.Join(src, path)
+
+		return b.addHostEntry(name, source, info.Mode())
+	}
+
+	err := fs.WalkDir(os.DirFS(src), ".", walkFunc)
+	if err != nil {
+		return fmt.Errorf("add dir %s: %w", src, err)
+	}
+
+	return nil
+}
+
+// addHostEntry adds the host file at source with the given mode as name.
+//
+// Directories are created, symbolic links are copied as links, not
+// dereferenced, and regular files keep their permissions. Any other file
+// types are rejected.
+func (b *fsBuilder) addHostEntry(
+	name string,
+	source string,
+	mode fs.FileMode,
+) error {
+	switch mode.Type() {
+	case fs.ModeDir:
+		return b.mkdirAll(name)
+	case fs.ModeSymlink:
+		target, err := os.Readlink(source)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		return b.symlink(target, name)
+	case 0:
+		err := b.fs.AddMode(name, mode, func() (fs.File, error) {
+			return os.Open(source)
+		})
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		b.setSource(name, source)
+
+		return nil
+	default:
+		return fmt.Errorf("%s: %w", source, initramfs.ErrFileNotRegular)
+	}
+}
+
 func (b *fsBuilder) symlinkTo(dir string, paths []string) error {
 	for _, path := range paths {
 		if path == dir {
@@ -88,3 +268,52 @@ func (b *fsBuilder) symlinkTo(dir string, paths []string) error {
 
 	return nil
 }
+
+// addGuestConfig adds the given guest config as JSON file at
+// [sysinit.GuestConfigPath].
+func (b *fsBuilder) addGuestConfig(cfg *sysinit.GuestConfig) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("guest config: %w", err)
+	}
+
+	err = b.mkdirAll(filepath.Dir(sysinit.GuestConfigPath))
+	if err != nil {
+		return err
+	}
+
+	return b.add(sysinit.GuestConfigPath, func() (fs.File, error) {
+		return newMemFile(sysinit.GuestConfigPath, data), nil
+	})
+}
+
+// memFile is a read only regular [fs.File] with in-memory content.
+type memFile struct {
+	*bytes.Reader
+	info memFileInfo
+}
+
+func newMemFile(name string, data []byte) *memFile {
+	return &memFile{
+		Reader: bytes.NewReader(data),
+		info: memFileInfo{
+			name: filepath.Base(name),
+			size: int64(len(data)),
+		},
+	}
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return &f.info, nil }
+func (*memFile) Close() error                 { return nil }
+
+type memFileInfo struct {
+	name string
+	size int64
+}
+
+func (i *memFileInfo) Name() string     { return i.name }
+func (i *memFileInfo) Size() int64      { return i.size }
+func (*memFileInfo) Mode() fs.FileMode  { return 0 }
+func (*memFileInfo) ModTime() time.Time { return time.Time{} }
+func (*memFileInfo) IsDir() bool        { return false }
+func (*memFileInfo) Sys() any           { return nil }
@@ -7,10 +7,9 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
 
 	"github.com/aibor/virtrun/sysinit"
 )
@@ -18,28 +17,19 @@ import (
 func main() {
 	cfg := sysinit.DefaultConfig()
 	cfg.ModulesDir = "/lib/modules"
-	// Set PATH environment variable to the directory all additional files
-	// are written to by virtrun.
-	cfg.Env["PATH"] = "/data"
+	cfg.FirmwareDir = "/lib/firmware"
 
 	sysinit.Main(cfg, func() (int, error) {
-		// "/main" is the file virtrun copies the given binary to.
-		cmd := exec.Command("/main", os.Args[1:]...)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-
-		var exitErr *exec.ExitError
-
-		err := cmd.Run()
+		// "/main" is the file virtrun copies the given binary to. Additional
+		// binaries are copied to "/binaries" with names sorting in the order
+		// they are given.
+		binaries, err := filepath.Glob("/binaries/*")
 		if err != nil {
-			if errors.As(err, &exitErr) {
-				return exitErr.ExitCode(), nil
-			}
-
-			return -1, fmt.Errorf("main: %w", err)
+			return -1, fmt.Errorf("binaries: %w", err)
 		}
 
-		return 0, nil
+		binaries = append([]string{"/main"}, binaries...)
+
+		return sysinit.RunBinaries(binaries, os.Args[1:])
 	})
 }
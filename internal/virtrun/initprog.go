@@ -6,9 +6,12 @@ package virtrun
 
 import (
 	"embed"
+	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
 
+	"github.com/aibor/virtrun/internal/initramfs"
 	"github.com/aibor/virtrun/internal/sys"
 )
 
@@ -17,6 +20,7 @@ import (
 //
 //go:generate env CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -buildvcs=false -trimpath -ldflags "-s -w" -o bin/amd64 ./init/
 //go:generate env CGO_ENABLED=0 GOOS=linux GOARCH=arm64 go build -buildvcs=false -trimpath -ldflags "-s -w" -o bin/arm64 ./init/
+//go:generate env CGO_ENABLED=0 GOOS=linux GOARCH=arm GOARM=7 go build -buildvcs=false -trimpath -ldflags "-s -w" -o bin/arm ./init/
 //go:generate env CGO_ENABLED=0 GOOS=linux GOARCH=riscv64 go build -buildvcs=false -trimpath -ldflags "-s -w" -o bin/riscv64 ./init/
 
 // Embed pre-compiled init programs explicitly to trigger build time errors.
@@ -38,3 +42,42 @@ func initProgFor(arch sys.Arch) (fs.File, error) {
 
 	return file, nil
 }
+
+// initProgOpenFunc returns an [initramfs.FileOpenFunc] for the pre-built init
+// binary for the arch.
+//
+// The arch must be the one of the main binary, not the one of the host, so
+// the init matches the guest even if it is cross-compiled.
+func initProgOpenFunc(arch sys.Arch) initramfs.FileOpenFunc {
+	return func() (fs.File, error) {
+		return initProgFor(arch)
+	}
+}
+
+// initOpenFunc returns an [initramfs.FileOpenFunc] for the init binary of the
+// given [Initramfs]. That is the [Initramfs.InitBinary], if set, or the
+// pre-built init binary for the arch otherwise.
+//
+// The custom init binary must be an ELF file built for the arch.
+func initOpenFunc(
+	cfg Initramfs,
+	arch sys.Arch,
+) (initramfs.FileOpenFunc, error) {
+	if cfg.InitBinary == "" {
+		return initProgOpenFunc(arch), nil
+	}
+
+	initArch, err := sys.ReadELFArch(cfg.InitBinary)
+	if err != nil {
+		return nil, fmt.Errorf("read init binary arch: %w", err)
+	}
+
+	if initArch != arch {
+		return nil, fmt.Errorf("%w: init binary is %s, main binary is %s",
+			ErrArchMismatch, initArch, arch)
+	}
+
+	return func() (fs.File, error) {
+		return os.Open(cfg.InitBinary)
+	}, nil
+}
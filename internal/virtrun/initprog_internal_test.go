@@ -5,12 +5,35 @@
 package virtrun
 
 import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/aibor/virtrun/internal/sys"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// writeTempFile writes the content of the given file into a new file in a
+// temporary directory and returns its path.
+func writeTempFile(t *testing.T, file fs.File) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "file")
+
+	dst, err := os.Create(path)
+	require.NoError(t, err)
+
+	_, err = io.Copy(dst, file)
+	require.NoError(t, err)
+	require.NoError(t, dst.Close())
+
+	return path
+}
+
 func TestInits(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -25,6 +48,10 @@ func TestInits(t *testing.T) {
 			name: "valid arm64",
 			arch: sys.ARM64,
 		},
+		{
+			name: "valid arm",
+			arch: sys.ARM,
+		},
 		{
 			name: "valid riscv64",
 			arch: sys.RISCV64,
@@ -38,8 +65,187 @@ func TestInits(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(string(tt.arch), func(t *testing.T) {
-			_, err := initProgFor(tt.arch)
+			file, err := initProgFor(tt.arch)
 			require.ErrorIs(t, err, tt.expectedErr)
+
+			if tt.expectedErr != nil {
+				return
+			}
+
+			defer file.Close()
+
+			arch, err := sys.ReadELFArch(writeTempFile(t, file))
+			require.NoError(t, err)
+			assert.Equal(t, tt.arch, arch, "init must match arch")
+		})
+	}
+}
+
+func TestBuildInitramfsArchive_CrossArch(t *testing.T) {
+	for _, arch := range sys.SupportedArches() {
+		t.Run(string(arch), func(t *testing.T) {
+			// Use the static init binary as main binary, as it is a valid
+			// binary for the arch independent of the host.
+			file, err := initProgFor(arch)
+			require.NoError(t, err)
+
+			defer file.Close()
+
+			binary := writeTempFile(t, file)
+
+			binaryArch, err := sys.ReadELFArch(binary)
+			require.NoError(t, err)
+
+			irfs, err := buildInitramfsArchive(
+				context.Background(),
+				Initramfs{Binary: binary},
+				initProgOpenFunc(binaryArch),
+			)
+			require.NoError(t, err)
+
+			init, err := irfs.Open("init")
+			require.NoError(t, err)
+
+			defer init.Close()
+
+			initArch, err := sys.ReadELFArch(writeTempFile(t, init))
+			require.NoError(t, err)
+			assert.Equal(t, arch, initArch, "init must match main binary")
 		})
 	}
 }
+
+func TestBuildInitramfsArchive_InitModes(t *testing.T) {
+	binary := "../sys/testdata/bin/main"
+
+	arch, err := sys.ReadELFArch(binary)
+	require.NoError(t, err)
+
+	embedded, err := initProgFor(arch)
+	require.NoError(t, err)
+
+	defer embedded.Close()
+
+	expectedEmbedded, err := io.ReadAll(embedded)
+	require.NoError(t, err)
+
+	expectedCustom, err := os.ReadFile(binary)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		cfg            Initramfs
+		expectedTarget string
+		expectedInit   []byte
+	}{
+		{
+			name: "embedded",
+			cfg: Initramfs{
+				Binary: binary,
+			},
+			expectedInit: expectedEmbedded,
+		},
+		{
+			name: "standalone",
+			cfg: Initramfs{
+				Binary:         binary,
+				StandaloneInit: true,
+			},
+			expectedTarget: "main",
+			expectedInit:   expectedCustom,
+		},
+		{
+			name: "custom init",
+			cfg: Initramfs{
+				Binary:     binary,
+				InitBinary: binary,
+			},
+			expectedInit: expectedCustom,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initFn, err := initOpenFunc(tt.cfg, arch)
+			require.NoError(t, err)
+
+			irfs, err := buildInitramfsArchive(
+				context.Background(),
+				tt.cfg,
+				initFn,
+			)
+			require.NoError(t, err)
+
+			if tt.expectedTarget != "" {
+				target, err := irfs.ReadLink("init")
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedTarget, target)
+			}
+
+			actual, err := fs.ReadFile(irfs, "init")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedInit, actual)
+
+			main, err := fs.ReadFile(irfs, "main")
+			require.NoError(t, err)
+			assert.Equal(t, expectedCustom, main, "main binary must be kept")
+		})
+	}
+}
+
+func TestInitOpenFunc(t *testing.T) {
+	// Use the static init binaries as custom init, as they are valid
+	// binaries for their arch independent of the host.
+	otherArch := sys.ARM64
+	if sys.Native == sys.ARM64 {
+		otherArch = sys.AMD64
+	}
+
+	file, err := initProgFor(otherArch)
+	require.NoError(t, err)
+
+	defer file.Close()
+
+	otherArchInit := writeTempFile(t, file)
+
+	tests := []struct {
+		name        string
+		initBinary  string
+		expectedErr error
+	}{
+		{
+			name:       "matching arch",
+			initBinary: "../sys/testdata/bin/main",
+		},
+		{
+			name:        "other arch",
+			initBinary:  otherArchInit,
+			expectedErr: ErrArchMismatch,
+		},
+		{
+			name:        "not an elf file",
+			initBinary:  "initprog.go",
+			expectedErr: sys.ErrNotELFFile,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Initramfs{InitBinary: tt.initBinary}
+
+			_, err := initOpenFunc(cfg, sys.Native)
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestBuildInitramfsArchive_InitBinaryStandalone(t *testing.T) {
+	cfg := Initramfs{
+		Binary:         "/main",
+		InitBinary:     "/init",
+		StandaloneInit: true,
+	}
+
+	_, err := buildInitramfsArchive(context.Background(), cfg, nil)
+	require.ErrorIs(t, err, ErrInitBinaryStandalone)
+}
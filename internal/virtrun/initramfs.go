@@ -5,22 +5,35 @@
 package virtrun
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 
 	"github.com/aibor/virtrun/internal/initramfs"
 	"github.com/aibor/virtrun/internal/sys"
+	"github.com/aibor/virtrun/sysinit"
 )
 
 const (
-	dataDir    = "/data"
-	libsDir    = "/lib"
-	modulesDir = "/lib/modules"
+	initFile    = "init"
+	dataDir     = "/data"
+	binariesDir = "/binaries"
+	libsDir     = "/lib"
+	modulesDir  = "/lib/modules"
+	firmwareDir = "/lib/firmware"
+	homeDir     = "/root"
+	stdinFile   = "/stdin"
+
+	cpioMagicNewc = "070701"
+	cpioMagicCRC  = "070702"
 )
 
 type Initramfs struct {
@@ -28,24 +41,181 @@ type Initramfs struct {
 	// program depending on the StandaloneInit flag.
 	Binary string
 
+	// Binaries is a list of additional binaries that are run one after
+	// another after the main Binary by the init program. They get the same
+	// arguments. The exit code of the first one that fails is communicated.
+	// They are added to the binariesDir directory. For ELF files the
+	// required dynamic libraries are added the libsDir directory. Not
+	// supported with StandaloneInit.
+	Binaries []string
+
 	// Files is a list of any additional files that should be added to the
 	// dataDir directory. For ELF files the required dynamic libraries are
 	// added the libsDir directory.
 	Files []string
 
+	// NamedFiles is a list of additional files that are added to the dataDir
+	// directory with their target name, which may include sub directories.
+	// If the target is empty, the base name of the source is used. For ELF
+	// files the required dynamic libraries are added the libsDir directory.
+	NamedFiles []FileMapping
+
+	// Dirs is a list of directories that are added recursively to the
+	// dataDir directory with their target name. If the target is empty, the
+	// base name of the source is used. Relative paths, file permissions and
+	// symbolic links are preserved.
+	Dirs []FileMapping
+
+	// AllowedSourceDirs restricts the sources of Files, NamedFiles, Dirs,
+	// Trees and Overlays to these host directories and their sub
+	// directories, if any are given.
+	// This is intended for specs from untrusted input. Symbolic links are
+	// resolved, so sources can not escape via them. Sources outside are
+	// rejected with [ErrSourceNotAllowed].
+	AllowedSourceDirs []string
+
+	// Trees is a list of directories that are added recursively at their
+	// absolute target path in the guest, like "/etc/app". Relative paths,
+	// file permissions and symbolic links are preserved. Trees may overlap,
+	// in which case the later tree wins for paths present in several trees.
+	// They are added last, so they must not collide with any other file,
+	// apart from directories.
+	Trees []FileMapping
+
+	// Overlays is a list of directories that are applied in the given order
+	// on top of the generated file tree, like layers. Relative paths, file
+	// permissions and symbolic links are preserved. Entries of later
+	// overlays replace entries of earlier overlays and any other file, apart
+	// from directories which are merged.
+	Overlays []string
+
 	// Modules is a list of kernel module files. They are added to the
-	// modulesDir directory.
+	// modulesDir directory. Modules compressed with gzip, xz or zstd are
+	// added decompressed as plain ".ko" files, so the guest kernel does not
+	// need to support module decompression.
 	Modules []string
 
+	// Firmware is a list of firmware files. They are added to the
+	// firmwareDir directory with their target name that may contain sub
+	// directories. If the target is empty, the base name of the source is
+	// used.
+	Firmware []FileMapping
+
+	// VerifyModules determines if the Modules are checked to be built for
+	// the guest kernel before the archive is built.
+	VerifyModules bool
+
+	// KernelVersion is the release version of the guest kernel the Modules
+	// are verified against. If empty, it is read from the kernel image.
+	KernelVersion string
+
+	// PrependArchives is a list of uncompressed CPIO archive files that are
+	// written in the given order before the generated archive. The kernel
+	// unpacks concatenated archives in sequence, so this can be used for
+	// early loaded content like CPU microcode.
+	PrependArchives []string
+
+	// RequireStatic determines if the main Binary and all additional
+	// binaries and files must not be dynamically linked ELF files. Host
+	// libraries may not match the guest, so this ensures no libraries are
+	// added. Dynamically linked files are rejected with
+	// [ErrDynamicallyLinked].
+	RequireStatic bool
+
+	// LibGraph is the path of a file the dependency graph of the main binary,
+	// the additional files and their shared libraries is written to in
+	// graphviz DOT format, if set.
+	LibGraph string
+
+	// MaxFileSize is the maximum size in MB of any single file added to the
+	// archive. Zero disables the check. It is not enforced by
+	// [BuildInitramfsArchive].
+	MaxFileSize uint64
+
+	// MaxSize is the maximum size in MB of all files added to the archive in
+	// total. Zero disables the check. It is not enforced by
+	// [BuildInitramfsArchive].
+	MaxSize uint64
+
+	// Timezone is the name of the timezone for the guest, like
+	// "Europe/Berlin", or [LocalTimezone] for the host's timezone. If set, the
+	// zoneinfo file is added and the guest's TZ environment variable is set,
+	// unless it is given explicitly.
+	Timezone string
+
+	// Stdin is a file that is added to the archive and read by the guest
+	// binaries as their standard input, instead of the console. See
+	// [sysinit.Config.Stdin].
+	Stdin string
+
+	// WorkDir is the absolute path of the guest directory the guest binaries
+	// run in, like "/data". It must exist in the archive. See
+	// [sysinit.Config.WorkDir].
+	WorkDir string
+
+	// Sysctls are kernel parameters with dotted keys, like
+	// "kernel.core_pattern", that the guest's init sets once the file
+	// systems are mounted. They override the ones of the GuestConfig. See
+	// [sysinit.Config.Sysctls].
+	Sysctls map[string]string
+
+	// zoneinfo is the host path of the zoneinfo file for the Timezone. It is
+	// set by [Spec.applyTimezone].
+	zoneinfo string
+
+	// progress is called with the progress of the build. It is set from
+	// [Spec.Progress] by [Run].
+	progress ProgressFunc
+
+	// GuestConfig is added to the archive at [sysinit.GuestConfigPath], if
+	// set. The init program merges it into its config. In standalone mode,
+	// the main binary must read it itself, like [sysinit.Main] does.
+	GuestConfig *sysinit.GuestConfig
+
+	// Compression is the compression format of the archive. The guest kernel
+	// must support decompressing it, otherwise the guest fails to boot.
+	// Defaults to [CompressionNone].
+	Compression Compression
+
 	// StandaloneInit determines if the main Binary should be called as init
 	// directly. The main binary is responsible for a clean shutdown of the
 	// system.
 	StandaloneInit bool
 
+	// InitBinary is a custom init program that is added as init instead of
+	// the pre-built one. The main Binary is still added as "main" for it to
+	// run. It must be built for the same architecture as the main Binary.
+	// For ELF files the required dynamic libraries are added to the libsDir
+	// directory. Not supported with StandaloneInit.
+	InitBinary string
+
 	// Keep determines if the archive file is removed by the cleanup function
 	// returned by [BuildInitramfsArchive]. If set to true, the file is not
 	// removed. Instead, a log message with the file's path is printed.
 	Keep bool
+
+	// CacheDir is a directory built archives are cached in, if set. An
+	// archive with the same file tree and input files is reused instead of
+	// written again. Input files are identified by path, size, modification
+	// time, mode and content, see [archiveCacheKey]. The directory is created
+	// if it does not exist. Cached archives are never removed, regardless of
+	// Keep.
+	CacheDir string
+}
+
+// FileMapping maps a source file on the host to a target path in the guest.
+type FileMapping struct {
+	Source string
+	Target string
+}
+
+// target returns the Target or the base name of the Source, if empty.
+func (m FileMapping) target() string {
+	if m.Target == "" {
+		return filepath.Base(m.Source)
+	}
+
+	return m.Target
 }
 
 // BuildInitramfsArchive creates a new initramfs CPIO archive file.
@@ -57,9 +227,10 @@ type Initramfs struct {
 // directory. The paths to the directories they have been found at are added as
 // symlinks to the libsDir directory as well.
 //
-// The CPIO archive is written to [os.TempDir]. The path to the file is
-// returned along with a cleanup function. The caller is responsible to call
-// the function once the archive file is no longer needed.
+// The CPIO archive is written to [os.TempDir], or looked up in and written to
+// [Initramfs.CacheDir], if set. The path to the file is returned along with a
+// cleanup function. The caller is responsible to call the function once the
+// archive file is no longer needed.
 func BuildInitramfsArchive(
 	ctx context.Context,
 	cfg Initramfs,
@@ -70,7 +241,12 @@ func BuildInitramfsArchive(
 		return "", nil, err
 	}
 
-	path, err := writeFSToTempFile(irfs, "")
+	if cfg.CacheDir != "" {
+		return cachedInitramfsArchive(irfs, cfg)
+	}
+
+	path, err := writeFSToTempFile(irfs, "", cfg.Compression, cfg.progress,
+		cfg.PrependArchives...)
 	if err != nil {
 		return "", nil, err
 	}
@@ -94,21 +270,64 @@ func BuildInitramfsArchive(
 	return path, removeFn, nil
 }
 
+// archiveFS is the file tree of an initramfs archive.
+type archiveFS struct {
+	*initramfs.FS
+
+	// sources maps the names of regular files to the host files they are
+	// read from. Files created by virtrun itself, like the init program, are
+	// missing.
+	sources map[string]string
+}
+
 // buildInitramfsArchive creates a new CPIO archive file according to the given
 // [Initramfs] spec.
 func buildInitramfsArchive(
 	ctx context.Context,
 	cfg Initramfs,
 	initFileOpenFn initramfs.FileOpenFunc,
-) (*initramfs.FS, error) {
+) (*archiveFS, error) {
+	if cfg.StandaloneInit && len(cfg.Binaries) > 0 {
+		return nil, ErrBinariesStandalone
+	}
+
+	if cfg.StandaloneInit && cfg.InitBinary != "" {
+		return nil, ErrInitBinaryStandalone
+	}
+
 	binaryFiles := []string{cfg.Binary}
+	binaryFiles = append(binaryFiles, cfg.Binaries...)
 	binaryFiles = append(binaryFiles, cfg.Files...)
 
+	for _, mapping := range cfg.NamedFiles {
+		binaryFiles = append(binaryFiles, mapping.Source)
+	}
+
+	if cfg.InitBinary != "" {
+		binaryFiles = append(binaryFiles, cfg.InitBinary)
+	}
+
+	cfg.progress.report(ProgressCollectLibs, 0)
+
 	libs, err := sys.CollectLibsFor(ctx, binaryFiles...)
 	if err != nil {
 		return nil, fmt.Errorf("collect libs: %w", err)
 	}
 
+	if cfg.RequireStatic {
+		err := verifyStatic(&libs, binaryFiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.LibGraph != "" {
+		err := writeLibGraph(cfg.LibGraph, &libs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	initFn := func(b *fsBuilder, name string) error {
 		return b.add(name, initFileOpenFn)
 	}
@@ -126,40 +345,158 @@ func buildInitramfsArchive(
 		return nil, fmt.Errorf("build: %w", err)
 	}
 
+	// Overlays may replace the init, so check the final file tree.
+	err = verifyInit(irfs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifyWorkDir(irfs, cfg.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+
 	return irfs, nil
 }
 
-// buildInitramFS creates a new [initramfs.FS].
+// verifyStatic checks that none of the given files is a dynamically linked ELF
+// file. All that are, are listed in the returned error.
+func verifyStatic(libs *sys.LibCollection, files []string) error {
+	dynamic, err := libs.DynamicallyLinked(files...)
+	if err != nil {
+		return fmt.Errorf("verify static: %w", err)
+	}
+
+	if len(dynamic) > 0 {
+		return fmt.Errorf("%w: %s", ErrDynamicallyLinked,
+			strings.Join(dynamic, ", "))
+	}
+
+	return nil
+}
+
+// verifyInit checks that the init file exists in the given file tree and is
+// an executable regular file, possibly behind symbolic links. Otherwise, the
+// guest kernel panics with "No working init found" after boot.
+func verifyInit(fsys fs.FS) error {
+	info, err := fs.Stat(fsys, initFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInitInvalid, err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: not a regular file", ErrInitInvalid)
+	}
+
+	if info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("%w: not executable", ErrInitInvalid)
+	}
+
+	return nil
+}
+
+// writeLibGraph writes the dependency graph of the given [sys.LibCollection]
+// into the file at the given path.
+func writeLibGraph(path string, libs *sys.LibCollection) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create lib graph file: %w", err)
+	}
+	defer file.Close()
+
+	err = libs.WriteDOT(file)
+	if err != nil {
+		return fmt.Errorf("lib graph: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("close lib graph file: %w", err)
+	}
+
+	return nil
+}
+
+// buildInitramFS creates a new [archiveFS].
 //
 // It does not read any source files. Only the FS file tree is created.
 func buildInitramFS(
 	cfg Initramfs,
 	libs sys.LibCollection,
 	initFn func(*fsBuilder, string) error,
-) (*initramfs.FS, error) {
+) (*archiveFS, error) {
 	irfs := initramfs.New()
-	builder := fsBuilder{irfs}
+	builder := fsBuilder{fs: irfs}
 
 	err := builder.addFilePathAs("main", cfg.Binary)
 	if err != nil {
 		return nil, err
 	}
 
-	err = initFn(&builder, "init")
+	err = initFn(&builder, initFile)
 	if err != nil {
 		return nil, err
 	}
 
+	// Default home directory of the guest, see [defaultGuestEnv].
+	err = builder.mkdirAll(homeDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Binaries) > 0 {
+		err = builder.addFilesTo(binariesDir, cfg.Binaries, indexedName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err = builder.addFilesTo(dataDir, cfg.Files, baseName)
 	if err != nil {
 		return nil, err
 	}
 
-	err = builder.addFilesTo(modulesDir, cfg.Modules, modName)
+	err = builder.addFileMappingsTo(dataDir, cfg.NamedFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	err = builder.addDirsTo(dataDir, cfg.Dirs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = builder.addModulesTo(modulesDir, cfg.Modules)
+	if err != nil {
+		return nil, err
+	}
+
+	err = builder.addFileMappingsTo(firmwareDir, cfg.Firmware)
 	if err != nil {
 		return nil, err
 	}
 
+	if cfg.zoneinfo != "" {
+		err = builder.addTimezone(cfg.Timezone, cfg.zoneinfo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Stdin != "" {
+		err = builder.addFilePathAs(stdinFile, cfg.Stdin)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.GuestConfig != nil {
+		err = builder.addGuestConfig(cfg.GuestConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err = builder.addFilesTo(libsDir, slices.Collect(libs.Libs()), baseName)
 	if err != nil {
 		return nil, err
@@ -170,29 +507,167 @@ func buildInitramFS(
 		return nil, err
 	}
 
-	return irfs, nil
+	err = builder.addTrees(cfg.Trees)
+	if err != nil {
+		return nil, err
+	}
+
+	err = builder.addOverlays(cfg.Overlays)
+	if err != nil {
+		return nil, err
+	}
+
+	return &archiveFS{FS: irfs, sources: builder.sources}, nil
 }
 
 // writeFSToTempFile writes the [fs.FS] as CPIO archive into a new temporary
 // file and returns the absolute path to this file.
 //
 // If the given dir name is not empty, the file is created in this directory.
-// Otherwise the default tempdir is used. See [os.CreateTemp].
-func writeFSToTempFile(fsys fs.FS, dir string) (string, error) {
-	file, err := os.CreateTemp(dir, "initramfs")
+// Otherwise the default tempdir is used. The file is written unnamed, if
+// supported, and linked into the directory only once it is complete. See
+// [tempFile]. The content of the given prepend archive files is written
+// before the archive in the order given. Only the archive itself is
+// compressed with the given compression. If the progress function is not
+// nil, the percentage of the written archive is reported.
+func writeFSToTempFile(
+	fsys fs.FS,
+	dir string,
+	compression Compression,
+	progress ProgressFunc,
+	prepend ...string,
+) (string, error) {
+	file, err := createTempFile(dir, "initramfs")
 	if err != nil {
 		return "", fmt.Errorf("create archive file: %w", err)
 	}
-	defer file.Close()
 
-	writer := initramfs.NewCPIOFSWriter(file)
-	defer writer.Close()
+	for _, path := range prepend {
+		err := copyArchive(file, path)
+		if err != nil {
+			file.discard()
+			return "", fmt.Errorf("prepend archive %s: %w", path, err)
+		}
+	}
+
+	compressor, err := compression.newWriter(file)
+	if err != nil {
+		file.discard()
+		return "", fmt.Errorf("archive compression: %w", err)
+	}
+
+	var dst io.Writer = compressor
+
+	if progress != nil {
+		total, err := regularFilesSize(fsys)
+		if err != nil {
+			file.discard()
+			return "", fmt.Errorf("archive size: %w", err)
+		}
+
+		dst = newProgressWriter(compressor, total, progress)
+	}
+
+	writer := initramfs.NewCPIOFSWriter(dst)
 
 	err = writer.AddFS(fsys)
+	if err == nil {
+		err = writer.Close()
+	}
+
+	if err == nil {
+		err = compressor.Close()
+	}
+
 	if err != nil {
-		_ = os.Remove(file.Name())
+		file.discard()
 		return "", fmt.Errorf("write archive: %w", err)
 	}
 
-	return file.Name(), nil
+	path, err := file.link()
+	if err != nil {
+		file.discard()
+		return "", fmt.Errorf("archive file: %w", err)
+	}
+
+	_ = file.Close()
+
+	return path, nil
+}
+
+// copyArchive copies the uncompressed CPIO archive file at the given path to
+// the writer.
+//
+// The kernel requires each archive in a sequence to start at a 4 byte
+// boundary, so the output is padded with zeros if necessary. It returns
+// [ErrNotCPIOArchive] if the file does not start with a "newc" CPIO magic.
+func copyArchive(dst io.Writer, path string) error {
+	const alignment = 4
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(cpioMagicNewc))
+
+	_, err = io.ReadFull(file, magic)
+	if err != nil || !isCPIOMagic(magic) {
+		return ErrNotCPIOArchive
+	}
+
+	written, err := io.Copy(dst, io.MultiReader(bytes.NewReader(magic), file))
+	if err != nil {
+		return fmt.Errorf("copy: %w", err)
+	}
+
+	padding := (alignment - written%alignment) % alignment
+
+	_, err = dst.Write(make([]byte, padding))
+	if err != nil {
+		return fmt.Errorf("padding: %w", err)
+	}
+
+	return nil
+}
+
+func isCPIOMagic(magic []byte) bool {
+	return string(magic) == cpioMagicNewc || string(magic) == cpioMagicCRC
+}
+
+// verifyModuleVersions checks that all given kernel modules are built for
+// the given kernel version.
+//
+// Modules whose version can not be read because of unsupported compression
+// are skipped with a warning. It returns [ErrModuleVersionMismatch] for the
+// first module that does not match.
+func verifyModuleVersions(kernelVersion string, modules []string) error {
+	for _, module := range modules {
+		moduleVersion, err := sys.ReadModuleVersion(module)
+		if err != nil {
+			if errors.Is(err, errors.ErrUnsupported) {
+				slog.Warn("Skip module version check",
+					slog.String("module", module),
+					slog.Any("error", err),
+				)
+
+				continue
+			}
+
+			return fmt.Errorf("module %s: %w", module, err)
+		}
+
+		if moduleVersion != kernelVersion {
+			return fmt.Errorf(
+				"%w: module %s built for %s, kernel is %s",
+				ErrModuleVersionMismatch,
+				module,
+				moduleVersion,
+				kernelVersion,
+			)
+		}
+	}
+
+	return nil
 }
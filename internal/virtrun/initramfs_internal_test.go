@@ -0,0 +1,721 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/aibor/virtrun/internal/initramfs"
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/aibor/virtrun/sysinit"
+	"github.com/cavaliergopher/cpio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readCPIONames(t *testing.T, r io.Reader) []string {
+	t.Helper()
+
+	var names []string
+
+	reader := cpio.NewReader(r)
+
+	for {
+		hdr, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return names
+		}
+
+		require.NoError(t, err)
+
+		names = append(names, hdr.Name)
+	}
+}
+
+func readDirNames(t *testing.T, fsys fs.FS, dir string) []string {
+	t.Helper()
+
+	entries, err := fs.ReadDir(fsys, dir)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	return names
+}
+
+func TestWriteFSToTempFile_Prepend(t *testing.T) {
+	tempDir := t.TempDir()
+
+	earlyPath := filepath.Join(tempDir, "early.cpio")
+	earlyFile, err := os.Create(earlyPath)
+	require.NoError(t, err)
+
+	earlyWriter := cpio.NewWriter(earlyFile)
+	require.NoError(t, earlyWriter.WriteHeader(&cpio.Header{
+		Name: "kernel/x86/microcode/GenuineIntel.bin",
+		Mode: cpio.TypeReg,
+		Size: 3,
+	}))
+	_, err = earlyWriter.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.NoError(t, earlyWriter.Close())
+	require.NoError(t, earlyFile.Close())
+
+	mainFS := fstest.MapFS{
+		"main": &fstest.MapFile{Data: []byte("main")},
+	}
+
+	path, err := writeFSToTempFile(mainFS, tempDir, "", nil, earlyPath)
+	require.NoError(t, err)
+
+	archive, err := os.Open(path)
+	require.NoError(t, err)
+	defer archive.Close()
+
+	assert.Equal(t,
+		[]string{"kernel/x86/microcode/GenuineIntel.bin"},
+		readCPIONames(t, archive),
+		"first archive",
+	)
+
+	// The reader stops at the trailer name, so skip its padding.
+	offset, err := archive.Seek(0, io.SeekCurrent)
+	require.NoError(t, err)
+	_, err = archive.Seek((4-offset%4)%4, io.SeekCurrent)
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		[]string{".", "main"},
+		readCPIONames(t, archive),
+		"second archive",
+	)
+}
+
+func TestWriteFSToTempFile_PrependInvalid(t *testing.T) {
+	tempDir := t.TempDir()
+
+	invalidPath := filepath.Join(tempDir, "invalid")
+	err := os.WriteFile(invalidPath, []byte("\x1f\x8b compressed"), 0o600)
+	require.NoError(t, err)
+
+	_, err = writeFSToTempFile(fstest.MapFS{}, tempDir, "", nil,
+		invalidPath)
+	require.ErrorIs(t, err, ErrNotCPIOArchive)
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temp file should be removed")
+}
+
+func TestVerifyModuleVersions(t *testing.T) {
+	tempDir := t.TempDir()
+
+	matching := filepath.Join(tempDir, "matching.ko")
+	sys.WriteModuleFile(t, matching, "vermagic=6.8.0-40-generic SMP\x00")
+
+	mismatching := filepath.Join(tempDir, "mismatching.ko")
+	sys.WriteModuleFile(t, mismatching, "vermagic=6.1.0-13-amd64 SMP\x00")
+
+	tests := []struct {
+		name        string
+		modules     []string
+		expectedErr error
+	}{
+		{
+			name:    "matching",
+			modules: []string{matching},
+		},
+		{
+			name:        "mismatching",
+			modules:     []string{matching, mismatching},
+			expectedErr: ErrModuleVersionMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyModuleVersions("6.8.0-40-generic", tt.modules)
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestBuildInitramFS_Firmware(t *testing.T) {
+	cfg := Initramfs{
+		Binary: "/main",
+		Firmware: []FileMapping{
+			{Source: "/fw/blob.bin", Target: "vendor/dev.bin"},
+			{Source: "/fw/other.bin"},
+		},
+	}
+
+	initFn := func(b *fsBuilder, name string) error {
+		return b.symlink("main", name)
+	}
+
+	irfs, err := buildInitramFS(cfg, sys.LibCollection{}, initFn)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"other.bin", "vendor"},
+		readDirNames(t, irfs, "lib/firmware"))
+	assert.Equal(t, []string{"dev.bin"},
+		readDirNames(t, irfs, "lib/firmware/vendor"))
+}
+
+func TestBuildInitramFS_NamedFiles(t *testing.T) {
+	cfg := Initramfs{
+		Binary: "/main",
+		Files:  []string{"/tmp/plain.txt"},
+		NamedFiles: []FileMapping{
+			{Source: "/tmp/input.json", Target: "fixtures/in.json"},
+			{Source: "/tmp/other.json"},
+		},
+	}
+
+	initFn := func(b *fsBuilder, name string) error {
+		return b.symlink("main", name)
+	}
+
+	irfs, err := buildInitramFS(cfg, sys.LibCollection{}, initFn)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"fixtures", "other.json", "plain.txt"},
+		readDirNames(t, irfs, "data"))
+	assert.Equal(t, []string{"in.json"},
+		readDirNames(t, irfs, "data/fixtures"))
+}
+
+func TestBuildInitramFS_Binaries(t *testing.T) {
+	cfg := Initramfs{
+		Binary:   "/main",
+		Binaries: []string{"/bin/b.test", "/bin/a.test"},
+	}
+
+	initFn := func(b *fsBuilder, name string) error {
+		return b.symlink("main", name)
+	}
+
+	irfs, err := buildInitramFS(cfg, sys.LibCollection{}, initFn)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"0000-b.test", "0001-a.test"},
+		readDirNames(t, irfs, "binaries"))
+}
+
+func TestBuildInitramFS_Modules(t *testing.T) {
+	tempDir := t.TempDir()
+	modules := []string{
+		filepath.Join(tempDir, "plain.ko"),
+		filepath.Join(tempDir, "xz.ko.xz"),
+		filepath.Join(tempDir, "zstd.ko.zst"),
+	}
+
+	for _, module := range modules {
+		sys.WriteModuleFile(t, module, "vermagic=6.8.0-40-generic SMP\x00")
+	}
+
+	cfg := Initramfs{
+		Binary:  "/main",
+		Modules: modules,
+	}
+
+	initFn := func(b *fsBuilder, name string) error {
+		return b.symlink("main", name)
+	}
+
+	irfs, err := buildInitramFS(cfg, sys.LibCollection{}, initFn)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"0000-plain.ko", "0001-xz.ko", "0002-zstd.ko"},
+		readDirNames(t, irfs, "lib/modules"))
+
+	expected, err := os.ReadFile(modules[0])
+	require.NoError(t, err)
+
+	for _, name := range []string{"0001-xz.ko", "0002-zstd.ko"} {
+		actual, err := fs.ReadFile(irfs, "lib/modules/"+name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual, "module should be decompressed")
+	}
+}
+
+func TestBuildInitramfsArchive_BinariesStandalone(t *testing.T) {
+	cfg := Initramfs{
+		Binary:         "/main",
+		Binaries:       []string{"/bin/a.test"},
+		StandaloneInit: true,
+	}
+
+	_, err := buildInitramfsArchive(context.Background(), cfg, nil)
+	require.ErrorIs(t, err, ErrBinariesStandalone)
+}
+
+func TestBuildInitramfsArchive_InitReplaced(t *testing.T) {
+	overlay := writeTree(t, map[string]string{
+		"init": "not executable",
+	})
+
+	cfg := Initramfs{
+		Binary:         "../sys/testdata/bin/main",
+		StandaloneInit: true,
+		Overlays:       []string{overlay},
+	}
+
+	_, err := buildInitramfsArchive(context.Background(), cfg, nil)
+	require.ErrorIs(t, err, ErrInitInvalid)
+}
+
+func TestBuildInitramfsArchive_RequireStatic(t *testing.T) {
+	// The pre-built init programs are statically linked.
+	static := "bin/" + string(sys.AMD64)
+	dynamic := "../sys/testdata/bin/main"
+
+	tests := []struct {
+		name      string
+		cfg       Initramfs
+		assertErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "static",
+			cfg: Initramfs{
+				Binary: static,
+				Files:  []string{"initramfs.go"},
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "dynamic binary",
+			cfg: Initramfs{
+				Binary: dynamic,
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrDynamicallyLinked)
+				require.ErrorContains(t, err, dynamic)
+			},
+		},
+		{
+			name: "dynamic file",
+			cfg: Initramfs{
+				Binary: static,
+				Files:  []string{dynamic},
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrDynamicallyLinked)
+				require.ErrorContains(t, err, dynamic)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.RequireStatic = true
+			tt.cfg.StandaloneInit = true
+
+			_, err := buildInitramfsArchive(context.Background(), tt.cfg, nil)
+			tt.assertErr(t, err)
+		})
+	}
+}
+
+func TestBuildInitramfsArchive_WorkDir(t *testing.T) {
+	cfg := Initramfs{
+		Binary:         "../sys/testdata/bin/main",
+		StandaloneInit: true,
+		WorkDir:        homeDir,
+	}
+
+	_, err := buildInitramfsArchive(context.Background(), cfg, nil)
+	require.NoError(t, err)
+
+	cfg.WorkDir = "/missing"
+
+	_, err = buildInitramfsArchive(context.Background(), cfg, nil)
+	require.ErrorIs(t, err, ErrWorkDirInvalid)
+}
+
+func TestVerifyInit(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "source")
+	require.NoError(t, os.WriteFile(source, []byte("binary"), 0o600))
+
+	openFn := func() (fs.File, error) {
+		return os.Open(source)
+	}
+
+	tests := []struct {
+		name        string
+		prepare     func(irfs *initramfs.FS) error
+		expectedErr error
+	}{
+		{
+			name: "executable",
+			prepare: func(irfs *initramfs.FS) error {
+				return irfs.AddMode(initFile, 0o755, openFn)
+			},
+		},
+		{
+			name: "link to executable",
+			prepare: func(irfs *initramfs.FS) error {
+				err := irfs.Add("main", openFn)
+				if err != nil {
+					return err
+				}
+
+				return irfs.Symlink("main", initFile)
+			},
+		},
+		{
+			name:        "missing",
+			prepare:     func(*initramfs.FS) error { return nil },
+			expectedErr: ErrInitInvalid,
+		},
+		{
+			name: "dangling link",
+			prepare: func(irfs *initramfs.FS) error {
+				return irfs.Symlink("main", initFile)
+			},
+			expectedErr: ErrInitInvalid,
+		},
+		{
+			name: "not executable",
+			prepare: func(irfs *initramfs.FS) error {
+				return irfs.AddMode(initFile, 0o644, openFn)
+			},
+			expectedErr: ErrInitInvalid,
+		},
+		{
+			name: "directory",
+			prepare: func(irfs *initramfs.FS) error {
+				return irfs.Mkdir(initFile)
+			},
+			expectedErr: ErrInitInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			irfs := initramfs.New()
+			require.NoError(t, tt.prepare(irfs))
+
+			err := verifyInit(irfs)
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestBuildInitramfsArchive_Reproducible(t *testing.T) {
+	tree := writeTree(t, map[string]string{
+		"conf/app.conf": "key=value",
+		"link":          "->conf",
+	})
+
+	arch, err := sys.ReadELFArch("../sys/testdata/bin/main")
+	require.NoError(t, err)
+
+	for _, compression := range []Compression{
+		CompressionNone,
+		CompressionGzip,
+		CompressionZstd,
+	} {
+		t.Run(string(compression), func(t *testing.T) {
+			t.Setenv("TMPDIR", t.TempDir())
+
+			cfg := Initramfs{
+				Binary:      "../sys/testdata/bin/main",
+				Files:       []string{"../sys/testdata/lib/libfunc2.so"},
+				Trees:       []FileMapping{{Source: tree, Target: "/etc"}},
+				Compression: compression,
+			}
+
+			build := func() []byte {
+				path, removeFn, err := BuildInitramfsArchive(
+					context.Background(),
+					cfg,
+					initProgOpenFunc(arch),
+				)
+				require.NoError(t, err)
+
+				defer removeFn() //nolint:errcheck
+
+				content, err := os.ReadFile(path)
+				require.NoError(t, err)
+
+				return content
+			}
+
+			first := build()
+
+			// Modification times of the sources must not matter.
+			modTime := time.Now().Add(time.Hour)
+			err := os.Chtimes(filepath.Join(tree, "conf/app.conf"),
+				modTime, modTime)
+			require.NoError(t, err)
+
+			second := build()
+
+			assert.True(t, bytes.Equal(first, second),
+				"archives should be identical")
+		})
+	}
+}
+
+func TestBuildInitramFS_Dirs(t *testing.T) {
+	src := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "a/b"), 0o755))
+	require.NoError(t,
+		os.WriteFile(filepath.Join(src, "a/b/file"), []byte("data"), 0o640))
+	require.NoError(t,
+		os.WriteFile(filepath.Join(src, "top"), []byte("top"), 0o755))
+	require.NoError(t, os.Symlink("b/file", filepath.Join(src, "a/link")))
+
+	cfg := Initramfs{
+		Binary: "/main",
+		Dirs: []FileMapping{
+			{Source: src, Target: "fixtures/tree"},
+		},
+	}
+
+	initFn := func(b *fsBuilder, name string) error {
+		return b.symlink("main", name)
+	}
+
+	irfs, err := buildInitramFS(cfg, sys.LibCollection{}, initFn)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a", "top"},
+		readDirNames(t, irfs, "data/fixtures/tree"))
+	assert.Equal(t, []string{"b", "link"},
+		readDirNames(t, irfs, "data/fixtures/tree/a"))
+
+	info, err := fs.Stat(irfs, "data/fixtures/tree/a/b/file")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o640), info.Mode())
+
+	content, err := fs.ReadFile(irfs, "data/fixtures/tree/a/b/file")
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(content))
+
+	target, err := irfs.ReadLink("data/fixtures/tree/a/link")
+	require.NoError(t, err)
+	assert.Equal(t, "b/file", target)
+}
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+
+		if target, isLink := strings.CutPrefix(content, "->"); isLink {
+			require.NoError(t, os.Symlink(target, path))
+			continue
+		}
+
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	return root
+}
+
+func TestBuildInitramFS_Trees(t *testing.T) {
+	first := writeTree(t, map[string]string{
+		"conf/a":   "first a",
+		"conf/b":   "first b",
+		"data/x":   "first x",
+		"link":     "->conf",
+		"only/one": "first one",
+	})
+
+	second := writeTree(t, map[string]string{
+		"conf/b": "second b",
+		"data":   "second data",
+		"only":   "->conf",
+	})
+
+	cfg := Initramfs{
+		Binary: "/main",
+		Trees: []FileMapping{
+			{Source: first, Target: "/etc/app"},
+			{Source: second, Target: "/etc/app"},
+		},
+	}
+
+	initFn := func(b *fsBuilder, name string) error {
+		return b.symlink("main", name)
+	}
+
+	irfs, err := buildInitramFS(cfg, sys.LibCollection{}, initFn)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"conf", "data", "link", "only"},
+		readDirNames(t, irfs, "etc/app"))
+
+	expectedFiles := map[string]string{
+		"etc/app/conf/a": "first a",
+		"etc/app/conf/b": "second b",
+		"etc/app/data":   "second data",
+	}
+
+	for name, expected := range expectedFiles {
+		content, err := fs.ReadFile(irfs, name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, string(content), name)
+	}
+
+	expectedLinks := map[string]string{
+		"etc/app/link": "conf",
+		"etc/app/only": "conf",
+	}
+
+	for name, expected := range expectedLinks {
+		target, err := irfs.ReadLink(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, target, name)
+	}
+}
+
+func TestBuildInitramFS_TreesCollision(t *testing.T) {
+	tree := writeTree(t, map[string]string{
+		"main": "not the main binary",
+	})
+
+	cfg := Initramfs{
+		Binary: "/main",
+		Trees: []FileMapping{
+			{Source: tree, Target: "/"},
+		},
+	}
+
+	initFn := func(b *fsBuilder, name string) error {
+		return b.symlink("main", name)
+	}
+
+	_, err := buildInitramFS(cfg, sys.LibCollection{}, initFn)
+	require.ErrorIs(t, err, initramfs.ErrFileExist)
+}
+
+func TestBuildInitramFS_Overlays(t *testing.T) {
+	base := writeTree(t, map[string]string{
+		"etc/conf": "base conf",
+		"etc/keep": "base keep",
+		"main":     "base main",
+		"tools/x":  "base x",
+	})
+
+	second := writeTree(t, map[string]string{
+		"etc/conf": "second conf",
+		"tools":    "->etc",
+	})
+
+	third := writeTree(t, map[string]string{
+		"etc/conf": "third conf",
+		"extra":    "third extra",
+	})
+
+	cfg := Initramfs{
+		Binary:   "/main",
+		Overlays: []string{base, second, third},
+	}
+
+	initFn := func(b *fsBuilder, name string) error {
+		return b.symlink("main", name)
+	}
+
+	irfs, err := buildInitramFS(cfg, sys.LibCollection{}, initFn)
+	require.NoError(t, err)
+
+	expectedFiles := map[string]string{
+		"etc/conf": "third conf",
+		"etc/keep": "base keep",
+		"extra":    "third extra",
+		"main":     "base main",
+	}
+
+	for name, expected := range expectedFiles {
+		content, err := fs.ReadFile(irfs, name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, string(content), name)
+	}
+
+	expectedLinks := map[string]string{
+		"init":  "main",
+		"tools": "etc",
+	}
+
+	for name, expected := range expectedLinks {
+		target, err := irfs.ReadLink(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, target, name)
+	}
+
+	_, err = irfs.Lstat("tools/x")
+	require.ErrorIs(t, err, fs.ErrNotExist,
+		"content of replaced directory should be gone")
+}
+
+func TestBuildInitramFS_GuestConfig(t *testing.T) {
+	guestCfg := &sysinit.GuestConfig{
+		Env:     sysinit.EnvVars{"A": "1"},
+		Sysctls: map[string]string{"vm.overcommit_memory": "1"},
+	}
+
+	cfg := Initramfs{
+		Binary:      "/main",
+		GuestConfig: guestCfg,
+	}
+
+	initFn := func(b *fsBuilder, name string) error {
+		return b.symlink("main", name)
+	}
+
+	irfs, err := buildInitramFS(cfg, sys.LibCollection{}, initFn)
+	require.NoError(t, err)
+
+	data, err := fs.ReadFile(irfs, "etc/virtrun.json")
+	require.NoError(t, err)
+
+	var actual sysinit.GuestConfig
+
+	require.NoError(t, json.Unmarshal(data, &actual))
+	assert.Equal(t, *guestCfg, actual)
+}
+
+func TestBuildInitramFS_Stdin(t *testing.T) {
+	stdinPath := filepath.Join(t.TempDir(), "input.txt")
+	require.NoError(t, os.WriteFile(stdinPath, []byte("some input\n"), 0o600))
+
+	cfg := Initramfs{
+		Binary: "/main",
+		Stdin:  stdinPath,
+	}
+
+	initFn := func(b *fsBuilder, name string) error {
+		return b.symlink("main", name)
+	}
+
+	irfs, err := buildInitramFS(cfg, sys.LibCollection{}, initFn)
+	require.NoError(t, err)
+
+	data, err := fs.ReadFile(irfs, "stdin")
+	require.NoError(t, err)
+	assert.Equal(t, "some input\n", string(data))
+}
@@ -0,0 +1,206 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/aibor/virtrun/internal/sys"
+)
+
+// MatrixTarget is the architecture specific input for a single run of
+// [RunMatrix].
+type MatrixTarget struct {
+	// Binary is the binary built for the architecture.
+	Binary string
+
+	// Kernel is the kernel built for the architecture.
+	Kernel string
+}
+
+// MatrixResult is the outcome of a single run of [RunMatrix].
+type MatrixResult struct {
+	// Result is the [Result] of the run.
+	Result *Result
+
+	// Output is the combined guest stdout and stderr output.
+	Output []byte
+
+	// Err is the error returned by the run, if any.
+	Err error
+}
+
+// MatrixResults are the [MatrixResult]s of [RunMatrix] keyed by architecture.
+type MatrixResults map[sys.Arch]MatrixResult
+
+// Err returns all errors of the runs joined, each prefixed with its
+// architecture, in order of the architectures. It returns nil if all runs
+// succeeded.
+func (r MatrixResults) Err() error {
+	var errs []error
+
+	for _, arch := range slices.Sorted(maps.Keys(r)) {
+		if err := r[arch].Err; err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", arch, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewSpec creates a new [Spec] for the given target from a copy of the base
+// spec. The architecture specific QEMU settings are reset, so the defaults for
+// the target's architecture are used.
+//
+// The copy is shallow, except for the init arguments and environment
+// variables, which are extended during a run. Settings that refer to output
+// files, like [Spec.CastFile], are shared by all specs created from the same
+// base. Each run builds its own initramfs archive file, though.
+func NewSpec(base *Spec, target MatrixTarget) *Spec {
+	spec := *base
+
+	spec.Qemu.InitArgs = slices.Clone(base.Qemu.InitArgs)
+	spec.Qemu.Env = slices.Clone(base.Qemu.Env)
+
+	spec.Qemu.Kernel = target.Kernel
+	spec.Qemu.Executable = ""
+	spec.Qemu.Machine = ""
+	spec.Qemu.TransportType = ""
+	spec.Initramfs.Binary = target.Binary
+
+	return &spec
+}
+
+// matrixRunFunc runs a single [Spec] of a matrix.
+type matrixRunFunc func(
+	ctx context.Context,
+	arch sys.Arch,
+	spec *Spec,
+	output io.Writer,
+) (*Result, error)
+
+// RunMatrix runs the base spec for each of the given targets keyed by
+// architecture. The specs are created with [NewSpec]. Runs are started in
+// order of [sys.SupportedArches] with at most parallel runs at once. If
+// parallel is less than 2, they run one after another. Use [MatrixResults.Err]
+// to check if all succeeded.
+func RunMatrix(
+	ctx context.Context,
+	base *Spec,
+	targets map[sys.Arch]MatrixTarget,
+	parallel int,
+) MatrixResults {
+	return runMatrix(ctx, base, targets, parallel, runMatrixTarget)
+}
+
+func runMatrixTarget(
+	ctx context.Context,
+	arch sys.Arch,
+	spec *Spec,
+	output io.Writer,
+) (*Result, error) {
+	binaryArch, err := sys.ReadELFArch(spec.Initramfs.Binary)
+	if err != nil {
+		return &Result{}, fmt.Errorf("read binary arch: %w", err)
+	}
+
+	if binaryArch != arch {
+		return &Result{}, fmt.Errorf("%w: binary is %s",
+			ErrArchMismatch, binaryArch)
+	}
+
+	return RunWithResult(ctx, spec, nil, output, output)
+}
+
+func runMatrix(
+	ctx context.Context,
+	base *Spec,
+	targets map[sys.Arch]MatrixTarget,
+	parallel int,
+	runFn matrixRunFunc,
+) MatrixResults {
+	var (
+		results = make(MatrixResults, len(targets))
+		slots   = make(chan struct{}, max(parallel, 1))
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
+
+	runArch := func(arch sys.Arch, target MatrixTarget) {
+		var output syncBuffer
+
+		result, err := runFn(ctx, arch, NewSpec(base, target), &output)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		results[arch] = MatrixResult{
+			Result: result,
+			Output: output.Bytes(),
+			Err:    err,
+		}
+	}
+
+	for arch := range targets {
+		if !slices.Contains(sys.SupportedArches(), arch) {
+			results[arch] = MatrixResult{
+				Result: &Result{},
+				Err:    sys.ErrArchNotSupported,
+			}
+		}
+	}
+
+	for _, arch := range sys.SupportedArches() {
+		target, exists := targets[arch]
+		if !exists {
+			continue
+		}
+
+		// Wait for a free slot before starting the next run, so the runs
+		// are started in order.
+		slots <- struct{}{}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			runArch(arch, target)
+		}()
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// syncBuffer is a [bytes.Buffer] that is safe for concurrent writes, as
+// stdout and stderr of a run are written concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p) //nolint:wrapcheck
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return bytes.Clone(b.buf.Bytes())
+}
@@ -0,0 +1,235 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSpec(t *testing.T) {
+	base := &Spec{
+		Qemu: Qemu{
+			Executable:    "qemu-system-x86_64",
+			Kernel:        "/kernel-amd64",
+			Machine:       "q35",
+			TransportType: qemu.TransportTypePCI,
+			Memory:        256,
+		},
+		Initramfs: Initramfs{
+			Binary: "/bin-amd64",
+			Files:  []string{"/file"},
+		},
+	}
+
+	spec := NewSpec(base, MatrixTarget{
+		Binary: "/bin-arm64",
+		Kernel: "/kernel-arm64",
+	})
+
+	expected := &Spec{
+		Qemu: Qemu{
+			Kernel: "/kernel-arm64",
+			Memory: 256,
+		},
+		Initramfs: Initramfs{
+			Binary: "/bin-arm64",
+			Files:  []string{"/file"},
+		},
+	}
+	assert.Equal(t, expected, spec)
+	assert.Equal(t, "/bin-amd64", base.Initramfs.Binary, "base unchanged")
+}
+
+func TestNewSpec_ClonesAppendedSlices(t *testing.T) {
+	base := &Spec{
+		Qemu: Qemu{
+			InitArgs: make([]string, 1, 2),
+			Env:      make([]string, 1, 2),
+		},
+	}
+
+	first := NewSpec(base, MatrixTarget{})
+	second := NewSpec(base, MatrixTarget{})
+
+	first.Qemu.InitArgs = append(first.Qemu.InitArgs, "first")
+	first.Qemu.Env = append(first.Qemu.Env, "FIRST=1")
+	first.Qemu.InitArgs[0] = "changed"
+
+	second.Qemu.InitArgs = append(second.Qemu.InitArgs, "second")
+	second.Qemu.Env = append(second.Qemu.Env, "SECOND=1")
+
+	assert.Equal(t, []string{"changed", "first"}, first.Qemu.InitArgs)
+	assert.Equal(t, []string{"", "FIRST=1"}, first.Qemu.Env)
+	assert.Equal(t, []string{"", "second"}, second.Qemu.InitArgs)
+	assert.Equal(t, []string{"", "SECOND=1"}, second.Qemu.Env)
+	assert.Equal(t, []string{""}, base.Qemu.InitArgs)
+}
+
+func TestRunMatrix(t *testing.T) {
+	errFail := errors.New("fail")
+
+	targets := map[sys.Arch]MatrixTarget{
+		sys.AMD64: {Binary: "/bin-amd64", Kernel: "/kernel-amd64"},
+		sys.ARM64: {Binary: "/bin-arm64", Kernel: "/kernel-arm64"},
+	}
+
+	fakeRun := func(
+		_ context.Context,
+		arch sys.Arch,
+		spec *Spec,
+		output io.Writer,
+	) (*Result, error) {
+		_, _ = fmt.Fprintf(output, "%s %s", spec.Initramfs.Binary,
+			spec.Qemu.Kernel)
+
+		if arch == sys.ARM64 {
+			return &Result{ExitCode: 1}, errFail
+		}
+
+		return &Result{}, nil
+	}
+
+	for _, parallel := range []int{1, 2} {
+		t.Run(fmt.Sprintf("parallel %d", parallel), func(t *testing.T) {
+			results := runMatrix(
+				context.Background(),
+				&Spec{},
+				targets,
+				parallel,
+				fakeRun,
+			)
+			require.Len(t, results, 2)
+
+			amd64 := results[sys.AMD64]
+			require.NoError(t, amd64.Err)
+			assert.Equal(t, "/bin-amd64 /kernel-amd64", string(amd64.Output))
+
+			arm64 := results[sys.ARM64]
+			require.ErrorIs(t, arm64.Err, errFail)
+			assert.Equal(t, 1, arm64.Result.ExitCode)
+			assert.Equal(t, "/bin-arm64 /kernel-arm64", string(arm64.Output))
+
+			err := results.Err()
+			require.ErrorIs(t, err, errFail)
+			assert.EqualError(t, err, "arm64: fail")
+		})
+	}
+}
+
+func TestRunMatrix_UnsupportedArch(t *testing.T) {
+	targets := map[sys.Arch]MatrixTarget{
+		"mips": {Binary: "/bin-mips"},
+	}
+
+	results := runMatrix(
+		context.Background(),
+		&Spec{},
+		targets,
+		1,
+		func(context.Context, sys.Arch, *Spec, io.Writer) (*Result, error) {
+			t.Fatal("must not run")
+			return nil, nil //nolint:nilnil
+		},
+	)
+
+	require.ErrorIs(t, results["mips"].Err, sys.ErrArchNotSupported)
+	require.ErrorIs(t, results.Err(), sys.ErrArchNotSupported)
+}
+
+func TestRunMatrix_Concurrent(t *testing.T) {
+	targets := map[sys.Arch]MatrixTarget{
+		sys.AMD64: {Binary: "/bin-amd64"},
+		sys.ARM64: {Binary: "/bin-arm64"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var started sync.WaitGroup
+
+	started.Add(len(targets))
+
+	// Each run only finishes once all runs are started, so it fails if the
+	// runs are not concurrent.
+	fakeRun := func(
+		ctx context.Context,
+		_ sys.Arch,
+		spec *Spec,
+		output io.Writer,
+	) (*Result, error) {
+		started.Done()
+
+		allStarted := make(chan struct{})
+
+		go func() {
+			started.Wait()
+			close(allStarted)
+		}()
+
+		select {
+		case <-allStarted:
+		case <-ctx.Done():
+			return &Result{}, ctx.Err()
+		}
+
+		_, _ = fmt.Fprint(output, spec.Initramfs.Binary)
+
+		return &Result{}, nil
+	}
+
+	results := runMatrix(ctx, &Spec{}, targets, 2, fakeRun)
+	require.NoError(t, results.Err())
+
+	assert.Equal(t, "/bin-amd64", string(results[sys.AMD64].Output))
+	assert.Equal(t, "/bin-arm64", string(results[sys.ARM64].Output))
+}
+
+func TestRunMatrix_Bounded(t *testing.T) {
+	targets := map[sys.Arch]MatrixTarget{
+		sys.AMD64:   {Binary: "/bin-amd64"},
+		sys.ARM64:   {Binary: "/bin-arm64"},
+		sys.RISCV64: {Binary: "/bin-riscv64"},
+	}
+
+	var running, maxRunning atomic.Int32
+
+	fakeRun := func(
+		context.Context,
+		sys.Arch,
+		*Spec,
+		io.Writer,
+	) (*Result, error) {
+		current := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			seen := maxRunning.Load()
+			if current <= seen || maxRunning.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		return &Result{}, nil
+	}
+
+	results := runMatrix(context.Background(), &Spec{}, targets, 2, fakeRun)
+	require.NoError(t, results.Err())
+	assert.Len(t, results, 3)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+}
@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/sysinit"
+)
+
+// networkInterface is the name of the guest's interface of [Qemu.Network].
+// It is the only network device, so the kernel names it like this.
+const networkInterface = "eth0"
+
+// applyNetwork adds the configuration of the guest's interface of
+// [Qemu.Network] to [Initramfs.GuestConfig], so the guest's init sets it up
+// with the static addresses of the QEMU user mode network. A given guest
+// config is copied, not modified.
+func (s *Spec) applyNetwork() {
+	if s.Qemu.Network == nil {
+		return
+	}
+
+	guestCfg := sysinit.GuestConfig{}
+	if s.Initramfs.GuestConfig != nil {
+		guestCfg = *s.Initramfs.GuestConfig
+	}
+
+	guestCfg.EthInterface = &sysinit.EthInterface{
+		Name:       networkInterface,
+		Address:    qemu.NetworkGuestAddress,
+		Gateway:    qemu.NetworkGateway,
+		Nameserver: qemu.NetworkNameserver,
+	}
+
+	s.Initramfs.GuestConfig = &guestCfg
+}
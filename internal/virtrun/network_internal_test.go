@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/sysinit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec_ApplyNetwork(t *testing.T) {
+	t.Run("no network", func(t *testing.T) {
+		spec := &Spec{}
+		spec.applyNetwork()
+
+		assert.Nil(t, spec.Initramfs.GuestConfig)
+	})
+
+	t.Run("network", func(t *testing.T) {
+		given := &sysinit.GuestConfig{
+			Env: sysinit.EnvVars{"A": "1"},
+		}
+
+		spec := &Spec{
+			Qemu: Qemu{
+				Network: &qemu.Network{},
+			},
+			Initramfs: Initramfs{
+				GuestConfig: given,
+			},
+		}
+
+		spec.applyNetwork()
+
+		require.NotNil(t, spec.Initramfs.GuestConfig)
+		assert.Equal(t, &sysinit.EthInterface{
+			Name:       "eth0",
+			Address:    netip.MustParsePrefix("10.0.2.15/24"),
+			Gateway:    netip.MustParseAddr("10.0.2.2"),
+			Nameserver: netip.MustParseAddr("10.0.2.3"),
+		}, spec.Initramfs.GuestConfig.EthInterface)
+		assert.Equal(t, given.Env, spec.Initramfs.GuestConfig.Env)
+		assert.Nil(t, given.EthInterface, "given config must not be modified")
+	})
+}
@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// ProgressStage is a stage of a run reported by a [ProgressFunc].
+type ProgressStage string
+
+const (
+	// ProgressCollectLibs is reported before the shared libraries of the
+	// binaries are collected.
+	ProgressCollectLibs ProgressStage = "collect libs"
+
+	// ProgressWriteArchive is reported while the initramfs archive is
+	// written, each time the percentage changes.
+	ProgressWriteArchive ProgressStage = "write archive"
+
+	// ProgressStartQemu is reported right before QEMU is started.
+	ProgressStartQemu ProgressStage = "start qemu"
+
+	// ProgressGuestBooted is reported once the guest's init finished its
+	// setup.
+	ProgressGuestBooted ProgressStage = "guest booted"
+)
+
+// ProgressEvent describes the progress of a run.
+type ProgressEvent struct {
+	// Stage is the stage the run is in.
+	Stage ProgressStage
+
+	// Percent is the completion of the stage, if the stage reports it. It is
+	// only set for [ProgressWriteArchive].
+	Percent int
+}
+
+// String returns a human readable representation of the event.
+func (e ProgressEvent) String() string {
+	if e.Stage == ProgressWriteArchive {
+		return fmt.Sprintf("%s %d%%", e.Stage, e.Percent)
+	}
+
+	return string(e.Stage)
+}
+
+// ProgressFunc is called with progress events of a run, like for showing a
+// progress bar. It is called synchronously, so it must return quickly.
+type ProgressFunc func(event ProgressEvent)
+
+// report calls the function with the given event, if the function is not nil.
+func (fn ProgressFunc) report(stage ProgressStage, percent int) {
+	if fn != nil {
+		fn(ProgressEvent{Stage: stage, Percent: percent})
+	}
+}
+
+// progressWriter reports the percentage of the expected total bytes written
+// each time it changes. As the archive contains headers in addition to the
+// file content, it is capped at 100.
+type progressWriter struct {
+	w        io.Writer
+	total    int64
+	written  int64
+	reported int
+	fn       ProgressFunc
+}
+
+func newProgressWriter(
+	w io.Writer,
+	total int64,
+	fn ProgressFunc,
+) *progressWriter {
+	fn.report(ProgressWriteArchive, 0)
+
+	return &progressWriter{w: w, total: total, fn: fn}
+}
+
+// Write implements [io.Writer].
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.written += int64(n)
+
+	const full = 100
+
+	percent := full
+	if w.written < w.total {
+		percent = int(w.written * full / w.total)
+	}
+
+	if percent > w.reported {
+		w.reported = percent
+		w.fn.report(ProgressWriteArchive, percent)
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// regularFilesSize returns the total size of all regular files in the
+// [fs.FS].
+func regularFilesSize(fsys fs.FS) (int64, error) {
+	var total int64
+
+	err := fs.WalkDir(fsys, ".", func(
+		_ string, d fs.DirEntry, err error,
+	) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		total += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("walk: %w", err)
+	}
+
+	return total, nil
+}
@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/aibor/virtrun/sysinit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildInitramfsArchive_Progress(t *testing.T) {
+	file, err := initProgFor(sys.Native)
+	require.NoError(t, err)
+
+	defer file.Close()
+
+	var events []ProgressEvent
+
+	cfg := Initramfs{
+		Binary: writeTempFile(t, file),
+		progress: func(event ProgressEvent) {
+			events = append(events, event)
+		},
+	}
+
+	_, removeFn, err := BuildInitramfsArchive(
+		context.Background(),
+		cfg,
+		initProgOpenFunc(sys.Native),
+	)
+	require.NoError(t, err)
+
+	_ = removeFn()
+
+	require.Greater(t, len(events), 3)
+
+	assert.Equal(t, ProgressEvent{Stage: ProgressCollectLibs}, events[0])
+	assert.Equal(t,
+		ProgressEvent{Stage: ProgressWriteArchive, Percent: 0}, events[1])
+	assert.Equal(t,
+		ProgressEvent{Stage: ProgressWriteArchive, Percent: 100},
+		events[len(events)-1])
+
+	for idx := 2; idx < len(events); idx++ {
+		assert.Equal(t, ProgressWriteArchive, events[idx].Stage)
+		assert.Greater(t, events[idx].Percent, events[idx-1].Percent,
+			"percent must increase")
+	}
+}
+
+func TestNewQemuCommand_Progress(t *testing.T) {
+	cfg := Qemu{
+		Executable:    "qemu-system-x86_64",
+		TransportType: qemu.TransportTypePCI,
+		progress:      func(ProgressEvent) {},
+	}
+
+	cmd, err := NewQemuCommand(context.Background(), cfg, "/initramfs")
+	require.NoError(t, err)
+
+	assert.Contains(t, cmd.String(), sysinit.BootMarkerEnvVar+"=1",
+		"boot marker required for guest booted event")
+}
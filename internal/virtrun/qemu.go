@@ -5,11 +5,19 @@
 package virtrun
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aibor/virtrun/internal/qemu"
 	"github.com/aibor/virtrun/internal/sys"
@@ -22,13 +30,86 @@ type Qemu struct {
 	Machine             string
 	CPU                 string
 	SMP                 uint64
+	Sockets             uint64
+	NUMANodes           uint64
+	HostCPUs            []int
 	Memory              uint64
+	ResourceLimits      qemu.ResourceLimits
 	TransportType       qemu.TransportType
+	RTC                 qemu.RTC
+	Display             qemu.Display
+	SMBIOS              []qemu.SMBIOS
+	Shares              []qemu.Share
+	Disks               []qemu.Disk
+	DiskMount           *DiskMount
+	Network             *qemu.Network
+	Snapshot            bool
 	InitArgs            []string
+	ExtraInitArgs       []string
+	Env                 []string
+	EnvDeny             []string
+	ExtraKernelArgs     []string
 	ExtraArgs           []qemu.Argument
 	NoKVM               bool
+	Accel               qemu.Accel
+	AddRNG              bool
+	MemLock             bool
 	Verbose             bool
 	NoGoTestFlagRewrite bool
+	NoTestBinaryCheck   bool
+	IdleTimeout         time.Duration
+	Timeout             time.Duration
+	WaitForLine         *regexp.Regexp
+	WaitForLineTimeout  time.Duration
+	Monitor             *qemu.ConsoleBackend
+	QMPSocket           string
+	GDB                 qemu.GDBAddress
+	GDBWait             bool
+	InitVerbose         bool
+	InitSkipMounts      bool
+	InitReportResources bool
+	InitPoweroff        bool
+	InitUser            *sysinit.Credential
+	InitPanicBehavior   sysinit.PanicBehavior
+	MaxBootTime         time.Duration
+	OnReady             string
+	OutputFilter        qemu.OutputFilter
+	DumpGuestCore       bool
+	CrashDump           string
+
+	// OutputFiles maps files in the guest to host paths. The guest's init
+	// copies the files to dedicated consoles once the main binary is done,
+	// and the host writes them to the host paths. As consoles are line
+	// based, only text files are supported, like coverage profiles. Guest
+	// paths must not contain ",", ":" or white space.
+	OutputFiles map[string]string
+
+	// CoverProfile is the host path the coverage profile of the main binary
+	// is written to, if set. The main binary must be a Go test binary built
+	// with coverage enabled. It is told to write the profile to a guest file
+	// that is returned like [Qemu.OutputFiles]. So, "-test.coverprofile" must
+	// not be given in InitArgs as well.
+	CoverProfile string
+
+	// NamedConsoles maps names to host files. A console is added for each.
+	// The guest finds the device of a console by its name with
+	// [sysinit.ConsolePath]. Like for [qemu.CommandSpec.AdditionalConsoles],
+	// the output is sanitized before written to the host file. Names must
+	// not contain ",", ":" or white space.
+	NamedConsoles map[string]string
+
+	// Seed is conveyed to the guest in the environment variable
+	// [sysinit.SeedEnvVar], if set. The guest's virtio-rng device is fed
+	// with a deterministic random stream derived from it.
+	Seed *uint64
+
+	// rngSource is the path of the named pipe the deterministic random
+	// stream for the Seed is fed into. It is set by [Run].
+	rngSource string
+
+	// progress is called with the progress of the run. It is set from
+	// [Spec.Progress] by [Run].
+	progress ProgressFunc
 }
 
 func (s *Qemu) addDefaultsFor(arch sys.Arch) error {
@@ -47,6 +128,10 @@ func (s *Qemu) addDefaultsFor(arch sys.Arch) error {
 		executable = "qemu-system-aarch64"
 		machine = "virt"
 		transportType = qemu.TransportTypeMMIO
+	case sys.ARM:
+		executable = "qemu-system-arm"
+		machine = "virt"
+		transportType = qemu.TransportTypeMMIO
 	case sys.RISCV64:
 		executable = "qemu-system-riscv64"
 		machine = "virt"
@@ -67,8 +152,19 @@ func (s *Qemu) addDefaultsFor(arch sys.Arch) error {
 		s.TransportType = transportType
 	}
 
-	if !s.NoKVM {
-		s.NoKVM = !arch.KVMAvailable()
+	switch {
+	case s.Accel != "":
+		slog.Debug("Accelerator set", slog.String("accel", string(s.Accel)))
+	case s.NoKVM:
+		slog.Debug("KVM disabled")
+	default:
+		status := arch.KVMStatus()
+		s.NoKVM = status != sys.KVMUsable
+
+		slog.Debug("KVM detected",
+			slog.Bool("enabled", !s.NoKVM),
+			slog.String("reason", string(status)),
+		)
 	}
 
 	return nil
@@ -80,19 +176,80 @@ func NewQemuCommand(
 	initramfsPath string,
 ) (*qemu.Command, error) {
 	cmdSpec := qemu.CommandSpec{
-		Executable:    cfg.Executable,
-		Kernel:        cfg.Kernel,
-		Initramfs:     initramfsPath,
-		Machine:       cfg.Machine,
-		CPU:           cfg.CPU,
-		Memory:        cfg.Memory,
-		SMP:           cfg.SMP,
-		TransportType: cfg.TransportType,
-		InitArgs:      cfg.InitArgs,
-		ExtraArgs:     cfg.ExtraArgs,
-		NoKVM:         cfg.NoKVM,
-		Verbose:       cfg.Verbose,
-		ExitCodeFmt:   sysinit.ExitCodeFmt,
+		Executable:       cfg.Executable,
+		Kernel:           cfg.Kernel,
+		Initramfs:        initramfsPath,
+		Machine:          cfg.Machine,
+		CPU:              cfg.CPU,
+		Memory:           cfg.Memory,
+		ResourceLimits:   cfg.ResourceLimits,
+		SMP:              cfg.SMP,
+		ACPI:             cfg.InitPoweroff,
+		Sockets:          cfg.Sockets,
+		NUMANodes:        cfg.NUMANodes,
+		HostCPUs:         cfg.HostCPUs,
+		TransportType:    cfg.TransportType,
+		RTC:              cfg.RTC,
+		Display:          cfg.Display,
+		SMBIOS:           cfg.SMBIOS,
+		Shares:           cfg.Shares,
+		Disks:            cfg.Disks,
+		Network:          cfg.Network,
+		Snapshot:         cfg.Snapshot,
+		InitArgs:         cfg.InitArgs,
+		Env:              guestEnv(cfg.Env, cfg.EnvDeny),
+		KernelArgs:       cfg.ExtraKernelArgs,
+		ExtraArgs:        cfg.ExtraArgs,
+		NoKVM:            cfg.NoKVM,
+		RNG:              cfg.AddRNG,
+		Accel:            cfg.Accel,
+		MemLock:          cfg.MemLock,
+		DumpGuestCore:    cfg.DumpGuestCore,
+		CrashDump:        cfg.CrashDump,
+		Verbose:          cfg.Verbose,
+		IdleTimeout:      cfg.IdleTimeout,
+		Timeout:          cfg.Timeout,
+		ExitCodeFmt:      sysinit.ExitCodeFmt,
+		CompletionPrefix: sysinit.CompletionPrefix,
+		OopsMarker:       sysinit.OopsMarker,
+		Monitor:          cfg.Monitor,
+		QMPSocket:        cfg.QMPSocket,
+		GDB:              cfg.GDB,
+		GDBWait:          cfg.GDBWait,
+
+		WaitForLine:        cfg.WaitForLine,
+		WaitForLineTimeout: cfg.WaitForLineTimeout,
+		MaxBootTime:        cfg.MaxBootTime,
+	}
+
+	if cfg.needsBootMarker() {
+		cmdSpec.BootMarker = sysinit.BootMarker
+	}
+
+	if cfg.OnReady != "" {
+		cmdSpec.OnReady = onReadyFunc(ctx, cfg.OnReady)
+	}
+
+	if cfg.progress != nil {
+		cmdSpec.OnBooted = func() {
+			cfg.progress.report(ProgressGuestBooted, 0)
+		}
+	}
+
+	if !cfg.OutputFilter.IsZero() {
+		cmdSpec.StdoutFilter = &cfg.OutputFilter
+	}
+
+	cmdSpec.Env = append(cmdSpec.Env, memoryEnvVar(cfg.Memory))
+	cmdSpec.Env = append(cmdSpec.Env, initFlagEnvVars(cfg)...)
+
+	if cfg.Seed != nil {
+		cmdSpec.Env = append(cmdSpec.Env, seedEnvVar(*cfg.Seed))
+		cmdSpec.RNGSource = cfg.rngSource
+	}
+
+	if cfg.MemLock {
+		warnMemLockLimit(cfg.Memory)
 	}
 
 	// In order to be useful with "go test -exec", rewrite the file based flags
@@ -101,6 +258,37 @@ func NewQemuCommand(
 		rewriteGoTestFlagsPath(&cmdSpec)
 	}
 
+	outputFiles := cfg.OutputFiles
+
+	// Added after the rewrite, so the profile is written to the guest file
+	// instead of a console.
+	if cfg.CoverProfile != "" {
+		cmdSpec.InitArgs = append(cmdSpec.InitArgs,
+			"-test.gocoverdir=/tmp",
+			"-test.coverprofile="+coverProfileGuestPath,
+		)
+
+		outputFiles = maps.Clone(outputFiles)
+		if outputFiles == nil {
+			outputFiles = make(map[string]string, 1)
+		}
+
+		outputFiles[coverProfileGuestPath] = cfg.CoverProfile
+	}
+
+	if len(outputFiles) > 0 {
+		cmdSpec.Env = append(cmdSpec.Env,
+			outputFilesEnvVar(&cmdSpec, outputFiles))
+	}
+
+	if len(cfg.NamedConsoles) > 0 {
+		cmdSpec.Env = append(cmdSpec.Env,
+			namedConsolesEnvVar(&cmdSpec, cfg.NamedConsoles))
+	}
+
+	// Extra init args are passed verbatim, so append them after the rewrite.
+	cmdSpec.InitArgs = append(cmdSpec.InitArgs, cfg.ExtraInitArgs...)
+
 	cmd, err := qemu.NewCommand(ctx, cmdSpec)
 	if err != nil {
 		return nil, fmt.Errorf("build command: %w", err)
@@ -111,6 +299,236 @@ func NewQemuCommand(
 	return cmd, nil
 }
 
+// consoleConfigOptions are the kernel config options that must be built in
+// for the console device of the transport type.
+var consoleConfigOptions = map[qemu.TransportType][]string{
+	qemu.TransportTypeISA: {
+		"CONFIG_SERIAL_8250",
+		"CONFIG_SERIAL_8250_CONSOLE",
+	},
+	qemu.TransportTypePCI: {
+		"CONFIG_VIRTIO_PCI",
+		"CONFIG_VIRTIO_CONSOLE",
+	},
+	qemu.TransportTypeMMIO: {
+		"CONFIG_VIRTIO_MMIO",
+		"CONFIG_VIRTIO_CONSOLE",
+	},
+}
+
+// verifyConsoleSupport checks that the kernel supports the console device of
+// the transport type, if the kernel config can be found. Otherwise, the guest
+// produces no output and the run might hang silently.
+func (s *Qemu) verifyConsoleSupport() error {
+	path, err := sys.FindKernelConfig(s.Kernel)
+	if err != nil {
+		slog.Debug("Skip console support check", slog.Any("error", err))
+		return nil
+	}
+
+	config, err := sys.ReadKernelConfig(path)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return checkConsoleSupport(config, s.TransportType)
+}
+
+func checkConsoleSupport(
+	config sys.KernelConfig,
+	transportType qemu.TransportType,
+) error {
+	for _, option := range consoleConfigOptions[transportType] {
+		if !config.Builtin(option) {
+			return fmt.Errorf(
+				"%w: %s must be built in for transport %s, "+
+					"try a different transport (-transport)",
+				ErrConsoleNotSupported,
+				option,
+				transportType.String(),
+			)
+		}
+	}
+
+	return nil
+}
+
+// coverProfileGuestPath is the guest file the main binary writes the coverage
+// profile to, if [Qemu.CoverProfile] is set.
+const coverProfileGuestPath = "/tmp/virtrun-cover.out"
+
+// defaultGuestEnv are the environment variables set in the guest unless
+// overridden. The kernel sets HOME and TERM for init already, but with
+// different values.
+var defaultGuestEnv = []string{
+	"PATH=/data:/bin:/usr/bin",
+	"HOME=/root",
+	"TERM=linux",
+}
+
+// guestEnv merges the given environment variables into the default guest
+// environment variables. Given variables take precedence. Variables with a
+// key matching any of the deny patterns are removed afterwards. See
+// [path.Match] for the pattern syntax.
+func guestEnv(env []string, deny []string) []string {
+	merged := make([]string, 0, len(defaultGuestEnv)+len(env))
+
+	for _, envVar := range defaultGuestEnv {
+		key, _, _ := strings.Cut(envVar, "=")
+		if !slices.ContainsFunc(env, hasEnvKey(key)) {
+			merged = append(merged, envVar)
+		}
+	}
+
+	merged = append(merged, env...)
+
+	return slices.DeleteFunc(merged, func(envVar string) bool {
+		key, _, _ := strings.Cut(envVar, "=")
+
+		return slices.ContainsFunc(deny, func(pattern string) bool {
+			// Invalid patterns never match. They are rejected by the flag
+			// parser already.
+			matched, _ := path.Match(pattern, key)
+			return matched
+		})
+	})
+}
+
+// memoryEnvVar returns the environment variable that conveys the configured
+// memory size to the guest. See [sysinit.ConfiguredMemory].
+func memoryEnvVar(memory uint64) string {
+	return sysinit.MemoryEnvVar + "=" + strconv.FormatUint(memory, 10)
+}
+
+// outputFilesEnvVar adds a console for each of the given output files and
+// returns the environment variable that makes the guest's init copy the files
+// to them. See [sysinit.OutputFiles].
+func outputFilesEnvVar(c *qemu.CommandSpec, files map[string]string) string {
+	guestFiles := sysinit.OutputFiles(c.AddNamedConsoles(files))
+
+	return sysinit.OutputFilesEnvVar + "=" + guestFiles.String()
+}
+
+// namedConsolesEnvVar adds a console for each of the given named consoles
+// and returns the environment variable that tells the guest their devices.
+// See [sysinit.ConsolePath].
+func namedConsolesEnvVar(
+	c *qemu.CommandSpec,
+	consoles map[string]string,
+) string {
+	devices := sysinit.Consoles(c.AddNamedConsoles(consoles))
+
+	return sysinit.ConsolesEnvVar + "=" + devices.String()
+}
+
+// initFlagEnvVars returns the environment variables that enable the
+// requested flags of the init program. See [sysinit.Main].
+func initFlagEnvVars(cfg Qemu) []string {
+	var envVars []string
+
+	if cfg.InitVerbose {
+		envVars = append(envVars, sysinit.VerboseEnvVar+"=1")
+	}
+
+	if cfg.InitSkipMounts {
+		envVars = append(envVars, sysinit.SkipMountsEnvVar+"=1")
+	}
+
+	if cfg.InitReportResources {
+		envVars = append(envVars, sysinit.ReportResourcesEnvVar+"=1")
+	}
+
+	if cfg.InitPoweroff {
+		envVars = append(envVars, sysinit.PoweroffEnvVar+"=1")
+	}
+
+	if cfg.InitUser != nil {
+		envVars = append(envVars, sysinit.UserEnvVar+"="+cfg.InitUser.String())
+	}
+
+	if cfg.InitPanicBehavior != sysinit.PanicBehaviorNone {
+		envVars = append(envVars,
+			sysinit.PanicBehaviorEnvVar+"="+string(cfg.InitPanicBehavior))
+	}
+
+	if cfg.needsBootMarker() {
+		envVars = append(envVars, sysinit.BootMarkerEnvVar+"=1")
+	}
+
+	return envVars
+}
+
+// needsBootMarker returns true if the guest must print the
+// [sysinit.BootMarker]. It is used for the boot time check, as readiness
+// signal for [Qemu.OnReady] if no [Qemu.WaitForLine] is given and for
+// reporting the progress.
+func (s *Qemu) needsBootMarker() bool {
+	return s.MaxBootTime > 0 ||
+		(s.OnReady != "" && s.WaitForLine == nil) ||
+		s.progress != nil
+}
+
+// onReadyFunc returns a function that runs the given shell command on the
+// host. Its output is logged. It returns an error containing the output if
+// the command fails.
+func onReadyFunc(ctx context.Context, command string) func() error {
+	return func() error {
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, bytes.TrimSpace(output))
+		}
+
+		slog.Info("On ready command succeeded",
+			slog.String("command", command),
+			slog.String("output", string(bytes.TrimSpace(output))),
+		)
+
+		return nil
+	}
+}
+
+func hasEnvKey(key string) func(string) bool {
+	return func(envVar string) bool {
+		return strings.HasPrefix(envVar, key+"=")
+	}
+}
+
+// warnMemLockLimit logs a warning if the host memlock limit is not
+// sufficient for locking the given guest memory (in MB).
+func warnMemLockLimit(memory uint64) {
+	limit, err := sys.MemLockLimit()
+	if err != nil {
+		slog.Warn("Failed to read memlock limit", slog.Any("error", err))
+		return
+	}
+
+	if !memLockSufficient(memory, limit, sys.IsPrivileged()) {
+		slog.Warn("Memlock limit might be too low for mem-lock",
+			slog.Uint64("limit_bytes", limit),
+			slog.Uint64("memory_mb", memory),
+		)
+	}
+}
+
+// memLockSufficient returns true if the limit (in bytes) is sufficient for
+// locking the given guest memory (in MB). Privileged processes are not
+// limited.
+func memLockSufficient(memory, limit uint64, privileged bool) bool {
+	const (
+		mb = 1 << 20
+		// Some headroom for QEMU's own memory.
+		overhead = 64 * mb
+	)
+
+	if privileged || limit == sys.RlimitInfinity {
+		return true
+	}
+
+	return limit >= memory*mb+overhead
+}
+
 // rewriteGoTestFlagsPath processes file related go test flags in
 // [qemu.CommandSpec.InitArgs] and changes them, so the guest system's writes
 // end up in the host systems file paths.
@@ -5,10 +5,18 @@
 package virtrun
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/aibor/virtrun/sysinit"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestProcessGoTestFlags(t *testing.T) {
@@ -92,3 +100,534 @@ func TestProcessGoTestFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestMemLockSufficient(t *testing.T) {
+	tests := []struct {
+		name       string
+		memory     uint64
+		limit      uint64
+		privileged bool
+		assert     assert.BoolAssertionFunc
+	}{
+		{
+			name:   "default limit",
+			memory: 256,
+			limit:  8 << 20,
+			assert: assert.False,
+		},
+		{
+			name:   "sufficient limit",
+			memory: 256,
+			limit:  512 << 20,
+			assert: assert.True,
+		},
+		{
+			name:   "unlimited",
+			memory: 256,
+			limit:  sys.RlimitInfinity,
+			assert: assert.True,
+		},
+		{
+			name:       "privileged",
+			memory:     256,
+			limit:      8 << 20,
+			privileged: true,
+			assert:     assert.True,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.assert(t, memLockSufficient(tt.memory, tt.limit, tt.privileged))
+		})
+	}
+}
+
+func TestGuestEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      []string
+		deny     []string
+		expected []string
+	}{
+		{
+			name: "defaults",
+			expected: []string{
+				"PATH=/data:/bin:/usr/bin",
+				"HOME=/root",
+				"TERM=linux",
+			},
+		},
+		{
+			name: "override",
+			env: []string{
+				"PATH=/data",
+				"FOO=bar",
+				"TERMINAL=x",
+			},
+			expected: []string{
+				"HOME=/root",
+				"TERM=linux",
+				"PATH=/data",
+				"FOO=bar",
+				"TERMINAL=x",
+			},
+		},
+		{
+			name: "deny",
+			env: []string{
+				"AWS_SECRET_ACCESS_KEY=secret",
+				"AWS_REGION=eu-central-1",
+				"GITHUB_TOKEN=token",
+				"FOO=bar",
+			},
+			deny: []string{
+				"AWS_*",
+				"*_TOKEN",
+				"TERM",
+			},
+			expected: []string{
+				"PATH=/data:/bin:/usr/bin",
+				"HOME=/root",
+				"FOO=bar",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, guestEnv(tt.env, tt.deny))
+		})
+	}
+}
+
+func TestCheckConsoleSupport(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        string
+		transportType qemu.TransportType
+		expectedErr   error
+	}{
+		{
+			name: "pci supported",
+			config: "CONFIG_VIRTIO_PCI=y\n" +
+				"CONFIG_VIRTIO_CONSOLE=y\n",
+			transportType: qemu.TransportTypePCI,
+		},
+		{
+			name: "pci console missing",
+			config: "CONFIG_VIRTIO_PCI=y\n" +
+				"# CONFIG_VIRTIO_CONSOLE is not set\n",
+			transportType: qemu.TransportTypePCI,
+			expectedErr:   ErrConsoleNotSupported,
+		},
+		{
+			name: "mmio console module",
+			config: "CONFIG_VIRTIO_MMIO=y\n" +
+				"CONFIG_VIRTIO_CONSOLE=m\n",
+			transportType: qemu.TransportTypeMMIO,
+			expectedErr:   ErrConsoleNotSupported,
+		},
+		{
+			name: "isa supported",
+			config: "CONFIG_SERIAL_8250=y\n" +
+				"CONFIG_SERIAL_8250_CONSOLE=y\n",
+			transportType: qemu.TransportTypeISA,
+		},
+		{
+			name:          "isa missing",
+			config:        "CONFIG_VIRTIO_CONSOLE=y\n",
+			transportType: qemu.TransportTypeISA,
+			expectedErr:   ErrConsoleNotSupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := sys.ParseKernelConfig(strings.NewReader(tt.config))
+			require.NoError(t, err)
+
+			err = checkConsoleSupport(config, tt.transportType)
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestQemu_VerifyConsoleSupport(t *testing.T) {
+	fullConfig := "CONFIG_VIRTIO_PCI=y\n" +
+		"CONFIG_VIRTIO_MMIO=y\n" +
+		"CONFIG_VIRTIO_CONSOLE=y\n" +
+		"CONFIG_SERIAL_8250=y\n" +
+		"CONFIG_SERIAL_8250_CONSOLE=y\n"
+
+	tests := []struct {
+		name          string
+		config        string
+		transportType qemu.TransportType
+		assertErr     require.ErrorAssertionFunc
+	}{
+		{
+			name:          "no config",
+			transportType: qemu.TransportTypePCI,
+			assertErr:     require.NoError,
+		},
+		{
+			name:          "pci supported",
+			config:        fullConfig,
+			transportType: qemu.TransportTypePCI,
+			assertErr:     require.NoError,
+		},
+		{
+			name:          "mmio supported",
+			config:        fullConfig,
+			transportType: qemu.TransportTypeMMIO,
+			assertErr:     require.NoError,
+		},
+		{
+			name:          "isa supported",
+			config:        fullConfig,
+			transportType: qemu.TransportTypeISA,
+			assertErr:     require.NoError,
+		},
+		{
+			name:          "pci missing",
+			config:        "CONFIG_VIRTIO_CONSOLE=y\n",
+			transportType: qemu.TransportTypePCI,
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrConsoleNotSupported)
+				require.ErrorContains(t, err, "CONFIG_VIRTIO_PCI")
+			},
+		},
+		{
+			name:          "mmio missing",
+			config:        "CONFIG_VIRTIO_PCI=y\nCONFIG_VIRTIO_CONSOLE=y\n",
+			transportType: qemu.TransportTypeMMIO,
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrConsoleNotSupported)
+				require.ErrorContains(t, err, "CONFIG_VIRTIO_MMIO")
+			},
+		},
+		{
+			name:          "isa missing",
+			config:        "CONFIG_SERIAL_8250=y\n",
+			transportType: qemu.TransportTypeISA,
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrConsoleNotSupported)
+				require.ErrorContains(t, err, "CONFIG_SERIAL_8250_CONSOLE")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			kernel := filepath.Join(dir, "vmlinuz-6.8.0")
+			require.NoError(t, os.WriteFile(kernel, nil, 0o600))
+
+			// Without config, the check is skipped.
+			if tt.config != "" {
+				config := filepath.Join(dir, "config-6.8.0")
+				require.NoError(t,
+					os.WriteFile(config, []byte(tt.config), 0o600))
+			}
+
+			cfg := Qemu{
+				Kernel:        kernel,
+				TransportType: tt.transportType,
+			}
+
+			tt.assertErr(t, cfg.verifyConsoleSupport())
+		})
+	}
+}
+
+func TestNewQemuCommand_ExtraInitArgs(t *testing.T) {
+	cfg := Qemu{
+		Executable:    "qemu-system-x86_64",
+		TransportType: qemu.TransportTypePCI,
+		InitArgs: []string{
+			"-test.coverprofile=/tmp/cover.out",
+		},
+		ExtraInitArgs: []string{
+			"-test.coverprofile=/tmp/verbatim.out",
+			"plain",
+		},
+	}
+
+	cmd, err := NewQemuCommand(context.Background(), cfg, "/initramfs")
+	require.NoError(t, err)
+
+	assert.Contains(t, cmd.String(),
+		"-- -test.coverprofile=/dev/hvc1 "+
+			"-test.coverprofile=/tmp/verbatim.out plain")
+}
+
+func TestNewQemuCommand_OutputFiles(t *testing.T) {
+	cfg := Qemu{
+		Executable:    "qemu-system-x86_64",
+		TransportType: qemu.TransportTypePCI,
+		InitArgs: []string{
+			"-test.coverprofile=/tmp/cover.out",
+		},
+		OutputFiles: map[string]string{
+			"/tmp/trace.log":  "/host/trace.log",
+			"/tmp/cover2.out": "/host/cover2.out",
+		},
+	}
+
+	cmd, err := NewQemuCommand(context.Background(), cfg, "/initramfs")
+	require.NoError(t, err)
+
+	// The go test flag consoles come first, the output files follow in
+	// lexicographic order of the guest paths.
+	assert.Contains(t, cmd.String(), sysinit.OutputFilesEnvVar+
+		"=/tmp/cover2.out:/dev/hvc2,/tmp/trace.log:/dev/hvc3")
+	assert.Contains(t, cmd.String(), "-- -test.coverprofile=/dev/hvc1")
+	assert.Contains(t, cmd.String(), "-chardev file,id=con2,path=/dev/fd/5")
+}
+
+func TestNewQemuCommand_CoverProfile(t *testing.T) {
+	cfg := Qemu{
+		Executable:    "qemu-system-x86_64",
+		TransportType: qemu.TransportTypePCI,
+		InitArgs:      []string{"-test.v"},
+		OutputFiles: map[string]string{
+			"/tmp/trace.log": "/host/trace.log",
+		},
+		CoverProfile: "/host/cover.out",
+	}
+
+	cmd, err := NewQemuCommand(context.Background(), cfg, "/initramfs")
+	require.NoError(t, err)
+
+	assert.Contains(t, cmd.String(), "-- -test.v -test.gocoverdir=/tmp "+
+		"-test.coverprofile="+coverProfileGuestPath)
+	assert.Contains(t, cmd.String(), sysinit.OutputFilesEnvVar+"="+
+		"/tmp/trace.log:/dev/hvc1,"+coverProfileGuestPath+":/dev/hvc2")
+	assert.Len(t, cfg.OutputFiles, 1, "given output files unchanged")
+}
+
+func TestNewQemuCommand_NamedConsoles(t *testing.T) {
+	cfg := Qemu{
+		Executable:    "qemu-system-x86_64",
+		TransportType: qemu.TransportTypePCI,
+		OutputFiles: map[string]string{
+			"/tmp/trace.log": "/host/trace.log",
+		},
+		NamedConsoles: map[string]string{
+			"metrics": "/host/metrics.txt",
+			"events":  "/host/events.json",
+		},
+	}
+
+	cmd, err := NewQemuCommand(context.Background(), cfg, "/initramfs")
+	require.NoError(t, err)
+
+	assert.Contains(t, cmd.String(), sysinit.ConsolesEnvVar+
+		"=events:/dev/hvc2,metrics:/dev/hvc3")
+	assert.Contains(t, cmd.String(), "-chardev file,id=con2,path=/dev/fd/5")
+}
+
+func TestNewQemuCommand_Memory(t *testing.T) {
+	cfg := Qemu{
+		Executable:    "qemu-system-x86_64",
+		TransportType: qemu.TransportTypePCI,
+		Memory:        512,
+	}
+
+	cmd, err := NewQemuCommand(context.Background(), cfg, "/initramfs")
+	require.NoError(t, err)
+
+	assert.Contains(t, cmd.String(), "-m 512")
+	assert.Contains(t, cmd.String(), sysinit.MemoryEnvVar+"=512")
+}
+
+func TestNewQemuCommand_Seed(t *testing.T) {
+	seed := uint64(42)
+
+	cfg := Qemu{
+		Executable:    "qemu-system-x86_64",
+		TransportType: qemu.TransportTypePCI,
+		Seed:          &seed,
+		rngSource:     "/tmp/rng",
+	}
+
+	cmd, err := NewQemuCommand(context.Background(), cfg, "/initramfs")
+	require.NoError(t, err)
+
+	assert.Contains(t, cmd.String(), sysinit.SeedEnvVar+"=42")
+	assert.Contains(t, cmd.String(), "rng-random,id=rng0,filename=/tmp/rng")
+	assert.Contains(t, cmd.String(), "virtio-rng-pci,rng=rng0")
+}
+
+func TestNewQemuCommand_InitFlags(t *testing.T) {
+	tests := []struct {
+		name            string
+		verbose         bool
+		skipMounts      bool
+		reportResources bool
+		poweroff        bool
+		user            *sysinit.Credential
+		panicBehavior   sysinit.PanicBehavior
+		maxBootTime     time.Duration
+		onReady         string
+		expected        []string
+		unexpected      []string
+	}{
+		{
+			name: "none",
+			unexpected: []string{
+				sysinit.VerboseEnvVar,
+				sysinit.SkipMountsEnvVar,
+				sysinit.ReportResourcesEnvVar,
+				sysinit.PoweroffEnvVar,
+				sysinit.UserEnvVar,
+				sysinit.PanicBehaviorEnvVar,
+				sysinit.BootMarkerEnvVar,
+			},
+		},
+		{
+			name:       "verbose",
+			verbose:    true,
+			expected:   []string{sysinit.VerboseEnvVar + "=1"},
+			unexpected: []string{sysinit.SkipMountsEnvVar},
+		},
+		{
+			name:       "skip mounts",
+			skipMounts: true,
+			expected:   []string{sysinit.SkipMountsEnvVar + "=1"},
+			unexpected: []string{sysinit.VerboseEnvVar},
+		},
+		{
+			name:            "report resources",
+			reportResources: true,
+			expected:        []string{sysinit.ReportResourcesEnvVar + "=1"},
+			unexpected:      []string{sysinit.VerboseEnvVar},
+		},
+		{
+			name:       "poweroff",
+			poweroff:   true,
+			expected:   []string{sysinit.PoweroffEnvVar + "=1"},
+			unexpected: []string{sysinit.VerboseEnvVar},
+		},
+		{
+			name:       "user",
+			user:       &sysinit.Credential{UID: 1000, GID: 100},
+			expected:   []string{sysinit.UserEnvVar + "=1000:100"},
+			unexpected: []string{sysinit.VerboseEnvVar},
+		},
+		{
+			name:          "panic behavior",
+			panicBehavior: sysinit.PanicBehaviorReport,
+			expected:      []string{sysinit.PanicBehaviorEnvVar + "=report"},
+			unexpected:    []string{sysinit.VerboseEnvVar},
+		},
+		{
+			name:        "max boot time",
+			maxBootTime: time.Second,
+			expected:    []string{sysinit.BootMarkerEnvVar + "=1"},
+			unexpected:  []string{sysinit.VerboseEnvVar},
+		},
+		{
+			name:     "on ready",
+			onReady:  "true",
+			expected: []string{sysinit.BootMarkerEnvVar + "=1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Qemu{
+				Executable:          "qemu-system-x86_64",
+				TransportType:       qemu.TransportTypePCI,
+				InitVerbose:         tt.verbose,
+				InitSkipMounts:      tt.skipMounts,
+				InitReportResources: tt.reportResources,
+				InitPoweroff:        tt.poweroff,
+				InitUser:            tt.user,
+				InitPanicBehavior:   tt.panicBehavior,
+				MaxBootTime:         tt.maxBootTime,
+				OnReady:             tt.onReady,
+			}
+
+			cmd, err := NewQemuCommand(context.Background(), cfg, "/initramfs")
+			require.NoError(t, err)
+
+			for _, envVar := range tt.expected {
+				assert.Contains(t, cmd.String(), envVar)
+			}
+
+			for _, envVar := range tt.unexpected {
+				assert.NotContains(t, cmd.String(), envVar)
+			}
+		})
+	}
+}
+
+func TestOnReadyFunc(t *testing.T) {
+	err := onReadyFunc(context.Background(), "echo fine")()
+	require.NoError(t, err)
+
+	err = onReadyFunc(context.Background(), "echo broken; exit 3")()
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "exit status 3: broken")
+}
+
+func TestQemu_AddDefaultsFor(t *testing.T) {
+	tests := []struct {
+		arch          sys.Arch
+		executable    string
+		machine       string
+		transportType qemu.TransportType
+	}{
+		{
+			arch:          sys.AMD64,
+			executable:    "qemu-system-x86_64",
+			machine:       "q35",
+			transportType: qemu.TransportTypePCI,
+		},
+		{
+			arch:          sys.ARM64,
+			executable:    "qemu-system-aarch64",
+			machine:       "virt",
+			transportType: qemu.TransportTypeMMIO,
+		},
+		{
+			arch:          sys.ARM,
+			executable:    "qemu-system-arm",
+			machine:       "virt",
+			transportType: qemu.TransportTypeMMIO,
+		},
+		{
+			arch:          sys.RISCV64,
+			executable:    "qemu-system-riscv64",
+			machine:       "virt",
+			transportType: qemu.TransportTypeMMIO,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.arch), func(t *testing.T) {
+			cfg := Qemu{NoKVM: true}
+
+			require.NoError(t, cfg.addDefaultsFor(tt.arch))
+			assert.Equal(t, tt.executable, cfg.Executable)
+			assert.Equal(t, tt.machine, cfg.Machine)
+			assert.Equal(t, tt.transportType, cfg.TransportType)
+		})
+	}
+
+	t.Run("accel skips kvm detection", func(t *testing.T) {
+		cfg := Qemu{Accel: "tcg"}
+
+		require.NoError(t, cfg.addDefaultsFor(sys.AMD64))
+		assert.False(t, cfg.NoKVM)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		cfg := Qemu{}
+
+		err := cfg.addDefaultsFor("mips64")
+		require.ErrorIs(t, err, sys.ErrArchNotSupported)
+	})
+}
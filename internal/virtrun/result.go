@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/sysinit"
+)
+
+// Result describes the outcome of a [Run].
+type Result struct {
+	// ExitCode is the exit code communicated by the guest. It is only
+	// meaningful if the guest ran and communicated it.
+	ExitCode int
+
+	// Duration is the total duration of the run including building the
+	// initramfs archive.
+	Duration time.Duration
+
+	// InitramfsSize is the size of the initramfs archive file in bytes.
+	InitramfsSize int64
+
+	// InitramfsPath is the path of the initramfs archive file, if it is kept
+	// after the run, see [Initramfs.Keep] and [Initramfs.CacheDir].
+	InitramfsPath string
+
+	// Command is the QEMU command line, starting with the executable. It is
+	// only set if the command has been built.
+	Command []string
+
+	// Panic is true if a guest kernel panic has been detected.
+	Panic bool
+
+	// OOM is true if the guest ran out of memory.
+	OOM bool
+
+	// Oops is true if the guest reported a kernel oops, see
+	// [sysinit.PanicBehaviorReport].
+	Oops bool
+
+	// GuestDuration is the run duration as measured by the guest's init. It
+	// is only set if the guest communicated a [sysinit.Completion].
+	GuestDuration time.Duration
+
+	// GuestError is the error message communicated by the guest's init, if
+	// any.
+	GuestError string
+
+	// GuestResources are the resources left in the guest once the main
+	// binary is done. It is only set if [Qemu.InitReportResources] is set and
+	// the guest communicated them in its [sysinit.Completion].
+	GuestResources *sysinit.Resources
+
+	// GuestFailedMounts are the optional guest mount points that failed to
+	// mount, usually because the guest kernel lacks support for them.
+	GuestFailedMounts []string
+
+	// StderrTail is the last few KiB of the stderr output of the run. It is
+	// intended for diagnosis and reporting.
+	StderrTail []byte
+}
+
+// ResultProcessor processes the [Result] of a run, like writing reports.
+type ResultProcessor interface {
+	// ProcessResult is called once the run is done. The output is the
+	// guest's stdout as written to the stdout writer of the run.
+	ProcessResult(result *Result, output []byte) error
+}
+
+// processResult calls all processors in order. It stops on the first error.
+func processResult(
+	processors []ResultProcessor,
+	result *Result,
+	output []byte,
+) error {
+	for _, processor := range processors {
+		err := processor.ProcessResult(result, output)
+		if err != nil {
+			return fmt.Errorf("process result: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// setError populates the guest related fields from the given error returned
+// by [qemu.Command.Run].
+func (r *Result) setError(err error) {
+	var cmdErr *qemu.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Guest {
+		r.ExitCode = cmdErr.ExitCode
+	}
+
+	r.Panic = errors.Is(err, qemu.ErrGuestPanic)
+	r.OOM = errors.Is(err, qemu.ErrGuestOom)
+	r.Oops = errors.Is(err, qemu.ErrGuestOops)
+}
+
+// setCompletion populates the fields communicated by the guest via the
+// structured completion line. Guests that only communicate the legacy exit
+// code line send no completion, so nothing is set in that case. A malformed
+// completion is logged only, as the exit code is communicated separately.
+func (r *Result) setCompletion(data []byte) {
+	if data == nil {
+		return
+	}
+
+	var completion sysinit.Completion
+
+	err := json.Unmarshal(data, &completion)
+	if err != nil {
+		slog.Warn("Failed to parse guest completion", slog.Any("error", err))
+		return
+	}
+
+	r.GuestDuration = completion.Duration
+	r.GuestError = completion.Error
+	r.GuestResources = completion.Resources
+	r.GuestFailedMounts = completion.FailedMounts
+
+	if len(r.GuestFailedMounts) > 0 {
+		slog.Info("Optional guest mounts unavailable in this kernel",
+			slog.Any("paths", r.GuestFailedMounts),
+		)
+	}
+
+	if r.GuestResources != nil && r.GuestResources.Processes > 0 {
+		slog.Warn("Guest processes leaked",
+			slog.Int("processes", r.GuestResources.Processes),
+			slog.Int("fds", r.GuestResources.FDs),
+		)
+	}
+}
@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/sysinit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingProcessor struct {
+	result *Result
+	output []byte
+	err    error
+}
+
+func (p *recordingProcessor) ProcessResult(r *Result, output []byte) error {
+	p.result = r
+	p.output = output
+
+	return p.err
+}
+
+func TestRunWithResult_Processors(t *testing.T) {
+	first := &recordingProcessor{}
+	second := &recordingProcessor{}
+
+	spec := &Spec{
+		Initramfs: Initramfs{
+			Binary: "/nonexistent",
+		},
+		ResultProcessors: []ResultProcessor{first, second},
+	}
+
+	result, err := RunWithResult(
+		context.Background(),
+		spec,
+		nil,
+		io.Discard,
+		io.Discard,
+	)
+	require.Error(t, err)
+
+	assert.Same(t, result, first.result, "first processor")
+	assert.Same(t, result, second.result, "second processor")
+	assert.Empty(t, first.output)
+}
+
+func TestGuestExitCode(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		exitCode     int
+		expectedCode int
+		expectedErr  error
+	}{
+		{
+			name: "success",
+		},
+		{
+			name: "non-zero exit code",
+			err: &qemu.CommandError{
+				Err:      qemu.ErrGuestNonZeroExitCode,
+				Guest:    true,
+				ExitCode: 42,
+			},
+			exitCode:     42,
+			expectedCode: 42,
+		},
+		{
+			name: "no exit code",
+			err: &qemu.CommandError{
+				Err:   qemu.ErrGuestNoExitCodeFound,
+				Guest: true,
+			},
+			expectedErr: qemu.ErrGuestNoExitCodeFound,
+		},
+		{
+			name:        "infrastructure failure",
+			err:         assert.AnError,
+			expectedErr: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &Result{ExitCode: tt.exitCode}
+
+			exitCode, err := guestExitCode(result, tt.err)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			assert.Equal(t, tt.expectedCode, exitCode)
+		})
+	}
+}
+
+func TestProcessResult(t *testing.T) {
+	errProcess := errors.New("process failed")
+	failing := &recordingProcessor{err: errProcess}
+	skipped := &recordingProcessor{}
+	result := &Result{ExitCode: 3}
+
+	err := processResult(
+		[]ResultProcessor{failing, skipped},
+		result,
+		[]byte("output"),
+	)
+	require.ErrorIs(t, err, errProcess)
+
+	assert.Same(t, result, failing.result)
+	assert.Equal(t, []byte("output"), failing.output)
+	assert.Nil(t, skipped.result, "processors after failure are skipped")
+}
+
+func TestResult_SetCompletion(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected Result
+	}{
+		{
+			name: "structured",
+			data: []byte(`{"exitCode":1,"duration":1500000000,"error":"fail"}`),
+			expected: Result{
+				GuestDuration: 1500 * time.Millisecond,
+				GuestError:    "fail",
+			},
+		},
+		{
+			name: "resources",
+			data: []byte(`{"exitCode":0,"resources":{"processes":1,"fds":5}}`),
+			expected: Result{
+				GuestResources: &sysinit.Resources{Processes: 1, FDs: 5},
+			},
+		},
+		{
+			name: "failed mounts",
+			data: []byte(`{"exitCode":0,"failedMounts":["/sys/fs/bpf"]}`),
+			expected: Result{
+				GuestFailedMounts: []string{"/sys/fs/bpf"},
+			},
+		},
+		{
+			name: "legacy guest",
+		},
+		{
+			name: "malformed",
+			data: []byte(`{"exitCode":`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Result{}
+			result.setCompletion(tt.data)
+
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/aibor/virtrun/sysinit"
+	"golang.org/x/sys/unix"
+)
+
+// seedEnvVar returns the guest environment variable conveying the seed.
+func seedEnvVar(seed uint64) string {
+	return sysinit.SeedEnvVar + "=" + strconv.FormatUint(seed, 10)
+}
+
+// newSeededReader returns a reader of a deterministic random stream derived
+// from the given seed.
+func newSeededReader(seed uint64) io.Reader {
+	var key [32]byte
+
+	binary.LittleEndian.PutUint64(key[:], seed)
+
+	return rand.NewChaCha8(key)
+}
+
+// startRNGFeed creates a named pipe that is fed with the deterministic random
+// stream derived from the given seed. QEMU reads it as entropy source for the
+// guest's virtio-rng device.
+//
+// The path of the pipe is returned along with a cleanup function that stops
+// the feed and removes the pipe. The caller is responsible to call it once
+// the run is done.
+func startRNGFeed(seed uint64) (string, func() error, error) {
+	dir, err := os.MkdirTemp("", "virtrun-rng")
+	if err != nil {
+		return "", nil, fmt.Errorf("create rng dir: %w", err)
+	}
+
+	path := filepath.Join(dir, "rng")
+
+	err = unix.Mkfifo(path, 0o600)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("create rng pipe: %w", err)
+	}
+
+	// Open read-write, so opening does not block until QEMU opens the pipe
+	// for reading.
+	pipe, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("open rng pipe: %w", err)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		// Fails once the pipe is closed by the cleanup function.
+		_, _ = io.Copy(pipe, newSeededReader(seed))
+	}()
+
+	stopFn := func() error {
+		_ = pipe.Close()
+		<-done
+
+		return os.RemoveAll(dir)
+	}
+
+	return path, stopFn, nil
+}
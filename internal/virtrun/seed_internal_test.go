@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readRNGFeed(t *testing.T, seed uint64) []byte {
+	t.Helper()
+
+	path, stopFn, err := startRNGFeed(seed)
+	require.NoError(t, err)
+
+	pipe, err := os.Open(path)
+	require.NoError(t, err)
+
+	data := make([]byte, 64)
+	_, err = io.ReadFull(pipe, data)
+	require.NoError(t, err)
+	require.NoError(t, pipe.Close())
+
+	require.NoError(t, stopFn())
+	assert.NoDirExists(t, filepath.Dir(path), "rng dir should be removed")
+
+	return data
+}
+
+func TestStartRNGFeed(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	first := readRNGFeed(t, 42)
+	second := readRNGFeed(t, 42)
+	other := readRNGFeed(t, 43)
+
+	assert.Equal(t, first, second, "same seed")
+	assert.NotEqual(t, first, other, "different seed")
+}
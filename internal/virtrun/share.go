@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"maps"
+	"path/filepath"
+
+	"github.com/aibor/virtrun/sysinit"
+)
+
+// shareMountDir is the directory the [Qemu.Shares] are mounted in by their
+// tag in the guest.
+const shareMountDir = "/mnt"
+
+// applyShares adds a mount point for each of [Qemu.Shares] to
+// [Initramfs.GuestConfig], so the guest's init mounts them at
+// shareMountDir. A given guest config is copied, not modified.
+func (s *Spec) applyShares() {
+	if len(s.Qemu.Shares) == 0 {
+		return
+	}
+
+	guestCfg := sysinit.GuestConfig{}
+	if s.Initramfs.GuestConfig != nil {
+		guestCfg = *s.Initramfs.GuestConfig
+	}
+
+	guestCfg.Mounts = maps.Clone(guestCfg.Mounts)
+	if guestCfg.Mounts == nil {
+		guestCfg.Mounts = make(sysinit.MountPoints, len(s.Qemu.Shares))
+	}
+
+	for _, share := range s.Qemu.Shares {
+		path := filepath.Join(shareMountDir, share.Tag)
+		guestCfg.Mounts[path] = sysinit.ShareMountOptions(
+			share.Tag,
+			share.ReadOnly,
+		)
+	}
+
+	s.Initramfs.GuestConfig = &guestCfg
+}
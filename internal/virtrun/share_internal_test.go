@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"testing"
+
+	"github.com/aibor/virtrun/internal/qemu"
+	"github.com/aibor/virtrun/sysinit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec_ApplyShares(t *testing.T) {
+	t.Run("no shares", func(t *testing.T) {
+		spec := &Spec{}
+		spec.applyShares()
+
+		assert.Nil(t, spec.Initramfs.GuestConfig)
+	})
+
+	t.Run("shares", func(t *testing.T) {
+		given := &sysinit.GuestConfig{
+			Mounts: sysinit.MountPoints{
+				"/mnt/scratch": {FSType: sysinit.FSTypeTmp},
+			},
+			Env: sysinit.EnvVars{"A": "1"},
+		}
+
+		spec := &Spec{
+			Qemu: Qemu{
+				Shares: []qemu.Share{
+					{HostPath: "/srv/fixtures", Tag: "fixtures"},
+					{HostPath: "/srv/golden", Tag: "golden", ReadOnly: true},
+				},
+			},
+			Initramfs: Initramfs{
+				GuestConfig: given,
+			},
+		}
+
+		spec.applyShares()
+
+		require.NotNil(t, spec.Initramfs.GuestConfig)
+		assert.Equal(t, sysinit.MountPoints{
+			"/mnt/scratch": {FSType: sysinit.FSTypeTmp},
+			"/mnt/fixtures": {
+				FSType: sysinit.FSType9P,
+				Source: "fixtures",
+				Data:   "trans=virtio,version=9p2000.L",
+			},
+			"/mnt/golden": {
+				FSType: sysinit.FSType9P,
+				Source: "golden",
+				Flags:  sysinit.MountFlagReadOnly,
+				Data:   "trans=virtio,version=9p2000.L",
+			},
+		}, spec.Initramfs.GuestConfig.Mounts)
+		assert.Equal(t, given.Env, spec.Initramfs.GuestConfig.Env)
+		assert.Len(t, given.Mounts, 1, "given config must not be modified")
+	})
+}
@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aibor/virtrun/internal/sys"
+)
+
+// SmokeMarker is the line printed by the smoke binary if it runs.
+const SmokeMarker = "virtrun smoke test passed"
+
+// Pre-compile smoke programs for all supported architectures. Statically
+// linked so they can be used on any host platform.
+//
+//go:generate env CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -buildvcs=false -trimpath -ldflags "-s -w" -o smoke/bin/amd64 ./smoke/
+//go:generate env CGO_ENABLED=0 GOOS=linux GOARCH=arm64 go build -buildvcs=false -trimpath -ldflags "-s -w" -o smoke/bin/arm64 ./smoke/
+//go:generate env CGO_ENABLED=0 GOOS=linux GOARCH=arm GOARM=7 go build -buildvcs=false -trimpath -ldflags "-s -w" -o smoke/bin/arm ./smoke/
+//go:generate env CGO_ENABLED=0 GOOS=linux GOARCH=riscv64 go build -buildvcs=false -trimpath -ldflags "-s -w" -o smoke/bin/riscv64 ./smoke/
+
+// Embed pre-compiled smoke programs explicitly to trigger build time errors.
+//
+//go:embed smoke/bin/*
+var smokeFS embed.FS
+
+// WriteSmokeBinary writes the pre-built smoke binary for the arch to a
+// temporary file.
+//
+// The smoke binary prints [SmokeMarker] and exits with 0. It can be used as
+// [Initramfs.Binary] to verify kernel, QEMU, transport and console work
+// together. The path to the file is returned along with a cleanup function.
+// The caller is responsible to call the function once the file is no longer
+// needed.
+func WriteSmokeBinary(arch sys.Arch) (string, func() error, error) {
+	src, err := smokeFS.Open(filepath.Join("smoke", "bin", arch.String()))
+	if err != nil {
+		return "", nil, sys.ErrArchNotSupported
+	}
+	defer src.Close()
+
+	file, err := os.CreateTemp("", "virtrun-smoke")
+	if err != nil {
+		return "", nil, fmt.Errorf("create file: %w", err)
+	}
+	defer file.Close()
+
+	removeFn := func() error {
+		return os.Remove(file.Name())
+	}
+
+	_, err = io.Copy(file, src)
+	if err != nil {
+		_ = removeFn()
+		return "", nil, fmt.Errorf("write file: %w", err)
+	}
+
+	return file.Name(), removeFn, nil
+}
@@ -0,0 +1,20 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+// Trivial program that can be pre-compiled for multiple architectures and
+// embedded into the main binary. It is run as main binary in smoke mode to
+// verify the guest system works at all.
+package main
+
+import "os"
+
+// marker must match virtrun.SmokeMarker.
+const marker = "virtrun smoke test passed"
+
+func main() {
+	_, err := os.Stdout.WriteString(marker + "\n")
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSmokeBinary(t *testing.T) {
+	tests := []struct {
+		name        string
+		arch        sys.Arch
+		expectedErr error
+	}{
+		{
+			name: "valid amd64",
+			arch: sys.AMD64,
+		},
+		{
+			name: "valid arm64",
+			arch: sys.ARM64,
+		},
+		{
+			name: "valid arm",
+			arch: sys.ARM,
+		},
+		{
+			name: "valid riscv64",
+			arch: sys.RISCV64,
+		},
+		{
+			name:        "unknown arch",
+			arch:        "mips64",
+			expectedErr: sys.ErrArchNotSupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, removeFn, err := WriteSmokeBinary(tt.arch)
+			require.ErrorIs(t, err, tt.expectedErr)
+
+			if tt.expectedErr != nil {
+				return
+			}
+
+			arch, err := sys.ReadELFArch(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.arch, arch)
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Contains(t, string(content), SmokeMarker)
+
+			require.NoError(t, removeFn())
+			assert.NoFileExists(t, path)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// verifySources checks that the sources of [Initramfs.Files],
+// [Initramfs.NamedFiles], [Initramfs.Dirs], [Initramfs.Trees] and
+// [Initramfs.Overlays] are within one of the [Initramfs.AllowedSourceDirs], if
+// any are given.
+//
+// All paths are resolved, including symbolic links, before they are
+// compared. So, a source can not escape via a symbolic link or "..".
+func (cfg Initramfs) verifySources() error {
+	if len(cfg.AllowedSourceDirs) == 0 {
+		return nil
+	}
+
+	allowedDirs := make([]string, 0, len(cfg.AllowedSourceDirs))
+
+	for _, dir := range cfg.AllowedSourceDirs {
+		resolved, err := resolvePath(dir)
+		if err != nil {
+			return fmt.Errorf("allowed source dir: %w", err)
+		}
+
+		allowedDirs = append(allowedDirs, resolved)
+	}
+
+	sources := slices.Clone(cfg.Files)
+	for _, mapping := range cfg.NamedFiles {
+		sources = append(sources, mapping.Source)
+	}
+
+	for _, mapping := range cfg.Dirs {
+		sources = append(sources, mapping.Source)
+	}
+
+	for _, mapping := range cfg.Trees {
+		sources = append(sources, mapping.Source)
+	}
+
+	sources = append(sources, cfg.Overlays...)
+
+	for _, source := range sources {
+		resolved, err := resolvePath(source)
+		if err != nil {
+			return fmt.Errorf("source: %w", err)
+		}
+
+		isAllowed := func(dir string) bool {
+			return isWithinDir(resolved, dir)
+		}
+
+		if !slices.ContainsFunc(allowedDirs, isAllowed) {
+			return fmt.Errorf("%w: %s", ErrSourceNotAllowed, source)
+		}
+	}
+
+	return nil
+}
+
+// resolvePath returns the absolute path of the given path with all symbolic
+// links resolved. The path must exist.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("absolute path: %w", err)
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", fmt.Errorf("resolve path: %w", err)
+	}
+
+	return resolved, nil
+}
+
+// isWithinDir checks if the given path is the directory itself or below it.
+// Both must be clean absolute paths.
+func isWithinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	parent := ".." + string(filepath.Separator)
+
+	return rel != ".." && !strings.HasPrefix(rel, parent)
+}
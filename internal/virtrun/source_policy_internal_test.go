@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitramfs_VerifySources(t *testing.T) {
+	tempDir := t.TempDir()
+	allowed := filepath.Join(tempDir, "allowed")
+	outside := filepath.Join(tempDir, "outside")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(allowed, "dir"), 0o755))
+	require.NoError(t, os.MkdirAll(outside, 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "allow"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(allowed, "file"), nil, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "file"), nil, 0o600))
+	require.NoError(t, os.Symlink(
+		filepath.Join(outside, "file"),
+		filepath.Join(allowed, "escape"),
+	))
+	require.NoError(t, os.Symlink(outside, filepath.Join(allowed, "escapeDir")))
+	require.NoError(t, os.Symlink(allowed, filepath.Join(tempDir, "link")))
+
+	tests := []struct {
+		name        string
+		cfg         Initramfs
+		expectedErr error
+	}{
+		{
+			name: "no policy",
+			cfg: Initramfs{
+				Files: []string{filepath.Join(outside, "file")},
+			},
+		},
+		{
+			name: "allowed",
+			cfg: Initramfs{
+				Files: []string{filepath.Join(allowed, "file")},
+				Dirs: []FileMapping{
+					{Source: filepath.Join(allowed, "dir")},
+				},
+				AllowedSourceDirs: []string{allowed},
+			},
+		},
+		{
+			name: "allowed via linked allowed dir",
+			cfg: Initramfs{
+				Files:             []string{filepath.Join(allowed, "file")},
+				AllowedSourceDirs: []string{filepath.Join(tempDir, "link")},
+			},
+		},
+		{
+			name: "file outside",
+			cfg: Initramfs{
+				Files:             []string{filepath.Join(outside, "file")},
+				AllowedSourceDirs: []string{allowed},
+			},
+			expectedErr: ErrSourceNotAllowed,
+		},
+		{
+			name: "file outside via dot dot",
+			cfg: Initramfs{
+				Files: []string{
+					filepath.Join(allowed, "..", "outside", "file"),
+				},
+				AllowedSourceDirs: []string{allowed},
+			},
+			expectedErr: ErrSourceNotAllowed,
+		},
+		{
+			name: "file outside via symlink",
+			cfg: Initramfs{
+				Files:             []string{filepath.Join(allowed, "escape")},
+				AllowedSourceDirs: []string{allowed},
+			},
+			expectedErr: ErrSourceNotAllowed,
+		},
+		{
+			name: "named file outside",
+			cfg: Initramfs{
+				NamedFiles: []FileMapping{
+					{Source: filepath.Join(outside, "file"), Target: "f"},
+				},
+				AllowedSourceDirs: []string{allowed},
+			},
+			expectedErr: ErrSourceNotAllowed,
+		},
+		{
+			name: "dir outside",
+			cfg: Initramfs{
+				Dirs:              []FileMapping{{Source: outside}},
+				AllowedSourceDirs: []string{allowed},
+			},
+			expectedErr: ErrSourceNotAllowed,
+		},
+		{
+			name: "dir outside via symlink",
+			cfg: Initramfs{
+				Dirs: []FileMapping{
+					{Source: filepath.Join(allowed, "escapeDir")},
+				},
+				AllowedSourceDirs: []string{allowed},
+			},
+			expectedErr: ErrSourceNotAllowed,
+		},
+		{
+			name: "tree and overlay allowed",
+			cfg: Initramfs{
+				Trees: []FileMapping{
+					{Source: filepath.Join(allowed, "dir"), Target: "/etc/app"},
+				},
+				Overlays:          []string{filepath.Join(allowed, "dir")},
+				AllowedSourceDirs: []string{allowed},
+			},
+		},
+		{
+			name: "tree outside",
+			cfg: Initramfs{
+				Trees: []FileMapping{
+					{Source: outside, Target: "/etc"},
+				},
+				AllowedSourceDirs: []string{allowed},
+			},
+			expectedErr: ErrSourceNotAllowed,
+		},
+		{
+			name: "tree outside via symlink",
+			cfg: Initramfs{
+				Trees: []FileMapping{
+					{
+						Source: filepath.Join(allowed, "escapeDir"),
+						Target: "/etc",
+					},
+				},
+				AllowedSourceDirs: []string{allowed},
+			},
+			expectedErr: ErrSourceNotAllowed,
+		},
+		{
+			name: "overlay outside",
+			cfg: Initramfs{
+				Overlays:          []string{outside},
+				AllowedSourceDirs: []string{allowed},
+			},
+			expectedErr: ErrSourceNotAllowed,
+		},
+		{
+			name: "overlay outside via symlink",
+			cfg: Initramfs{
+				Overlays: []string{
+					filepath.Join(allowed, "escapeDir"),
+				},
+				AllowedSourceDirs: []string{allowed},
+			},
+			expectedErr: ErrSourceNotAllowed,
+		},
+		{
+			name: "sibling with common prefix",
+			cfg: Initramfs{
+				Files:             []string{filepath.Join(allowed, "file")},
+				AllowedSourceDirs: []string{filepath.Join(tempDir, "allow")},
+			},
+			expectedErr: ErrSourceNotAllowed,
+		},
+		{
+			name: "source missing",
+			cfg: Initramfs{
+				Files:             []string{filepath.Join(allowed, "missing")},
+				AllowedSourceDirs: []string{allowed},
+			},
+			expectedErr: os.ErrNotExist,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.verifySources()
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// StdinBinary is the [Initramfs.Binary] that makes [Run] read the main binary
+// from stdin. The guest's stdin is empty in this case.
+const StdinBinary = "-"
+
+// spoolStdinBinary writes the main binary read from the given reader to a
+// temporary file and sets [Initramfs.Binary] to its path. The returned
+// cleanup function removes the file and restores [Initramfs.Binary] to
+// [StdinBinary], so the [Spec] can be reused. On error, the file is removed
+// already.
+func (s *Spec) spoolStdinBinary(stdin io.Reader) (func(), error) {
+	if stdin == nil {
+		return nil, fmt.Errorf("main binary: %w", os.ErrInvalid)
+	}
+
+	file, err := os.CreateTemp("", "virtrun-stdin")
+	if err != nil {
+		return nil, fmt.Errorf("create stdin binary file: %w", err)
+	}
+	defer file.Close()
+
+	removeFn := func() {
+		_ = os.Remove(file.Name())
+		s.Initramfs.Binary = StdinBinary
+	}
+
+	_, err = io.Copy(file, stdin)
+	if err == nil {
+		err = file.Close()
+	}
+
+	if err != nil {
+		removeFn()
+		return nil, fmt.Errorf("write stdin binary file: %w", err)
+	}
+
+	s.Initramfs.Binary = file.Name()
+
+	return removeFn, nil
+}
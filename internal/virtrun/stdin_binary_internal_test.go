@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec_SpoolStdinBinary(t *testing.T) {
+	file, err := initProgFor(sys.Native)
+	require.NoError(t, err)
+
+	defer file.Close()
+
+	elf, err := io.ReadAll(file)
+	require.NoError(t, err)
+
+	spec := &Spec{Initramfs: Initramfs{Binary: StdinBinary}}
+
+	cleanupFn, err := spec.spoolStdinBinary(bytes.NewReader(elf))
+	require.NoError(t, err)
+
+	path := spec.Initramfs.Binary
+	require.NotEqual(t, StdinBinary, path)
+
+	arch, err := sys.ReadELFArch(path)
+	require.NoError(t, err)
+	assert.Equal(t, sys.Native, arch)
+
+	irfs, err := buildInitramfsArchive(
+		context.Background(),
+		spec.Initramfs,
+		initProgOpenFunc(arch),
+	)
+	require.NoError(t, err)
+
+	main, err := irfs.Open("main")
+	require.NoError(t, err)
+
+	defer main.Close()
+
+	content, err := io.ReadAll(main)
+	require.NoError(t, err)
+	assert.Equal(t, elf, content, "main must be the binary from stdin")
+
+	cleanupFn()
+
+	assert.NoFileExists(t, path)
+	assert.Equal(t, StdinBinary, spec.Initramfs.Binary)
+}
+
+func TestSpec_SpoolStdinBinary_NoStdin(t *testing.T) {
+	spec := &Spec{Initramfs: Initramfs{Binary: StdinBinary}}
+
+	_, err := spec.spoolStdinBinary(nil)
+	require.ErrorIs(t, err, os.ErrInvalid)
+}
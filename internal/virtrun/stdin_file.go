@@ -0,0 +1,24 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import "github.com/aibor/virtrun/sysinit"
+
+// applyStdin sets the guest path of [Initramfs.Stdin] in
+// [Initramfs.GuestConfig], so the guest's init reads it as standard input. A
+// given guest config is copied, not modified.
+func (s *Spec) applyStdin() {
+	if s.Initramfs.Stdin == "" {
+		return
+	}
+
+	guestCfg := sysinit.GuestConfig{}
+	if s.Initramfs.GuestConfig != nil {
+		guestCfg = *s.Initramfs.GuestConfig
+	}
+
+	guestCfg.Stdin = stdinFile
+	s.Initramfs.GuestConfig = &guestCfg
+}
@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"testing"
+
+	"github.com/aibor/virtrun/sysinit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec_ApplyStdin(t *testing.T) {
+	t.Run("no stdin", func(t *testing.T) {
+		spec := &Spec{}
+		spec.applyStdin()
+
+		assert.Nil(t, spec.Initramfs.GuestConfig)
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		given := &sysinit.GuestConfig{
+			Env: sysinit.EnvVars{"A": "1"},
+		}
+
+		spec := &Spec{
+			Initramfs: Initramfs{
+				Stdin:       "/tmp/input.txt",
+				GuestConfig: given,
+			},
+		}
+
+		spec.applyStdin()
+
+		require.NotNil(t, spec.Initramfs.GuestConfig)
+		assert.Equal(t, "/stdin", spec.Initramfs.GuestConfig.Stdin)
+		assert.Equal(t, given.Env, spec.Initramfs.GuestConfig.Env)
+		assert.Empty(t, given.Stdin, "given config must not be modified")
+	})
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"maps"
+
+	"github.com/aibor/virtrun/sysinit"
+)
+
+// applySysctls adds [Initramfs.Sysctls] to [Initramfs.GuestConfig], so the
+// guest's init sets them. A given guest config is copied, not modified.
+func (s *Spec) applySysctls() {
+	if len(s.Initramfs.Sysctls) == 0 {
+		return
+	}
+
+	guestCfg := sysinit.GuestConfig{}
+	if s.Initramfs.GuestConfig != nil {
+		guestCfg = *s.Initramfs.GuestConfig
+	}
+
+	guestCfg.Sysctls = maps.Clone(guestCfg.Sysctls)
+	if guestCfg.Sysctls == nil {
+		guestCfg.Sysctls = make(map[string]string, len(s.Initramfs.Sysctls))
+	}
+
+	maps.Copy(guestCfg.Sysctls, s.Initramfs.Sysctls)
+
+	s.Initramfs.GuestConfig = &guestCfg
+}
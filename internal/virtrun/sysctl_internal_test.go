@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"testing"
+
+	"github.com/aibor/virtrun/sysinit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec_ApplySysctls(t *testing.T) {
+	t.Run("no sysctls", func(t *testing.T) {
+		spec := &Spec{}
+		spec.applySysctls()
+
+		assert.Nil(t, spec.Initramfs.GuestConfig)
+	})
+
+	t.Run("sysctls", func(t *testing.T) {
+		given := &sysinit.GuestConfig{
+			Sysctls: map[string]string{
+				"kernel.core_pattern":  "core",
+				"vm.overcommit_memory": "1",
+			},
+		}
+
+		spec := &Spec{
+			Initramfs: Initramfs{
+				GuestConfig: given,
+				Sysctls: map[string]string{
+					"kernel.core_pattern":  "|/data/handler %p",
+					"kernel.core_uses_pid": "1",
+				},
+			},
+		}
+
+		spec.applySysctls()
+
+		require.NotNil(t, spec.Initramfs.GuestConfig)
+		assert.Equal(t, map[string]string{
+			"kernel.core_pattern":  "|/data/handler %p",
+			"kernel.core_uses_pid": "1",
+			"vm.overcommit_memory": "1",
+		}, spec.Initramfs.GuestConfig.Sysctls)
+		assert.Equal(t, "core", given.Sysctls["kernel.core_pattern"],
+			"given config must not be modified")
+	})
+}
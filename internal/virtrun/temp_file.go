@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxLinkAttempts is the number of random names tried for linking a
+// [tempFile] before giving up.
+const maxLinkAttempts = 10000
+
+// tempFile is a temporary file that is not visible in the file system until
+// it is linked.
+//
+// It is created unnamed with O_TMPFILE, if supported by the kernel and the
+// file system. So, no partially written file is left behind if the process
+// is interrupted. Otherwise, a regular named temporary file is used.
+type tempFile struct {
+	*os.File
+
+	dir     string
+	pattern string
+
+	// path is the path the file is linked with. It is empty as long as the
+	// file is unnamed.
+	path string
+}
+
+// createTempFile creates a new [tempFile] in the given directory. If dir is
+// empty, the default temp directory is used. The pattern is used as prefix
+// of the name the file is linked with.
+func createTempFile(dir, pattern string) (*tempFile, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	fd, err := unix.Open(dir, unix.O_TMPFILE|unix.O_RDWR|unix.O_CLOEXEC, 0o600)
+	if err != nil {
+		// Not supported by the kernel or the file system.
+		file, err := os.CreateTemp(dir, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("create temp file: %w", err)
+		}
+
+		return &tempFile{File: file, path: file.Name()}, nil
+	}
+
+	return &tempFile{
+		File:    os.NewFile(uintptr(fd), dir),
+		dir:     dir,
+		pattern: pattern,
+	}, nil
+}
+
+// link makes the file visible in its directory with a random name and
+// returns its path. The file stays open.
+//
+// Linking an unnamed file requires the capability CAP_DAC_READ_SEARCH. If it
+// is missing, the file is linked via its /proc/self/fd path. If /proc is not
+// available either, the content is copied into a new named temporary file.
+func (f *tempFile) link() (string, error) {
+	if f.path != "" {
+		return f.path, nil
+	}
+
+	path, err := f.linkWith(func(name string) error {
+		return unix.Linkat(
+			int(f.Fd()), "", unix.AT_FDCWD, name, unix.AT_EMPTY_PATH,
+		)
+	})
+	if err == nil {
+		return path, nil
+	}
+
+	path, err = f.linkWith(func(name string) error {
+		procPath := "/proc/self/fd/" + strconv.Itoa(int(f.Fd()))
+
+		return unix.Linkat(
+			unix.AT_FDCWD, procPath,
+			unix.AT_FDCWD, name,
+			unix.AT_SYMLINK_FOLLOW,
+		)
+	})
+	if err == nil {
+		return path, nil
+	}
+
+	return f.copyToNamed()
+}
+
+// linkWith calls the given link function with random names until it
+// succeeds or fails with an error other than [unix.EEXIST].
+func (f *tempFile) linkWith(linkFn func(name string) error) (string, error) {
+	for range maxLinkAttempts {
+		name := filepath.Join(
+			f.dir,
+			f.pattern+strconv.FormatUint(uint64(rand.Uint32()), 10),
+		)
+
+		err := linkFn(name)
+		if errors.Is(err, unix.EEXIST) {
+			continue
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("link temp file: %w", err)
+		}
+
+		f.path = name
+
+		return name, nil
+	}
+
+	return "", fmt.Errorf("link temp file: %w", unix.EEXIST)
+}
+
+// copyToNamed copies the content of the unnamed file into a new named
+// temporary file and replaces the file with it.
+func (f *tempFile) copyToNamed() (string, error) {
+	named, err := os.CreateTemp(f.dir, f.pattern)
+	if err != nil {
+		return "", fmt.Errorf("create temp file: %w", err)
+	}
+
+	_, err = io.Copy(named, io.NewSectionReader(f.File, 0, 1<<63-1))
+	if err != nil {
+		_ = named.Close()
+		_ = os.Remove(named.Name())
+
+		return "", fmt.Errorf("copy temp file: %w", err)
+	}
+
+	_ = f.File.Close()
+
+	f.File = named
+	f.path = named.Name()
+
+	return named.Name(), nil
+}
+
+// discard closes the file and removes it, if it has been linked already.
+func (f *tempFile) discard() {
+	_ = f.Close()
+
+	if f.path != "" {
+		_ = os.Remove(f.path)
+	}
+}
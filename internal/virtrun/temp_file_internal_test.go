@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTempFile_Link(t *testing.T) {
+	dir := t.TempDir()
+
+	file, err := createTempFile(dir, "initramfs")
+	require.NoError(t, err)
+
+	defer file.Close()
+
+	if file.path != "" {
+		t.Skip("O_TMPFILE not supported")
+	}
+
+	_, err = file.WriteString("content")
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "unnamed file must not be visible")
+
+	path, err := file.link()
+	require.NoError(t, err)
+
+	assert.Equal(t, dir, filepath.Dir(path))
+	assert.True(t, strings.HasPrefix(filepath.Base(path), "initramfs"))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+}
+
+func TestTempFile_CopyToNamed(t *testing.T) {
+	dir := t.TempDir()
+
+	file, err := createTempFile(dir, "initramfs")
+	require.NoError(t, err)
+
+	defer file.Close()
+
+	if file.path != "" {
+		t.Skip("O_TMPFILE not supported")
+	}
+
+	_, err = file.WriteString("content")
+	require.NoError(t, err)
+
+	path, err := file.copyToNamed()
+	require.NoError(t, err)
+
+	assert.Equal(t, path, file.Name())
+	assert.Equal(t, path, file.path)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+}
+
+func TestTempFile_Discard(t *testing.T) {
+	dir := t.TempDir()
+
+	file, err := createTempFile(dir, "initramfs")
+	require.NoError(t, err)
+
+	_, err = file.link()
+	require.NoError(t, err)
+
+	file.discard()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// LocalTimezone is the [Initramfs.Timezone] that is resolved to the
+	// timezone of the host.
+	LocalTimezone = "local"
+
+	zoneinfoDir   = "/usr/share/zoneinfo"
+	localtimePath = "/etc/localtime"
+)
+
+// hostZoneinfoDirs are the directories the zoneinfo files are searched in on
+// the host, in addition to the one given by the ZONEINFO environment
+// variable. Same as the Go runtime uses.
+var hostZoneinfoDirs = []string{
+	"/usr/share/zoneinfo",
+	"/usr/share/lib/zoneinfo",
+	"/usr/lib/locale/TZ",
+}
+
+// hostTimezone returns the name of the host's timezone. It is taken from the
+// TZ environment variable, if set, or from the target of the /etc/localtime
+// symlink otherwise.
+func hostTimezone(
+	getenv func(string) string,
+	readlink func(string) (string, error),
+) (string, error) {
+	if zone := strings.TrimPrefix(getenv("TZ"), ":"); zone != "" {
+		return zone, nil
+	}
+
+	target, err := readlink(localtimePath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTimezoneUnknown, err)
+	}
+
+	_, zone, found := strings.Cut(target, "zoneinfo/")
+	if !found || zone == "" {
+		return "", fmt.Errorf("%w: %s links to %s",
+			ErrTimezoneUnknown, localtimePath, target)
+	}
+
+	return zone, nil
+}
+
+// findZoneinfo returns the path of the zoneinfo file for the given zone on
+// the host.
+func findZoneinfo(zone string) (string, error) {
+	if !filepath.IsLocal(zone) {
+		return "", fmt.Errorf("%w: %s", ErrTimezoneUnknown, zone)
+	}
+
+	dirs := hostZoneinfoDirs
+	if dir := os.Getenv("ZONEINFO"); dir != "" {
+		dirs = slices.Concat([]string{dir}, dirs)
+	}
+
+	for _, dir := range dirs {
+		path := filepath.Join(dir, zone)
+
+		info, err := os.Stat(path)
+		if err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: no zoneinfo file for %s",
+		ErrTimezoneUnknown, zone)
+}
+
+// applyTimezone resolves [Initramfs.Timezone] and sets the TZ environment
+// variable for the guest accordingly, unless it is set explicitly already.
+func (s *Spec) applyTimezone() error {
+	zone := s.Initramfs.Timezone
+	if zone == "" {
+		return nil
+	}
+
+	if zone == LocalTimezone {
+		var err error
+
+		zone, err = hostTimezone(os.Getenv, os.Readlink)
+		if err != nil {
+			return err
+		}
+	}
+
+	source, err := findZoneinfo(zone)
+	if err != nil {
+		return err
+	}
+
+	s.Initramfs.Timezone = zone
+	s.Initramfs.zoneinfo = source
+
+	if !slices.ContainsFunc(s.Qemu.Env, hasEnvKey("TZ")) {
+		s.Qemu.Env = append(s.Qemu.Env, "TZ="+zone)
+	}
+
+	return nil
+}
+
+// addTimezone adds the zoneinfo file for the guest and links /etc/localtime
+// to it.
+func (b *fsBuilder) addTimezone(zone, source string) error {
+	name := filepath.Join(zoneinfoDir, zone)
+
+	err := b.mkdirAll(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+
+	err = b.addFilePathAs(name, source)
+	if err != nil {
+		return err
+	}
+
+	err = b.mkdirAll(filepath.Dir(localtimePath))
+	if err != nil {
+		return err
+	}
+
+	return b.symlink(name, localtimePath)
+}
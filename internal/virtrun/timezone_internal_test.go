@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aibor/virtrun/internal/sys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostTimezone(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       string
+		link      string
+		linkErr   error
+		expected  string
+		assertErr require.ErrorAssertionFunc
+	}{
+		{
+			name:      "from env",
+			env:       ":Europe/Berlin",
+			expected:  "Europe/Berlin",
+			assertErr: require.NoError,
+		},
+		{
+			name:      "from localtime link",
+			link:      "/usr/share/zoneinfo/America/New_York",
+			expected:  "America/New_York",
+			assertErr: require.NoError,
+		},
+		{
+			name:    "no localtime link",
+			linkErr: fs.ErrNotExist,
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrTimezoneUnknown)
+			},
+		},
+		{
+			name: "localtime link not into zoneinfo",
+			link: "/etc/mytime",
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrTimezoneUnknown)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(string) string { return tt.env }
+			readlink := func(string) (string, error) {
+				return tt.link, tt.linkErr
+			}
+
+			actual, err := hostTimezone(getenv, readlink)
+			tt.assertErr(t, err)
+
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestSpec_ApplyTimezone(t *testing.T) {
+	zoneinfo := t.TempDir()
+	source := filepath.Join(zoneinfo, "Europe", "Berlin")
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(source), 0o755))
+	require.NoError(t, os.WriteFile(source, []byte("TZif"), 0o600))
+
+	t.Setenv("ZONEINFO", zoneinfo)
+
+	t.Run("sets env", func(t *testing.T) {
+		spec := &Spec{Initramfs: Initramfs{Timezone: "Europe/Berlin"}}
+
+		require.NoError(t, spec.applyTimezone())
+
+		assert.Equal(t, []string{"TZ=Europe/Berlin"}, spec.Qemu.Env)
+		assert.Equal(t, source, spec.Initramfs.zoneinfo)
+	})
+
+	t.Run("keeps explicit env", func(t *testing.T) {
+		spec := &Spec{
+			Qemu:      Qemu{Env: []string{"TZ=UTC"}},
+			Initramfs: Initramfs{Timezone: "Europe/Berlin"},
+		}
+
+		require.NoError(t, spec.applyTimezone())
+
+		assert.Equal(t, []string{"TZ=UTC"}, spec.Qemu.Env)
+	})
+
+	t.Run("unknown zone", func(t *testing.T) {
+		spec := &Spec{Initramfs: Initramfs{Timezone: "Mars/Olympus"}}
+
+		err := spec.applyTimezone()
+		require.ErrorIs(t, err, ErrTimezoneUnknown)
+	})
+
+	t.Run("not local path", func(t *testing.T) {
+		spec := &Spec{Initramfs: Initramfs{Timezone: "../../etc/passwd"}}
+
+		err := spec.applyTimezone()
+		require.ErrorIs(t, err, ErrTimezoneUnknown)
+	})
+}
+
+func TestBuildInitramFS_Timezone(t *testing.T) {
+	cfg := Initramfs{
+		Binary:   "/main",
+		Timezone: "Europe/Berlin",
+		zoneinfo: "/usr/share/zoneinfo/Europe/Berlin",
+	}
+
+	initFn := func(b *fsBuilder, name string) error {
+		return b.symlink("main", name)
+	}
+
+	irfs, err := buildInitramFS(cfg, sys.LibCollection{}, initFn)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Berlin"},
+		readDirNames(t, irfs, "usr/share/zoneinfo/Europe"))
+
+	target, err := irfs.ReadLink("etc/localtime")
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/share/zoneinfo/Europe/Berlin", target)
+}
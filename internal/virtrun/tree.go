@@ -0,0 +1,179 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aibor/virtrun/internal/initramfs"
+)
+
+// treeEntry is a host file of a merged tree, see [mergeTrees].
+type treeEntry struct {
+	// source is the host path of the file. It is empty for parent
+	// directories implied by a tree's target.
+	source string
+	mode   fs.FileMode
+}
+
+// mergeTrees walks the host directories of the given mappings in order and
+// merges their entries by guest path.
+//
+// The trees may overlap. If several trees have an entry at the same path, the
+// entry of the later tree wins. If the types differ, the earlier entry is
+// dropped along with anything below it. So directories are merged, while
+// files and symbolic links are replaced.
+func mergeTrees(mappings []FileMapping) (map[string]treeEntry, error) {
+	merged := map[string]treeEntry{}
+
+	for _, mapping := range mappings {
+		target := filepath.Clean(mapping.Target)
+
+		// Parents of the target must be directories.
+		for dir := filepath.Dir(target); dir != "/" && dir != "."; {
+			setTreeEntry(merged, dir, treeEntry{mode: fs.ModeDir | 0o755})
+			dir = filepath.Dir(dir)
+		}
+
+		walkFunc := func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err //nolint:wrapcheck
+			}
+
+			setTreeEntry(merged, filepath.Join(target, path), treeEntry{
+				source: filepath.Join(mapping.Source, path),
+				mode:   info.Mode(),
+			})
+
+			return nil
+		}
+
+		err := fs.WalkDir(os.DirFS(mapping.Source), ".", walkFunc)
+		if err != nil {
+			return nil, fmt.Errorf("add tree %s: %w", mapping.Source, err)
+		}
+	}
+
+	return merged, nil
+}
+
+// setTreeEntry sets the entry at the given path. If the existing entry is a
+// directory, it is kept unless the new entry is not a directory. In this
+// case all entries below the path are removed.
+func setTreeEntry(merged map[string]treeEntry, path string, entry treeEntry) {
+	existing, exists := merged[path]
+	if exists && existing.mode.IsDir() && entry.mode.IsDir() {
+		// Keep the directory of an earlier tree, but take over the mode of
+		// an actual host directory.
+		if entry.source != "" {
+			merged[path] = entry
+		}
+
+		return
+	}
+
+	if exists && existing.mode.IsDir() {
+		prefix := path + string(filepath.Separator)
+
+		maps.DeleteFunc(merged, func(name string, _ treeEntry) bool {
+			return strings.HasPrefix(name, prefix)
+		})
+	}
+
+	merged[path] = entry
+}
+
+// addTrees adds the host directory trees of the given mappings at their
+// guest target paths.
+//
+// The trees are merged by [mergeTrees] first. Entries are added in lexical
+// order, so parent directories are created before their content.
+// Directories that exist already, like "/etc", are merged. Any other entry
+// colliding with an existing file fails.
+func (b *fsBuilder) addTrees(mappings []FileMapping) error {
+	merged, err := mergeTrees(mappings)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(merged)) {
+		entry := merged[name]
+
+		if entry.source == "" {
+			err = b.mkdirAll(name)
+		} else {
+			err = b.addHostEntry(name, entry.source, entry.mode)
+		}
+
+		if err != nil {
+			return fmt.Errorf("add tree entry %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// addOverlays applies the host directories of the given overlays on top of
+// the file tree in order, like layers.
+//
+// The overlays are merged by [mergeTrees] at the root first, so later
+// overlays win over earlier ones. Unlike trees, overlay entries replace any
+// existing entry, including generated ones like the main binary. Only
+// directories present on both sides are merged.
+func (b *fsBuilder) addOverlays(dirs []string) error {
+	mappings := make([]FileMapping, 0, len(dirs))
+	for _, dir := range dirs {
+		mappings = append(mappings, FileMapping{Source: dir, Target: "/"})
+	}
+
+	merged, err := mergeTrees(mappings)
+	if err != nil {
+		return err
+	}
+
+	// The root directory exists already.
+	delete(merged, "/")
+
+	for _, name := range slices.Sorted(maps.Keys(merged)) {
+		entry := merged[name]
+
+		err := b.replaceWithHostEntry(name, entry)
+		if err != nil {
+			return fmt.Errorf("add overlay entry %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// replaceWithHostEntry adds the given entry at the given path. An existing
+// entry is removed first, unless both are directories.
+func (b *fsBuilder) replaceWithHostEntry(name string, entry treeEntry) error {
+	if entry.mode.IsDir() {
+		err := b.mkdirAll(name)
+		if !errors.Is(err, initramfs.ErrFileNotDir) {
+			return err
+		}
+	}
+
+	err := b.removeAll(name)
+	if err != nil {
+		return err
+	}
+
+	return b.addHostEntry(name, entry.source, entry.mode)
+}
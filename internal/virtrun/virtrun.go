@@ -5,11 +5,17 @@
 package virtrun
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/aibor/virtrun/internal/qemu"
 	"github.com/aibor/virtrun/internal/sys"
 )
 
@@ -20,6 +26,23 @@ import (
 type Spec struct {
 	Qemu      Qemu
 	Initramfs Initramfs
+
+	// ResultProcessors are called in order once the run is done.
+	ResultProcessors []ResultProcessor
+
+	// CastFile is the path of an asciinema v2 cast file the guest's console
+	// output is recorded into with timing, if set.
+	CastFile string
+
+	// DiskExtractDir is a directory the files of the first of [Qemu.Disks]
+	// are extracted to once the run is done, if set. The disk must be a
+	// writable raw image file with an ext4 file system. Existing files are
+	// not overwritten.
+	DiskExtractDir string
+
+	// Progress is called with progress events while building the initramfs
+	// archive and booting the guest, if set.
+	Progress ProgressFunc
 }
 
 // Run runs with the given [Spec].
@@ -34,6 +57,94 @@ func Run(
 	stdin io.Reader,
 	stdout, stderr io.Writer,
 ) error {
+	_, err := RunWithResult(ctx, spec, stdin, stdout, stderr)
+	return err
+}
+
+// RunWithExitCode runs like [Run] but returns the exit code communicated by
+// the guest.
+//
+// Unlike [Run], a non-zero exit code is not an error. The error is reserved
+// for failures of the run itself, like building the initramfs archive,
+// starting QEMU or the guest not communicating an exit code at all. The exit
+// code is only meaningful if no error is returned.
+func RunWithExitCode(
+	ctx context.Context,
+	spec *Spec,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) (int, error) {
+	result, err := RunWithResult(ctx, spec, stdin, stdout, stderr)
+
+	return guestExitCode(result, err)
+}
+
+// guestExitCode returns the exit code of the [Result]. The error is dropped
+// if it only reports a non-zero guest exit code.
+func guestExitCode(result *Result, err error) (int, error) {
+	if errors.Is(err, qemu.ErrGuestNonZeroExitCode) {
+		return result.ExitCode, nil
+	}
+
+	return result.ExitCode, err
+}
+
+// RunWithResult runs like [Run] and additionally returns a [Result] with
+// details about the run.
+//
+// The [Result] is returned in any case, even if an error is returned. If
+// [Spec.ResultProcessors] are present, the guest output is captured and
+// passed to them along with the [Result].
+func RunWithResult(
+	ctx context.Context,
+	spec *Spec,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+) (*Result, error) {
+	var output bytes.Buffer
+
+	if len(spec.ResultProcessors) > 0 {
+		stdout = io.MultiWriter(stdout, &output)
+	}
+
+	result := &Result{}
+	start := time.Now()
+
+	err := run(ctx, spec, stdin, stdout, stderr, result)
+	result.Duration = time.Since(start)
+	result.setError(err)
+
+	processErr := processResult(spec.ResultProcessors, result, output.Bytes())
+	if processErr != nil {
+		// Do not mask the more relevant run error.
+		if err == nil {
+			return result, processErr
+		}
+
+		slog.Error("Failed to process result", slog.Any("error", processErr))
+	}
+
+	return result, err
+}
+
+func run(
+	ctx context.Context,
+	spec *Spec,
+	stdin io.Reader,
+	stdout, stderr io.Writer,
+	result *Result,
+) error {
+	if spec.Initramfs.Binary == StdinBinary {
+		cleanupFn, err := spec.spoolStdinBinary(stdin)
+		if err != nil {
+			return err
+		}
+		defer cleanupFn()
+
+		// The binary consumed stdin, so the guest gets none.
+		stdin = nil
+	}
+
 	arch, err := sys.ReadELFArch(spec.Initramfs.Binary)
 	if err != nil {
 		return fmt.Errorf("read main binary arch: %w", err)
@@ -44,7 +155,27 @@ func Run(
 		return err
 	}
 
-	initFn := func() (fs.File, error) { return initProgFor(arch) }
+	err = spec.Qemu.verifyConsoleSupport()
+	if err != nil {
+		return err
+	}
+
+	err = spec.verifyDiskExtract()
+	if err != nil {
+		return err
+	}
+
+	err = spec.prepareInitramfs()
+	if err != nil {
+		return err
+	}
+
+	spec.Qemu.progress = spec.Progress
+
+	initFn, err := initOpenFunc(spec.Initramfs, arch)
+	if err != nil {
+		return err
+	}
 
 	path, removeFn, err := BuildInitramfsArchive(ctx, spec.Initramfs, initFn)
 	if err != nil {
@@ -52,15 +183,121 @@ func Run(
 	}
 	defer removeFn() //nolint:errcheck
 
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat initramfs: %w", err)
+	}
+
+	result.InitramfsSize = info.Size()
+
+	if spec.Initramfs.Keep || spec.Initramfs.CacheDir != "" {
+		result.InitramfsPath = path
+	}
+
+	if spec.Qemu.Seed != nil {
+		rngSource, stopFn, err := startRNGFeed(*spec.Qemu.Seed)
+		if err != nil {
+			return err
+		}
+		defer stopFn() //nolint:errcheck
+
+		spec.Qemu.rngSource = rngSource
+	}
+
 	cmd, err := NewQemuCommand(ctx, spec.Qemu, path)
 	if err != nil {
 		return err
 	}
 
+	result.Command = cmd.Args()
+
+	if spec.CastFile != "" {
+		title := filepath.Base(spec.Initramfs.Binary)
+
+		castWriter, closeFn, err := createCastFile(spec.CastFile, title)
+		if err != nil {
+			return err
+		}
+		defer closeFn() //nolint:errcheck
+
+		stdout = io.MultiWriter(stdout, castWriter)
+	}
+
+	spec.Progress.report(ProgressStartQemu, 0)
+
 	err = cmd.Run(stdin, stdout, stderr)
+	result.setCompletion(cmd.Completion())
+	result.StderrTail = cmd.StderrTail()
+
+	if spec.DiskExtractDir != "" {
+		extractErr := spec.extractDisk()
+		if extractErr != nil {
+			// Do not mask the more relevant run error.
+			if err == nil {
+				return extractErr
+			}
+
+			slog.Error("Failed to extract disk", slog.Any("error", extractErr))
+		}
+	}
+
 	if err != nil {
 		return fmt.Errorf("qemu run: %w", err)
 	}
 
 	return nil
 }
+
+// prepareInitramfs verifies the sources and kernel modules and applies the
+// settings of the [Spec] that affect the [Initramfs] before it is built.
+func (s *Spec) prepareInitramfs() error {
+	err := s.Initramfs.verifySources()
+	if err != nil {
+		return err
+	}
+
+	err = s.verifyModules()
+	if err != nil {
+		return err
+	}
+
+	err = s.applyTimezone()
+	if err != nil {
+		return err
+	}
+
+	err = s.applyDiskMount()
+	if err != nil {
+		return err
+	}
+
+	s.applyShares()
+	s.applyNetwork()
+	s.applySysctls()
+	s.applyStdin()
+	s.applyWorkDir()
+
+	s.Initramfs.progress = s.Progress
+
+	return nil
+}
+
+// verifyModules verifies the kernel modules are built for the kernel, if
+// [Initramfs.VerifyModules] is set.
+func (s *Spec) verifyModules() error {
+	if !s.Initramfs.VerifyModules || len(s.Initramfs.Modules) == 0 {
+		return nil
+	}
+
+	version := s.Initramfs.KernelVersion
+	if version == "" {
+		var err error
+
+		version, err = sys.ReadKernelVersion(s.Qemu.Kernel)
+		if err != nil {
+			return fmt.Errorf("read kernel version: %w", err)
+		}
+	}
+
+	return verifyModuleVersions(version, s.Initramfs.Modules)
+}
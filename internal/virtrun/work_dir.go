@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/aibor/virtrun/sysinit"
+)
+
+// applyWorkDir sets [Initramfs.WorkDir] in [Initramfs.GuestConfig], so the
+// guest's init changes into it. A given guest config is copied, not modified.
+func (s *Spec) applyWorkDir() {
+	if s.Initramfs.WorkDir == "" {
+		return
+	}
+
+	guestCfg := sysinit.GuestConfig{}
+	if s.Initramfs.GuestConfig != nil {
+		guestCfg = *s.Initramfs.GuestConfig
+	}
+
+	guestCfg.WorkDir = s.Initramfs.WorkDir
+	s.Initramfs.GuestConfig = &guestCfg
+}
+
+// verifyWorkDir checks that the given absolute guest path is a directory in
+// the given file tree, possibly behind symbolic links. Otherwise, the guest's
+// init fails to change into it. The empty path is valid.
+func verifyWorkDir(fsys fs.FS, workDir string) error {
+	if workDir == "" {
+		return nil
+	}
+
+	if !path.IsAbs(workDir) {
+		return fmt.Errorf("%w: not absolute: %s", ErrWorkDirInvalid, workDir)
+	}
+
+	name := strings.TrimPrefix(path.Clean(workDir), "/")
+	if name == "" {
+		return nil
+	}
+
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWorkDirInvalid, err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%w: not a directory: %s", ErrWorkDirInvalid,
+			workDir)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package virtrun
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aibor/virtrun/sysinit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec_ApplyWorkDir(t *testing.T) {
+	t.Run("no work dir", func(t *testing.T) {
+		spec := &Spec{}
+		spec.applyWorkDir()
+
+		assert.Nil(t, spec.Initramfs.GuestConfig)
+	})
+
+	t.Run("work dir", func(t *testing.T) {
+		given := &sysinit.GuestConfig{
+			Stdin: "/stdin",
+		}
+
+		spec := &Spec{
+			Initramfs: Initramfs{
+				WorkDir:     "/data",
+				GuestConfig: given,
+			},
+		}
+
+		spec.applyWorkDir()
+
+		require.NotNil(t, spec.Initramfs.GuestConfig)
+		assert.Equal(t, "/data", spec.Initramfs.GuestConfig.WorkDir)
+		assert.Equal(t, "/stdin", spec.Initramfs.GuestConfig.Stdin)
+		assert.Empty(t, given.WorkDir, "given config must not be modified")
+	})
+}
+
+func TestVerifyWorkDir(t *testing.T) {
+	fsys := fstest.MapFS{
+		"data/fixtures": &fstest.MapFile{Mode: fs.ModeDir | 0o755},
+		"data/input":    &fstest.MapFile{Data: []byte("input")},
+	}
+
+	tests := []struct {
+		workDir     string
+		expectedErr error
+	}{
+		{
+			workDir: "",
+		},
+		{
+			workDir: "/",
+		},
+		{
+			workDir: "/data",
+		},
+		{
+			workDir: "/data/fixtures/",
+		},
+		{
+			workDir:     "data",
+			expectedErr: ErrWorkDirInvalid,
+		},
+		{
+			workDir:     "/missing",
+			expectedErr: ErrWorkDirInvalid,
+		},
+		{
+			workDir:     "/data/input",
+			expectedErr: ErrWorkDirInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.workDir, func(t *testing.T) {
+			err := verifyWorkDir(fsys, tt.workDir)
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
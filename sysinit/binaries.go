@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// ErrNoCommand is returned by [RunCommand] if no command is given.
+var ErrNoCommand = errors.New("no command given")
+
+// binaryUser is the credential [RunBinaries] runs the binaries with. It is set
+// by [Main] from [Config.User] once the system is set up, as the setup
+// requires root.
+var binaryUser *Credential
+
+// RunBinaries runs the given binaries one after another with the given
+// arguments. They are connected to the standard streams of the process.
+//
+// All binaries are run, even if one of them fails, so each reports its
+// results. The exit code of the first binary that fails is returned, or 0 if
+// all succeed. An error is returned right away if a binary can not be run at
+// all.
+//
+// If [Config.User] is set for [Main], the binaries are run with that
+// credential and without supplementary groups.
+func RunBinaries(paths []string, args []string) (int, error) {
+	exitCode := 0
+
+	for _, path := range paths {
+		code, err := runBinary(binaryCommand(path, args, binaryUser))
+		if err != nil {
+			return -1, err
+		}
+
+		if exitCode == 0 {
+			exitCode = code
+		}
+	}
+
+	return exitCode, nil
+}
+
+// RunCommand is the entry point for an init system that runs a single
+// command, given as argv with the executable first, instead of Go tests.
+//
+// It sets up the system like [Main], runs the command connected to the
+// standard streams of the process and waits for it. Its exit code is
+// communicated to the host and the system is shut down then. Like
+// [RunTests], it returns only in case of failure.
+//
+// If [Config.User] is set, the command is run with that credential like with
+// [RunBinaries].
+func RunCommand(cfg Config, argv []string) {
+	Main(cfg, func() (int, error) {
+		return runCommand(argv)
+	})
+}
+
+// runCommand runs the command given as argv and returns its exit code.
+func runCommand(argv []string) (int, error) {
+	if len(argv) == 0 {
+		return -1, ErrNoCommand
+	}
+
+	return runBinary(binaryCommand(argv[0], argv[1:], binaryUser))
+}
+
+// binaryCommand creates the command for running the binary at the given path.
+// If a user is given, the command drops the privileges to it before exec.
+func binaryCommand(path string, args []string, user *Credential) *exec.Cmd {
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if user != nil {
+		cmd.SysProcAttr = user.sysProcAttr()
+	}
+
+	return cmd
+}
+
+func runBinary(cmd *exec.Cmd) (int, error) {
+	var exitErr *exec.ExitError
+
+	err := cmd.Run()
+	if err != nil {
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+
+		return -1, fmt.Errorf("%s: %w", cmd.Path, err)
+	}
+
+	return 0, nil
+}
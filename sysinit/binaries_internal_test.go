@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeReturnScript(t *testing.T, dir, name, exitCode string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	content := "#!/bin/sh\necho " + name + " >> " +
+		filepath.Join(dir, "ran") + "\nexit " + exitCode + "\n"
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o755))
+
+	return path
+}
+
+func TestRunBinaries(t *testing.T) {
+	tests := []struct {
+		name         string
+		exitCodes    []string
+		missing      bool
+		expectedCode int
+		expectedRan  string
+		assertErr    require.ErrorAssertionFunc
+	}{
+		{
+			name:         "all succeed",
+			exitCodes:    []string{"0", "0"},
+			expectedRan:  "bin0\nbin1\n",
+			expectedCode: 0,
+			assertErr:    require.NoError,
+		},
+		{
+			name:         "second fails",
+			exitCodes:    []string{"0", "3"},
+			expectedRan:  "bin0\nbin1\n",
+			expectedCode: 3,
+			assertErr:    require.NoError,
+		},
+		{
+			name:         "first failure wins",
+			exitCodes:    []string{"4", "0", "3"},
+			expectedRan:  "bin0\nbin1\nbin2\n",
+			expectedCode: 4,
+			assertErr:    require.NoError,
+		},
+		{
+			name:         "missing binary",
+			exitCodes:    []string{"0"},
+			missing:      true,
+			expectedRan:  "bin0\n",
+			expectedCode: -1,
+			assertErr:    require.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			paths := make([]string, 0, len(tt.exitCodes)+1)
+
+			for idx, exitCode := range tt.exitCodes {
+				name := fmt.Sprintf("bin%d", idx)
+				paths = append(paths, writeReturnScript(t, dir, name, exitCode))
+			}
+
+			if tt.missing {
+				paths = append(paths, filepath.Join(dir, "missing"))
+			}
+
+			exitCode, err := RunBinaries(paths, nil)
+			tt.assertErr(t, err)
+			assert.Equal(t, tt.expectedCode, exitCode)
+
+			ran, err := os.ReadFile(filepath.Join(dir, "ran"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedRan, string(ran))
+		})
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("exit code propagated", func(t *testing.T) {
+		path := writeReturnScript(t, dir, "cmd", "7")
+
+		exitCode, err := runCommand([]string{path, "-v"})
+		require.NoError(t, err)
+		assert.Equal(t, 7, exitCode)
+
+		ran, err := os.ReadFile(filepath.Join(dir, "ran"))
+		require.NoError(t, err)
+		assert.Equal(t, "cmd\n", string(ran))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		path := writeReturnScript(t, dir, "ok", "0")
+
+		exitCode, err := runCommand([]string{path})
+		require.NoError(t, err)
+		assert.Equal(t, 0, exitCode)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		exitCode, err := runCommand([]string{filepath.Join(dir, "missing")})
+		require.ErrorIs(t, err, os.ErrNotExist)
+		assert.Equal(t, -1, exitCode)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		exitCode, err := runCommand(nil)
+		require.ErrorIs(t, err, ErrNoCommand)
+		assert.Equal(t, -1, exitCode)
+	})
+}
+
+func TestBinaryCommand(t *testing.T) {
+	t.Run("root", func(t *testing.T) {
+		cmd := binaryCommand("/bin/true", []string{"-v"}, nil)
+		assert.Equal(t, []string{"/bin/true", "-v"}, cmd.Args)
+		assert.Nil(t, cmd.SysProcAttr)
+	})
+
+	t.Run("user", func(t *testing.T) {
+		user := &Credential{UID: 1000, GID: 100}
+
+		cmd := binaryCommand("/bin/true", nil, user)
+		require.NotNil(t, cmd.SysProcAttr)
+		assert.Equal(t, user.sysProcAttr(), cmd.SysProcAttr)
+	})
+
+	t.Run("drops privileges", func(t *testing.T) {
+		if os.Getuid() != 0 {
+			t.Skip("requires root for changing the credential")
+		}
+
+		script := "id -u; id -g; id -G"
+		user := &Credential{UID: 65534, GID: 65533}
+
+		var stdout strings.Builder
+
+		cmd := binaryCommand("/bin/sh", []string{"-c", script}, user)
+		cmd.Stdout = &stdout
+
+		exitCode, err := runBinary(cmd)
+		require.NoError(t, err)
+		require.Equal(t, 0, exitCode)
+
+		// The supplementary groups only consist of the primary group, so
+		// root's groups are gone.
+		assert.Equal(t, "65534\n65533\n65533\n", stdout.String())
+	})
+}
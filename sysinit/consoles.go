@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ConsolesEnvVar is the environment variable virtrun sets to the named
+// consoles given for the run, as returned by [Consoles.String].
+const ConsolesEnvVar = "VIRTRUN_CONSOLES"
+
+var (
+	// ErrConsoleNotConfigured is returned if no console of the requested
+	// name is conveyed by virtrun.
+	ErrConsoleNotConfigured = errors.New("console not configured")
+
+	// ErrInvalidConsoles is returned if [Consoles] can not be parsed.
+	ErrInvalidConsoles = errors.New("invalid consoles")
+)
+
+// Consoles maps console names to their device paths in the guest, like
+// "trace" to "/dev/hvc1". Names must not contain ",", ":" or white space.
+type Consoles map[string]string
+
+// String returns the consoles as comma separated list of "NAME:DEVICE" pairs
+// in lexicographic order of the names.
+func (c Consoles) String() string {
+	pairs := make([]string, 0, len(c))
+
+	for name, device := range sortedByKeys(c) {
+		pairs = append(pairs, name+":"+device)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// parseConsoles parses [Consoles] as returned by [Consoles.String].
+func parseConsoles(s string) (Consoles, error) {
+	consoles := Consoles{}
+
+	for _, pair := range strings.Split(s, ",") {
+		name, device, found := strings.Cut(pair, ":")
+		if !found || name == "" || device == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidConsoles, pair)
+		}
+
+		consoles[name] = device
+	}
+
+	return consoles, nil
+}
+
+// ConsolePath returns the device path of the named console given for the run
+// by virtrun. Data written to it ends up in the host file given for the
+// console.
+//
+// The value is read from the environment variable [ConsolesEnvVar].
+func ConsolePath(name string) (string, error) {
+	value, exists := os.LookupEnv(ConsolesEnvVar)
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrConsoleNotConfigured, name)
+	}
+
+	consoles, err := parseConsoles(value)
+	if err != nil {
+		return "", fmt.Errorf("parse %s: %w", ConsolesEnvVar, err)
+	}
+
+	device, exists := consoles[name]
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrConsoleNotConfigured, name)
+	}
+
+	return device, nil
+}
@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsoles_String(t *testing.T) {
+	consoles := Consoles{
+		"trace":  "/dev/hvc2",
+		"events": "/dev/hvc1",
+	}
+
+	assert.Equal(t, "events:/dev/hvc1,trace:/dev/hvc2", consoles.String())
+}
+
+func TestConsolePath(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		unset       bool
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "found",
+			value:    "events:/dev/hvc1,trace:/dev/ttyS2",
+			expected: "/dev/ttyS2",
+		},
+		{
+			name:        "missing",
+			value:       "events:/dev/hvc1",
+			expectedErr: ErrConsoleNotConfigured,
+		},
+		{
+			name:        "not set",
+			unset:       true,
+			expectedErr: ErrConsoleNotConfigured,
+		},
+		{
+			name:        "invalid",
+			value:       "trace",
+			expectedErr: ErrInvalidConsoles,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ConsolesEnvVar, tt.value)
+
+			if tt.unset {
+				// Restored by t.Setenv on cleanup.
+				require.NoError(t, os.Unsetenv(ConsolesEnvVar))
+			}
+
+			actual, err := ConsolePath("trace")
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrInvalidCredential is returned if a credential can not be parsed.
+var ErrInvalidCredential = errors.New("invalid credential")
+
+// Credential is the user and group ID binaries are run with, see
+// [Config.User].
+type Credential struct {
+	UID uint32
+	GID uint32
+}
+
+// ParseCredential parses a credential in the format "UID:GID", like
+// "1000:1000".
+func ParseCredential(s string) (Credential, error) {
+	uid, gid, found := strings.Cut(s, ":")
+	if !found {
+		return Credential{}, fmt.Errorf("%w: %q: want UID:GID",
+			ErrInvalidCredential, s)
+	}
+
+	uidValue, err := strconv.ParseUint(uid, 10, 32)
+	if err != nil {
+		return Credential{}, fmt.Errorf("%w: uid: %w",
+			ErrInvalidCredential, err)
+	}
+
+	gidValue, err := strconv.ParseUint(gid, 10, 32)
+	if err != nil {
+		return Credential{}, fmt.Errorf("%w: gid: %w",
+			ErrInvalidCredential, err)
+	}
+
+	return Credential{UID: uint32(uidValue), GID: uint32(gidValue)}, nil
+}
+
+// String returns the credential in the format "UID:GID", as accepted by
+// [ParseCredential].
+func (c Credential) String() string {
+	return fmt.Sprintf("%d:%d", c.UID, c.GID)
+}
+
+// sysProcAttr returns the attributes for running a child process with the
+// credential.
+//
+// The child drops the privileges after fork and before exec. The Go runtime
+// calls setgroups(2) first, then setgid(2) and setuid(2) last, as the process
+// can not change its groups anymore once it dropped the user ID. The empty
+// group list clears the supplementary groups, so the child does not keep
+// root's groups.
+func (c Credential) sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Credential: &syscall.Credential{
+			Uid:         c.UID,
+			Gid:         c.GID,
+			Groups:      []uint32{},
+			NoSetGroups: false,
+		},
+	}
+}
@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCredential(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    Credential
+		expectedErr error
+	}{
+		{
+			name:     "valid",
+			value:    "1000:100",
+			expected: Credential{UID: 1000, GID: 100},
+		},
+		{
+			name:     "max",
+			value:    "4294967295:4294967295",
+			expected: Credential{UID: 4294967295, GID: 4294967295},
+		},
+		{
+			name:        "missing gid",
+			value:       "1000",
+			expectedErr: ErrInvalidCredential,
+		},
+		{
+			name:        "name instead of id",
+			value:       "nobody:1000",
+			expectedErr: strconv.ErrSyntax,
+		},
+		{
+			name:        "gid out of range",
+			value:       "1000:4294967296",
+			expectedErr: strconv.ErrRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ParseCredential(tt.value)
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, actual)
+
+			if err == nil {
+				assert.Equal(t, tt.value, actual.String())
+			}
+		})
+	}
+}
+
+func TestCredential_SysProcAttr(t *testing.T) {
+	attr := Credential{UID: 1000, GID: 100}.sysProcAttr()
+
+	expected := &syscall.Credential{
+		Uid: 1000,
+		Gid: 100,
+		// Must be empty but not nil, so the supplementary groups are
+		// cleared instead of inherited.
+		Groups:      []uint32{},
+		NoSetGroups: false,
+	}
+
+	assert.Equal(t, expected, attr.Credential)
+	assert.NotNil(t, attr.Credential.Groups)
+}
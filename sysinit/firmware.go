@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"fmt"
+	"os"
+)
+
+// firmwarePathParam is the kernel parameter file for a custom firmware search
+// path.
+const firmwarePathParam = "/sys/module/firmware_class/parameters/path"
+
+// SetFirmwarePath sets the custom firmware search path of the kernel's
+// firmware loader.
+//
+// The kernel searches this path before its default search paths. The sysfs
+// must be mounted at /sys.
+func SetFirmwarePath(dir string) error {
+	return setFirmwarePath(firmwarePathParam, dir)
+}
+
+func setFirmwarePath(paramFile, dir string) error {
+	const mode = 0o600
+
+	if err := os.WriteFile(paramFile, []byte(dir), mode); err != nil {
+		return fmt.Errorf("set firmware path: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetFirmwarePath(t *testing.T) {
+	paramFile := filepath.Join(t.TempDir(), "path")
+	require.NoError(t, os.WriteFile(paramFile, nil, 0o600))
+
+	err := setFirmwarePath(paramFile, "/lib/firmware")
+	require.NoError(t, err)
+
+	actual, err := os.ReadFile(paramFile)
+	require.NoError(t, err)
+	assert.Equal(t, "/lib/firmware", string(actual))
+}
+
+func TestSetFirmwarePath_NoLoader(t *testing.T) {
+	paramFile := filepath.Join(t.TempDir(), "missing", "path")
+
+	err := setFirmwarePath(paramFile, "/lib/firmware")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -13,6 +13,7 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 )
 
 // FSType is a file system type.
@@ -20,6 +21,7 @@ type FSType string
 
 // Special file system types.
 const (
+	FSType9P       FSType = "9p"
 	FSTypeBpf      FSType = "bpf"
 	FSTypeCgroup2  FSType = "cgroup2"
 	FSTypeConfig   FSType = "configfs"
@@ -29,6 +31,7 @@ const (
 	FSTypeFuseCtl  FSType = "fusectl"
 	FSTypeHugeTlb  FSType = "hugetlbfs"
 	FSTypeMqueue   FSType = "mqueue"
+	FSTypeOverlay  FSType = "overlay"
 	FSTypeProc     FSType = "proc"
 	FSTypePstore   FSType = "pstore"
 	FSTypeSecurity FSType = "securityfs"
@@ -39,26 +42,31 @@ const (
 	defaultDirMode = 0o755
 )
 
+// FirstDisk is the device of the first disk the host attaches to the guest as
+// virtio block device.
+const FirstDisk = "/dev/vda"
+
 // MountOptions contains parameters for a mount point.
 type MountOptions struct {
 	// FSType is the files system type. It must be set to an available [FSType].
-	FSType FSType
+	FSType FSType `json:"fsType"`
 
 	// Source is the source device to mount. Can be empty for all the special
 	// file system types [FSType]s. If empty it is set to the string of the
 	// type.
-	Source string
+	Source string `json:"source,omitempty"`
 
 	// Flags are optional mount flags as defined by mount(2).
-	Flags MountFlags
+	Flags MountFlags `json:"flags,omitempty"`
 
 	// Data are optional additional parameters that depend of the [FSType] used.
-	Data string
+	Data string `json:"data,omitempty"`
 
 	// MayFail determines if the mount operation may fail. If set to true, a
 	// mount error does not fail a [MountAll] operation. Instead, a warning is
-	// printed to stdout and the next mount point is tried.
-	MayFail bool
+	// printed to stdout and the next mount point is tried. The path is reported
+	// in [Completion.FailedMounts] by [Main].
+	MayFail bool `json:"mayFail,omitempty"`
 }
 
 // Mount mounts the system file system of [FSType] at the given path.
@@ -74,24 +82,83 @@ func Mount(path string, opts MountOptions) error {
 	return mount(path, opts.Source, string(opts.FSType), opts.Flags, opts.Data)
 }
 
+// ShareMountOptions returns the [MountOptions] for mounting the virtio 9p share
+// with the given tag, as set up by the host.
+func ShareMountOptions(tag string, readOnly bool) MountOptions {
+	opts := MountOptions{
+		FSType: FSType9P,
+		Source: tag,
+		Data:   "trans=virtio,version=9p2000.L",
+	}
+
+	if readOnly {
+		opts.Flags = MountFlagReadOnly
+	}
+
+	return opts
+}
+
+// DiskMountOptions returns the [MountOptions] for mounting the file system of
+// the given type on [FirstDisk], as set up by the host.
+func DiskMountOptions(fsType FSType, readOnly bool) MountOptions {
+	opts := MountOptions{
+		FSType: fsType,
+		Source: FirstDisk,
+	}
+
+	if readOnly {
+		opts.Flags = MountFlagReadOnly
+	}
+
+	return opts
+}
+
+// hasDeviceSource returns true if the source is a device file.
+func (o *MountOptions) hasDeviceSource() bool {
+	return strings.HasPrefix(o.Source, "/dev/")
+}
+
 // MountPoints is a collection of MountPoints.
 type MountPoints map[string]MountOptions
 
 // MountAll mounts the given set of system file systems.
 //
-// The mounts are executed in lexicographic order of the paths.
+// The mounts are executed in lexicographic order of the paths. Mount points
+// with a device source, like [FirstDisk], are mounted after all others, so the
+// device file system is available, even if it is mounted at a later path.
 func MountAll(mountPoints MountPoints) error {
-	for path, opts := range sortedByKeys(mountPoints) {
-		if err := Mount(path, opts); err != nil {
-			if !opts.MayFail {
-				return err
+	_, err := mountAll(mountPoints, Mount)
+
+	return err
+}
+
+// mountAll mounts the given mount points using the given mount function. It
+// returns the paths of the mount points that failed but may fail.
+func mountAll(
+	mountPoints MountPoints,
+	mountFn func(string, MountOptions) error,
+) ([]string, error) {
+	var failed []string
+
+	for _, devices := range []bool{false, true} {
+		for path, opts := range sortedByKeys(mountPoints) {
+			if opts.hasDeviceSource() != devices {
+				continue
 			}
 
-			PrintWarning(err)
+			if err := mountFn(path, opts); err != nil {
+				if !opts.MayFail {
+					return failed, err
+				}
+
+				PrintWarning(err)
+
+				failed = append(failed, path)
+			}
 		}
 	}
 
-	return nil
+	return failed, nil
 }
 
 // Symlinks is a collection of symbolic links. Keys are symbolic links to
@@ -5,9 +5,11 @@
 package sysinit
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSortedByKeys(t *testing.T) {
@@ -69,3 +71,124 @@ func TestSortedByKeys(t *testing.T) {
 		})
 	}
 }
+
+func TestShareMountOptions(t *testing.T) {
+	assert.Equal(t, MountOptions{
+		FSType: FSType9P,
+		Source: "fixtures",
+		Data:   "trans=virtio,version=9p2000.L",
+	}, ShareMountOptions("fixtures", false))
+
+	assert.Equal(t, MountOptions{
+		FSType: FSType9P,
+		Source: "golden",
+		Flags:  MountFlagReadOnly,
+		Data:   "trans=virtio,version=9p2000.L",
+	}, ShareMountOptions("golden", true))
+}
+
+func TestMountAll(t *testing.T) {
+	errMount := errors.New("no such device")
+
+	mountPoints := MountPoints{
+		"/proc":              {FSType: FSTypeProc},
+		"/sys/fs/bpf":        {FSType: FSTypeBpf, MayFail: true},
+		"/sys/kernel/debug":  {FSType: FSTypeDebug, MayFail: true},
+		"/sys/kernel/config": {FSType: FSTypeConfig, MayFail: true},
+	}
+
+	tests := []struct {
+		name           string
+		unsupported    []FSType
+		expectedFailed []string
+		expectedErr    error
+	}{
+		{
+			name: "all mounted",
+		},
+		{
+			name:           "optional mounts failed",
+			unsupported:    []FSType{FSTypeBpf, FSTypeDebug},
+			expectedFailed: []string{"/sys/fs/bpf", "/sys/kernel/debug"},
+		},
+		{
+			name:        "required mount failed",
+			unsupported: []FSType{FSTypeProc},
+			expectedErr: errMount,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mountFn := func(_ string, opts MountOptions) error {
+				for _, fsType := range tt.unsupported {
+					if opts.FSType == fsType {
+						return errMount
+					}
+				}
+
+				return nil
+			}
+
+			failed, err := mountAll(mountPoints, mountFn)
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedFailed, failed)
+		})
+	}
+}
+
+func TestMountAll_DeviceSourcesLast(t *testing.T) {
+	mountPoints := MountPoints{
+		"/data": DiskMountOptions("ext4", false),
+		"/dev":  {FSType: FSTypeDevTmp},
+		"/proc": {FSType: FSTypeProc},
+	}
+
+	var actual []string
+
+	mountFn := func(path string, _ MountOptions) error {
+		actual = append(actual, path)
+		return nil
+	}
+
+	_, err := mountAll(mountPoints, mountFn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/dev", "/proc", "/data"}, actual)
+}
+
+func TestDiskMountOptions(t *testing.T) {
+	assert.Equal(t, MountOptions{
+		FSType: "ext4",
+		Source: "/dev/vda",
+	}, DiskMountOptions("ext4", false))
+	assert.Equal(t, MountOptions{
+		FSType: "ext4",
+		Source: "/dev/vda",
+		Flags:  MountFlagReadOnly,
+	}, DiskMountOptions("ext4", true))
+}
+
+func TestMountAll_Options(t *testing.T) {
+	mountPoints := MountPoints{
+		"/mnt/ro": {
+			FSType: FSTypeTmp,
+			Flags:  MountFlagReadOnly | MountFlagNoExec,
+		},
+		"/mnt/data": {
+			FSType: FSType9P,
+			Source: "data",
+			Data:   "trans=virtio",
+		},
+	}
+
+	actual := MountPoints{}
+
+	mountFn := func(path string, opts MountOptions) error {
+		actual[path] = opts
+		return nil
+	}
+
+	_, err := mountAll(mountPoints, mountFn)
+	require.NoError(t, err)
+	assert.Equal(t, mountPoints, actual)
+}
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"time"
+)
+
+// GuestConfigPath is the path of the [GuestConfig] file in the guest. The host
+// adds it to the initramfs.
+const GuestConfigPath = "/etc/virtrun.json"
+
+// GuestConfig is the guest side configuration that is read from
+// [GuestConfigPath] on init. Unlike init flags, it is not limited by the
+// kernel command line. All values are merged into the [Config].
+type GuestConfig struct {
+	// Mounts are added to [Config.MountPoints]. Mount points with the same
+	// path are replaced.
+	Mounts MountPoints `json:"mounts,omitempty"`
+
+	// Symlinks are added to [Config.Symlinks]. Links with the same path are
+	// replaced.
+	Symlinks Symlinks `json:"symlinks,omitempty"`
+
+	// Env is added to [Config.Env].
+	Env EnvVars `json:"env,omitempty"`
+
+	// Sysctls are added to [Config.Sysctls].
+	Sysctls map[string]string `json:"sysctls,omitempty"`
+
+	// SetupStepTimeout sets [Config.SetupStepTimeout], if not empty. It is
+	// given as duration string, like "10s".
+	SetupStepTimeout string `json:"setupStepTimeout,omitempty"`
+
+	// Stdin sets [Config.Stdin], if not empty.
+	Stdin string `json:"stdin,omitempty"`
+
+	// WorkDir sets [Config.WorkDir], if not empty.
+	WorkDir string `json:"workDir,omitempty"`
+
+	// EthInterface sets [Config.EthInterface], if not nil.
+	EthInterface *EthInterface `json:"ethInterface,omitempty"`
+}
+
+// ReadGuestConfig reads the [GuestConfig] from the JSON file at the given
+// path.
+func ReadGuestConfig(path string) (GuestConfig, error) {
+	var guestCfg GuestConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return guestCfg, fmt.Errorf("read guest config: %w", err)
+	}
+
+	err = json.Unmarshal(data, &guestCfg)
+	if err != nil {
+		return guestCfg, fmt.Errorf("parse guest config: %w", err)
+	}
+
+	return guestCfg, nil
+}
+
+// apply merges the guest config into the given config.
+func (g *GuestConfig) apply(cfg *Config) error {
+	if g.SetupStepTimeout != "" {
+		timeout, err := time.ParseDuration(g.SetupStepTimeout)
+		if err != nil {
+			return fmt.Errorf("guest config setup step timeout: %w", err)
+		}
+
+		cfg.SetupStepTimeout = timeout
+	}
+
+	if g.Stdin != "" {
+		cfg.Stdin = g.Stdin
+	}
+
+	if g.WorkDir != "" {
+		cfg.WorkDir = g.WorkDir
+	}
+
+	if g.EthInterface != nil {
+		cfg.EthInterface = g.EthInterface
+	}
+
+	cfg.MountPoints = mergeMap(cfg.MountPoints, g.Mounts)
+	cfg.Symlinks = mergeMap(cfg.Symlinks, g.Symlinks)
+	cfg.Env = mergeMap(cfg.Env, g.Env)
+	cfg.Sysctls = mergeMap(cfg.Sysctls, g.Sysctls)
+
+	return nil
+}
+
+// loadGuestConfig reads the [GuestConfig] at the given path and merges it
+// into the given config. A missing file is not an error, as the host only
+// adds it if there is anything to configure.
+func loadGuestConfig(cfg *Config, path string) error {
+	guestCfg, err := ReadGuestConfig(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	return guestCfg.apply(cfg)
+}
+
+// mergeMap returns a new map with all entries of dst and src. Entries of src
+// replace the ones of dst with the same key. If src is empty, dst is returned
+// as is.
+func mergeMap[M ~map[K]V, K comparable, V any](dst, src M) M {
+	if len(src) == 0 {
+		return dst
+	}
+
+	merged := make(M, len(dst)+len(src))
+	maps.Copy(merged, dst)
+	maps.Copy(merged, src)
+
+	return merged
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadGuestConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expected    Config
+		errContains string
+	}{
+		{
+			name: "no file",
+			expected: Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+				Env:         EnvVars{"A": "1"},
+			},
+		},
+		{
+			name: "sample config",
+			content: `{
+				"mounts": {
+					"/mnt": {"fsType": "tmpfs", "data": "size=1M"},
+					"/proc": {"fsType": "proc", "mayFail": true}
+				},
+				"symlinks": {"/dev/foo": "bar"},
+				"env": {"A": "2", "B": "with \"quotes\""},
+				"sysctls": {"vm.overcommit_memory": "1"},
+				"setupStepTimeout": "10s",
+				"stdin": "/stdin",
+				"workDir": "/data",
+				"ethInterface": {
+					"name": "eth0",
+					"address": "10.0.2.15/24",
+					"gateway": "10.0.2.2",
+					"nameserver": ""
+				}
+			}`,
+			expected: Config{
+				MountPoints: MountPoints{
+					"/mnt":  {FSType: FSTypeTmp, Data: "size=1M"},
+					"/proc": {FSType: FSTypeProc, MayFail: true},
+				},
+				Symlinks: Symlinks{"/dev/foo": "bar"},
+				Env:      EnvVars{"A": "2", "B": `with "quotes"`},
+				Sysctls: map[string]string{
+					"vm.overcommit_memory": "1",
+				},
+				SetupStepTimeout: 10 * time.Second,
+				Stdin:            "/stdin",
+				WorkDir:          "/data",
+				EthInterface: &EthInterface{
+					Name:    "eth0",
+					Address: netip.MustParsePrefix("10.0.2.15/24"),
+					Gateway: netip.MustParseAddr("10.0.2.2"),
+				},
+			},
+		},
+		{
+			name:        "invalid json",
+			content:     `{"mounts": [}`,
+			errContains: "parse guest config",
+		},
+		{
+			name:        "invalid timeout",
+			content:     `{"setupStepTimeout": "soon"}`,
+			errContains: "setup step timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "virtrun.json")
+
+			if tt.content != "" {
+				err := os.WriteFile(path, []byte(tt.content), 0o600)
+				require.NoError(t, err)
+			}
+
+			cfg := Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+				Env:         EnvVars{"A": "1"},
+			}
+
+			err := loadGuestConfig(&cfg, path)
+			if tt.errContains != "" {
+				assert.ErrorContains(t, err, tt.errContains)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, cfg)
+		})
+	}
+}
+
+func TestMergeMap_DoesNotModifyDst(t *testing.T) {
+	dst := EnvVars{"A": "1"}
+
+	merged := mergeMap(dst, EnvVars{"A": "2", "B": "3"})
+
+	assert.Equal(t, EnvVars{"A": "2", "B": "3"}, merged)
+	assert.Equal(t, EnvVars{"A": "1"}, dst)
+}
@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Init flags can be set from the host via the kernel command line. The kernel
+// passes them as environment variables to the init program. They are enabled
+// with the value "1".
+const (
+	// VerboseEnvVar enables printing each setup step, see [Config.Verbose].
+	VerboseEnvVar = "SYSINIT_VERBOSE"
+
+	// SkipMountsEnvVar disables mounting [Config.MountPoints].
+	SkipMountsEnvVar = "SYSINIT_SKIP_MOUNTS"
+
+	// BootMarkerEnvVar enables printing the [BootMarker], see
+	// [Config.PrintBootMarker].
+	BootMarkerEnvVar = "SYSINIT_BOOT_MARKER"
+
+	// OverlayRootEnvVar enables the overlay root file system, see
+	// [Config.OverlayRoot].
+	OverlayRootEnvVar = "SYSINIT_OVERLAY_ROOT"
+
+	// ReportResourcesEnvVar enables reporting the resources left once the
+	// main function is done, see [Config.ReportResources].
+	ReportResourcesEnvVar = "SYSINIT_REPORT_RESOURCES"
+
+	// SetupStepTimeoutEnvVar sets [Config.SetupStepTimeout]. Unlike the
+	// other flags, its value is a duration, like "10s".
+	SetupStepTimeoutEnvVar = "SYSINIT_SETUP_STEP_TIMEOUT"
+
+	// PoweroffEnvVar sets [Config.ShutdownMethod] to [ShutdownPoweroff].
+	PoweroffEnvVar = "SYSINIT_POWEROFF"
+
+	// UserEnvVar sets [Config.User]. Its value is a credential in the format
+	// "UID:GID", see [ParseCredential].
+	UserEnvVar = "SYSINIT_USER"
+
+	// PanicBehaviorEnvVar sets [Config.PanicBehavior]. Its value is "report"
+	// or "panic", see [ParsePanicBehavior].
+	PanicBehaviorEnvVar = "SYSINIT_PANIC_BEHAVIOR"
+
+	// OutputFilesEnvVar sets [Config.OutputFiles]. Its value is a list of
+	// "GUEST:DEVICE" pairs, see [ParseOutputFiles].
+	OutputFilesEnvVar = "SYSINIT_OUTPUT_FILES"
+)
+
+// applyInitFlags applies the init flags found by the lookup function to the
+// config.
+func applyInitFlags(cfg *Config, lookup func(string) (string, bool)) {
+	if value, _ := lookup(VerboseEnvVar); value == "1" {
+		cfg.Verbose = true
+	}
+
+	if value, _ := lookup(SkipMountsEnvVar); value == "1" {
+		cfg.MountPoints = nil
+	}
+
+	if value, _ := lookup(BootMarkerEnvVar); value == "1" {
+		cfg.PrintBootMarker = true
+	}
+
+	if value, _ := lookup(OverlayRootEnvVar); value == "1" {
+		cfg.OverlayRoot = true
+	}
+
+	if value, _ := lookup(ReportResourcesEnvVar); value == "1" {
+		cfg.ReportResources = true
+	}
+
+	if value, _ := lookup(PoweroffEnvVar); value == "1" {
+		cfg.ShutdownMethod = ShutdownPoweroff
+	}
+
+	if value, exists := lookup(SetupStepTimeoutEnvVar); exists {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			PrintWarning(fmt.Errorf("%s: %w", SetupStepTimeoutEnvVar, err))
+		} else {
+			cfg.SetupStepTimeout = timeout
+		}
+	}
+
+	if value, exists := lookup(UserEnvVar); exists {
+		user, err := ParseCredential(value)
+		if err != nil {
+			PrintWarning(fmt.Errorf("%s: %w", UserEnvVar, err))
+		} else {
+			cfg.User = &user
+		}
+	}
+
+	if value, exists := lookup(PanicBehaviorEnvVar); exists {
+		behavior, err := ParsePanicBehavior(value)
+		if err != nil {
+			PrintWarning(fmt.Errorf("%s: %w", PanicBehaviorEnvVar, err))
+		} else {
+			cfg.PanicBehavior = behavior
+		}
+	}
+
+	if value, exists := lookup(OutputFilesEnvVar); exists {
+		files, err := ParseOutputFiles(value)
+		if err != nil {
+			PrintWarning(fmt.Errorf("%s: %w", OutputFilesEnvVar, err))
+		} else {
+			cfg.OutputFiles = files
+		}
+	}
+}
+
+// printVerbose prints the given message to stderr if verbose output is
+// enabled in the config.
+func (cfg *Config) printVerbose(format string, a ...any) {
+	if !cfg.Verbose {
+		return
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "sysinit: "+format+"\n", a...)
+}
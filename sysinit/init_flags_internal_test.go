@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyInitFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected Config
+	}{
+		{
+			name: "none",
+			expected: Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+			},
+		},
+		{
+			name: "verbose",
+			env: map[string]string{
+				VerboseEnvVar: "1",
+			},
+			expected: Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+				Verbose:     true,
+			},
+		},
+		{
+			name: "skip mounts",
+			env: map[string]string{
+				SkipMountsEnvVar: "1",
+			},
+			expected: Config{},
+		},
+		{
+			name: "boot marker",
+			env: map[string]string{
+				BootMarkerEnvVar: "1",
+			},
+			expected: Config{
+				MountPoints:     MountPoints{"/proc": {FSType: FSTypeProc}},
+				PrintBootMarker: true,
+			},
+		},
+		{
+			name: "overlay root",
+			env: map[string]string{
+				OverlayRootEnvVar: "1",
+			},
+			expected: Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+				OverlayRoot: true,
+			},
+		},
+		{
+			name: "report resources",
+			env: map[string]string{
+				ReportResourcesEnvVar: "1",
+			},
+			expected: Config{
+				MountPoints:     MountPoints{"/proc": {FSType: FSTypeProc}},
+				ReportResources: true,
+			},
+		},
+		{
+			name: "poweroff",
+			env: map[string]string{
+				PoweroffEnvVar: "1",
+			},
+			expected: Config{
+				MountPoints:    MountPoints{"/proc": {FSType: FSTypeProc}},
+				ShutdownMethod: ShutdownPoweroff,
+			},
+		},
+		{
+			name: "setup step timeout",
+			env: map[string]string{
+				SetupStepTimeoutEnvVar: "5s",
+			},
+			expected: Config{
+				MountPoints:      MountPoints{"/proc": {FSType: FSTypeProc}},
+				SetupStepTimeout: 5 * time.Second,
+			},
+		},
+		{
+			name: "invalid setup step timeout",
+			env: map[string]string{
+				SetupStepTimeoutEnvVar: "soon",
+			},
+			expected: Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+			},
+		},
+		{
+			name: "user",
+			env: map[string]string{
+				UserEnvVar: "1000:100",
+			},
+			expected: Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+				User:        &Credential{UID: 1000, GID: 100},
+			},
+		},
+		{
+			name: "invalid user",
+			env: map[string]string{
+				UserEnvVar: "nobody",
+			},
+			expected: Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+			},
+		},
+		{
+			name: "panic behavior",
+			env: map[string]string{
+				PanicBehaviorEnvVar: "report",
+			},
+			expected: Config{
+				MountPoints:   MountPoints{"/proc": {FSType: FSTypeProc}},
+				PanicBehavior: PanicBehaviorReport,
+			},
+		},
+		{
+			name: "invalid panic behavior",
+			env: map[string]string{
+				PanicBehaviorEnvVar: "ignore",
+			},
+			expected: Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+			},
+		},
+		{
+			name: "output files",
+			env: map[string]string{
+				OutputFilesEnvVar: "/tmp/a.out:/dev/hvc1,/tmp/b.out:/dev/hvc2",
+			},
+			expected: Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+				OutputFiles: OutputFiles{
+					"/tmp/a.out": "/dev/hvc1",
+					"/tmp/b.out": "/dev/hvc2",
+				},
+			},
+		},
+		{
+			name: "invalid output files",
+			env: map[string]string{
+				OutputFilesEnvVar: "/tmp/a.out",
+			},
+			expected: Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+			},
+		},
+		{
+			name: "disabled",
+			env: map[string]string{
+				VerboseEnvVar:    "0",
+				SkipMountsEnvVar: "",
+			},
+			expected: Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				MountPoints: MountPoints{"/proc": {FSType: FSTypeProc}},
+			}
+
+			applyInitFlags(&cfg, func(key string) (string, bool) {
+				value, exists := tt.env[key]
+				return value, exists
+			})
+
+			assert.Equal(t, tt.expected, cfg)
+		})
+	}
+}
@@ -6,11 +6,21 @@ package sysinit
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 )
 
-// ErrNotPidOne may be returned if the process is expected to be run as PID 1
-// but is not.
-var ErrNotPidOne = errors.New("process does not have ID 1")
+var (
+	// ErrNotPidOne may be returned if the process is expected to be run as
+	// PID 1 but is not.
+	ErrNotPidOne = errors.New("process does not have ID 1")
+
+	// ErrSetupStepTimeout is returned if a setup step does not finish within
+	// [Config.SetupStepTimeout].
+	ErrSetupStepTimeout = errors.New("setup step timed out")
+)
 
 // IsPidOne returns true if the running process has PID 1.
 func IsPidOne() bool {
@@ -23,21 +33,6 @@ func IsPidOneChild() bool {
 	return getppid() == 1
 }
 
-// Poweroff shuts down the system.
-//
-// Call when done, or deferred right at the beginning of your `TestMain`
-// function.
-func Poweroff() {
-	// Silence the kernel so it does not show up in our test output.
-	_ = sysctl("kernel/printk", "0")
-
-	// Use restart instead of poweroff for shutting down the system since it
-	// does not require ACPI. The guest system should be started with noreboot.
-	if err := reboot(); err != nil {
-		PrintError(err)
-	}
-}
-
 // EnvVars is a map of environment variable values by name.
 type EnvVars map[string]string
 
@@ -59,25 +54,107 @@ type Config struct {
 	// init.
 	ConfigureLoopback bool
 
+	// EthInterface is the ethernet interface configured on init, if set. See
+	// [ConfigureEthInterface].
+	EthInterface *EthInterface
+
+	// OverlayRoot enables replacing the root file system with an overlay
+	// file system, so the initramfs content is immutable while writes go to a
+	// fresh tmpfs. See [OverlayRoot].
+	OverlayRoot bool
+
+	// Verbose enables printing each setup step to stderr. Intended for
+	// debugging the init phase itself.
+	Verbose bool
+
+	// PrintBootMarker enables printing the [BootMarker] once the setup is
+	// done.
+	PrintBootMarker bool
+
+	// ReportResources enables counting the processes and file descriptors
+	// left once the main function is done and reporting them to the host in
+	// the [Completion]. See [CountResources].
+	ReportResources bool
+
 	// ModulesDir defines the directory that contains kernel modules. They are
 	// load on init automatically.
 	ModulesDir string
+
+	// SetupStepTimeout is the maximum duration of each setup step, like
+	// mounting the file systems or loading the modules. If a step takes
+	// longer, the init aborts with [ErrSetupStepTimeout] naming the step.
+	// Zero disables the timeout.
+	SetupStepTimeout time.Duration
+
+	// Sysctls is a set of kernel parameters that are set once the file
+	// systems are mounted. Keys use the dotted notation, like
+	// "vm.overcommit_memory".
+	Sysctls map[string]string
+
+	// PoweroffTimeout is the timeout for each attempt to shut down the
+	// system once done. See [PoweroffWithTimeout]. Zero uses
+	// [DefaultPoweroffTimeout].
+	PoweroffTimeout time.Duration
+
+	// ShutdownMethod defines how the system is shut down once done. The
+	// default is [ShutdownRestart].
+	ShutdownMethod ShutdownMethod
+
+	// FirmwareDir defines an additional directory the kernel searches for
+	// firmware files requested by device drivers. If the kernel has no
+	// firmware loader, a warning is printed.
+	FirmwareDir string
+
+	// Stdin is the path of a file that replaces the standard input of the
+	// process once the system is set up. So the main function and any
+	// binaries it runs read the file's content followed by EOF, instead of
+	// the console.
+	Stdin string
+
+	// WorkDir is the directory the process changes into once the system is
+	// set up. So the main function and any binaries it runs use it as
+	// working directory, instead of "/".
+	WorkDir string
+
+	// User is the credential binaries run by [RunBinaries] are run with,
+	// instead of root. Only the binaries drop their privileges. The init
+	// itself keeps running as root, as it must be able to shut down the
+	// system. Nil runs the binaries as root.
+	User *Credential
+
+	// PanicBehavior defines how kernel oopses are handled, so they are not
+	// missed in the output. The default is [PanicBehaviorNone].
+	PanicBehavior PanicBehavior
+
+	// OutputFiles are copied to their console devices once the main function
+	// returned, so the host receives them. If copying fails, the run fails,
+	// unless the main function failed already. Then, only a warning is
+	// printed.
+	OutputFiles OutputFiles
 }
 
 // DefaultConfig creates a new default config.
 func DefaultConfig() Config {
+	// File systems any user can write to must not be usable to gain
+	// privileges or access devices.
+	const hardened = MountFlagNoSuid | MountFlagNoDev
+
 	return Config{
 		// All special file systems required for usual operations, like
 		// accessing kernel variables, modifying kernel knobs or accessing
 		// devices.
 		MountPoints: MountPoints{
-			"/dev":                     {FSType: FSTypeDevTmp},
-			"/dev/hugepages":           {FSType: FSTypeHugeTlb, MayFail: true},
-			"/dev/mqueue":              {FSType: FSTypeMqueue, MayFail: true},
-			"/dev/pts":                 {FSType: FSTypeDevPts, MayFail: true},
-			"/dev/shm":                 {FSType: FSTypeTmp, MayFail: true},
+			"/dev":           {FSType: FSTypeDevTmp},
+			"/dev/hugepages": {FSType: FSTypeHugeTlb, MayFail: true},
+			"/dev/mqueue":    {FSType: FSTypeMqueue, MayFail: true},
+			"/dev/pts":       {FSType: FSTypeDevPts, MayFail: true},
+			"/dev/shm": {
+				FSType:  FSTypeTmp,
+				Flags:   hardened,
+				MayFail: true,
+			},
 			"/proc":                    {FSType: FSTypeProc},
-			"/run":                     {FSType: FSTypeTmp},
+			"/run":                     {FSType: FSTypeTmp, Flags: hardened},
 			"/sys/fs/bpf":              {FSType: FSTypeBpf, MayFail: true},
 			"/sys/fs/cgroup":           {FSType: FSTypeCgroup2, MayFail: true},
 			"/sys/fs/fuse/connections": {FSType: FSTypeFuseCtl, MayFail: true},
@@ -87,7 +164,7 @@ func DefaultConfig() Config {
 			"/sys/kernel/debug":        {FSType: FSTypeDebug, MayFail: true},
 			"/sys/kernel/security":     {FSType: FSTypeSecurity, MayFail: true},
 			"/sys/kernel/tracing":      {FSType: FSTypeTracing, MayFail: true},
-			"/tmp":                     {FSType: FSTypeTmp},
+			"/tmp":                     {FSType: FSTypeTmp, Flags: hardened},
 		},
 		Symlinks: Symlinks{
 			"/dev/core":   "/proc/kcore",
@@ -99,6 +176,7 @@ func DefaultConfig() Config {
 		},
 		Env:               EnvVars{},
 		ConfigureLoopback: true,
+		PoweroffTimeout:   DefaultPoweroffTimeout,
 	}
 }
 
@@ -107,11 +185,20 @@ func DefaultConfig() Config {
 // It sets up the system and ensures proper shut down. Preparation steps are:
 // - Guarding itself to be actually PID 1.
 // - Setup system poweroff (on function termination!).
+// - Replace the root file system with an overlay, if enabled.
 // - Load additional kernel modules.
 // - Mount all known virtual system file systems.
 // - Add well known symlinks in /dev.
+// - Set kernel parameters.
+// - Set firmware search path.
 // - Bring loopback interface up.
+// - Configure the ethernet interface, if any.
 // - Set environment variables.
+// - Change into the working directory, if set.
+//
+// The [GuestConfig] at [GuestConfigPath] is merged into the config before, if
+// present. Init flags given by the host via the kernel command line, like
+// [VerboseEnvVar], are applied after it.
 //
 // Once this is done, the given function is run. The function must not
 // terminate the process itself (by calling [os.Exit] or panicking)! Otherwise
@@ -123,7 +210,11 @@ func DefaultConfig() Config {
 // the given function is used, unless it returned with an error. It is ensured
 // that in case of any error a noon-zero exit code is sent (-1).
 func Main(cfg Config, fn func() (int, error)) {
-	exitCode, err := main(cfg, fn)
+	start := time.Now()
+
+	completion := Completion{}
+	exitCode, err := main(&cfg, &completion, fn)
+
 	if err != nil {
 		// Always print the error before printing the exit code, since
 		// output processing stops once exit code line is found and we want
@@ -135,6 +226,8 @@ func Main(cfg Config, fn func() (int, error)) {
 			exitCode = -1
 		}
 
+		completion.Error = err.Error()
+
 		// If this is not the init system, exit the process without shutting
 		// down the system.
 		if errors.Is(err, ErrNotPidOne) {
@@ -142,44 +235,200 @@ func Main(cfg Config, fn func() (int, error)) {
 		}
 	}
 
+	completion.ExitCode = exitCode
+	completion.Duration = time.Since(start)
+
+	if cfg.ReportResources {
+		resources, err := CountResources()
+		if err != nil {
+			PrintWarning(fmt.Errorf("count resources: %w", err))
+		} else {
+			completion.Resources = &resources
+		}
+	}
+
+	PrintCompletion(completion)
 	PrintExitCode(exitCode)
-	Poweroff()
+	Shutdown(cfg.ShutdownMethod, cfg.PoweroffTimeout)
 }
 
-func main(cfg Config, fn func() (int, error)) (int, error) {
+func main(
+	cfg *Config,
+	completion *Completion,
+	fn func() (int, error),
+) (int, error) {
 	if !IsPidOne() {
 		return -2, ErrNotPidOne
 	}
 
+	if err := loadGuestConfig(cfg, GuestConfigPath); err != nil {
+		return -1, err
+	}
+
+	applyInitFlags(cfg, os.LookupEnv)
+
 	// Setup the system.
-	if err := setup(cfg); err != nil {
+	if err := setup(*cfg, completion); err != nil {
 		return -1, err
 	}
 
-	return fn()
+	if cfg.Stdin != "" {
+		cfg.printVerbose("redirect stdin from %s", cfg.Stdin)
+
+		if err := redirectStdin(cfg.Stdin); err != nil {
+			return -1, err
+		}
+	}
+
+	if cfg.WorkDir != "" {
+		cfg.printVerbose("change working directory to %s", cfg.WorkDir)
+
+		if err := os.Chdir(cfg.WorkDir); err != nil {
+			return -1, fmt.Errorf("change working directory: %w", err)
+		}
+	}
+
+	if cfg.User != nil {
+		cfg.printVerbose("run binaries as %s", cfg.User)
+
+		binaryUser = cfg.User
+	}
+
+	oops := oopsDetector{readTainted: readTainted}
+
+	switch cfg.PanicBehavior {
+	case PanicBehaviorNone:
+	case PanicBehaviorReport:
+		cfg.printVerbose("report kernel oopses")
+
+		if err := oops.start(); err != nil {
+			return -1, err
+		}
+	case PanicBehaviorPanic:
+		cfg.printVerbose("panic on kernel oopses")
+
+		if err := sysctl("kernel/panic_on_oops", "1"); err != nil {
+			return -1, err
+		}
+	default:
+		return -1, fmt.Errorf("%w: %q",
+			ErrInvalidPanicBehavior, cfg.PanicBehavior)
+	}
+
+	if cfg.PrintBootMarker {
+		PrintBootMarker()
+	}
+
+	exitCode, err := fn()
+
+	if len(cfg.OutputFiles) > 0 {
+		cfg.printVerbose("copy %d output files", len(cfg.OutputFiles))
+
+		copyErr := CopyOutputFiles(cfg.OutputFiles)
+		switch {
+		case copyErr == nil:
+		case err == nil && exitCode == 0:
+			exitCode, err = -1, copyErr
+		default:
+			PrintWarning(copyErr)
+		}
+	}
+
+	if cfg.PanicBehavior == PanicBehaviorReport {
+		return oops.check(os.Stdout, exitCode, err)
+	}
+
+	return exitCode, err
 }
 
-func setup(cfg Config) error {
+func setup(cfg Config, completion *Completion) error {
+	// Must be first, so no other mount is hidden by the new root.
+	if cfg.OverlayRoot {
+		cfg.printVerbose("set up overlay root in %s", DefaultOverlayDir)
+
+		err := cfg.runStep("overlay root", func() error {
+			return OverlayRoot(DefaultOverlayDir)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	if cfg.ModulesDir != "" {
-		if err := LoadModules(cfg.ModulesDir); err != nil {
+		cfg.printVerbose("load modules from %s", cfg.ModulesDir)
+
+		err := cfg.runStep("load modules", func() error {
+			return LoadModules(cfg.ModulesDir)
+		})
+		if err != nil {
 			return err
 		}
 	}
 
 	if cfg.ConfigureLoopback {
-		if err := ConfigureLoopbackInterface(); err != nil {
+		cfg.printVerbose("configure loopback interface")
+
+		err := cfg.runStep("configure loopback", ConfigureLoopbackInterface)
+		if err != nil {
 			return err
 		}
 	}
 
-	if err := MountAll(cfg.MountPoints); err != nil {
+	if cfg.EthInterface != nil {
+		cfg.printVerbose("configure interface %s", cfg.EthInterface.Name)
+
+		err := cfg.runStep("configure ethernet", func() error {
+			return ConfigureEthInterface(*cfg.EthInterface)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cfg.printVerbose("mount %d file systems", len(cfg.MountPoints))
+
+	// Only read once the step returned, as the step might still be running
+	// after a timeout.
+	var failedMounts []string
+
+	err := cfg.runStep("mount file systems", func() error {
+		var err error
+
+		failedMounts, err = mountAll(cfg.MountPoints, Mount)
+
+		return err
+	})
+	if err != nil {
 		return err
 	}
 
-	if err := CreateSymlinks(cfg.Symlinks); err != nil {
+	completion.FailedMounts = failedMounts
+
+	cfg.printVerbose("create %d symlinks", len(cfg.Symlinks))
+
+	err = CreateSymlinks(cfg.Symlinks)
+	if err != nil {
 		return err
 	}
 
+	cfg.printVerbose("set %d sysctls", len(cfg.Sysctls))
+
+	for key, value := range sortedByKeys(cfg.Sysctls) {
+		if err := sysctl(strings.ReplaceAll(key, ".", "/"), value); err != nil {
+			return err
+		}
+	}
+
+	if cfg.FirmwareDir != "" {
+		cfg.printVerbose("set firmware path %s", cfg.FirmwareDir)
+
+		if err := SetFirmwarePath(cfg.FirmwareDir); err != nil {
+			PrintWarning(err)
+		}
+	}
+
+	cfg.printVerbose("set %d environment variables", len(cfg.Env))
+
 	for key, value := range cfg.Env {
 		if err := setenv(key, value); err != nil {
 			return err
@@ -188,3 +437,46 @@ func setup(cfg Config) error {
 
 	return nil
 }
+
+// runStep runs the given setup step. If [Config.SetupStepTimeout] is set and
+// the step does not finish in time, [ErrSetupStepTimeout] is returned. The
+// step keeps running in the background, as a stuck syscall can not be
+// interrupted, but the init aborts anyway.
+func (cfg *Config) runStep(name string, step func() error) error {
+	if cfg.SetupStepTimeout <= 0 {
+		return step()
+	}
+
+	err := runWithTimeout(cfg.SetupStepTimeout, step)
+	if errors.Is(err, errTimeout) {
+		return fmt.Errorf("%w: %s after %s",
+			ErrSetupStepTimeout, name, cfg.SetupStepTimeout)
+	}
+
+	return err
+}
+
+// errTimeout is returned by [runWithTimeout] if the function does not finish
+// in time.
+var errTimeout = errors.New("timeout")
+
+// runWithTimeout runs the given function and returns its error. If it does
+// not finish within the timeout, errTimeout is returned. The function keeps
+// running in the background, as a stuck syscall can not be interrupted.
+func runWithTimeout(timeout time.Duration, fn func() error) error {
+	done := make(chan error, 1)
+
+	go func() {
+		done <- fn()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return errTimeout
+	}
+}
@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_RunStep(t *testing.T) {
+	errStep := errors.New("step failed")
+
+	tests := []struct {
+		name      string
+		timeout   time.Duration
+		step      func(hang <-chan struct{}) error
+		assertErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "no timeout",
+			step: func(<-chan struct{}) error {
+				return errStep
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, errStep)
+			},
+		},
+		{
+			name:    "in time",
+			timeout: time.Second,
+			step: func(<-chan struct{}) error {
+				return errStep
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, errStep)
+			},
+		},
+		{
+			name:    "hanging",
+			timeout: 10 * time.Millisecond,
+			step: func(hang <-chan struct{}) error {
+				<-hang
+				return nil
+			},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, ErrSetupStepTimeout)
+				assert.ErrorContains(t, err, "mount file systems after 10ms")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hang := make(chan struct{})
+			defer close(hang)
+
+			cfg := Config{SetupStepTimeout: tt.timeout}
+
+			err := cfg.runStep("mount file systems", func() error {
+				return tt.step(hang)
+			})
+			tt.assertErr(t, err)
+		})
+	}
+}
+
+func TestDefaultConfig_Cgroup2(t *testing.T) {
+	cfg := DefaultConfig()
+
+	assert.Equal(t,
+		MountOptions{FSType: FSTypeCgroup2, MayFail: true},
+		cfg.MountPoints["/sys/fs/cgroup"],
+	)
+
+	var order []string
+
+	mountFn := func(path string, _ MountOptions) error {
+		order = append(order, path)
+		return nil
+	}
+
+	_, err := mountAll(cfg.MountPoints, mountFn)
+	require.NoError(t, err)
+
+	assert.Less(t,
+		slices.Index(order, "/sys"),
+		slices.Index(order, "/sys/fs/cgroup"),
+		"cgroup2 must be mounted after /sys",
+	)
+}
+
+func TestDefaultConfig_Hardening(t *testing.T) {
+	cfg := DefaultConfig()
+
+	for _, path := range []string{"/dev/shm", "/run", "/tmp"} {
+		flags := cfg.MountPoints[path].Flags
+		assert.Equal(t, MountFlagNoSuid|MountFlagNoDev, flags, path)
+	}
+
+	assert.Zero(t, cfg.MountPoints["/dev"].Flags, "devices must be usable")
+}
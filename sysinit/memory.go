@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MemoryEnvVar is the environment variable virtrun sets to the memory size
+// (in MB) configured for the guest.
+const MemoryEnvVar = "VIRTRUN_MEMORY"
+
+// memInfoFile is the kernel's memory statistics file.
+const memInfoFile = "/proc/meminfo"
+
+var (
+	// ErrMemoryNotConfigured is returned if no memory size is conveyed by
+	// virtrun.
+	ErrMemoryNotConfigured = errors.New("memory size not configured")
+
+	// ErrMemTotalNotFound is returned if the total memory can not be found
+	// in the kernel's memory statistics.
+	ErrMemTotalNotFound = errors.New("total memory not found")
+)
+
+// ConfiguredMemory returns the memory size (in MB) the guest is configured
+// with by virtrun.
+//
+// The value is read from the environment variable [MemoryEnvVar]. It is
+// larger than the memory actually available, as the kernel reserves some
+// memory for itself. Use [DetectedMemory] for the latter.
+func ConfiguredMemory() (uint64, error) {
+	value, exists := os.LookupEnv(MemoryEnvVar)
+	if !exists {
+		return 0, ErrMemoryNotConfigured
+	}
+
+	size, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", MemoryEnvVar, err)
+	}
+
+	return size, nil
+}
+
+// DetectedMemory returns the total memory size (in MB) usable by the kernel.
+//
+// The procfs must be mounted at /proc.
+func DetectedMemory() (uint64, error) {
+	return detectedMemory(memInfoFile)
+}
+
+func detectedMemory(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("open meminfo: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Format: "MemTotal:        1001412 kB"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] != "MemTotal:" {
+			continue
+		}
+
+		sizeKB, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse MemTotal: %w", err)
+		}
+
+		return sizeKB / 1024, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("read meminfo: %w", err)
+	}
+
+	return 0, ErrMemTotalNotFound
+}
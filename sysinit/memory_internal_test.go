@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfiguredMemory(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    uint64
+		expectedErr error
+	}{
+		{
+			name:     "valid",
+			value:    "256",
+			expected: 256,
+		},
+		{
+			name:        "invalid",
+			value:       "256M",
+			expectedErr: strconv.ErrSyntax,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(MemoryEnvVar, tt.value)
+
+			actual, err := ConfiguredMemory()
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestDetectedMemory(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		expected    uint64
+		expectedErr error
+	}{
+		{
+			name: "valid",
+			content: "MemTotal:         220552 kB\n" +
+				"MemFree:          180644 kB\n",
+			expected: 215,
+		},
+		{
+			name:        "missing",
+			content:     "MemFree:          180644 kB\n",
+			expectedErr: ErrMemTotalNotFound,
+		},
+		{
+			name:        "invalid",
+			content:     "MemTotal:         many kB\n",
+			expectedErr: strconv.ErrSyntax,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "meminfo")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			actual, err := detectedMemory(path)
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
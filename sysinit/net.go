@@ -4,6 +4,57 @@
 
 package sysinit
 
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+)
+
+// ResolvConfPath is the path of the resolver configuration written by
+// [ConfigureEthInterface] if a nameserver is given.
+const ResolvConfPath = "/etc/resolv.conf"
+
+// ErrInvalidEthInterface is returned if an [EthInterface] is incomplete or
+// not IPv4.
+var ErrInvalidEthInterface = errors.New("invalid ethernet interface")
+
+// EthInterface is the static IPv4 configuration of an ethernet interface.
+type EthInterface struct {
+	// Name is the name of the interface, like "eth0".
+	Name string `json:"name"`
+
+	// Address is the address of the interface with the prefix length of the
+	// network, like "10.0.2.15/24".
+	Address netip.Prefix `json:"address"`
+
+	// Gateway is used for the default route, if set.
+	Gateway netip.Addr `json:"gateway"`
+
+	// Nameserver is written to [ResolvConfPath], if set.
+	Nameserver netip.Addr `json:"nameserver"`
+}
+
+// validate checks the interface has a name and all addresses are IPv4.
+func (e EthInterface) validate() error {
+	switch {
+	case e.Name == "":
+		return fmt.Errorf("%w: empty name", ErrInvalidEthInterface)
+	case !e.Address.IsValid() || !e.Address.Addr().Is4():
+		return fmt.Errorf("%w: address: %s", ErrInvalidEthInterface,
+			e.Address)
+	case e.Gateway.IsValid() && !e.Gateway.Is4():
+		return fmt.Errorf("%w: gateway: %s", ErrInvalidEthInterface,
+			e.Gateway)
+	case e.Nameserver.IsValid() && !e.Nameserver.Is4():
+		return fmt.Errorf("%w: nameserver: %s", ErrInvalidEthInterface,
+			e.Nameserver)
+	}
+
+	return nil
+}
+
 // ConfigureLoopbackInterface brings the loopback interface up.
 //
 // Kernel configures addresses automatically.
@@ -11,7 +62,61 @@ func ConfigureLoopbackInterface() error {
 	return SetInterfaceUp("lo")
 }
 
+// ConfigureEthInterface sets the static address of the given interface and
+// brings it up. If a gateway is given, the default route is added. If a
+// nameserver is given, it is written to [ResolvConfPath].
+func ConfigureEthInterface(iface EthInterface) error {
+	if err := iface.validate(); err != nil {
+		return err
+	}
+
+	if err := setInterfaceAddress(iface.Name, iface.Address); err != nil {
+		return fmt.Errorf("%s: %w", iface.Name, err)
+	}
+
+	if err := SetInterfaceUp(iface.Name); err != nil {
+		return fmt.Errorf("%s: %w", iface.Name, err)
+	}
+
+	if iface.Gateway.IsValid() {
+		if err := addDefaultRoute(iface.Gateway); err != nil {
+			return fmt.Errorf("%s: %w", iface.Name, err)
+		}
+	}
+
+	if iface.Nameserver.IsValid() {
+		err := writeResolvConf(ResolvConfPath, iface.Nameserver)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // SetInterfaceUp brings the interface with the given name up.
 func SetInterfaceUp(name string) error {
 	return setInterfaceUp(name)
 }
+
+// writeResolvConf writes a resolver configuration with the given nameserver
+// to the given path. The directory is created if it does not exist.
+func writeResolvConf(path string, nameserver netip.Addr) error {
+	const (
+		dirMode  = 0o755
+		fileMode = 0o644
+	)
+
+	if err := os.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return fmt.Errorf("resolv.conf: %w", err)
+	}
+
+	content := "nameserver " + nameserver.String() + "\n"
+
+	err := os.WriteFile(path, []byte(content), fileMode)
+	if err != nil {
+		return fmt.Errorf("resolv.conf: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEthInterface_Validate(t *testing.T) {
+	valid := EthInterface{
+		Name:       "eth0",
+		Address:    netip.MustParsePrefix("10.0.2.15/24"),
+		Gateway:    netip.MustParseAddr("10.0.2.2"),
+		Nameserver: netip.MustParseAddr("10.0.2.3"),
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*EthInterface)
+		valid  bool
+	}{
+		{
+			name:   "complete",
+			modify: func(*EthInterface) {},
+			valid:  true,
+		},
+		{
+			name: "address only",
+			modify: func(e *EthInterface) {
+				e.Gateway = netip.Addr{}
+				e.Nameserver = netip.Addr{}
+			},
+			valid: true,
+		},
+		{
+			name:   "no name",
+			modify: func(e *EthInterface) { e.Name = "" },
+		},
+		{
+			name:   "no address",
+			modify: func(e *EthInterface) { e.Address = netip.Prefix{} },
+		},
+		{
+			name: "ipv6 address",
+			modify: func(e *EthInterface) {
+				e.Address = netip.MustParsePrefix("fd00::2/64")
+			},
+		},
+		{
+			name: "ipv6 gateway",
+			modify: func(e *EthInterface) {
+				e.Gateway = netip.MustParseAddr("fd00::1")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iface := valid
+			tt.modify(&iface)
+
+			err := iface.validate()
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrInvalidEthInterface)
+			}
+		})
+	}
+}
+
+func TestWriteResolvConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "etc", "resolv.conf")
+
+	err := writeResolvConf(path, netip.MustParseAddr("10.0.2.3"))
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "nameserver 10.0.2.3\n", string(content))
+}
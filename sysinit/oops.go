@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// OopsMarker is the line printed if a kernel oops occurred while the main
+// function ran, if [Config.PanicBehavior] is [PanicBehaviorReport].
+const OopsMarker = "SYSINIT_KERNEL_OOPS"
+
+// OopsExitCode is the exit code communicated if a kernel oops occurred while
+// the main function ran, if [Config.PanicBehavior] is [PanicBehaviorReport].
+const OopsExitCode = -3
+
+var (
+	// ErrKernelOops is returned if a kernel oops occurred while the main
+	// function ran.
+	ErrKernelOops = errors.New("kernel oops occurred")
+
+	// ErrInvalidPanicBehavior is returned if an unknown [PanicBehavior] is
+	// given.
+	ErrInvalidPanicBehavior = errors.New("invalid panic behavior")
+)
+
+// PanicBehavior defines how the init handles kernel oopses, like those
+// caused by a faulty kernel module under test.
+type PanicBehavior string
+
+const (
+	// PanicBehaviorNone leaves the kernel's oops handling as is. The kernel
+	// kills the faulting process only and keeps running, so an oops shows up
+	// in the output only. This is the default.
+	PanicBehaviorNone PanicBehavior = ""
+
+	// PanicBehaviorReport checks for an oops once the main function is
+	// done. If one occurred since the setup, the [OopsMarker] is printed and
+	// [OopsExitCode] is communicated with [ErrKernelOops].
+	PanicBehaviorReport PanicBehavior = "report"
+
+	// PanicBehaviorPanic sets the sysctl "kernel.panic_on_oops", so the
+	// kernel panics right away on an oops, which the host detects.
+	PanicBehaviorPanic PanicBehavior = "panic"
+)
+
+// ParsePanicBehavior parses a [PanicBehavior] given as "report" or "panic".
+func ParsePanicBehavior(s string) (PanicBehavior, error) {
+	switch behavior := PanicBehavior(s); behavior {
+	case PanicBehaviorReport, PanicBehaviorPanic:
+		return behavior, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidPanicBehavior, s)
+	}
+}
+
+// PrintOopsMarker prints the [OopsMarker] line to stdout.
+func PrintOopsMarker() {
+	writeOopsMarker(os.Stdout)
+}
+
+func writeOopsMarker(w io.Writer) {
+	// Ensure newlines before and after, as the oops message of the kernel
+	// might be interleaved with the output.
+	_, _ = fmt.Fprintf(w, "\n%s\n", OopsMarker)
+}
+
+// taintDie is the kernel taint flag that is set once an oops occurred
+// ("TAINT_DIE").
+const taintDie = 1 << 7
+
+// readTainted returns the kernel taint flags.
+func readTainted() (uint64, error) {
+	data, err := os.ReadFile("/proc/sys/kernel/tainted")
+	if err != nil {
+		return 0, fmt.Errorf("read tainted: %w", err)
+	}
+
+	tainted, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse tainted: %w", err)
+	}
+
+	return tainted, nil
+}
+
+// oopsDetector detects kernel oopses that occurred after it has been started.
+// Oopses before, like during boot, are ignored.
+type oopsDetector struct {
+	readTainted func() (uint64, error)
+	before      uint64
+}
+
+// start records the current state, so [oopsDetector.occurred] reports only
+// oopses from now on.
+func (d *oopsDetector) start() error {
+	tainted, err := d.readTainted()
+	if err != nil {
+		return err
+	}
+
+	d.before = tainted
+
+	if tainted&taintDie != 0 {
+		PrintWarning(fmt.Errorf("%w before start, later oopses are not "+
+			"detected", ErrKernelOops))
+	}
+
+	return nil
+}
+
+// occurred returns true if an oops occurred since the detector has been
+// started.
+func (d *oopsDetector) occurred() (bool, error) {
+	// The flag is sticky, so an oops before the start can not be told apart
+	// from one after.
+	if d.before&taintDie != 0 {
+		return false, nil
+	}
+
+	tainted, err := d.readTainted()
+	if err != nil {
+		return false, err
+	}
+
+	return tainted&taintDie != 0, nil
+}
+
+// check returns [OopsExitCode] and [ErrKernelOops] wrapping the given error,
+// if an oops occurred since the detector has been started. The [OopsMarker]
+// is written to the writer then. Otherwise, the given exit code and error are
+// returned as is.
+func (d *oopsDetector) check(
+	w io.Writer,
+	exitCode int,
+	err error,
+) (int, error) {
+	occurred, checkErr := d.occurred()
+	if checkErr != nil {
+		PrintWarning(fmt.Errorf("check kernel oops: %w", checkErr))
+		return exitCode, err
+	}
+
+	if !occurred {
+		return exitCode, err
+	}
+
+	writeOopsMarker(w)
+
+	if err != nil {
+		return OopsExitCode, fmt.Errorf("%w: %w", ErrKernelOops, err)
+	}
+
+	return OopsExitCode, ErrKernelOops
+}
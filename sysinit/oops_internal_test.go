@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePanicBehavior(t *testing.T) {
+	behavior, err := ParsePanicBehavior("report")
+	require.NoError(t, err)
+	assert.Equal(t, PanicBehaviorReport, behavior)
+
+	behavior, err = ParsePanicBehavior("panic")
+	require.NoError(t, err)
+	assert.Equal(t, PanicBehaviorPanic, behavior)
+
+	_, err = ParsePanicBehavior("")
+	require.ErrorIs(t, err, ErrInvalidPanicBehavior)
+}
+
+func TestOopsDetector_Check(t *testing.T) {
+	errFail := errors.New("fail")
+
+	tests := []struct {
+		name             string
+		before, after    uint64
+		err              error
+		expectedExitCode int
+		expectedErr      error
+		expectedOutput   string
+	}{
+		{
+			name:             "no oops",
+			before:           0,
+			after:            1,
+			expectedExitCode: 3,
+		},
+		{
+			name:             "oops",
+			before:           1,
+			after:            1 | taintDie,
+			expectedExitCode: OopsExitCode,
+			expectedErr:      ErrKernelOops,
+			expectedOutput:   "\n" + OopsMarker + "\n",
+		},
+		{
+			name:             "oops with error",
+			after:            taintDie,
+			err:              errFail,
+			expectedExitCode: OopsExitCode,
+			expectedErr:      errFail,
+			expectedOutput:   "\n" + OopsMarker + "\n",
+		},
+		{
+			name:             "oops before start",
+			before:           taintDie,
+			after:            taintDie,
+			expectedExitCode: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tainted := tt.before
+			detector := oopsDetector{
+				readTainted: func() (uint64, error) {
+					return tainted, nil
+				},
+			}
+
+			require.NoError(t, detector.start())
+
+			tainted = tt.after
+
+			var output strings.Builder
+
+			exitCode, err := detector.check(&output, 3, tt.err)
+			assert.Equal(t, tt.expectedExitCode, exitCode, "exit code")
+			assert.Equal(t, tt.expectedOutput, output.String(), "output")
+
+			if tt.expectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, tt.expectedErr)
+			require.ErrorIs(t, err, ErrKernelOops)
+		})
+	}
+}
+
+func TestOopsDetector_CheckReadFailure(t *testing.T) {
+	errRead := errors.New("read")
+	calls := 0
+
+	detector := oopsDetector{
+		readTainted: func() (uint64, error) {
+			calls++
+			if calls > 1 {
+				return 0, errRead
+			}
+
+			return 0, nil
+		},
+	}
+
+	require.NoError(t, detector.start())
+
+	var output strings.Builder
+
+	exitCode, err := detector.check(&output, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, exitCode)
+	assert.Empty(t, output.String())
+}
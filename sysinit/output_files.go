@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrInvalidOutputFiles is returned if [OutputFiles] can not be parsed.
+var ErrInvalidOutputFiles = errors.New("invalid output files")
+
+// OutputFiles maps files in the guest to the console devices they are copied
+// to, like "/tmp/cover.out" to "/dev/hvc1". The host writes the output of the
+// console to a host file.
+//
+// As consoles are line based, only text files are supported. Paths must not
+// contain ",", ":" or white space.
+type OutputFiles map[string]string
+
+// ParseOutputFiles parses [OutputFiles] given as comma separated list of
+// "GUEST:DEVICE" pairs, as returned by [OutputFiles.String].
+func ParseOutputFiles(s string) (OutputFiles, error) {
+	files := OutputFiles{}
+
+	for _, pair := range strings.Split(s, ",") {
+		file, device, found := strings.Cut(pair, ":")
+		if !found || file == "" || device == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidOutputFiles, pair)
+		}
+
+		files[file] = device
+	}
+
+	return files, nil
+}
+
+// String returns the files as comma separated list of "GUEST:DEVICE" pairs
+// in lexicographic order of the guest paths.
+func (o OutputFiles) String() string {
+	pairs := make([]string, 0, len(o))
+
+	for file, device := range sortedByKeys(o) {
+		pairs = append(pairs, file+":"+device)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// CopyOutputFiles copies each of the given files to its console device.
+//
+// All files are tried. The errors of those that failed are returned joined.
+func CopyOutputFiles(files OutputFiles) error {
+	var errs []error
+
+	for file, device := range sortedByKeys(files) {
+		err := copyFile(device, file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("output file %s: %w", file, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// copyFile copies the content of the file at src to the existing file at
+// dst.
+func copyFile(dst, src string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY, 0)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	_, err = io.Copy(dstFile, srcFile)
+	if err != nil {
+		_ = dstFile.Close()
+		return err //nolint:wrapcheck
+	}
+
+	return dstFile.Close() //nolint:wrapcheck
+}
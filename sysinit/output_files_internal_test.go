@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutputFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected OutputFiles
+	}{
+		{
+			name:     "single",
+			input:    "/tmp/cover.out:/dev/hvc1",
+			expected: OutputFiles{"/tmp/cover.out": "/dev/hvc1"},
+		},
+		{
+			name:  "multiple",
+			input: "/tmp/b:/dev/ttyS2,/tmp/a:/dev/ttyS1",
+			expected: OutputFiles{
+				"/tmp/a": "/dev/ttyS1",
+				"/tmp/b": "/dev/ttyS2",
+			},
+		},
+		{
+			name:  "missing device",
+			input: "/tmp/a:",
+		},
+		{
+			name:  "missing separator",
+			input: "/tmp/a",
+		},
+		{
+			name:  "empty",
+			input: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := ParseOutputFiles(tt.input)
+			if tt.expected == nil {
+				require.ErrorIs(t, err, ErrInvalidOutputFiles)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+
+			roundTrip, err := ParseOutputFiles(actual.String())
+			require.NoError(t, err)
+			assert.Equal(t, actual, roundTrip)
+		})
+	}
+}
+
+func TestOutputFiles_String(t *testing.T) {
+	files := OutputFiles{
+		"/tmp/b": "/dev/hvc2",
+		"/tmp/a": "/dev/hvc1",
+	}
+
+	assert.Equal(t, "/tmp/a:/dev/hvc1,/tmp/b:/dev/hvc2", files.String())
+}
+
+func TestCopyOutputFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	coverFile := filepath.Join(tempDir, "cover.out")
+	coverContent := "mode: atomic\nexample.com/pkg/file.go:3.14,5.2 1 1\n"
+	require.NoError(t, os.WriteFile(coverFile, []byte(coverContent), 0o600))
+
+	// Stand-in for the console device, which exists already.
+	device := filepath.Join(tempDir, "hvc1")
+	require.NoError(t, os.WriteFile(device, nil, 0o600))
+
+	otherDevice := filepath.Join(tempDir, "hvc2")
+	require.NoError(t, os.WriteFile(otherDevice, nil, 0o600))
+
+	t.Run("copied", func(t *testing.T) {
+		err := CopyOutputFiles(OutputFiles{coverFile: device})
+		require.NoError(t, err)
+
+		actual, err := os.ReadFile(device)
+		require.NoError(t, err)
+		assert.Equal(t, coverContent, string(actual))
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		missingFile := filepath.Join(tempDir, "missing.out")
+
+		err := CopyOutputFiles(OutputFiles{
+			missingFile: otherDevice,
+			coverFile:   device,
+		})
+		require.ErrorIs(t, err, os.ErrNotExist)
+		assert.ErrorContains(t, err, missingFile)
+	})
+
+	t.Run("missing device", func(t *testing.T) {
+		err := CopyOutputFiles(OutputFiles{
+			coverFile: filepath.Join(tempDir, "hvc9"),
+		})
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
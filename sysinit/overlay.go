@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// DefaultOverlayDir is the default base directory used by [OverlayRoot].
+const DefaultOverlayDir = "/.overlay"
+
+// overlayLayout describes the directories used for an overlay root.
+type overlayLayout struct {
+	// Lower is where the initial root file system is bind mounted.
+	Lower string
+
+	// RW is where the tmpfs for the upper and work directories is mounted.
+	RW string
+
+	// Upper is the upper directory receiving all writes.
+	Upper string
+
+	// Work is the work directory required by the overlay file system.
+	Work string
+
+	// Root is where the overlay file system is mounted before it is moved
+	// to "/".
+	Root string
+}
+
+func newOverlayLayout(baseDir string) overlayLayout {
+	rwDir := filepath.Join(baseDir, "rw")
+
+	return overlayLayout{
+		Lower: filepath.Join(baseDir, "lower"),
+		RW:    rwDir,
+		Upper: filepath.Join(rwDir, "upper"),
+		Work:  filepath.Join(rwDir, "work"),
+		Root:  filepath.Join(baseDir, "root"),
+	}
+}
+
+// overlayMount returns the overlay file system mount.
+func (l overlayLayout) overlayMount() MountOptions {
+	data := strings.Join([]string{
+		"lowerdir=" + l.Lower,
+		"upperdir=" + l.Upper,
+		"workdir=" + l.Work,
+	}, ",")
+
+	return MountOptions{
+		FSType: FSTypeOverlay,
+		Data:   data,
+	}
+}
+
+// OverlayRoot replaces the root file system with an overlay file system. The
+// lower layer is the initial root file system, the upper layer is a fresh
+// tmpfs. So, the content of the initramfs can not be modified anymore while
+// the root file system is still writable.
+//
+// The given base directory is used for the mount points the overlay is
+// assembled from. It must be called before any other file systems are
+// mounted, as those are not part of the lower layer.
+func OverlayRoot(baseDir string) error {
+	layout := newOverlayLayout(baseDir)
+
+	// The bind mount is not recursive, so it is a snapshot of the initial
+	// root without any of the mounts on top of it.
+	err := Mount(layout.Lower, MountOptions{Source: "/", Flags: unix.MS_BIND})
+	if err != nil {
+		return err
+	}
+
+	err = Mount(layout.RW, MountOptions{FSType: FSTypeTmp})
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range []string{layout.Upper, layout.Work} {
+		err := os.Mkdir(dir, defaultDirMode)
+		if err != nil {
+			return fmt.Errorf("mkdir %s: %w", dir, err)
+		}
+	}
+
+	err = Mount(layout.Root, layout.overlayMount())
+	if err != nil {
+		return err
+	}
+
+	return switchRoot(layout.Root)
+}
@@ -0,0 +1,32 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlayLayout(t *testing.T) {
+	layout := newOverlayLayout("/.overlay")
+
+	expected := overlayLayout{
+		Lower: "/.overlay/lower",
+		RW:    "/.overlay/rw",
+		Upper: "/.overlay/rw/upper",
+		Work:  "/.overlay/rw/work",
+		Root:  "/.overlay/root",
+	}
+	assert.Equal(t, expected, layout)
+
+	expectedMount := MountOptions{
+		FSType: FSTypeOverlay,
+		Data: "lowerdir=/.overlay/lower," +
+			"upperdir=/.overlay/rw/upper," +
+			"workdir=/.overlay/rw/work",
+	}
+	assert.Equal(t, expectedMount, layout.overlayMount())
+}
@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// DefaultPoweroffTimeout is the timeout for each poweroff attempt, if
+// [Config.PoweroffTimeout] is not set.
+const DefaultPoweroffTimeout = 5 * time.Second
+
+// ErrPoweroffTimeout is returned if a poweroff attempt does not finish within
+// the timeout.
+var ErrPoweroffTimeout = errors.New("poweroff timed out")
+
+// ShutdownMethod is the way the system is shut down once done.
+type ShutdownMethod int
+
+const (
+	// ShutdownRestart restarts the system. It is used by default since it
+	// does not require ACPI. The guest system must be started with QEMU's
+	// -no-reboot flag, so QEMU exits instead of restarting the guest.
+	ShutdownRestart ShutdownMethod = iota
+
+	// ShutdownPoweroff powers the system off. Use it for setups that do not
+	// terminate on restart. On x86, the guest kernel needs ACPI support and
+	// must not be started with "acpi=off". Otherwise, the kernel just halts
+	// and QEMU keeps running.
+	ShutdownPoweroff
+)
+
+// rebootCmd returns the reboot syscall command for the shutdown method.
+func (m ShutdownMethod) rebootCmd() int {
+	if m == ShutdownPoweroff {
+		return unix.LINUX_REBOOT_CMD_POWER_OFF
+	}
+
+	return unix.LINUX_REBOOT_CMD_RESTART
+}
+
+// sysrqReboot is the magic SysRq key that reboots the system immediately,
+// without syncing or unmounting file systems.
+const sysrqReboot = 'b'
+
+// Poweroff shuts down the system.
+//
+// Call when done, or deferred right at the beginning of your `TestMain`
+// function. See [PoweroffWithTimeout] for the fallbacks if shutting down
+// hangs. The [DefaultPoweroffTimeout] is used.
+func Poweroff() {
+	PoweroffWithTimeout(DefaultPoweroffTimeout)
+}
+
+// PoweroffWithTimeout shuts down the system like [Poweroff] with the given
+// timeout for each attempt.
+//
+// If the reboot syscall does not finish in time or fails, the file systems
+// are synced and the syscall is retried. As last resort, an immediate reboot
+// is triggered via /proc/sysrq-trigger, so the host is not stuck waiting for
+// the guest. A non-positive timeout uses [DefaultPoweroffTimeout].
+func PoweroffWithTimeout(timeout time.Duration) {
+	Shutdown(ShutdownRestart, timeout)
+}
+
+// Shutdown shuts down the system like [PoweroffWithTimeout] with the given
+// [ShutdownMethod].
+func Shutdown(method ShutdownMethod, timeout time.Duration) {
+	// Silence the kernel so it does not show up in our test output.
+	_ = sysctl("kernel/printk", "0")
+
+	p := poweroffer{
+		reboot:    reboot,
+		rebootCmd: method.rebootCmd(),
+		sync:      syncFS,
+		sysrq:     sysrq,
+		timeout:   timeout,
+	}
+
+	if err := p.poweroff(); err != nil {
+		PrintError(err)
+	}
+}
+
+// poweroffer shuts down the system with the injected functions.
+type poweroffer struct {
+	reboot    func(cmd int) error
+	rebootCmd int
+	sync      func()
+	sysrq     func(key byte) error
+	timeout   time.Duration
+}
+
+// poweroff runs the attempts to shut down the system in order until one
+// succeeds. Each attempt is limited by the timeout. Failed attempts are
+// printed as warnings. The error of the last attempt is returned if all
+// attempts fail.
+func (p *poweroffer) poweroff() error {
+	timeout := p.timeout
+	if timeout <= 0 {
+		timeout = DefaultPoweroffTimeout
+	}
+
+	attempts := []struct {
+		name string
+		fn   func() error
+	}{
+		{
+			name: "reboot",
+			fn: func() error {
+				return p.reboot(p.rebootCmd)
+			},
+		},
+		{
+			name: "sync and reboot",
+			fn: func() error {
+				p.sync()
+				return p.reboot(p.rebootCmd)
+			},
+		},
+		{
+			name: "sysrq reboot",
+			fn: func() error {
+				return p.sysrq(sysrqReboot)
+			},
+		},
+	}
+
+	var err error
+
+	for idx, attempt := range attempts {
+		err = runWithTimeout(timeout, attempt.fn)
+		if errors.Is(err, errTimeout) {
+			err = fmt.Errorf("%w: %s after %s",
+				ErrPoweroffTimeout, attempt.name, timeout)
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		if idx < len(attempts)-1 {
+			PrintWarning(err)
+		}
+	}
+
+	return err
+}
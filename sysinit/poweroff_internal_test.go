@@ -0,0 +1,170 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestPoweroffer_Poweroff(t *testing.T) {
+	errReboot := errors.New("reboot failed")
+	errSysrq := errors.New("sysrq failed")
+
+	tests := []struct {
+		name      string
+		reboots   []func(hang <-chan struct{}) error
+		sysrqErr  error
+		expected  []string
+		assertErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "reboot succeeds",
+			reboots: []func(<-chan struct{}) error{
+				func(<-chan struct{}) error { return nil },
+			},
+			expected:  []string{"reboot"},
+			assertErr: require.NoError,
+		},
+		{
+			name: "reboot fails, sync and reboot succeeds",
+			reboots: []func(<-chan struct{}) error{
+				func(<-chan struct{}) error { return errReboot },
+				func(<-chan struct{}) error { return nil },
+			},
+			expected:  []string{"reboot", "sync", "reboot"},
+			assertErr: require.NoError,
+		},
+		{
+			name: "reboot hangs, sysrq succeeds",
+			reboots: []func(<-chan struct{}) error{
+				func(hang <-chan struct{}) error { <-hang; return nil },
+				func(hang <-chan struct{}) error { <-hang; return nil },
+			},
+			expected:  []string{"reboot", "sync", "reboot", "sysrq b"},
+			assertErr: require.NoError,
+		},
+		{
+			name: "all fail",
+			reboots: []func(<-chan struct{}) error{
+				func(<-chan struct{}) error { return errReboot },
+				func(<-chan struct{}) error { return errReboot },
+			},
+			sysrqErr: errSysrq,
+			expected: []string{"reboot", "sync", "reboot", "sysrq b"},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, errSysrq)
+			},
+		},
+		{
+			name: "all hang",
+			reboots: []func(<-chan struct{}) error{
+				func(hang <-chan struct{}) error { <-hang; return nil },
+				func(hang <-chan struct{}) error { <-hang; return nil },
+			},
+			sysrqErr: errSysrq,
+			expected: []string{"reboot", "sync", "reboot", "sysrq b"},
+			assertErr: func(t require.TestingT, err error, _ ...any) {
+				require.ErrorIs(t, err, errSysrq)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hang := make(chan struct{})
+			defer close(hang)
+
+			var (
+				mu     sync.Mutex
+				calls  []string
+				reboot int
+			)
+
+			record := func(call string) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				calls = append(calls, call)
+			}
+
+			nextReboot := func() func(<-chan struct{}) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				calls = append(calls, "reboot")
+				fn := tt.reboots[reboot]
+				reboot++
+
+				return fn
+			}
+
+			p := poweroffer{
+				reboot: func(int) error {
+					return nextReboot()(hang)
+				},
+				sync: func() {
+					record("sync")
+				},
+				sysrq: func(key byte) error {
+					record("sysrq " + string(key))
+					return tt.sysrqErr
+				},
+				timeout: 10 * time.Millisecond,
+			}
+
+			err := p.poweroff()
+			tt.assertErr(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			assert.Equal(t, tt.expected, calls)
+		})
+	}
+}
+
+func TestShutdown_RebootCmd(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   ShutdownMethod
+		expected int
+	}{
+		{
+			name:     "restart",
+			method:   ShutdownRestart,
+			expected: unix.LINUX_REBOOT_CMD_RESTART,
+		},
+		{
+			name:     "poweroff",
+			method:   ShutdownPoweroff,
+			expected: unix.LINUX_REBOOT_CMD_POWER_OFF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cmds []int
+
+			p := poweroffer{
+				reboot: func(cmd int) error {
+					cmds = append(cmds, cmd)
+					return nil
+				},
+				rebootCmd: tt.method.rebootCmd(),
+				timeout:   time.Second,
+			}
+
+			require.NoError(t, p.poweroff())
+			assert.Equal(t, []int{tt.expected}, cmds)
+		})
+	}
+}
@@ -5,8 +5,10 @@
 package sysinit
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 )
 
 // ExitCodeFmt is the format string for communicating the test results
@@ -15,6 +17,55 @@ import (
 // matched correctly.
 const ExitCodeFmt = "SYSINIT_EXIT_CODE: %d"
 
+// BootMarker is the line printed once the system setup is done, if
+// [Config.PrintBootMarker] is set. The host uses it to measure the boot time.
+const BootMarker = "SYSINIT_BOOTED"
+
+// PrintBootMarker prints the [BootMarker] line to stdout.
+func PrintBootMarker() {
+	_, _ = fmt.Fprintf(os.Stdout, "\n%s\n", BootMarker)
+}
+
+// CompletionPrefix is the prefix of the line communicating the structured
+// [Completion] information. The line is printed right before the legacy
+// [ExitCodeFmt] line, so hosts that do not know about it still work.
+const CompletionPrefix = "SYSINIT_COMPLETION: "
+
+// Completion is the structured information about the completed run that is
+// communicated to the host as single JSON line prefixed with
+// [CompletionPrefix].
+type Completion struct {
+	// ExitCode is the exit code, same as communicated by [PrintExitCode].
+	ExitCode int `json:"exitCode"`
+
+	// Duration is the time from start of the init until completion.
+	Duration time.Duration `json:"duration"`
+
+	// Error is the error message, if the run failed with an error.
+	Error string `json:"error,omitempty"`
+
+	// Resources are the resources left once the run is done. Only set if
+	// [Config.ReportResources] is set.
+	Resources *Resources `json:"resources,omitempty"`
+
+	// FailedMounts are the paths of the mount points with
+	// [MountOptions.MayFail] set that failed to mount, usually because the
+	// kernel lacks support for the file system.
+	FailedMounts []string `json:"failedMounts,omitempty"`
+}
+
+// PrintCompletion prints the given [Completion] as JSON line prefixed with
+// [CompletionPrefix] to stdout.
+func PrintCompletion(completion Completion) {
+	data, err := json.Marshal(completion)
+	if err != nil {
+		PrintWarning(fmt.Errorf("marshal completion: %w", err))
+		return
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "\n%s%s\n", CompletionPrefix, data)
+}
+
 // PrintExitCode prints the magic string communicating the exit code of the
 // init to stdout.
 func PrintExitCode(exitCode int) {
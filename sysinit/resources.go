@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procDir is the mount point of the procfs.
+const procDir = "/proc"
+
+// kthreaddPID is the PID of the kernel thread daemon, the parent of all other
+// kernel threads.
+const kthreaddPID = 2
+
+// ErrPPIDNotFound is returned if the parent PID can not be found in the
+// status file of a process.
+var ErrPPIDNotFound = errors.New("parent pid not found")
+
+// Resources are the resources left in the guest once the main function is
+// done. They are reported to the host in the [Completion], if
+// [Config.ReportResources] is set.
+type Resources struct {
+	// Processes is the number of processes other than the init itself and
+	// kernel threads. Any such process is leaked by the main function.
+	Processes int `json:"processes"`
+
+	// FDs is the number of open file descriptors of the init and all counted
+	// processes. It includes the init's standard streams and those used by
+	// the Go runtime.
+	FDs int `json:"fds"`
+}
+
+// CountResources counts the remaining processes and open file descriptors.
+//
+// The procfs must be mounted at /proc.
+func CountResources() (Resources, error) {
+	return countResources(procDir, getpid())
+}
+
+func countResources(dir string, self int) (Resources, error) {
+	var resources Resources
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return resources, fmt.Errorf("read proc dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		if pid != self {
+			ppid, err := readPPID(filepath.Join(dir, entry.Name(), "status"))
+			if err != nil {
+				// The process might have terminated in the meantime.
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+
+				return resources, err
+			}
+
+			if pid == kthreaddPID || ppid == kthreaddPID {
+				continue
+			}
+
+			resources.Processes++
+		}
+
+		fds, err := os.ReadDir(filepath.Join(dir, entry.Name(), "fd"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return resources, fmt.Errorf("read fds of %d: %w", pid, err)
+		}
+
+		resources.FDs += len(fds)
+	}
+
+	return resources, nil
+}
+
+// readPPID reads the parent PID from the given proc status file.
+func readPPID(path string) (int, error) {
+	const key = "PPid:"
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("open status: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, found := strings.CutPrefix(scanner.Text(), key)
+		if !found {
+			continue
+		}
+
+		ppid, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return 0, fmt.Errorf("parse %s: %w", key, err)
+		}
+
+		return ppid, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("read status: %w", err)
+	}
+
+	return 0, fmt.Errorf("%w: %s", ErrPPIDNotFound, path)
+}
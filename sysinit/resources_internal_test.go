@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFakeProc writes a fake process into the proc dir with the given
+// parent PID and number of open file descriptors.
+func writeFakeProc(t *testing.T, dir string, pid, ppid, fds int) {
+	t.Helper()
+
+	procDir := filepath.Join(dir, strconv.Itoa(pid))
+	fdDir := filepath.Join(procDir, "fd")
+
+	require.NoError(t, os.MkdirAll(fdDir, 0o755))
+
+	status := "Name:\ttest\nPid:\t" + strconv.Itoa(pid) +
+		"\nPPid:\t" + strconv.Itoa(ppid) + "\n"
+
+	err := os.WriteFile(filepath.Join(procDir, "status"), []byte(status), 0o600)
+	require.NoError(t, err)
+
+	for fd := range fds {
+		err := os.Symlink("/dev/null", filepath.Join(fdDir, strconv.Itoa(fd)))
+		require.NoError(t, err)
+	}
+}
+
+func TestCountResources(t *testing.T) {
+	tests := []struct {
+		name     string
+		prepare  func(t *testing.T, dir string)
+		expected Resources
+	}{
+		{
+			name: "init only",
+			prepare: func(t *testing.T, dir string) {
+				t.Helper()
+				writeFakeProc(t, dir, 1, 0, 3)
+			},
+			expected: Resources{FDs: 3},
+		},
+		{
+			name: "kernel threads",
+			prepare: func(t *testing.T, dir string) {
+				t.Helper()
+				writeFakeProc(t, dir, 1, 0, 3)
+				writeFakeProc(t, dir, 2, 0, 0)
+				writeFakeProc(t, dir, 3, 2, 0)
+			},
+			expected: Resources{FDs: 3},
+		},
+		{
+			name: "leaked processes",
+			prepare: func(t *testing.T, dir string) {
+				t.Helper()
+				writeFakeProc(t, dir, 1, 0, 4)
+				writeFakeProc(t, dir, 2, 0, 0)
+				writeFakeProc(t, dir, 42, 1, 2)
+				writeFakeProc(t, dir, 43, 42, 1)
+			},
+			expected: Resources{Processes: 2, FDs: 7},
+		},
+		{
+			name: "non-process entries",
+			prepare: func(t *testing.T, dir string) {
+				t.Helper()
+				writeFakeProc(t, dir, 1, 0, 3)
+
+				err := os.MkdirAll(filepath.Join(dir, "sys"), 0o755)
+				require.NoError(t, err)
+
+				err = os.WriteFile(filepath.Join(dir, "99"), nil, 0o600)
+				require.NoError(t, err)
+			},
+			expected: Resources{FDs: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.prepare(t, dir)
+
+			actual, err := countResources(dir, 1)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestReadPPID_NotFound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status")
+	require.NoError(t, os.WriteFile(path, []byte("Name:\ttest\n"), 0o600))
+
+	_, err := readPPID(path)
+	assert.ErrorIs(t, err, ErrPPIDNotFound)
+}
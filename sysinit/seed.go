@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// SeedEnvVar is the environment variable virtrun sets to the seed given for
+// the run.
+const SeedEnvVar = "VIRTRUN_SEED"
+
+// ErrSeedNotConfigured is returned if no seed is conveyed by virtrun.
+var ErrSeedNotConfigured = errors.New("seed not configured")
+
+// Seed returns the seed given for the run by virtrun.
+//
+// The value is read from the environment variable [SeedEnvVar]. Use it to
+// seed the random sources of tests, so failures can be reproduced by running
+// with the same seed again.
+func Seed() (uint64, error) {
+	value, exists := os.LookupEnv(SeedEnvVar)
+	if !exists {
+		return 0, ErrSeedNotConfigured
+	}
+
+	seed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", SeedEnvVar, err)
+	}
+
+	return seed, nil
+}
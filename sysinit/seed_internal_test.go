@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2024 Tobias Böhm <code@aibor.de>
+//
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package sysinit
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeed(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    uint64
+		expectedErr error
+	}{
+		{
+			name:     "valid",
+			value:    "18446744073709551615",
+			expected: 18446744073709551615,
+		},
+		{
+			name:        "invalid",
+			value:       "-1",
+			expectedErr: strconv.ErrSyntax,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(SeedEnvVar, tt.value)
+
+			actual, err := Seed()
+			require.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
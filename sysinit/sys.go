@@ -7,13 +7,33 @@ package sysinit
 import (
 	"errors"
 	"fmt"
+	"net"
+	"net/netip"
 	"os"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
 
+// MountFlags are mount flags as defined by mount(2). They can be combined by
+// bitwise OR.
 type MountFlags int
 
+const (
+	// MountFlagReadOnly mounts the file system read-only.
+	MountFlagReadOnly MountFlags = unix.MS_RDONLY
+
+	// MountFlagNoSuid ignores set-user-ID and set-group-ID bits and file
+	// capabilities of files on the file system.
+	MountFlagNoSuid MountFlags = unix.MS_NOSUID
+
+	// MountFlagNoDev disallows access to device files on the file system.
+	MountFlagNoDev MountFlags = unix.MS_NODEV
+
+	// MountFlagNoExec disallows executing programs from the file system.
+	MountFlagNoExec MountFlags = unix.MS_NOEXEC
+)
+
 func mount(path, source, fsType string, flags MountFlags, data string) error {
 	if source == "" {
 		source = fsType
@@ -26,6 +46,28 @@ func mount(path, source, fsType string, flags MountFlags, data string) error {
 	return nil
 }
 
+// switchRoot moves the mount at the given path to "/" and changes the root
+// directory into it.
+func switchRoot(newRoot string) error {
+	if err := unix.Chdir(newRoot); err != nil {
+		return fmt.Errorf("chdir %s: %w", newRoot, err)
+	}
+
+	if err := unix.Mount(".", "/", "", unix.MS_MOVE, ""); err != nil {
+		return fmt.Errorf("move mount %s: %w", newRoot, err)
+	}
+
+	if err := unix.Chroot("."); err != nil {
+		return fmt.Errorf("chroot: %w", err)
+	}
+
+	if err := unix.Chdir("/"); err != nil {
+		return fmt.Errorf("chdir /: %w", err)
+	}
+
+	return nil
+}
+
 func initModule(data []byte, params string) error {
 	if err := unix.InitModule(data, params); err != nil {
 		return fmt.Errorf("init_module: %w", err)
@@ -51,14 +93,48 @@ func finitModule(fd int, params string, flags finitFlags) error {
 	return nil
 }
 
-func reboot() error {
-	if err := unix.Reboot(unix.LINUX_REBOOT_CMD_RESTART); err != nil {
+// reboot runs the reboot syscall with the given command, like
+// [unix.LINUX_REBOOT_CMD_RESTART]. See [ShutdownMethod].
+func reboot(cmd int) error {
+	if err := unix.Reboot(cmd); err != nil {
 		return fmt.Errorf("reboot: %w", err)
 	}
 
 	return nil
 }
 
+// redirectStdin replaces the standard input file descriptor of the process
+// with the file at the given path.
+func redirectStdin(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("redirect stdin: %w", err)
+	}
+	defer file.Close()
+
+	err = unix.Dup3(int(file.Fd()), int(os.Stdin.Fd()), 0)
+	if err != nil {
+		return fmt.Errorf("redirect stdin: %w", err)
+	}
+
+	return nil
+}
+
+func syncFS() {
+	unix.Sync()
+}
+
+func sysrq(key byte) error {
+	const mode = 0o200
+
+	err := os.WriteFile("/proc/sysrq-trigger", []byte{key}, mode)
+	if err != nil {
+		return fmt.Errorf("sysrq: %w", err)
+	}
+
+	return nil
+}
+
 func setInterfaceUp(name string) error {
 	sock, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
 	if err != nil {
@@ -79,6 +155,94 @@ func setInterfaceUp(name string) error {
 	return nil
 }
 
+// setInterfaceAddress sets the IPv4 address and netmask of the interface with
+// the given name.
+func setInterfaceAddress(name string, prefix netip.Prefix) error {
+	sock, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return fmt.Errorf("control socket: %w", err)
+	}
+	defer unix.Close(sock)
+
+	mask := net.CIDRMask(prefix.Bits(), net.IPv4len)
+
+	requests := []struct {
+		name  string
+		req   uint
+		value []byte
+	}{
+		{"address", unix.SIOCSIFADDR, prefix.Addr().AsSlice()},
+		{"netmask", unix.SIOCSIFNETMASK, mask},
+	}
+
+	for _, request := range requests {
+		ifReq, err := unix.NewIfreq(name)
+		if err != nil {
+			return fmt.Errorf("interface request: %w", err)
+		}
+
+		err = ifReq.SetInet4Addr(request.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", request.name, err)
+		}
+
+		err = unix.IoctlIfreq(sock, request.req, ifReq)
+		if err != nil {
+			return fmt.Errorf("set %s: %w", request.name, err)
+		}
+	}
+
+	return nil
+}
+
+// rtentry is the route of the SIOCADDRT ioctl, see route(4).
+type rtentry struct {
+	pad1    uintptr
+	dst     unix.RawSockaddrInet4
+	gateway unix.RawSockaddrInet4
+	genmask unix.RawSockaddrInet4
+	flags   uint16
+	pad2    int16
+	pad3    uintptr
+	pad4    uintptr
+	metric  int16
+	dev     *byte
+	mtu     uintptr
+	window  uintptr
+	irtt    uint16
+}
+
+// addDefaultRoute adds the IPv4 default route via the given gateway.
+func addDefaultRoute(gateway netip.Addr) error {
+	sock, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return fmt.Errorf("control socket: %w", err)
+	}
+	defer unix.Close(sock)
+
+	route := rtentry{
+		dst: unix.RawSockaddrInet4{Family: unix.AF_INET},
+		gateway: unix.RawSockaddrInet4{
+			Family: unix.AF_INET,
+			Addr:   gateway.As4(),
+		},
+		genmask: unix.RawSockaddrInet4{Family: unix.AF_INET},
+		flags:   unix.RTF_UP | unix.RTF_GATEWAY,
+	}
+
+	_, _, errno := unix.Syscall(
+		unix.SYS_IOCTL,
+		uintptr(sock),
+		unix.SIOCADDRT,
+		uintptr(unsafe.Pointer(&route)),
+	)
+	if errno != 0 {
+		return fmt.Errorf("add default route: %w", errno)
+	}
+
+	return nil
+}
+
 func sysctl(key, value string) error {
 	const mode = 0o600
 
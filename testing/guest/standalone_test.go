@@ -15,7 +15,6 @@ import (
 func TestMain(m *testing.M) {
 	cfg := sysinit.DefaultConfig()
 	cfg.ModulesDir = "/lib/modules"
-	cfg.Env["PATH"] = "/data"
 
 	sysinit.RunTests(m, cfg)
 }
@@ -10,7 +10,9 @@ import (
 	"bufio"
 	"context"
 	"flag"
+	"io"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"runtime"
@@ -18,6 +20,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aibor/virtrun/sysinit"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -87,12 +90,75 @@ func TestLoopbackInterface(t *testing.T) {
 	assert.Equal(t, "::1/128", addrs[1].String())
 }
 
+func TestNetwork(t *testing.T) {
+	url, exists := os.LookupEnv("VIRTRUN_HOST_URL")
+	if !exists {
+		t.Skip("no host url given, network may not be attached")
+	}
+
+	iface, err := net.InterfaceByName("eth0")
+	require.NoError(t, err, "must get interface")
+
+	assert.Positive(t, iface.Flags&net.FlagUp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err, "must create request")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err, "host must be reachable via the gateway")
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err, "must read response")
+
+	assert.Equal(t, "hello from the host\n", string(body))
+}
+
 func TestEnv(t *testing.T) {
 	envPath, envPathExists := os.LookupEnv("PATH")
 
 	if assert.True(t, envPathExists, "PATH env var should be present") {
-		assert.Equal(t, "/data", envPath, "PATH env var should be correct")
+		assert.Equal(t, "/data:/bin:/usr/bin", envPath,
+			"PATH env var should be correct")
 	}
+
+	greeting, greetingExists := os.LookupEnv("VIRTRUN_GREETING")
+
+	if assert.True(t, greetingExists, "host env var should be present") {
+		assert.Equal(t, "hello world", greeting,
+			"host env var should be correct")
+	}
+}
+
+func TestStdin(t *testing.T) {
+	data, err := io.ReadAll(os.Stdin)
+	require.NoError(t, err, "stdin must be readable until EOF")
+
+	assert.Equal(t, "hello from the host\n", string(data),
+		"stdin should have the host file's content")
+}
+
+func TestWorkDir(t *testing.T) {
+	workDir, err := os.Getwd()
+	require.NoError(t, err, "working directory must be readable")
+
+	assert.Equal(t, "/root", workDir,
+		"working directory should be the configured one")
+}
+
+func TestMemory(t *testing.T) {
+	configured, err := sysinit.ConfiguredMemory()
+	require.NoError(t, err, "configured memory must be readable")
+
+	detected, err := sysinit.DetectedMemory()
+	require.NoError(t, err, "detected memory must be readable")
+
+	assert.Positive(t, detected, "detected memory should be present")
+	assert.LessOrEqual(t, detected, configured,
+		"detected memory should not exceed configured memory")
 }
 
 func TestCommonSymlinks(t *testing.T) {
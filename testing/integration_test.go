@@ -16,12 +16,20 @@ import (
 	"bytes"
 	"context"
 	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/aibor/virtrun/internal/cmd"
 	"github.com/aibor/virtrun/internal/qemu"
 	"github.com/aibor/virtrun/internal/virtrun"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -30,6 +38,7 @@ var (
 	KernelPath            = "/kernels/vmlinuz"
 	ForceTransportTypePCI bool
 	Verbose               bool
+	Network               bool
 )
 
 //nolint:gochecknoinits
@@ -51,6 +60,12 @@ func init() {
 		Verbose,
 		"show complete guest output",
 	)
+	flag.BoolVar(
+		&Network,
+		"network",
+		Network,
+		"run network tests, requires virtio-net built into the test kernel",
+	)
 }
 
 func TestIntegration(t *testing.T) {
@@ -138,6 +153,11 @@ func TestIntegration(t *testing.T) {
 		},
 	}
 
+	// Checked by the guest tests for the round trip.
+	stdinPath := filepath.Join(t.TempDir(), "stdin.txt")
+	err := os.WriteFile(stdinPath, []byte("hello from the host\n"), 0o600)
+	require.NoError(t, err)
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
@@ -153,10 +173,15 @@ func TestIntegration(t *testing.T) {
 					Memory:   128,
 					SMP:      2,
 					InitArgs: tt.args,
+					// Checked by the guest tests for the round trip.
+					Env: []string{"VIRTRUN_GREETING=hello world"},
 				},
 				Initramfs: virtrun.Initramfs{
 					Binary:         binary,
 					StandaloneInit: tt.standalone,
+					Stdin:          stdinPath,
+					// Checked by the guest tests.
+					WorkDir: "/root",
 				},
 			}
 
@@ -178,3 +203,214 @@ func TestIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestIntegration_RunWithExitCode(t *testing.T) {
+	t.Parallel()
+
+	binary, err := cmd.AbsoluteFilePath("bin/return")
+	require.NoError(t, err)
+
+	for _, exitCode := range []int{0, 1, 42} {
+		t.Run(strconv.Itoa(exitCode), func(t *testing.T) {
+			t.Parallel()
+
+			spec := &virtrun.Spec{
+				Qemu: virtrun.Qemu{
+					Kernel:   KernelPath,
+					Verbose:  Verbose,
+					CPU:      "max",
+					Memory:   128,
+					SMP:      1,
+					InitArgs: []string{strconv.Itoa(exitCode)},
+				},
+				Initramfs: virtrun.Initramfs{
+					Binary: binary,
+				},
+			}
+
+			if ForceTransportTypePCI {
+				spec.Qemu.TransportType = qemu.TransportTypePCI
+			}
+
+			ctx, cancel := context.WithTimeout(
+				context.Background(),
+				30*time.Second,
+			)
+			t.Cleanup(cancel)
+
+			var stdOut, stdErr bytes.Buffer
+
+			actual, err := virtrun.RunWithExitCode(
+				ctx,
+				spec,
+				nil,
+				&stdOut,
+				&stdErr,
+			)
+
+			t.Log(stdOut.String())
+			t.Log(stdErr.String())
+
+			require.NoError(t, err)
+			assert.Equal(t, exitCode, actual)
+		})
+	}
+}
+
+func TestIntegration_Network(t *testing.T) {
+	t.Parallel()
+
+	if !Network {
+		t.Skip("network tests not enabled")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprintln(w, "hello from the host")
+		},
+	))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	// The guest reaches the host's loopback address via the gateway.
+	guestURL := "http://" + qemu.NetworkGateway.String() + ":" +
+		serverURL.Port() + "/"
+
+	binary, err := cmd.AbsoluteFilePath("bin/guest.test")
+	require.NoError(t, err)
+
+	spec := &virtrun.Spec{
+		Qemu: virtrun.Qemu{
+			Kernel:   KernelPath,
+			Verbose:  Verbose,
+			CPU:      "max",
+			Memory:   128,
+			SMP:      1,
+			InitArgs: []string{"-test.run", "TestNetwork", "-test.v"},
+			Env:      []string{"VIRTRUN_HOST_URL=" + guestURL},
+			Network:  &qemu.Network{},
+		},
+		Initramfs: virtrun.Initramfs{
+			Binary: binary,
+		},
+	}
+
+	if ForceTransportTypePCI {
+		spec.Qemu.TransportType = qemu.TransportTypePCI
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+
+	var stdOut, stdErr bytes.Buffer
+
+	err = virtrun.Run(ctx, spec, nil, &stdOut, &stdErr)
+
+	t.Log(stdOut.String())
+	t.Log(stdErr.String())
+
+	require.NoError(t, err)
+	assert.Contains(t, stdOut.String(), "--- PASS: TestNetwork")
+}
+
+func TestIntegration_OutputFiles(t *testing.T) {
+	t.Parallel()
+
+	binary, err := cmd.AbsoluteFilePath("bin/guest.test")
+	require.NoError(t, err)
+
+	coverFile := filepath.Join(t.TempDir(), "cover.out")
+
+	spec := &virtrun.Spec{
+		Qemu: virtrun.Qemu{
+			Kernel:  KernelPath,
+			Verbose: Verbose,
+			CPU:     "max",
+			Memory:  128,
+			SMP:     1,
+			InitArgs: []string{
+				"-test.run", "TestEnv",
+				"-test.coverprofile=/tmp/cover.out",
+			},
+			Env: []string{"VIRTRUN_GREETING=hello world"},
+			// Written by the guest test itself, so it must not be rewritten
+			// to a console.
+			NoGoTestFlagRewrite: true,
+			OutputFiles: map[string]string{
+				"/tmp/cover.out": coverFile,
+			},
+		},
+		Initramfs: virtrun.Initramfs{
+			Binary: binary,
+		},
+	}
+
+	if ForceTransportTypePCI {
+		spec.Qemu.TransportType = qemu.TransportTypePCI
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+
+	var stdOut, stdErr bytes.Buffer
+
+	err = virtrun.Run(ctx, spec, nil, &stdOut, &stdErr)
+
+	t.Log(stdOut.String())
+	t.Log(stdErr.String())
+
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(coverFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "mode: atomic\n")
+	assert.Contains(t, string(content), "github.com/aibor/virtrun/sysinit/")
+}
+
+func TestIntegration_CoverProfile(t *testing.T) {
+	t.Parallel()
+
+	binary, err := cmd.AbsoluteFilePath("bin/guest.test")
+	require.NoError(t, err)
+
+	coverProfile := filepath.Join(t.TempDir(), "cover.out")
+
+	spec := &virtrun.Spec{
+		Qemu: virtrun.Qemu{
+			Kernel:       KernelPath,
+			Verbose:      Verbose,
+			CPU:          "max",
+			Memory:       128,
+			SMP:          1,
+			InitArgs:     []string{"-test.run", "TestEnv"},
+			Env:          []string{"VIRTRUN_GREETING=hello world"},
+			CoverProfile: coverProfile,
+		},
+		Initramfs: virtrun.Initramfs{
+			Binary: binary,
+		},
+	}
+
+	if ForceTransportTypePCI {
+		spec.Qemu.TransportType = qemu.TransportTypePCI
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+
+	var stdOut, stdErr bytes.Buffer
+
+	err = virtrun.Run(ctx, spec, nil, &stdOut, &stdErr)
+
+	t.Log(stdOut.String())
+	t.Log(stdErr.String())
+
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(coverProfile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "mode: atomic\n")
+	assert.Contains(t, string(content), "github.com/aibor/virtrun/sysinit/")
+}